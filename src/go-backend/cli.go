@@ -0,0 +1,636 @@
+package main
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "flag"
+    "fmt"
+    "io"
+    "log"
+    "net/http"
+    "net/url"
+    "os"
+    "runtime"
+    "runtime/debug"
+    "strings"
+    "text/tabwriter"
+    "time"
+
+    "github.com/prometheus/client_golang/prometheus"
+
+    "gateway-monitor/internal/alerts"
+    "gateway-monitor/internal/api"
+    "gateway-monitor/internal/checker"
+    "gateway-monitor/internal/clock"
+    "gateway-monitor/internal/config"
+    "gateway-monitor/internal/dashboard"
+    "gateway-monitor/internal/gitsync"
+    "gateway-monitor/internal/metrics"
+    "gateway-monitor/internal/notify"
+    "gateway-monitor/internal/otlp"
+    "gateway-monitor/internal/push"
+)
+
+// Exit codes of the check subcommand
+const (
+    exitOnline  = 0
+    exitOffline = 1
+    exitError   = 2
+)
+
+// redacted replaces secrets in the output of the config subcommand
+const redacted = "<redacted>"
+
+// subcommands run instead of the server; each returns the process exit code
+var subcommands = map[string]func(args []string) int{
+    "serve":       runServe,
+    "version":     runVersion,
+    "validate":    runValidate,
+    "check":       runCheck,
+    "config":      runConfig,
+    "alert-rules": runAlertRules,
+    "dashboard":   runDashboard,
+}
+
+// version, commit and buildDate are set at build time with
+// -ldflags "-X main.version=... -X main.commit=... -X main.buildDate=..."
+var (
+    version   = ""
+    commit    = ""
+    buildDate = ""
+)
+
+// buildVersion returns the version the binary was built as, falling back to the VCS revision
+// recorded by go build and then to "dev"
+func buildVersion() string {
+    if version != "" {
+        return version
+    }
+    if info := buildInfo(); info.Commit != "" {
+        return info.Commit
+    }
+    return "dev"
+}
+
+// buildInfo returns what the binary was built from. The commit and build date fall back to
+// the VCS revision and commit time recorded by go build, which only a build from a Git
+// checkout has.
+func buildInfo() api.BuildInfo {
+    info := api.BuildInfo{Version: version, Commit: commit, BuildDate: buildDate, GoVersion: runtime.Version()}
+    if info.Version == "" {
+        info.Version = "dev"
+    }
+    if build, ok := debug.ReadBuildInfo(); ok {
+        for _, setting := range build.Settings {
+            switch {
+            case setting.Key == "vcs.revision" && info.Commit == "":
+                info.Commit = setting.Value
+            case setting.Key == "vcs.time" && info.BuildDate == "":
+                info.BuildDate = setting.Value
+            }
+        }
+    }
+    return info
+}
+
+// runVersion implements "version"
+func runVersion(args []string) int {
+    info := buildInfo()
+    fmt.Printf("loracheck %s %s\n", info.Version, info.GoVersion)
+    if info.Commit != "" {
+        fmt.Printf("commit %s\n", info.Commit)
+    }
+    if info.BuildDate != "" {
+        fmt.Printf("built %s\n", info.BuildDate)
+    }
+    return exitOnline
+}
+
+// runValidate implements "validate <file>", listing every problem in the configuration
+func runValidate(args []string) int {
+    fs := flag.NewFlagSet("validate", flag.ExitOnError)
+    fs.Usage = func() {
+        fmt.Fprintf(fs.Output(), "Usage: %s validate <file or directory>\n", os.Args[0])
+    }
+    fs.Parse(args)
+    if fs.NArg() != 1 {
+        fs.Usage()
+        return exitError
+    }
+
+    gatewaysFile, err := config.ReadStrict(fs.Arg(0))
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "%s: %v\n", fs.Arg(0), err)
+        return exitOffline
+    }
+    problems := config.Problems(gatewaysFile)
+    for _, problem := range problems {
+        fmt.Fprintf(os.Stderr, "%s: %v\n", fs.Arg(0), problem)
+    }
+    if len(problems) > 0 {
+        return exitOffline
+    }
+
+    fmt.Printf("%s: %d gateways, configuration is valid\n", fs.Arg(0), len(gatewaysFile.Gateways))
+    return exitOnline
+}
+
+// runCheck implements "check --gateway <name>", running the gateway's checks once.
+// It exits 0 when the gateway is online, 1 when it is offline and 2 on errors.
+func runCheck(args []string) int {
+    var s settings
+    fs := flag.NewFlagSet("check", flag.ExitOnError)
+    s.addFlags(fs)
+    name := fs.String("gateway", "", "name of the gateway to check")
+    verbose := fs.Bool("v", false, "log what the checks do")
+    fs.Parse(args)
+    if *name == "" {
+        fmt.Fprintln(os.Stderr, "check requires --gateway")
+        fs.Usage()
+        return exitError
+    }
+    if err := s.loadEnv(); err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        return exitError
+    }
+
+    loader := config.NewLoader(s.ConfigLocation, &http.Client{Timeout: 30 * time.Second})
+    loader.Token = s.ConfigToken
+    gatewaysFile, err := loader.Load(context.Background())
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Failed to load gateway configuration from %s: %v\n", s.ConfigLocation, err)
+        return exitError
+    }
+    var gateway *config.Gateway
+    for i := range gatewaysFile.Gateways {
+        if gatewaysFile.Gateways[i].Name == *name {
+            gateway = &gatewaysFile.Gateways[i]
+        }
+    }
+    if gateway == nil {
+        fmt.Fprintf(os.Stderr, "Gateway %s is not configured\n", *name)
+        return exitError
+    }
+
+    logger := log.New(io.Discard, "", 0)
+    if *verbose {
+        logger = log.New(os.Stderr, "", log.LstdFlags)
+    }
+    clk := clock.Real{}
+    m := metrics.New(prometheus.NewRegistry())
+    c := checker.New(s.checkClient(), checker.NewForwarders(m, clk, logger), checker.NewSubscriptions(m, clk, logger), m, clk, logger)
+    c.MaxStatusAge = s.MaxStatusAge
+
+    table := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+    fmt.Fprintln(table, "CHECK\tTARGET\tSTATUS\tLAST SEEN\tDURATION\tERROR")
+    result := -1
+    var answers []bool
+    for _, check := range gateway.Checks {
+        start := time.Now()
+        online, err := c.FetchGatewayLinkStatus(context.Background(), *gateway, check)
+        duration := time.Since(start).Round(time.Millisecond)
+
+        status, message := checker.StatusOf(online, err), ""
+        if err != nil {
+            message = err.Error()
+        }
+        lastSeen := "-"
+        if seen, ok := c.LastSeen(*gateway, check); ok {
+            lastSeen = seen.Format(time.RFC3339)
+        }
+        fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\t%s\n", check.Type, check.Target(), status, lastSeen, duration, message)
+
+        if !errors.Is(err, checker.ErrNoStatus) {
+            answers = append(answers, online && err == nil)
+        }
+        // The first definite answer decides, as in the monitor
+        if result < 0 && !errors.Is(err, checker.ErrNoStatus) {
+            switch {
+            case err != nil:
+                result = exitError
+            case online:
+                result = exitOnline
+            default:
+                result = exitOffline
+            }
+        }
+    }
+    table.Flush()
+
+    if result < 0 {
+        return exitError
+    }
+    // Other policies combine every answer, so only the combined status is reported
+    if policy := gateway.Policy(s.StatusPolicy); policy != config.PolicyFirst {
+        if checker.Combine(policy, answers) {
+            return exitOnline
+        }
+        return exitOffline
+    }
+    return result
+}
+
+// runAlertRules implements "alert-rules", printing Prometheus alerting rules for the
+// gateways of the configuration
+func runAlertRules(args []string) int {
+    var s settings
+    fs := flag.NewFlagSet("alert-rules", flag.ExitOnError)
+    s.addFlags(fs)
+    var opts alerts.Options
+    fs.DurationVar(&opts.DownFor, "down-for", alerts.DefaultOptions.DownFor, "how long a gateway must be offline before GatewayDown fires")
+    fs.DurationVar(&opts.StaleFor, "stale-for", alerts.DefaultOptions.StaleFor, "how long a status must be stale before GatewayStatusStale fires")
+    fs.DurationVar(&opts.FailingFor, "failing-for", alerts.DefaultOptions.FailingFor, "how long every check must fail before GatewayAllChecksFailing fires")
+    fs.Parse(args)
+    if err := s.loadEnv(); err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        return exitError
+    }
+
+    loader := config.NewLoader(s.ConfigLocation, &http.Client{Timeout: 30 * time.Second})
+    loader.Token = s.ConfigToken
+    gatewaysFile, err := loader.Load(context.Background())
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Failed to load gateway configuration from %s: %v\n", s.ConfigLocation, err)
+        return exitError
+    }
+
+    body, err := alerts.Rules(gatewaysFile.Gateways, opts).Marshal()
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        return exitError
+    }
+    os.Stdout.Write(body)
+    return exitOnline
+}
+
+// runDashboard implements "dashboard", printing a Grafana dashboard for the gateways of the
+// configuration
+func runDashboard(args []string) int {
+    var s settings
+    fs := flag.NewFlagSet("dashboard", flag.ExitOnError)
+    s.addFlags(fs)
+    var opts dashboard.Options
+    fs.StringVar(&opts.Title, "title", dashboard.DefaultOptions.Title, "title of the dashboard")
+    fs.StringVar(&opts.Datasource, "datasource", dashboard.DefaultOptions.Datasource, "name of the Prometheus datasource")
+    fs.Parse(args)
+    if err := s.loadEnv(); err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        return exitError
+    }
+
+    loader := config.NewLoader(s.ConfigLocation, &http.Client{Timeout: 30 * time.Second})
+    loader.Token = s.ConfigToken
+    gatewaysFile, err := loader.Load(context.Background())
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Failed to load gateway configuration from %s: %v\n", s.ConfigLocation, err)
+        return exitError
+    }
+
+    body, err := dashboard.Fleet(gatewaysFile.Gateways, opts).Marshal()
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        return exitError
+    }
+    os.Stdout.Write(body)
+    return exitOnline
+}
+
+// effectiveConfig is printed by the config subcommand
+type effectiveConfig struct {
+    Settings map[string]interface{} `json:"settings"`
+    GitSync  *gitSyncSettings       `json:"git_sync,omitempty"`
+    Gateways *config.GatewaysFile   `json:"configuration"`
+}
+
+// gitSyncSettings shows the Git sync options without their credentials
+type gitSyncSettings struct {
+    Repo     string `json:"repo"`
+    Branch   string `json:"branch"`
+    Path     string `json:"path"`
+    Interval string `json:"interval"`
+    SSHKey   string `json:"ssh_key,omitempty"`
+    Token    string `json:"token,omitempty"`
+    Secret   string `json:"webhook_secret,omitempty"`
+}
+
+// runConfig implements "config", printing the merged configuration and the
+// settings derived from the environment with secrets redacted
+func runConfig(args []string) int {
+    var s settings
+    fs := flag.NewFlagSet("config", flag.ExitOnError)
+    s.addFlags(fs)
+    s.addServeFlags(fs)
+    fs.Parse(args)
+    if err := s.loadEnv(); err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        return exitError
+    }
+
+    loader := config.NewLoader(s.ConfigLocation, &http.Client{Timeout: 30 * time.Second})
+    loader.Token = s.ConfigToken
+    gatewaysFile, err := loader.Load(context.Background())
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Failed to load gateway configuration from %s: %v\n", s.ConfigLocation, err)
+        return exitError
+    }
+
+    out := effectiveConfig{
+        Settings: map[string]interface{}{
+            "config":                    s.ConfigLocation,
+            "config_token":              redact(s.ConfigToken),
+            "config_refresh":            s.ConfigRefresh.String(),
+            "config_watch":              s.ConfigWatch.String(),
+            "interval":                  s.Interval.String(),
+            "check_timeout":             s.CheckTimeout.String(),
+            "check_concurrency":         s.Concurrency,
+            "check_spread":              s.CheckSpread.String(),
+            "status_policy":             s.StatusPolicy,
+            "circuit_open_after":        s.CircuitOpenAfter.String(),
+            "circuit_probe_interval":    s.CircuitProbeInterval.String(),
+            "update_gap_anomaly_factor": s.UpdateGapFactor,
+            "rate_limit":                s.RateLimit,
+            "rate_limit_per_host":       s.RateLimitPerHost,
+            "rate_limit_hosts":          s.RateLimitHosts,
+            "offline_after":             s.OfflineAfter,
+            "online_after":              s.OnlineAfter,
+            "check_proxy":               proxySetting(s.CheckProxy),
+            "check_mode":                checkMode(s.ScrapeMode),
+            "scrape_max_age":            s.ScrapeMaxAge.String(),
+            "scrape_timeout":            s.ScrapeTimeout.String(),
+            "readyz_max_missed_cycles":  s.MaxMissedCycles,
+            "leader_election":           s.LeaderElection,
+            "leader_election_lease":     s.LeaderLease,
+            "leader_election_id":        s.LeaderID,
+            "leader_election_ttl":       s.LeaderTTL.String(),
+            "shutdown_timeout":          s.ShutdownTimeout.String(),
+            "listen_addr":               s.ListenAddr,
+            "grpc_listen_addr":          s.GRPCListenAddr,
+            "log_level":                 s.LogLevel,
+            "dashboards_dir":            s.DashboardsDir,
+            "tls_cert_file":             s.TLSCertFile,
+            "tls_key_file":              s.TLSKeyFile,
+            "acme_domains":              s.ACMEDomains,
+            "acme_email":                s.ACMEEmail,
+            "acme_cache_dir":            s.ACMECacheDir,
+            "acme_directory_url":        s.ACMEDirectoryURL,
+            "http_auth_username":        s.HTTPAuth.Username,
+            "http_auth_password":        redact(s.HTTPAuth.Password),
+            "http_auth_bearer_tokens":   redactAll(s.HTTPAuth.BearerTokens),
+            "heartbeat_url":             redactURL(s.HeartbeatURL),
+            "heartbeat_fail_url":        redactURL(s.HeartbeatFailURL),
+            "api_admin_token":           redact(s.AdminToken),
+            "silence_max_duration":      s.SilenceMaxDuration.String(),
+            "location_labels":           s.LocationLabels,
+            "drift_warn_after":          s.DriftWarnAfter.String(),
+            "max_status_age":            s.MaxStatusAge.String(),
+            "history_db":                s.HistoryDB,
+            "history_retention":         s.HistoryRetention.String(),
+            "smtp":                      smtpSettings(s.SMTP),
+            "push":                      pushSettings(s.Push),
+        },
+        Gateways: withDefaults(gatewaysFile),
+    }
+
+    exporter, err := otlp.NewFromEnv(nil, nil, nil)
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        return exitError
+    }
+    out.Settings["otlp"] = otlpSettings(exporter)
+
+    gitSync, err := gitsync.NewFromEnv(nil, nil, nil, nil)
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        return exitError
+    }
+    if gitSync != nil {
+        out.GitSync = &gitSyncSettings{
+            Repo:     withoutUserInfo(gitSync.Repo),
+            Branch:   gitSync.Branch,
+            Path:     gitSync.Path,
+            Interval: gitSync.Interval.String(),
+            SSHKey:   gitSync.SSHKey,
+            Token:    redact(gitSync.Token),
+            Secret:   redact(gitSync.Secret),
+        }
+    }
+
+    encoder := json.NewEncoder(os.Stdout)
+    encoder.SetIndent("", "  ")
+    encoder.SetEscapeHTML(false)
+    if err := encoder.Encode(out); err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        return exitError
+    }
+    return exitOnline
+}
+
+// withDefaults copies the configuration with the implicit check and webhook defaults
+// filled in and credentials in api keys, headers, auth, proxies, notification channels and
+// API tokens removed
+func withDefaults(gatewaysFile *config.GatewaysFile) *config.GatewaysFile {
+    out := *gatewaysFile
+    out.Gateways = make([]config.Gateway, len(gatewaysFile.Gateways))
+    for i, gateway := range gatewaysFile.Gateways {
+        gateway.Checks = append([]config.Check(nil), gateway.Checks...)
+        for j := range gateway.Checks {
+            check := &gateway.Checks[j]
+            switch check.Type {
+            case "udp-forwarder":
+                window, _ := check.ForwarderWindow()
+                check.Port, check.Window = check.ForwarderPort(), window.String()
+            case "tts", "ttn-v3", "chirpstack":
+                window, _ := check.StatusWindow()
+                check.Window = window.String()
+            }
+            // Whatever the check type, api_key holds a credential
+            check.APIKey = redact(check.APIKey)
+
+            check.Headers = redactHeaders(check.Headers)
+            if check.Auth != nil {
+                auth := *check.Auth
+                auth.Secret = redact(auth.Secret)
+                check.Auth = &auth
+            }
+            check.Proxy = withoutUserInfo(check.Proxy)
+
+            check.Steps = append([]config.Step(nil), check.Steps...)
+            for k := range check.Steps {
+                step := &check.Steps[k]
+                step.Method = step.HTTPMethod()
+                step.Headers = redactHeaders(step.Headers)
+            }
+        }
+        out.Gateways[i] = gateway
+    }
+
+    if gatewaysFile.Notifications != nil {
+        notifications := *gatewaysFile.Notifications
+        notifications.Webhooks = webhooksWithDefaults(notifications.Webhooks)
+        notifications.Chats = append([]config.ChatNotification(nil), notifications.Chats...)
+        for i := range notifications.Chats {
+            chat := &notifications.Chats[i]
+            chat.WebhookURL = redactURL(chat.WebhookURL)
+            chat.BotToken = redact(chat.BotToken)
+        }
+        out.Notifications = &notifications
+    }
+
+    out.APITokens = append([]config.APIToken(nil), gatewaysFile.APITokens...)
+    for i := range out.APITokens {
+        out.APITokens[i].Token = redact(out.APITokens[i].Token)
+    }
+
+    if gatewaysFile.Projects != nil {
+        out.Projects = make(map[string]config.Project, len(gatewaysFile.Projects))
+        for name, project := range gatewaysFile.Projects {
+            if project.Notifications != nil {
+                notifications := *project.Notifications
+                notifications.Webhooks = webhooksWithDefaults(notifications.Webhooks)
+                project.Notifications = &notifications
+            }
+            out.Projects[name] = project
+        }
+    }
+    return &out
+}
+
+// webhooksWithDefaults copies the webhooks with their retries filled in and credentials removed
+func webhooksWithDefaults(webhooks []config.WebhookNotification) []config.WebhookNotification {
+    out := append([]config.WebhookNotification(nil), webhooks...)
+    for i := range out {
+        webhook := &out[i]
+        retries := webhook.DeliveryRetries()
+        webhook.Retries = &retries
+        webhook.Secret = redact(webhook.Secret)
+        webhook.Headers = redactHeaders(webhook.Headers)
+    }
+    return out
+}
+
+// smtpSettings shows the mail server without its password
+func smtpSettings(server *notify.SMTP) interface{} {
+    if server == nil {
+        return nil
+    }
+    return map[string]interface{}{
+        "host":     server.Host,
+        "port":     server.Port,
+        "username": server.Username,
+        "password": redact(server.Password),
+        "from":     server.From,
+    }
+}
+
+// redactHeaders copies headers with the values of sensitive ones removed
+func redactHeaders(headers map[string]string) map[string]string {
+    if len(headers) == 0 {
+        return headers
+    }
+    out := map[string]string{}
+    for name, value := range headers {
+        if sensitiveHeader(name) {
+            value = redacted
+        }
+        out[name] = value
+    }
+    return out
+}
+
+// proxySetting describes the global check proxy without its credentials
+func proxySetting(proxy *url.URL) string {
+    if proxy == nil {
+        return ""
+    }
+    return withoutUserInfo(proxy.String())
+}
+
+// pushSettings describes the push target with its credentials redacted
+func pushSettings(target push.Target) interface{} {
+    if !target.Enabled() {
+        return nil
+    }
+    return map[string]interface{}{
+        "remote_write_url": withoutUserInfo(target.RemoteWriteURL),
+        "pushgateway_url":  withoutUserInfo(target.PushgatewayURL),
+        "job":              target.Job,
+        "username":         target.Username,
+        "password":         redact(target.Password),
+        "bearer_token":     redact(target.BearerToken),
+        "external_labels":  target.ExternalLabels,
+    }
+}
+
+// otlpSettings describes the OpenTelemetry exporter with its header values redacted
+func otlpSettings(exporter *otlp.Exporter) interface{} {
+    if exporter == nil {
+        return nil
+    }
+    headers := map[string]string{}
+    for name := range exporter.Headers {
+        headers[name] = redacted
+    }
+    return map[string]interface{}{
+        "metrics_endpoint": withoutUserInfo(exporter.MetricsEndpoint),
+        "traces_endpoint":  withoutUserInfo(exporter.TracesEndpoint),
+        "headers":          headers,
+        "resource":         exporter.Resource,
+        "timeout":          exporter.Timeout.String(),
+    }
+}
+
+// redactAll redacts each of the secrets
+func redactAll(secrets []string) []string {
+    redactedAll := make([]string, len(secrets))
+    for i, secret := range secrets {
+        redactedAll[i] = redact(secret)
+    }
+    return redactedAll
+}
+
+// checkMode names the CHECK_MODE the settings were read from
+func checkMode(scrape bool) string {
+    if scrape {
+        return "scrape"
+    }
+    return "loop"
+}
+
+// sensitiveHeader reports whether a header usually carries credentials
+func sensitiveHeader(name string) bool {
+    name = strings.ToLower(name)
+    return name == "authorization" || name == "cookie" || strings.Contains(name, "token") || strings.Contains(name, "key")
+}
+
+func redact(secret string) string {
+    if secret == "" {
+        return ""
+    }
+    return redacted
+}
+
+// redactURL keeps the scheme and host of a URL, which may carry credentials in its user info or path
+func redactURL(raw string) string {
+    if raw == "" {
+        return ""
+    }
+    u, err := url.Parse(raw)
+    if err != nil || u.Host == "" {
+        return redacted
+    }
+    if u.User == nil && (u.Path == "" || u.Path == "/") {
+        return raw
+    }
+    return u.Scheme + "://" + u.Host + "/" + redacted
+}
+
+// withoutUserInfo drops credentials embedded in a repository URL
+func withoutUserInfo(raw string) string {
+    u, err := url.Parse(raw)
+    if err != nil || u.User == nil {
+        return raw
+    }
+    u.User = nil
+    return u.String()
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+    "testing"
+
+    "gateway-monitor/internal/config"
+)
+
+func TestWithDefaultsRedactsAPIKeys(t *testing.T) {
+    tests := []struct {
+        name  string
+        check config.Check
+    }{
+        {name: "tts", check: config.Check{Type: "tts", GatewayID: "gw1", APIKey: "NNSXS.secret"}},
+        {name: "mqtt password", check: config.Check{Type: "mqtt", URL: "mqtts://broker.example.com:8883", APIKey: "broker-password"}},
+        {name: "snmp v2c community", check: config.Check{Type: "snmp", Host: "10.0.0.1", OID: "1.3.6.1.2.1.1.3.0", APIKey: "private"}},
+        {name: "basics station token", check: config.Check{Type: "basics-station", URL: "https://station.example.com", EUI: "0102030405060708", APIKey: "station-token"}},
+        {name: "snmp v3 passphrase", check: config.Check{Type: "snmp", Host: "10.0.0.1", OID: "1.3.6.1.2.1.1.3.0", SNMPVersion: "3", Username: "monitor", AuthProtocol: "SHA", APIKey: "auth-passphrase"}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            gatewaysFile := &config.GatewaysFile{Gateways: []config.Gateway{{Name: "gw1", Checks: []config.Check{tt.check}}}}
+            out := withDefaults(gatewaysFile)
+            if got := out.Gateways[0].Checks[0].APIKey; got != redacted {
+                t.Errorf("api_key = %q, want it redacted", got)
+            }
+            if gatewaysFile.Gateways[0].Checks[0].APIKey != tt.check.APIKey {
+                t.Error("the running configuration was redacted too")
+            }
+        })
+    }
+}
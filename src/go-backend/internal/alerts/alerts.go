@@ -0,0 +1,150 @@
+// Package alerts generates Prometheus alerting rules for the configured gateways.
+package alerts
+
+import (
+    "bytes"
+    "fmt"
+    "sort"
+    "strconv"
+    "time"
+
+    "github.com/prometheus/common/model"
+    "gopkg.in/yaml.v3"
+
+    "gateway-monitor/internal/config"
+)
+
+// Options sets how long each condition must hold before its alert fires
+type Options struct {
+    DownFor    time.Duration
+    StaleFor   time.Duration
+    FailingFor time.Duration
+}
+
+// DefaultOptions are used for the durations left at zero
+var DefaultOptions = Options{
+    DownFor:    5 * time.Minute,
+    StaleFor:   15 * time.Minute,
+    FailingFor: 5 * time.Minute,
+}
+
+// RuleFile is a Prometheus rule file
+type RuleFile struct {
+    Groups []Group `yaml:"groups"`
+}
+
+// Group holds the rules of one project
+type Group struct {
+    Name  string `yaml:"name"`
+    Rules []Rule `yaml:"rules"`
+}
+
+// Rule is an alerting rule
+type Rule struct {
+    Alert       string            `yaml:"alert"`
+    Expr        string            `yaml:"expr"`
+    For         string            `yaml:"for"`
+    Labels      map[string]string `yaml:"labels"`
+    Annotations map[string]string `yaml:"annotations"`
+}
+
+// Rules creates a group per project with three rules per gateway: it is reported offline
+// outside maintenance, one of its checks reports a stale status and every check fails
+func Rules(gateways []config.Gateway, opts Options) RuleFile {
+    opts = opts.withDefaults()
+
+    groups := map[string]*Group{}
+    var names []string
+    for _, gateway := range gateways {
+        name := "loracheck"
+        if gateway.Project != "" {
+            name = "loracheck-" + gateway.Project
+        }
+        group, ok := groups[name]
+        if !ok {
+            group = &Group{Name: name}
+            groups[name] = group
+            names = append(names, name)
+        }
+        group.Rules = append(group.Rules, gatewayRules(gateway, opts)...)
+    }
+
+    sort.Strings(names)
+    rules := RuleFile{Groups: []Group{}}
+    for _, name := range names {
+        rules.Groups = append(rules.Groups, *groups[name])
+    }
+    return rules
+}
+
+// Marshal encodes the rule file as YAML
+func (r RuleFile) Marshal() ([]byte, error) {
+    var buf bytes.Buffer
+    encoder := yaml.NewEncoder(&buf)
+    encoder.SetIndent(2)
+    if err := encoder.Encode(r); err != nil {
+        return nil, err
+    }
+    return buf.Bytes(), encoder.Close()
+}
+
+func gatewayRules(gateway config.Gateway, opts Options) []Rule {
+    name := strconv.Quote(gateway.Name)
+    labels := func(severity string) map[string]string {
+        labels := map[string]string{"gateway": gateway.Name, "severity": severity}
+        if gateway.Project != "" {
+            labels["project"] = gateway.Project
+        }
+        if gateway.Owner != "" {
+            labels["owner"] = gateway.Owner
+        }
+        return labels
+    }
+
+    return []Rule{
+        {
+            Alert: "GatewayDown",
+            Expr: fmt.Sprintf("max(gateway_online_status{name=%s}) == 0 unless max(gateway_in_maintenance{name=%s}) == 1",
+                name, name),
+            For:    model.Duration(opts.DownFor).String(),
+            Labels: labels("critical"),
+            Annotations: map[string]string{
+                "summary":     fmt.Sprintf("Gateway %s is offline", gateway.Name),
+                "description": fmt.Sprintf("Gateway %s has been reported offline for more than %s.", gateway.Name, model.Duration(opts.DownFor)),
+            },
+        },
+        {
+            Alert:  "GatewayStatusStale",
+            Expr:   fmt.Sprintf("max(gateway_status_stale{gateway_name=%s}) == 1", name),
+            For:    model.Duration(opts.StaleFor).String(),
+            Labels: labels("warning"),
+            Annotations: map[string]string{
+                "summary":     fmt.Sprintf("Status of gateway %s is stale", gateway.Name),
+                "description": fmt.Sprintf("The status reported for gateway %s has not been updated within its max_age.", gateway.Name),
+            },
+        },
+        {
+            Alert:  "GatewayAllChecksFailing",
+            Expr:   fmt.Sprintf("max(gateway_link_status{gateway_name=%s}) == 0", name),
+            For:    model.Duration(opts.FailingFor).String(),
+            Labels: labels("critical"),
+            Annotations: map[string]string{
+                "summary":     fmt.Sprintf("Every check of gateway %s is failing", gateway.Name),
+                "description": fmt.Sprintf("None of the checks of gateway %s has succeeded for more than %s.", gateway.Name, model.Duration(opts.FailingFor)),
+            },
+        },
+    }
+}
+
+func (o Options) withDefaults() Options {
+    if o.DownFor <= 0 {
+        o.DownFor = DefaultOptions.DownFor
+    }
+    if o.StaleFor <= 0 {
+        o.StaleFor = DefaultOptions.StaleFor
+    }
+    if o.FailingFor <= 0 {
+        o.FailingFor = DefaultOptions.FailingFor
+    }
+    return o
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+    "net/http"
+    "time"
+
+    "gateway-monitor/internal/alerts"
+)
+
+// handleAlertRules serves GET /api/v1/alert-rules, Prometheus alerting rules for the
+// monitored gateways. The down_for, stale_for and failing_for parameters override the
+// default durations.
+func (s *Server) handleAlertRules(w http.ResponseWriter, r *http.Request) {
+    var opts alerts.Options
+    for param, d := range map[string]*time.Duration{
+        "down_for":    &opts.DownFor,
+        "stale_for":   &opts.StaleFor,
+        "failing_for": &opts.FailingFor,
+    } {
+        if value := r.URL.Query().Get(param); value != "" {
+            var err error
+            if *d, err = time.ParseDuration(value); err != nil || *d <= 0 {
+                writeError(w, http.StatusBadRequest, "invalid "+param)
+                return
+            }
+        }
+    }
+
+    body, err := alerts.Rules(s.monitor.Gateways(), opts).Marshal()
+    if err != nil {
+        writeError(w, http.StatusInternalServerError, err.Error())
+        return
+    }
+    w.Header().Set("Content-Type", "application/yaml")
+    w.Write(body)
+}
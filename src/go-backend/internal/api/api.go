@@ -0,0 +1,185 @@
+// Package api serves the JSON HTTP API under /api/v1.
+package api
+
+import (
+    "encoding/json"
+    "io"
+    "log"
+    "net/http"
+    "sync/atomic"
+    "time"
+
+    "gateway-monitor/internal/config"
+    "gateway-monitor/internal/gitsync"
+    "gateway-monitor/internal/history"
+    "gateway-monitor/internal/monitor"
+)
+
+// Server implements the API handlers
+type Server struct {
+    monitor   *monitor.Monitor
+    gitSync   *gitsync.GitSync
+    logger    *log.Logger
+    startedAt time.Time
+
+    // AdminToken is the bootstrap bearer token with the admin role; without it and without
+    // admin API tokens the endpoints that change the gateways are disabled
+    AdminToken string
+    // MaxSilence is the longest silence handleCreateSilence accepts; zero leaves it unbounded
+    MaxSilence time.Duration
+    // History serves the reports; they are unavailable while it is nil
+    History *history.Store
+    // Auth protects the endpoints when set; see Protect
+    Auth Auth
+    // Build is served by GET /version
+    Build BuildInfo
+
+    shuttingDown atomic.Bool
+}
+
+// New creates the API server; gitSync may be nil when Git sync is disabled
+func New(mon *monitor.Monitor, gitSync *gitsync.GitSync, logger *log.Logger, startedAt time.Time) *Server {
+    return &Server{
+        monitor:   mon,
+        gitSync:   gitSync,
+        logger:    logger,
+        startedAt: startedAt,
+    }
+}
+
+// Register adds the API routes to mux. Each route requires a role: viewers read, operators
+// also silence gateways and save the running configuration, admins also change the gateways.
+// See Access.Authorize for the rules until the configuration defines API tokens. Only the
+// leader accepts changes when leader election is enabled.
+func (s *Server) Register(mux *http.ServeMux) {
+    viewer := func(next http.HandlerFunc) http.HandlerFunc { return s.require(config.RoleViewer, next) }
+    operator := func(next http.HandlerFunc) http.HandlerFunc { return s.require(config.RoleOperator, s.leading(next)) }
+    admin := func(next http.HandlerFunc) http.HandlerFunc { return s.require(config.RoleAdmin, s.leading(next)) }
+
+    mux.HandleFunc("GET /{$}", viewer(s.handleStatusPage))
+    mux.HandleFunc("GET /healthz", s.handleHealthz)
+    mux.HandleFunc("GET /readyz", s.handleReadyz)
+    mux.HandleFunc("GET /version", viewer(s.handleVersion))
+    mux.HandleFunc("GET /api/v1/info", viewer(s.handleInfo))
+    mux.HandleFunc("GET /api/v1/alert-rules", viewer(s.handleAlertRules))
+    mux.HandleFunc("GET /api/v1/dashboard", viewer(s.handleDashboard))
+    mux.HandleFunc("GET /api/v1/status", viewer(s.handleStatus))
+    mux.HandleFunc("GET /api/v1/status/{name}", viewer(s.handleGatewayStatus))
+    mux.HandleFunc("GET /api/v1/events", viewer(s.handleEvents))
+    mux.HandleFunc("GET /api/v1/gateways", viewer(s.handleListGateways))
+    mux.HandleFunc("GET /api/v1/gateways/{name}", viewer(s.handleGetGateway))
+    mux.HandleFunc("POST /api/v1/gateways", admin(s.handleCreateGateway))
+    mux.HandleFunc("PUT /api/v1/gateways/{name}", admin(s.handleUpdateGateway))
+    mux.HandleFunc("DELETE /api/v1/gateways/{name}", admin(s.handleDeleteGateway))
+    mux.HandleFunc("GET /api/v1/reports/uptime", viewer(s.handleUptimeReport))
+    mux.HandleFunc("GET /api/v1/history", viewer(s.handleHistory))
+    mux.HandleFunc("GET /api/v1/silences", viewer(s.handleListSilences))
+    mux.HandleFunc("GET /api/v1/gateways/{name}/silence", viewer(s.handleListSilences))
+    mux.HandleFunc("POST /api/v1/gateways/{name}/silence", operator(s.handleCreateSilence))
+    mux.HandleFunc("GET /api/v1/config/drift", viewer(s.handleConfigDrift))
+    mux.HandleFunc("POST /api/v1/config/save", operator(s.handleConfigSave))
+    if s.gitSync != nil {
+        mux.HandleFunc("POST /api/v1/config/sync", s.handleConfigSync)
+    }
+}
+
+// InfoResponse is returned by GET /api/v1/info
+type InfoResponse struct {
+    StartedAt     time.Time `json:"started_at"`
+    ConfigSource  string    `json:"config_source"`
+    ConfigCommit  string    `json:"config_commit,omitempty"`
+    GatewaysCount int       `json:"gateways"`
+}
+
+// handleInfo reports which configuration the backend is running with
+func (s *Server) handleInfo(w http.ResponseWriter, r *http.Request) {
+    gatewaysFile, source := s.monitor.Current()
+    writeJSON(w, http.StatusOK, InfoResponse{
+        StartedAt:     s.startedAt,
+        ConfigSource:  source.Kind,
+        ConfigCommit:  source.Commit,
+        GatewaysCount: len(gatewaysFile.Gateways),
+    })
+}
+
+// BuildInfo describes the release the backend runs, as returned by GET /version
+type BuildInfo struct {
+    Version   string `json:"version"`
+    Commit    string `json:"commit,omitempty"`
+    BuildDate string `json:"build_date,omitempty"`
+    GoVersion string `json:"go_version"`
+}
+
+// handleVersion reports which release the backend runs
+func (s *Server) handleVersion(w http.ResponseWriter, r *http.Request) {
+    writeJSON(w, http.StatusOK, s.Build)
+}
+
+// ReadyzResponse is returned by GET /readyz
+type ReadyzResponse struct {
+    monitor.Health
+    ShuttingDown bool `json:"shutting_down,omitempty"`
+}
+
+// MarkShuttingDown makes /readyz fail so no new traffic is sent while the process shuts down
+func (s *Server) MarkShuttingDown() {
+    s.shuttingDown.Store(true)
+}
+
+// handleHealthz is the liveness probe: it succeeds as long as the process serves HTTP
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+    writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// handleReadyz succeeds once the first cycle has completed and fails once the check loop
+// has stopped completing cycles or the process is shutting down
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+    resp := ReadyzResponse{Health: s.monitor.Health(), ShuttingDown: s.shuttingDown.Load()}
+    status := http.StatusOK
+    if !resp.Ready || resp.ShuttingDown {
+        status = http.StatusServiceUnavailable
+    }
+    writeJSON(w, status, resp)
+}
+
+// handleConfigSync serves POST /api/v1/config/sync for CI webhooks
+func (s *Server) handleConfigSync(w http.ResponseWriter, r *http.Request) {
+    if !s.gitSync.WebhookEnabled() {
+        writeError(w, http.StatusForbidden, "webhook secret not configured")
+        return
+    }
+
+    body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "failed to read request body")
+        return
+    }
+    if !s.gitSync.Authorized(r, body) {
+        writeError(w, http.StatusUnauthorized, "invalid webhook secret")
+        return
+    }
+
+    commit, err := s.gitSync.Sync()
+    if err != nil {
+        s.logger.Printf("Git sync triggered by webhook failed: %v", err)
+        writeError(w, http.StatusInternalServerError, err.Error())
+        return
+    }
+
+    _, applied := s.monitor.Current()
+    writeJSON(w, http.StatusOK, map[string]string{"commit": commit, "applied_commit": applied.Commit})
+}
+
+// writeJSON encodes a response body as JSON
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    if err := json.NewEncoder(w).Encode(body); err != nil {
+        log.Printf("Failed to write response: %v", err)
+    }
+}
+
+// writeError sends an error message as a JSON object
+func writeError(w http.ResponseWriter, status int, message string) {
+    writeJSON(w, status, map[string]string{"error": message})
+}
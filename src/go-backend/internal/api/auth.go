@@ -0,0 +1,137 @@
+package api
+
+import (
+    "crypto/subtle"
+    "errors"
+    "fmt"
+    "net/http"
+    "strings"
+
+    "gateway-monitor/internal/config"
+)
+
+// Auth are the credentials required by the HTTP server when it is exposed beyond the local network
+type Auth struct {
+    // Username and Password enable basic auth
+    Username string
+    Password string
+    // BearerTokens are accepted in an Authorization: Bearer header
+    BearerTokens []string
+}
+
+// Enabled reports whether any credentials are configured
+func (a Auth) Enabled() bool {
+    return a.Username != "" || len(a.BearerTokens) > 0
+}
+
+// unauthenticated are the paths that stay open: the probes, which orchestrators call without
+// credentials, and the Git sync webhook, which checks its own signature
+var unauthenticated = map[string]bool{
+    "/healthz":            true,
+    "/readyz":             true,
+    "/api/v1/config/sync": true,
+}
+
+// Protect requires the Auth credentials on every request except the unauthenticated paths.
+// The admin token and the API tokens are accepted too, so the endpoints they grant access
+// to keep working.
+func (s *Server) Protect(next http.Handler) http.Handler {
+    if !s.Auth.Enabled() {
+        return next
+    }
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if _, role := s.access().Caller(r.Header); unauthenticated[r.URL.Path] || role != "" {
+            next.ServeHTTP(w, r)
+            return
+        }
+        if s.Auth.Username != "" {
+            w.Header().Set("WWW-Authenticate", `Basic realm="LoRaCheck", charset="UTF-8"`)
+        } else {
+            w.Header().Set("WWW-Authenticate", `Bearer realm="LoRaCheck"`)
+        }
+        writeError(w, http.StatusUnauthorized, "authentication required")
+    })
+}
+
+// Authorized reports whether the Authorization header carries valid basic auth credentials
+// or a valid bearer token; adminToken, when set, is accepted as a bearer token too
+func (a Auth) Authorized(header http.Header, adminToken string) bool {
+    if username, password, ok := (&http.Request{Header: header}).BasicAuth(); ok && a.Username != "" {
+        return equal(username, a.Username) && equal(password, a.Password)
+    }
+    token, ok := strings.CutPrefix(header.Get("Authorization"), "Bearer ")
+    if !ok {
+        return false
+    }
+    valid := false
+    for _, candidate := range a.BearerTokens {
+        valid = equal(token, candidate) || valid
+    }
+    return valid || (adminToken != "" && equal(token, adminToken))
+}
+
+// ErrUnauthenticated and ErrForbidden are the reasons Access.Authorize turns a caller away:
+// credentials that are missing or unknown, or a role that doesn't allow the endpoint
+var (
+    ErrUnauthenticated = errors.New("authentication required")
+    ErrForbidden       = errors.New("permission denied")
+)
+
+// Access resolves the credentials of a request to a role on the management API, for the HTTP
+// and gRPC servers alike
+type Access struct {
+    // AdminToken is the bootstrap token, which always has the admin role
+    AdminToken string
+    // Auth are the credentials of the server, which have the viewer role
+    Auth Auth
+    // Tokens are the API tokens of the running configuration
+    Tokens []config.APIToken
+}
+
+// Caller returns the name and role of the credentials in the Authorization header, or empty
+// strings when they are missing or unknown
+func (a Access) Caller(header http.Header) (string, string) {
+    if token, ok := strings.CutPrefix(header.Get("Authorization"), "Bearer "); ok {
+        if a.AdminToken != "" && equal(token, a.AdminToken) {
+            return "the admin token", config.RoleAdmin
+        }
+        for _, candidate := range a.Tokens {
+            if equal(token, candidate.Token) {
+                return "api token " + candidate.Name, candidate.Role
+            }
+        }
+    }
+    if a.Auth.Enabled() && a.Auth.Authorized(header, "") {
+        return "the server credentials", config.RoleViewer
+    }
+    return "", ""
+}
+
+// Authorize checks that the caller may use an endpoint requiring role. Until the configuration
+// defines API tokens the viewer endpoints are open and the others require the admin token;
+// without one the admin endpoints are disabled and the operator endpoints stay open.
+func (a Access) Authorize(header http.Header, role string) error {
+    if len(a.Tokens) == 0 {
+        switch {
+        case role == config.RoleViewer:
+            return nil
+        case a.AdminToken == "" && role == config.RoleAdmin:
+            return fmt.Errorf("%w: admin token not configured", ErrForbidden)
+        case a.AdminToken == "":
+            return nil
+        }
+    }
+    name, granted := a.Caller(header)
+    if granted == "" {
+        return ErrUnauthenticated
+    }
+    if !config.RoleAllows(granted, role) {
+        return fmt.Errorf("%w: %s has the %s role, the %s role is required", ErrForbidden, name, granted, role)
+    }
+    return nil
+}
+
+// equal compares credentials in constant time
+func equal(a, b string) bool {
+    return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
@@ -0,0 +1,189 @@
+package api
+
+import (
+    "errors"
+    "io"
+    "log"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/prometheus/client_golang/prometheus"
+
+    "gateway-monitor/internal/checker"
+    "gateway-monitor/internal/clock"
+    "gateway-monitor/internal/config"
+    "gateway-monitor/internal/metrics"
+    "gateway-monitor/internal/monitor"
+)
+
+var testTokens = []config.APIToken{
+    {Name: "dashboards", Token: "viewer-token-0123456", Role: config.RoleViewer},
+    {Name: "noc", Token: "operator-token-0123456", Role: config.RoleOperator},
+    {Name: "ops", Token: "admin-api-token-0123456", Role: config.RoleAdmin},
+}
+
+func bearer(token string) http.Header {
+    header := http.Header{}
+    if token != "" {
+        header.Set("Authorization", "Bearer "+token)
+    }
+    return header
+}
+
+func TestAuthorize(t *testing.T) {
+    basic := http.Header{}
+    basic.Set("Authorization", "Basic "+"dXNlcjpwYXNz") // user:pass
+    server := Auth{Username: "user", Password: "pass"}
+
+    tests := []struct {
+        name   string
+        access Access
+        header http.Header
+        // allowed lists the roles the caller gets through, the others fail with the error
+        allowed []string
+        err     error
+    }{
+        {name: "nothing configured, anonymous", access: Access{}, header: bearer(""),
+            allowed: []string{config.RoleViewer, config.RoleOperator}, err: ErrForbidden},
+        {name: "admin token only, anonymous", access: Access{AdminToken: "admin-token"}, header: bearer(""),
+            allowed: []string{config.RoleViewer}, err: ErrUnauthenticated},
+        {name: "admin token only, wrong token", access: Access{AdminToken: "admin-token"}, header: bearer("guess"),
+            allowed: []string{config.RoleViewer}, err: ErrUnauthenticated},
+        {name: "admin token only, server credentials", access: Access{AdminToken: "admin-token", Auth: server}, header: basic,
+            allowed: []string{config.RoleViewer}, err: ErrForbidden},
+        {name: "admin token only, admin token", access: Access{AdminToken: "admin-token"}, header: bearer("admin-token"),
+            allowed: []string{config.RoleViewer, config.RoleOperator, config.RoleAdmin}},
+        {name: "tokens, anonymous", access: Access{Tokens: testTokens}, header: bearer(""),
+            err: ErrUnauthenticated},
+        {name: "tokens, server credentials", access: Access{Tokens: testTokens, Auth: server}, header: basic,
+            allowed: []string{config.RoleViewer}, err: ErrForbidden},
+        {name: "tokens, viewer", access: Access{Tokens: testTokens}, header: bearer("viewer-token-0123456"),
+            allowed: []string{config.RoleViewer}, err: ErrForbidden},
+        {name: "tokens, operator", access: Access{Tokens: testTokens}, header: bearer("operator-token-0123456"),
+            allowed: []string{config.RoleViewer, config.RoleOperator}, err: ErrForbidden},
+        {name: "tokens, admin", access: Access{Tokens: testTokens}, header: bearer("admin-api-token-0123456"),
+            allowed: []string{config.RoleViewer, config.RoleOperator, config.RoleAdmin}},
+        {name: "tokens, bootstrap admin token", access: Access{Tokens: testTokens, AdminToken: "admin-token"}, header: bearer("admin-token"),
+            allowed: []string{config.RoleViewer, config.RoleOperator, config.RoleAdmin}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            for _, role := range []string{config.RoleViewer, config.RoleOperator, config.RoleAdmin} {
+                allowed := false
+                for _, r := range tt.allowed {
+                    allowed = allowed || r == role
+                }
+                err := tt.access.Authorize(tt.header, role)
+                if allowed && err != nil {
+                    t.Errorf("%s endpoint refused: %v", role, err)
+                } else if !allowed && !errors.Is(err, tt.err) {
+                    t.Errorf("%s endpoint returned %v, want %v", role, err, tt.err)
+                }
+            }
+        })
+    }
+}
+
+// newTestServer serves the API of a monitor of gw1 on a fake clock; opts may adjust the
+// server before its routes are registered
+func newTestServer(t *testing.T, adminToken string, tokens []config.APIToken, opts ...func(*Server)) *httptest.Server {
+    t.Helper()
+    return serveAPI(t, newTestMonitor(t, tokens, nil), adminToken, opts...)
+}
+
+// newTestMonitor returns a monitor of gw1 on a fake clock, elected by leader when it is set
+func newTestMonitor(t *testing.T, tokens []config.APIToken, leader monitor.Leader) *monitor.Monitor {
+    t.Helper()
+    clk := clock.NewFake(time.Date(2026, 1, 5, 12, 0, 0, 0, time.UTC))
+    logger := log.New(io.Discard, "", 0)
+    m := metrics.New(prometheus.NewRegistry())
+    forwarders := checker.NewForwarders(m, clk, logger)
+    mon := monitor.New(monitor.Options{
+        Checker:    checker.New(&http.Client{Timeout: time.Second}, forwarders, checker.NewSubscriptions(m, clk, logger), m, clk, logger),
+        Forwarders: forwarders,
+        Metrics:    m,
+        Clock:      clk,
+        Logger:     logger,
+        Interval:   time.Minute,
+        Leader:     leader,
+    })
+    gatewaysFile := &config.GatewaysFile{
+        Gateways:  []config.Gateway{{Name: "gw1", Checks: []config.Check{{Type: "http", URL: "http://127.0.0.1:1/status"}}}},
+        APITokens: tokens,
+    }
+    if err := mon.Apply(gatewaysFile, config.Source{Kind: "file"}); err != nil {
+        t.Fatal(err)
+    }
+    return mon
+}
+
+// serveAPI serves the API of mon
+func serveAPI(t *testing.T, mon *monitor.Monitor, adminToken string, opts ...func(*Server)) *httptest.Server {
+    t.Helper()
+    server := New(mon, nil, log.New(io.Discard, "", 0), time.Date(2026, 1, 5, 12, 0, 0, 0, time.UTC))
+    server.AdminToken = adminToken
+    for _, opt := range opts {
+        opt(server)
+    }
+    mux := http.NewServeMux()
+    server.Register(mux)
+    ts := httptest.NewServer(server.Protect(mux))
+    t.Cleanup(ts.Close)
+    return ts
+}
+
+func request(t *testing.T, ts *httptest.Server, method, path, token, body string) int {
+    t.Helper()
+    req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+    if err != nil {
+        t.Fatal(err)
+    }
+    req.Header = bearer(token)
+    resp, err := ts.Client().Do(req)
+    if err != nil {
+        t.Fatal(err)
+    }
+    resp.Body.Close()
+    return resp.StatusCode
+}
+
+func TestEndpointRoles(t *testing.T) {
+    silence := `{"duration": "1h", "comment": "maintenance"}`
+    tests := []struct {
+        name       string
+        adminToken string
+        tokens     []config.APIToken
+        token      string
+        // statuses of reading the status, creating a silence and deleting a gateway
+        status, silence, delete int
+    }{
+        {name: "admin token only, anonymous", adminToken: "admin-token",
+            status: http.StatusOK, silence: http.StatusUnauthorized, delete: http.StatusUnauthorized},
+        {name: "admin token only, admin", adminToken: "admin-token", token: "admin-token",
+            status: http.StatusOK, silence: http.StatusCreated, delete: http.StatusNotFound},
+        {name: "viewer", tokens: testTokens, token: "viewer-token-0123456",
+            status: http.StatusOK, silence: http.StatusForbidden, delete: http.StatusForbidden},
+        {name: "operator", tokens: testTokens, token: "operator-token-0123456",
+            status: http.StatusOK, silence: http.StatusCreated, delete: http.StatusForbidden},
+        {name: "admin", tokens: testTokens, token: "admin-api-token-0123456",
+            status: http.StatusOK, silence: http.StatusCreated, delete: http.StatusNotFound},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            ts := newTestServer(t, tt.adminToken, tt.tokens)
+            if got := request(t, ts, http.MethodGet, "/api/v1/status", tt.token, ""); got != tt.status {
+                t.Errorf("GET /api/v1/status = %d, want %d", got, tt.status)
+            }
+            if got := request(t, ts, http.MethodPost, "/api/v1/gateways/gw1/silence", tt.token, silence); got != tt.silence {
+                t.Errorf("POST /api/v1/gateways/gw1/silence = %d, want %d", got, tt.silence)
+            }
+            // Deleting an unknown gateway gets past the role check without changing anything
+            if got := request(t, ts, http.MethodDelete, "/api/v1/gateways/gw2", tt.token, ""); got != tt.delete {
+                t.Errorf("DELETE /api/v1/gateways/gw2 = %d, want %d", got, tt.delete)
+            }
+        })
+    }
+}
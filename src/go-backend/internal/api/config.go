@@ -0,0 +1,19 @@
+package api
+
+import (
+    "net/http"
+)
+
+// handleConfigDrift serves GET /api/v1/config/drift
+func (s *Server) handleConfigDrift(w http.ResponseWriter, r *http.Request) {
+    writeJSON(w, http.StatusOK, s.monitor.UpdateDrift())
+}
+
+// handleConfigSave serves POST /api/v1/config/save, writing the running configuration to disk
+func (s *Server) handleConfigSave(w http.ResponseWriter, r *http.Request) {
+    if err := s.monitor.Save(); err != nil {
+        writeError(w, http.StatusConflict, err.Error())
+        return
+    }
+    writeJSON(w, http.StatusOK, s.monitor.UpdateDrift())
+}
@@ -0,0 +1,20 @@
+package api
+
+import (
+    "net/http"
+
+    "gateway-monitor/internal/dashboard"
+)
+
+// handleDashboard serves GET /api/v1/dashboard, a Grafana dashboard of the monitored gateways
+// ready to import. The title and datasource parameters override the defaults.
+func (s *Server) handleDashboard(w http.ResponseWriter, r *http.Request) {
+    opts := dashboard.Options{Title: r.URL.Query().Get("title"), Datasource: r.URL.Query().Get("datasource")}
+    body, err := dashboard.Fleet(s.monitor.Gateways(), opts).Marshal()
+    if err != nil {
+        writeError(w, http.StatusInternalServerError, err.Error())
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    w.Write(body)
+}
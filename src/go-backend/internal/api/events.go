@@ -0,0 +1,65 @@
+package api
+
+import (
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "time"
+)
+
+// keepAliveInterval is how often an idle event stream sends a comment so proxies keep it open
+const keepAliveInterval = 30 * time.Second
+
+// handleEvents serves GET /api/v1/events as a Server-Sent Events stream. It starts with a
+// "status" event holding the state of every gateway, followed by a "transition" event
+// whenever a gateway changes state.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+    flusher, ok := w.(http.Flusher)
+    if !ok {
+        writeError(w, http.StatusInternalServerError, "streaming not supported")
+        return
+    }
+
+    events, unsubscribe := s.monitor.Events().Subscribe()
+    defer unsubscribe()
+
+    w.Header().Set("Content-Type", "text/event-stream")
+    w.Header().Set("Cache-Control", "no-cache")
+    w.Header().Set("X-Accel-Buffering", "no")
+    w.WriteHeader(http.StatusOK)
+    if err := writeEvent(w, "status", s.monitor.Status()); err != nil {
+        return
+    }
+    flusher.Flush()
+
+    keepAlive := time.NewTicker(keepAliveInterval)
+    defer keepAlive.Stop()
+    for {
+        select {
+        case <-r.Context().Done():
+            return
+        case transition, ok := <-events:
+            if !ok {
+                return
+            }
+            if err := writeEvent(w, "transition", transition); err != nil {
+                return
+            }
+        case <-keepAlive.C:
+            if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+                return
+            }
+        }
+        flusher.Flush()
+    }
+}
+
+// writeEvent writes one named event with a JSON payload
+func writeEvent(w http.ResponseWriter, name string, payload interface{}) error {
+    data, err := json.Marshal(payload)
+    if err != nil {
+        return err
+    }
+    _, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, data)
+    return err
+}
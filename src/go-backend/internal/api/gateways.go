@@ -0,0 +1,40 @@
+package api
+
+import (
+    "net/http"
+
+    "gateway-monitor/internal/config"
+)
+
+// GatewayResponse describes a monitored gateway
+type GatewayResponse struct {
+    Name      string   `json:"name"`
+    Origin    string   `json:"origin"`
+    Latitude  float64  `json:"latitude"`
+    Longitude float64  `json:"longitude"`
+    Checks    []string `json:"checks"`
+}
+
+// handleListGateways serves GET /api/v1/gateways
+func (s *Server) handleListGateways(w http.ResponseWriter, r *http.Request) {
+    gateways := s.monitor.Gateways()
+    response := make([]GatewayResponse, 0, len(gateways))
+    for _, gateway := range gateways {
+        response = append(response, newGatewayResponse(gateway))
+    }
+    writeJSON(w, http.StatusOK, response)
+}
+
+func newGatewayResponse(gateway config.Gateway) GatewayResponse {
+    checks := make([]string, 0, len(gateway.Checks))
+    for _, check := range gateway.Checks {
+        checks = append(checks, check.Type)
+    }
+    return GatewayResponse{
+        Name:      gateway.Name,
+        Origin:    gateway.GatewayOrigin(),
+        Latitude:  gateway.Location.Latitude,
+        Longitude: gateway.Location.Longitude,
+        Checks:    checks,
+    }
+}
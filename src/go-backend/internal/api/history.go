@@ -0,0 +1,106 @@
+package api
+
+import (
+    "encoding/csv"
+    "fmt"
+    "net/http"
+    "strconv"
+    "time"
+
+    "gateway-monitor/internal/history"
+)
+
+// HistoryExport is the body of GET /api/v1/history
+type HistoryExport struct {
+    From    time.Time       `json:"from"`
+    To      time.Time       `json:"to"`
+    Periods []HistoryPeriod `json:"periods"`
+}
+
+// HistoryPeriod is a period of a gateway's state history
+type HistoryPeriod struct {
+    history.Period
+    Project string `json:"project,omitempty"`
+}
+
+// handleHistory serves GET /api/v1/history?gateway=X&from=...&to=...&state=offline&format=csv,
+// the state periods of one or every gateway from the history store as JSON or CSV. From and
+// to are RFC 3339 times or dates and default to the last 30 days.
+func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
+    if s.History == nil {
+        writeError(w, http.StatusNotFound, "the history store is disabled, set HISTORY_DB to enable it")
+        return
+    }
+    query := r.URL.Query()
+    format := query.Get("format")
+    if format != "" && format != "json" && format != "csv" {
+        writeError(w, http.StatusBadRequest, fmt.Sprintf("unsupported format %q, use json or csv", format))
+        return
+    }
+    to, err := parseHistoryTime(query.Get("to"), time.Now())
+    if err != nil {
+        writeError(w, http.StatusBadRequest, err.Error())
+        return
+    }
+    window, _ := history.ParseWindow(defaultReportWindow)
+    from, err := parseHistoryTime(query.Get("from"), to.Add(-window))
+    if err != nil {
+        writeError(w, http.StatusBadRequest, err.Error())
+        return
+    }
+    if !from.Before(to) {
+        writeError(w, http.StatusBadRequest, "from must be before to")
+        return
+    }
+
+    periods, err := s.History.Periods(query.Get("gateway"), from, to)
+    if err != nil {
+        writeError(w, http.StatusInternalServerError, err.Error())
+        return
+    }
+    projects := map[string]string{}
+    for _, gateway := range s.monitor.Gateways() {
+        projects[gateway.Name] = gateway.Project
+    }
+    export := HistoryExport{From: from, To: to, Periods: []HistoryPeriod{}}
+    for _, period := range periods {
+        if state := query.Get("state"); state != "" && period.State != state {
+            continue
+        }
+        export.Periods = append(export.Periods, HistoryPeriod{Period: period, Project: projects[period.Gateway]})
+    }
+
+    if format != "csv" {
+        writeJSON(w, http.StatusOK, export)
+        return
+    }
+    w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+    w.Header().Set("Content-Disposition", `attachment; filename="loracheck-history.csv"`)
+    out := csv.NewWriter(w)
+    out.Write([]string{"gateway", "project", "state", "from", "to", "duration_seconds"})
+    for _, period := range export.Periods {
+        out.Write([]string{
+            period.Gateway,
+            period.Project,
+            period.State,
+            period.From.UTC().Format(time.RFC3339),
+            period.To.UTC().Format(time.RFC3339),
+            strconv.FormatFloat(period.DurationSeconds, 'f', 0, 64),
+        })
+    }
+    out.Flush()
+}
+
+// parseHistoryTime parses an RFC 3339 time or a date, UTC midnight; empty is the fallback
+func parseHistoryTime(value string, fallback time.Time) (time.Time, error) {
+    if value == "" {
+        return fallback, nil
+    }
+    if t, err := time.Parse(time.RFC3339, value); err == nil {
+        return t, nil
+    }
+    if t, err := time.Parse(time.DateOnly, value); err == nil {
+        return t, nil
+    }
+    return time.Time{}, fmt.Errorf("invalid time %q, use RFC 3339 or YYYY-MM-DD", value)
+}
@@ -0,0 +1,152 @@
+package api
+
+import (
+    "encoding/json"
+    "errors"
+    "net/http"
+
+    "gateway-monitor/internal/config"
+    "gateway-monitor/internal/monitor"
+)
+
+// ChangeResponse is returned by the gateway management endpoints
+type ChangeResponse struct {
+    Gateway *GatewayResponse `json:"gateway,omitempty"`
+    // Saved reports whether the change was written back to the configuration file
+    Saved     bool   `json:"saved"`
+    SaveError string `json:"save_error,omitempty"`
+}
+
+// require only lets callers whose role allows role through, logging who made each change
+func (s *Server) require(role string, next http.HandlerFunc) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        access := s.access()
+        if err := access.Authorize(r.Header, role); err != nil {
+            status := http.StatusForbidden
+            if errors.Is(err, ErrUnauthenticated) {
+                status = http.StatusUnauthorized
+                w.Header().Set("WWW-Authenticate", `Bearer realm="LoRaCheck"`)
+            }
+            writeError(w, status, err.Error())
+            return
+        }
+        if r.Method != http.MethodGet {
+            if name, _ := access.Caller(r.Header); name != "" {
+                s.logger.Printf("%s %s by %s", r.Method, r.URL.Path, name)
+            }
+        }
+        next(w, r)
+    }
+}
+
+// StandbyResponse is returned with 503 to changes sent to a standby replica
+type StandbyResponse struct {
+    Error string `json:"error"`
+    // Leader is the identity of the replica to send the change to, when known
+    Leader string `json:"leader,omitempty"`
+}
+
+// leading rejects changes on a standby replica: they would only reach its own running
+// configuration, while the leader runs the checks and sends the notifications
+func (s *Server) leading(next http.HandlerFunc) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        if s.monitor.Leading() {
+            next(w, r)
+            return
+        }
+        response := StandbyResponse{Error: "this replica is standing by, send changes to the leader", Leader: s.monitor.LeaderIdentity()}
+        if response.Leader != "" {
+            response.Error += " " + response.Leader
+        }
+        writeJSON(w, http.StatusServiceUnavailable, response)
+    }
+}
+
+// access returns the access rules with the API tokens of the running configuration
+func (s *Server) access() Access {
+    gatewaysFile, _ := s.monitor.Current()
+    return Access{AdminToken: s.AdminToken, Auth: s.Auth, Tokens: gatewaysFile.APITokens}
+}
+
+// handleGetGateway serves GET /api/v1/gateways/{name}
+func (s *Server) handleGetGateway(w http.ResponseWriter, r *http.Request) {
+    gateway, ok := s.monitor.Gateway(r.PathValue("name"))
+    if !ok {
+        writeError(w, http.StatusNotFound, "unknown gateway "+r.PathValue("name"))
+        return
+    }
+    writeJSON(w, http.StatusOK, newGatewayResponse(gateway))
+}
+
+// handleCreateGateway serves POST /api/v1/gateways
+func (s *Server) handleCreateGateway(w http.ResponseWriter, r *http.Request) {
+    gateway, ok := decodeGateway(w, r, "")
+    if !ok {
+        return
+    }
+    s.applyChange(w, http.StatusCreated, &gateway, s.monitor.AddGateway(gateway))
+}
+
+// handleUpdateGateway serves PUT /api/v1/gateways/{name}, replacing the gateway definition
+func (s *Server) handleUpdateGateway(w http.ResponseWriter, r *http.Request) {
+    gateway, ok := decodeGateway(w, r, r.PathValue("name"))
+    if !ok {
+        return
+    }
+    s.applyChange(w, http.StatusOK, &gateway, s.monitor.UpdateGateway(gateway))
+}
+
+// handleDeleteGateway serves DELETE /api/v1/gateways/{name}
+func (s *Server) handleDeleteGateway(w http.ResponseWriter, r *http.Request) {
+    s.applyChange(w, http.StatusOK, nil, s.monitor.RemoveGateway(r.PathValue("name")))
+}
+
+// decodeGateway reads a gateway definition; its name defaults to, and must match, the one in the path
+func decodeGateway(w http.ResponseWriter, r *http.Request, name string) (config.Gateway, bool) {
+    var gateway config.Gateway
+    decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+    decoder.DisallowUnknownFields()
+    if err := decoder.Decode(&gateway); err != nil {
+        writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+        return gateway, false
+    }
+    if name != "" {
+        if gateway.Name == "" {
+            gateway.Name = name
+        } else if gateway.Name != name {
+            writeError(w, http.StatusBadRequest, "gateway name does not match the URL; delete and recreate it to rename")
+            return gateway, false
+        }
+    }
+    if gateway.Name == "" {
+        writeError(w, http.StatusBadRequest, "gateway name is required")
+        return gateway, false
+    }
+    return gateway, true
+}
+
+// applyChange reports the outcome of a change and writes the configuration back to its file
+func (s *Server) applyChange(w http.ResponseWriter, status int, gateway *config.Gateway, err error) {
+    switch {
+    case errors.Is(err, monitor.ErrGatewayExists):
+        writeError(w, http.StatusConflict, err.Error())
+        return
+    case errors.Is(err, monitor.ErrGatewayNotFound):
+        writeError(w, http.StatusNotFound, err.Error())
+        return
+    case err != nil:
+        writeError(w, http.StatusUnprocessableEntity, err.Error())
+        return
+    }
+
+    response := ChangeResponse{Saved: true}
+    if gateway != nil {
+        gatewayResponse := newGatewayResponse(*gateway)
+        response.Gateway = &gatewayResponse
+    }
+    if err := s.monitor.Save(); err != nil {
+        s.logger.Printf("WARNING: gateway change applied but not saved: %v", err)
+        response.Saved, response.SaveError = false, err.Error()
+    }
+    writeJSON(w, status, response)
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+    "encoding/json"
+    "net/http"
+    "strings"
+    "testing"
+)
+
+// standby is a replica another one, primary, was elected over
+type standby struct{}
+
+func (standby) Leading() bool  { return false }
+func (standby) Holder() string { return "primary" }
+
+func TestStandbyRejectsChanges(t *testing.T) {
+    ts := serveAPI(t, newTestMonitor(t, nil, standby{}), "admin-token")
+    tests := []struct {
+        method, path, body string
+    }{
+        {method: http.MethodPost, path: "/api/v1/gateways/gw1/silence", body: `{"duration": "1h"}`},
+        {method: http.MethodPost, path: "/api/v1/gateways", body: `{"name": "gw2", "checks": [{"type": "http", "url": "http://127.0.0.1:1/status"}]}`},
+        {method: http.MethodPut, path: "/api/v1/gateways/gw1", body: `{"checks": [{"type": "http", "url": "http://127.0.0.1:2/status"}]}`},
+        {method: http.MethodDelete, path: "/api/v1/gateways/gw1"},
+        {method: http.MethodPost, path: "/api/v1/config/save"},
+    }
+    for _, tt := range tests {
+        req, err := http.NewRequest(tt.method, ts.URL+tt.path, strings.NewReader(tt.body))
+        if err != nil {
+            t.Fatal(err)
+        }
+        req.Header = bearer("admin-token")
+        resp, err := ts.Client().Do(req)
+        if err != nil {
+            t.Fatal(err)
+        }
+        var body StandbyResponse
+        json.NewDecoder(resp.Body).Decode(&body)
+        resp.Body.Close()
+        if resp.StatusCode != http.StatusServiceUnavailable || body.Leader != "primary" {
+            t.Errorf("%s %s = %d with leader %q, want 503 pointing to primary", tt.method, tt.path, resp.StatusCode, body.Leader)
+        }
+    }
+
+    // Reading still works on the standby
+    if got := request(t, ts, http.MethodGet, "/api/v1/gateways/gw1", "", ""); got != http.StatusOK {
+        t.Errorf("GET /api/v1/gateways/gw1 = %d, want 200", got)
+    }
+}
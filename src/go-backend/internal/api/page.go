@@ -0,0 +1,115 @@
+package api
+
+import (
+    "embed"
+    "html/template"
+    "math"
+    "net/http"
+    "sort"
+    "time"
+
+    "gateway-monitor/internal/monitor"
+)
+
+//go:embed templates/status.html
+var templates embed.FS
+
+var statusPage = template.Must(template.New("status.html").Funcs(template.FuncMap{
+    "ago": func(t *time.Time) string {
+        return time.Since(*t).Round(time.Second).String() + " ago"
+    },
+}).ParseFS(templates, "templates/status.html"))
+
+// Size of the map drawn on the status page, in pixels
+const (
+    mapWidth  = 720
+    mapHeight = 360
+    mapMargin = 12
+)
+
+// statusPageData is rendered by the status page template
+type statusPageData struct {
+    Now           time.Time
+    Online, Total int
+    Groups        []statusGroup
+    Map           *statusMap
+}
+
+// statusGroup lists the gateways of one project
+type statusGroup struct {
+    Project  string
+    Gateways []monitor.GatewayStatus
+}
+
+// statusMap places the gateways with a location on an equirectangular projection of their bounding box
+type statusMap struct {
+    Width, Height int
+    Points        []mapPoint
+}
+
+type mapPoint struct {
+    Name, State string
+    X, Y        float64
+}
+
+// handleStatusPage serves the HTML status page at /
+func (s *Server) handleStatusPage(w http.ResponseWriter, r *http.Request) {
+    statuses := s.monitor.Status()
+    data := statusPageData{Now: time.Now(), Total: len(statuses), Map: newStatusMap(statuses)}
+
+    groups := map[string]int{}
+    for _, status := range statuses {
+        if status.State == "online" {
+            data.Online++
+        }
+        i, ok := groups[status.Project]
+        if !ok {
+            i = len(data.Groups)
+            groups[status.Project] = i
+            data.Groups = append(data.Groups, statusGroup{Project: status.Project})
+        }
+        data.Groups[i].Gateways = append(data.Groups[i].Gateways, status)
+    }
+    // Named projects first, alphabetically, then the gateways without a project
+    sort.SliceStable(data.Groups, func(i, j int) bool {
+        a, b := data.Groups[i].Project, data.Groups[j].Project
+        return a != "" && (b == "" || a < b)
+    })
+
+    w.Header().Set("Content-Type", "text/html; charset=utf-8")
+    if err := statusPage.Execute(w, data); err != nil {
+        s.logger.Printf("Failed to render status page: %v", err)
+    }
+}
+
+// newStatusMap returns nil when no gateway has a location
+func newStatusMap(statuses []monitor.GatewayStatus) *statusMap {
+    minLat, maxLat, minLon, maxLon := math.Inf(1), math.Inf(-1), math.Inf(1), math.Inf(-1)
+    var located []monitor.GatewayStatus
+    for _, status := range statuses {
+        location := status.Location
+        if location.Latitude == 0 && location.Longitude == 0 {
+            continue
+        }
+        located = append(located, status)
+        minLat, maxLat = math.Min(minLat, location.Latitude), math.Max(maxLat, location.Latitude)
+        minLon, maxLon = math.Min(minLon, location.Longitude), math.Max(maxLon, location.Longitude)
+    }
+    if len(located) == 0 {
+        return nil
+    }
+
+    // Keep single gateways and tight clusters from collapsing into a point
+    spanLat, spanLon := math.Max(maxLat-minLat, 0.01), math.Max(maxLon-minLon, 0.01)
+    scale := math.Min((mapWidth-2*mapMargin)/spanLon, (mapHeight-2*mapMargin)/spanLat)
+    m := &statusMap{Width: mapWidth, Height: mapHeight}
+    for _, status := range located {
+        m.Points = append(m.Points, mapPoint{
+            Name:  status.Name,
+            State: status.State,
+            X:     mapWidth/2 + (status.Location.Longitude-(minLon+maxLon)/2)*scale,
+            Y:     mapHeight/2 - (status.Location.Latitude-(minLat+maxLat)/2)*scale,
+        })
+    }
+    return m
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+    "net/http"
+    "time"
+
+    "gateway-monitor/internal/history"
+)
+
+// defaultReportWindow is the window of the uptime report unless the request sets one
+const defaultReportWindow = "30d"
+
+// UptimeReport is the body of GET /api/v1/reports/uptime
+type UptimeReport struct {
+    From     time.Time       `json:"from"`
+    To       time.Time       `json:"to"`
+    Gateways []GatewayUptime `json:"gateways"`
+}
+
+// GatewayUptime is the availability of one monitored gateway
+type GatewayUptime struct {
+    history.Uptime
+    Project string `json:"project,omitempty"`
+}
+
+// handleUptimeReport serves GET /api/v1/reports/uptime?window=30d from the history store
+func (s *Server) handleUptimeReport(w http.ResponseWriter, r *http.Request) {
+    if s.History == nil {
+        writeError(w, http.StatusNotFound, "the history store is disabled, set HISTORY_DB to enable it")
+        return
+    }
+    window := r.URL.Query().Get("window")
+    if window == "" {
+        window = defaultReportWindow
+    }
+    d, err := history.ParseWindow(window)
+    if err != nil {
+        writeError(w, http.StatusBadRequest, err.Error())
+        return
+    }
+
+    to := time.Now()
+    report := UptimeReport{From: to.Add(-d), To: to, Gateways: []GatewayUptime{}}
+    uptimes, err := s.History.Uptime(report.From, report.To)
+    if err != nil {
+        writeError(w, http.StatusInternalServerError, err.Error())
+        return
+    }
+    for _, gateway := range s.monitor.Gateways() {
+        uptime := history.Uptime{Gateway: gateway.Name}
+        if recorded, ok := uptimes[gateway.Name]; ok {
+            uptime = *recorded
+        }
+        report.Gateways = append(report.Gateways, GatewayUptime{Uptime: uptime, Project: gateway.Project})
+    }
+    writeJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+    "encoding/json"
+    "net/http"
+    "time"
+)
+
+// SilenceRequest is the body of POST /api/v1/gateways/{name}/silence
+type SilenceRequest struct {
+    Duration string `json:"duration"`
+    Comment  string `json:"comment"`
+}
+
+// handleCreateSilence serves POST /api/v1/gateways/{name}/silence
+func (s *Server) handleCreateSilence(w http.ResponseWriter, r *http.Request) {
+    name := r.PathValue("name")
+    if _, ok := s.monitor.Gateway(name); !ok {
+        writeError(w, http.StatusNotFound, "unknown gateway "+name)
+        return
+    }
+
+    var req SilenceRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+        return
+    }
+    d, err := time.ParseDuration(req.Duration)
+    if err != nil || d <= 0 {
+        writeError(w, http.StatusBadRequest, "duration must be a positive Go duration such as 2h30m")
+        return
+    }
+    if s.MaxSilence > 0 && d > s.MaxSilence {
+        writeError(w, http.StatusBadRequest, "duration must not exceed "+s.MaxSilence.String())
+        return
+    }
+
+    writeJSON(w, http.StatusCreated, s.monitor.Silence(name, d, req.Comment))
+}
+
+// handleListSilences serves GET /api/v1/silences and GET /api/v1/gateways/{name}/silence
+func (s *Server) handleListSilences(w http.ResponseWriter, r *http.Request) {
+    name := r.PathValue("name")
+    if name != "" {
+        if _, ok := s.monitor.Gateway(name); !ok {
+            writeError(w, http.StatusNotFound, "unknown gateway "+name)
+            return
+        }
+    }
+    writeJSON(w, http.StatusOK, s.monitor.Silences().Active(name))
+}
@@ -0,0 +1,28 @@
+package api
+
+import (
+    "net/http"
+    "testing"
+    "time"
+)
+
+func TestCreateSilenceDuration(t *testing.T) {
+    ts := newTestServer(t, "admin-token", nil, func(s *Server) { s.MaxSilence = 7 * 24 * time.Hour })
+    tests := []struct {
+        duration string
+        status   int
+    }{
+        {duration: "2h30m", status: http.StatusCreated},
+        {duration: "168h", status: http.StatusCreated},
+        {duration: "168h1s", status: http.StatusBadRequest},
+        {duration: "8760h", status: http.StatusBadRequest},
+        {duration: "0s", status: http.StatusBadRequest},
+        {duration: "soon", status: http.StatusBadRequest},
+    }
+    for _, tt := range tests {
+        body := `{"duration": "` + tt.duration + `", "comment": "maintenance"}`
+        if got := request(t, ts, http.MethodPost, "/api/v1/gateways/gw1/silence", "admin-token", body); got != tt.status {
+            t.Errorf("silence for %s = %d, want %d", tt.duration, got, tt.status)
+        }
+    }
+}
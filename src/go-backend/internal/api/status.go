@@ -0,0 +1,20 @@
+package api
+
+import (
+    "net/http"
+)
+
+// handleStatus serves GET /api/v1/status with the current state of every gateway
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+    writeJSON(w, http.StatusOK, s.monitor.Status())
+}
+
+// handleGatewayStatus serves GET /api/v1/status/{name}
+func (s *Server) handleGatewayStatus(w http.ResponseWriter, r *http.Request) {
+    gateway, ok := s.monitor.Gateway(r.PathValue("name"))
+    if !ok {
+        writeError(w, http.StatusNotFound, "unknown gateway "+r.PathValue("name"))
+        return
+    }
+    writeJSON(w, http.StatusOK, s.monitor.GatewayStatus(gateway))
+}
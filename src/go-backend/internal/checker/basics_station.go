@@ -0,0 +1,100 @@
+package checker
+
+import (
+    "context"
+    "fmt"
+    "net/http"
+    "strings"
+
+    "github.com/gorilla/websocket"
+
+    "gateway-monitor/internal/config"
+    "gateway-monitor/internal/metrics"
+)
+
+// routerInfoResponse is the LNS answer to a Basics Station discovery request
+type routerInfoResponse struct {
+    URI   string `json:"uri"`
+    Error string `json:"error"`
+}
+
+// checkBasicsStation runs the Basics Station discovery handshake on the LNS router-info websocket
+// for the gateway's EUI. The LNS only hands out a traffic endpoint for gateways it accepts, so a
+// failure here means the gateway cannot establish its LNS session. The traffic endpoint itself is
+// not opened since that would take over the session of the real gateway.
+func (c *Checker) checkBasicsStation(ctx context.Context, gateway config.Gateway, check config.Check) (bool, error) {
+    connected, err := c.routerInfo(ctx, gateway, check)
+    c.metrics.GatewayLNSConnected.WithLabelValues(gateway.Name, gateway.Project).Set(metrics.Bool(connected && err == nil))
+    return connected, err
+}
+
+func (c *Checker) routerInfo(ctx context.Context, gateway config.Gateway, check config.Check) (bool, error) {
+    c.logger.Printf("Requesting Basics Station router info for %s from URL: %s", gateway.Name, check.URL)
+
+    timeout, err := check.CheckTimeout(c.client.Timeout)
+    if err != nil {
+        return false, err
+    }
+    header := http.Header{}
+    if key := check.ResolveAPIKey(); key != "" {
+        header.Set("Authorization", "Bearer "+key)
+    }
+    for name, value := range check.Headers {
+        header.Set(name, value)
+    }
+    tlsSettings, err := tlsKeyOf(check.TLS)
+    if err != nil {
+        return false, err
+    }
+    tlsConfig, err := c.clientTLS(tlsSettings)
+    if err != nil {
+        return false, err
+    }
+    dialer := websocket.Dialer{
+        Proxy:            c.proxy(check.Proxy),
+        NetDialContext:   networkKeyOf(check).dial(timeout),
+        HandshakeTimeout: timeout,
+        TLSClientConfig:  tlsConfig,
+    }
+    conn, resp, err := dialer.DialContext(ctx, check.URL, header)
+    if err != nil {
+        if resp != nil {
+            return false, fmt.Errorf("websocket handshake with %s failed with HTTP status %d: %w", check.URL, resp.StatusCode, err)
+        }
+        return false, fmt.Errorf("failed to connect to %s: %w", check.URL, err)
+    }
+    defer conn.Close()
+
+    if deadline, ok := ctx.Deadline(); ok {
+        conn.SetReadDeadline(deadline)
+    } else if timeout > 0 {
+        conn.SetReadDeadline(c.clock.Now().Add(timeout))
+    }
+
+    if err := conn.WriteJSON(map[string]string{"router": stationEUI(check.EUI)}); err != nil {
+        return false, fmt.Errorf("failed to send router info request: %w", err)
+    }
+    var info routerInfoResponse
+    if err := conn.ReadJSON(&info); err != nil {
+        return false, &ParseError{Err: err}
+    }
+
+    if info.Error != "" {
+        c.logger.Printf("LNS rejected gateway %s: %s", gateway.Name, info.Error)
+        return false, nil
+    }
+    if info.URI == "" {
+        return false, ErrNoStatus
+    }
+    return true, nil
+}
+
+// stationEUI formats an EUI the way Basics Station sends it, as dash-separated hex bytes
+func stationEUI(eui string) string {
+    eui = strings.NewReplacer(":", "", "-", "").Replace(config.NormalizeEUI(eui))
+    var parts []string
+    for i := 0; i+2 <= len(eui); i += 2 {
+        parts = append(parts, eui[i:i+2])
+    }
+    return strings.ToUpper(strings.Join(parts, "-"))
+}
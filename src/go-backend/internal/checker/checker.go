@@ -0,0 +1,535 @@
+// Package checker fetches and interprets the status sources configured for each gateway.
+package checker
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "log"
+    "net"
+    "net/http"
+    "sync"
+    "time"
+
+    "gateway-monitor/internal/clock"
+    "gateway-monitor/internal/config"
+    "gateway-monitor/internal/metrics"
+)
+
+// ErrNoStatus is returned when a check response does not say whether the gateway is online.
+// The next configured check is consulted in that case.
+var ErrNoStatus = errors.New("no 'online' status found")
+
+// Checker performs gateway checks
+type Checker struct {
+    client        *http.Client
+    forwarders    *Forwarders
+    subscriptions *Subscriptions
+    metrics       *metrics.Metrics
+    clock         clock.Clock
+    logger        *log.Logger
+
+    // MaxStatusAge is how old the status timestamp of an http check without a max_age
+    // may be before the gateway counts as offline; zero disables the check
+    MaxStatusAge time.Duration
+    // StatusPolicy is the policy of gateways without a status_policy; empty means config.PolicyFirst
+    StatusPolicy string
+    // CircuitOpenAfter is how long a check may keep failing before it is only probed every
+    // CircuitProbeInterval instead of every cycle; zero disables the circuit breaker
+    CircuitOpenAfter     time.Duration
+    CircuitProbeInterval time.Duration
+    // RateLimiter throttles the requests of HTTP based checks; nil leaves them unlimited
+    RateLimiter *RateLimiter
+    // UpdateGapFactor is how many times longer than the learned interval between the updates
+    // a check's status source reports the current gap must be to count as an anomaly; zero
+    // disables the detection
+    UpdateGapFactor float64
+
+    locationsMu sync.Mutex
+    // locations holds the last location reported by a check, for gateways with location_source "api"
+    locations map[string]config.Location
+
+    seenMu sync.Mutex
+    // seen records when each check last reported its gateway online
+    seen map[checkKey]time.Time
+
+    resultsMu sync.Mutex
+    results   map[checkKey]CheckResult
+
+    fieldsMu   sync.Mutex
+    samples    map[fieldKey][]sample
+    violations map[fieldKey]string
+
+    transportsMu sync.Mutex
+    transports   map[transportKey]*http.Transport
+
+    circuitsMu sync.Mutex
+    circuits   map[checkKey]circuit
+
+    responsesMu sync.Mutex
+    // responses holds the responses of http checks shared within the current cycle
+    responses map[sharedKey]*sharedResponse
+
+    trafficMu sync.Mutex
+    // traffic holds the message counts last read by each tts check
+    traffic map[checkKey]trafficCount
+
+    gapsMu sync.Mutex
+    gaps   map[checkKey]updateGap
+}
+
+// New creates a Checker that uses client for HTTP checks, forwarders for udp-forwarder checks
+// and subscriptions for mqtt checks
+func New(client *http.Client, forwarders *Forwarders, subscriptions *Subscriptions, m *metrics.Metrics, clk clock.Clock, logger *log.Logger) *Checker {
+    return &Checker{
+        client:        client,
+        forwarders:    forwarders,
+        subscriptions: subscriptions,
+        metrics:       m,
+        clock:         clk,
+        logger:        logger,
+        locations:     map[string]config.Location{},
+        seen:          map[checkKey]time.Time{},
+        results:       map[checkKey]CheckResult{},
+        samples:       map[fieldKey][]sample{},
+        violations:    map[fieldKey]string{},
+        transports:    map[transportKey]*http.Transport{},
+        circuits:      map[checkKey]circuit{},
+        traffic:       map[checkKey]trafficCount{},
+        gaps:          map[checkKey]updateGap{},
+        responses:     map[sharedKey]*sharedResponse{},
+    }
+}
+
+// Location returns the gateway location to export: the one last reported by a check
+// when location_source is "api", the configured one otherwise
+func (c *Checker) Location(gateway config.Gateway) config.Location {
+    if gateway.LocationSource != "api" {
+        return gateway.Location
+    }
+    c.locationsMu.Lock()
+    defer c.locationsMu.Unlock()
+    if location, ok := c.locations[gateway.Name]; ok {
+        if location.Altitude == nil {
+            location.Altitude = gateway.Location.Altitude
+        }
+        return location
+    }
+    return gateway.Location
+}
+
+func (c *Checker) setLocation(name string, location config.Location) {
+    if location.Latitude < -90 || location.Latitude > 90 || location.Longitude < -180 || location.Longitude > 180 {
+        c.logger.Printf("WARNING: ignoring the location %f, %f reported for %s, it is outside the valid range", location.Latitude, location.Longitude, name)
+        return
+    }
+    c.locationsMu.Lock()
+    defer c.locationsMu.Unlock()
+    c.locations[name] = config.NormalizeLocation(location)
+}
+
+// selfTimestampedChecks report when the gateway was last seen themselves
+var selfTimestampedChecks = map[string]bool{"tts": true, "ttn-v3": true, "chirpstack": true, "helium": true, "packet-broker": true}
+
+// selfTimestamped reports whether the check records its own last seen time,
+// either by type or through a timestamp_field
+func selfTimestamped(check config.Check) bool {
+    return selfTimestampedChecks[check.Type] || check.TimestampField != ""
+}
+
+// GatewayStatus runs every check of the gateway, so each link status is exported, and
+// combines their answers with the gateway's status policy: by default the first definite
+// answer in check order wins. A check that fails counts as offline; a check without an online
+// field is left out. The result is exported as gateway_overall_status.
+func (c *Checker) GatewayStatus(ctx context.Context, gateway config.Gateway) bool {
+    var answers []bool
+    for _, check := range gateway.Checks {
+        online, err := c.FetchGatewayLinkStatus(ctx, gateway, check)
+        if errors.Is(err, ErrCircuitOpen) {
+            // Logged when the circuit opened, a line every cycle would only fill the logs
+            err = nil
+        } else if errors.Is(err, ErrNoStatus) {
+            c.logger.Printf("No 'online' status found for %s in the fetched data", gateway.Name)
+            continue
+        }
+        if err != nil {
+            c.logger.Printf("Check for %s failed: %v", gateway.Name, err)
+            online = false
+        }
+
+        answers = append(answers, online)
+    }
+
+    status := Combine(gateway.Policy(c.StatusPolicy), answers)
+    c.metrics.GatewayOverallStatus.WithLabelValues(gateway.Name, gateway.Project).Set(metrics.Bool(status))
+    c.logger.Printf("Gateway %s online status: %v", gateway.Name, status)
+    return status
+}
+
+// Combine applies a status policy to the answers of a gateway's checks; a gateway without
+// any answer is offline
+func Combine(policy string, answers []bool) bool {
+    if len(answers) == 0 {
+        return false
+    }
+    switch policy {
+    case config.PolicyAny:
+        for _, online := range answers {
+            if online {
+                return true
+            }
+        }
+        return false
+    case config.PolicyAll:
+        for _, online := range answers {
+            if !online {
+                return false
+            }
+        }
+        return true
+    }
+    return answers[0]
+}
+
+// FetchGatewayLinkStatus runs a single check and reports whether it considers the gateway online
+func (c *Checker) FetchGatewayLinkStatus(ctx context.Context, gateway config.Gateway, check config.Check) (bool, error) {
+    labels := []string{gateway.Name, gateway.Project, check.Target(), check.Type}
+    start := c.clock.Now()
+    if check.Type == "udp-forwarder" || check.Type == "mqtt" {
+        var online bool
+        var err error
+        if check.Type == "udp-forwarder" {
+            online, err = c.forwarders.Status(gateway, check)
+        } else {
+            online, err = c.subscriptions.Status(gateway, check)
+        }
+        c.metrics.GatewayLinkStatus.WithLabelValues(labels...).Set(metrics.Bool(online && err == nil))
+        c.exportLastUpdate(gateway, check, labels)
+        c.trackUpdates(gateway, check, labels)
+        c.countError(labels, err)
+        c.recordResult(gateway, check, online, err, start)
+        return online, err
+    }
+
+    if c.circuitOpen(gateway, check) {
+        return false, c.circuitError(gateway, check)
+    }
+
+    statusCode := 0
+    ctx = withStatusRecorder(ctx, &statusCode)
+    online, size, err := c.withRetries(ctx, gateway, check, labels, func() (bool, int, error) {
+        return c.runCheck(ctx, gateway, check)
+    })
+    if statusCode != 0 {
+        c.metrics.GatewayCheckHTTPStatus.WithLabelValues(labels...).Set(float64(statusCode))
+    }
+
+    c.metrics.GatewayCheckDuration.WithLabelValues(labels...).Observe(c.clock.Now().Sub(start).Seconds())
+    c.metrics.GatewayCheckTotal.WithLabelValues(append(labels, classifyResult(err))...).Inc()
+    if size >= 0 {
+        c.metrics.GatewayCheckResponseSize.WithLabelValues(labels...).Set(float64(size))
+    }
+    if err == nil && online && !selfTimestamped(check) {
+        c.markSeen(gateway, check, c.clock.Now())
+    }
+    if !errors.Is(err, ErrNoStatus) {
+        c.metrics.GatewayLinkStatus.WithLabelValues(labels...).Set(metrics.Bool(online && err == nil))
+    }
+    c.exportLastUpdate(gateway, check, labels)
+    c.trackUpdates(gateway, check, labels)
+    c.countError(labels, err)
+    c.recordResult(gateway, check, online, err, start)
+    c.updateCircuit(gateway, check, labels, err)
+
+    return online, err
+}
+
+// runCheck makes one attempt at a check, returning -1 as the size when it has no response body
+func (c *Checker) runCheck(ctx context.Context, gateway config.Gateway, check config.Check) (bool, int, error) {
+    var online bool
+    var err error
+    switch check.Type {
+    case "tts", "ttn-v3":
+        return c.fetchTTS(ctx, gateway, check)
+    case "chirpstack":
+        return c.fetchChirpStack(ctx, gateway, check)
+    case "helium":
+        return c.fetchHelium(ctx, gateway, check)
+    case "packet-broker":
+        return c.fetchPacketBroker(ctx, gateway, check)
+    case "multi-step":
+        online, err = c.runSteps(ctx, gateway, check)
+    case "basics-station":
+        online, err = c.checkBasicsStation(ctx, gateway, check)
+    case "ping":
+        online, err = c.ping(ctx, gateway, check)
+    case "tcp":
+        online, err = c.dialTCP(ctx, gateway, check)
+    case "dns":
+        online, err = c.resolveDNS(ctx, gateway, check)
+    case "snmp":
+        online, err = c.pollSNMP(ctx, gateway, check)
+    default:
+        return c.fetchHTTP(ctx, gateway, check)
+    }
+    return online, -1, err
+}
+
+// fetchHTTP fetches the check URL and parses the online status, returning the response size
+func (c *Checker) fetchHTTP(ctx context.Context, gateway config.Gateway, check config.Check) (bool, int, error) {
+    c.logger.Printf("Fetching data for %s from URL: %s", gateway.Name, check.URL)
+
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, check.URL, nil)
+    if err != nil {
+        return false, -1, fmt.Errorf("invalid request for URL %s: %w", check.URL, err)
+    }
+    authorize(req, check)
+
+    client, err := c.httpClient(check)
+    if err != nil {
+        return false, -1, err
+    }
+    status, body, err := c.fetchShared(ctx, req, check, client)
+    if err != nil {
+        // Without a response there is no size to report
+        if body == nil {
+            return false, -1, err
+        }
+        return false, len(body), err
+    }
+    if status < 200 || status >= 300 {
+        return false, len(body), &StatusError{Code: status, URL: check.URL}
+    }
+
+    c.logger.Printf("Successfully fetched data from URL: %s", check.URL)
+
+    c.evaluateFields(gateway, check, body)
+    online, timestamp, err := parseMappedStatus(body, gateway.Name, check)
+    if err == nil && online && !timestamp.IsZero() {
+        c.markSeen(gateway, check, timestamp)
+    }
+    if timestamp.IsZero() {
+        if updated, ok, _ := updatedAt(body, gateway.Name); ok {
+            c.observeUpdate(gateway, check, updated)
+        }
+    }
+    if err == nil {
+        online, err = c.checkStaleness(gateway, check, body, online, timestamp)
+    }
+    if gateway.LocationSource == "api" {
+        if location, ok := ParseLocation(body, gateway.Name); ok {
+            c.setLocation(gateway.Name, location)
+        }
+    }
+    return online, len(body), err
+}
+
+// checkStaleness reports the gateway offline when its status timestamp is older than the
+// check's max_age. Without a timestamp_field the 'updatedAt' field is used when present.
+func (c *Checker) checkStaleness(gateway config.Gateway, check config.Check, body []byte, online bool, timestamp time.Time) (bool, error) {
+    maxAge, err := check.StatusMaxAge(c.MaxStatusAge)
+    if err != nil || maxAge <= 0 {
+        return online, err
+    }
+    if timestamp.IsZero() {
+        updated, ok, err := updatedAt(body, gateway.Name)
+        if err != nil {
+            return false, err
+        }
+        if !ok {
+            return online, nil
+        }
+        timestamp = updated
+    }
+
+    age := c.clock.Now().Sub(timestamp)
+    stale := age > maxAge
+    c.metrics.GatewayStatusStale.WithLabelValues(gateway.Name, gateway.Project, check.Target(), check.Type).Set(metrics.Bool(stale))
+    if stale {
+        c.logger.Printf("Status of %s was last updated %s ago, more than the max age of %s", gateway.Name, age.Round(time.Second), maxAge)
+        return false, nil
+    }
+    return online, nil
+}
+
+// updatedAt parses the 'updatedAt' field of a response; ok is false when there is none
+func updatedAt(body []byte, gatewayName string) (time.Time, bool, error) {
+    var doc interface{}
+    if err := json.Unmarshal(body, &doc); err != nil {
+        return time.Time{}, false, nil
+    }
+    value, ok := lookupMapped(doc, gatewayName, "updatedAt")
+    if !ok {
+        return time.Time{}, false, nil
+    }
+    timestamp, err := parseTimestamp(value)
+    if err != nil {
+        return time.Time{}, false, &ParseError{Err: fmt.Errorf("updatedAt: %w", err)}
+    }
+    return timestamp, true, nil
+}
+
+// classifyResult maps a check error to the result label of gateway_check_total
+func classifyResult(err error) string {
+    var netErr net.Error
+    var parseErr *ParseError
+    switch {
+    case err == nil:
+        return "success"
+    case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+        return "timeout"
+    case errors.Is(err, ErrNoStatus), errors.As(err, &parseErr):
+        return "parse_error"
+    default:
+        return "http_error"
+    }
+}
+
+// errorClass groups a failed check's error into timeout, dns, http_status, parse_error,
+// connection or other
+func errorClass(err error) string {
+    var dnsErr *net.DNSError
+    var netErr net.Error
+    var statusErr *StatusError
+    var parseErr *ParseError
+    var opErr *net.OpError
+    switch {
+    case errors.As(err, &dnsErr):
+        return "dns"
+    case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+        return "timeout"
+    case errors.As(err, &statusErr):
+        return "http_status"
+    case errors.Is(err, ErrNoStatus), errors.As(err, &parseErr):
+        return "parse_error"
+    case errors.As(err, &opErr):
+        return "connection"
+    default:
+        return "other"
+    }
+}
+
+// checkKey identifies one check of one gateway
+type checkKey struct {
+    gateway string
+    target  string
+}
+
+// LastSeen returns when the check last found the gateway online
+func (c *Checker) LastSeen(gateway config.Gateway, check config.Check) (time.Time, bool) {
+    switch check.Type {
+    case "udp-forwarder":
+        return c.forwarders.LastSeen(check)
+    case "mqtt":
+        return c.subscriptions.LastSeen(check)
+    }
+    c.seenMu.Lock()
+    defer c.seenMu.Unlock()
+    seen, ok := c.seen[checkKey{gateway: gateway.Name, target: check.Target()}]
+    return seen, ok
+}
+
+// countError counts a failed check by the class of its error
+func (c *Checker) countError(labels []string, err error) {
+    if err != nil {
+        c.metrics.GatewayCheckErrors.WithLabelValues(append(labels, errorClass(err))...).Inc()
+    }
+}
+
+// exportLastUpdate sets the last update gauge once the check has found the gateway online
+func (c *Checker) exportLastUpdate(gateway config.Gateway, check config.Check, labels []string) {
+    if seen, ok := c.LastSeen(gateway, check); ok && !seen.IsZero() {
+        c.metrics.GatewayLastUpdate.WithLabelValues(labels...).Set(float64(seen.Unix()))
+    }
+}
+
+// trackUpdates learns from the last update reported by a check whose status source has
+// timestamps of its own, and exports whether the current gap is anomalous
+func (c *Checker) trackUpdates(gateway config.Gateway, check config.Check, labels []string) {
+    if check.Type == "udp-forwarder" || check.Type == "mqtt" || selfTimestamped(check) {
+        if seen, ok := c.LastSeen(gateway, check); ok {
+            c.observeUpdate(gateway, check, seen)
+        }
+    }
+    c.exportUpdateGap(gateway, check, labels)
+}
+
+func (c *Checker) markSeen(gateway config.Gateway, check config.Check, at time.Time) {
+    c.seenMu.Lock()
+    defer c.seenMu.Unlock()
+    c.seen[checkKey{gateway: gateway.Name, target: check.Target()}] = at
+}
+
+// StatusError is returned when a status API answers with an unexpected HTTP status
+type StatusError struct {
+    Code int
+    URL  string
+}
+
+func (e *StatusError) Error() string {
+    return fmt.Sprintf("unexpected HTTP status %d from URL %s", e.Code, e.URL)
+}
+
+// ParseError is returned when a response body cannot be interpreted
+type ParseError struct {
+    Err error
+}
+
+func (e *ParseError) Error() string {
+    return "failed to parse JSON: " + e.Err.Error()
+}
+
+func (e *ParseError) Unwrap() error {
+    return e.Err
+}
+
+// ParseOnlineStatus reads the 'online' flag either at the top level of the JSON document
+// or inside an object keyed by the gateway name
+func ParseOnlineStatus(body []byte, gatewayName string) (bool, error) {
+    var result map[string]interface{}
+    if err := json.Unmarshal(body, &result); err != nil {
+        return false, &ParseError{Err: err}
+    }
+
+    if val, ok := result["online"]; ok {
+        if online, ok := val.(bool); ok {
+            return online, nil
+        }
+    } else if val, ok := result[gatewayName].(map[string]interface{}); ok {
+        if online, ok := val["online"].(bool); ok {
+            return online, nil
+        }
+    }
+
+    return false, ErrNoStatus
+}
+
+// ParseLocation reads a 'location' object next to the 'online' flag, as returned by the TTN gateway-data API
+func ParseLocation(body []byte, gatewayName string) (config.Location, bool) {
+    var result map[string]json.RawMessage
+    if err := json.Unmarshal(body, &result); err != nil {
+        return config.Location{}, false
+    }
+
+    raw, ok := result["location"]
+    if !ok {
+        var nested map[string]json.RawMessage
+        if err := json.Unmarshal(result[gatewayName], &nested); err != nil {
+            return config.Location{}, false
+        }
+        if raw, ok = nested["location"]; !ok {
+            return config.Location{}, false
+        }
+    }
+
+    var location struct {
+        Latitude  *float64 `json:"latitude"`
+        Longitude *float64 `json:"longitude"`
+        Altitude  *float64 `json:"altitude"`
+    }
+    if err := json.Unmarshal(raw, &location); err != nil || location.Latitude == nil || location.Longitude == nil {
+        return config.Location{}, false
+    }
+    return config.Location{Latitude: *location.Latitude, Longitude: *location.Longitude, Altitude: location.Altitude}, true
+}
@@ -0,0 +1,204 @@
+package checker
+
+import (
+    "context"
+    "errors"
+    "io"
+    "log"
+    "net"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+
+    "github.com/prometheus/client_golang/prometheus"
+    dto "github.com/prometheus/client_model/go"
+
+    "gateway-monitor/internal/clock"
+    "gateway-monitor/internal/config"
+    "gateway-monitor/internal/metrics"
+)
+
+var testTime = time.Date(2026, 1, 5, 12, 0, 0, 0, time.UTC)
+
+// newTestChecker returns a Checker on a fake clock with its own metrics registry
+func newTestChecker(client *http.Client) (*Checker, *metrics.Metrics, *clock.Fake) {
+    clk := clock.NewFake(testTime)
+    logger := log.New(io.Discard, "", 0)
+    m := metrics.New(prometheus.NewRegistry())
+    return New(client, NewForwarders(m, clk, logger), NewSubscriptions(m, clk, logger), m, clk, logger), m, clk
+}
+
+// serve returns a server answering every request with status and body
+func serve(t *testing.T, status int, body string) *httptest.Server {
+    t.Helper()
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        w.WriteHeader(status)
+        io.WriteString(w, body)
+    }))
+    t.Cleanup(server.Close)
+    return server
+}
+
+func httpGateway(url string) (config.Gateway, config.Check) {
+    check := config.Check{Type: "http", URL: url}
+    return config.Gateway{Name: "gw1", Project: "p", Checks: []config.Check{check}}, check
+}
+
+func gaugeValue(t *testing.T, gauge prometheus.Gauge) float64 {
+    t.Helper()
+    var metric dto.Metric
+    if err := gauge.Write(&metric); err != nil {
+        t.Fatal(err)
+    }
+    return metric.GetGauge().GetValue()
+}
+
+func counterValue(t *testing.T, counter prometheus.Counter) float64 {
+    t.Helper()
+    var metric dto.Metric
+    if err := counter.Write(&metric); err != nil {
+        t.Fatal(err)
+    }
+    return metric.GetCounter().GetValue()
+}
+
+func TestFetchGatewayLinkStatusOnline(t *testing.T) {
+    server := serve(t, http.StatusOK, `{"online": true}`)
+    c, m, _ := newTestChecker(&http.Client{Timeout: time.Second})
+    gateway, check := httpGateway(server.URL)
+
+    online, err := c.FetchGatewayLinkStatus(context.Background(), gateway, check)
+    if err != nil || !online {
+        t.Fatalf("got online %v, error %v; want online", online, err)
+    }
+    labels := []string{"gw1", "p", server.URL, "http"}
+    if got := gaugeValue(t, m.GatewayLinkStatus.WithLabelValues(labels...)); got != 1 {
+        t.Errorf("gateway_link_status = %v, want 1", got)
+    }
+    if got := counterValue(t, m.GatewayCheckTotal.WithLabelValues(append(labels, "success")...)); got != 1 {
+        t.Errorf("gateway_check_total{result=success} = %v, want 1", got)
+    }
+    if got := gaugeValue(t, m.GatewayCheckResponseSize.WithLabelValues(labels...)); got != float64(len(`{"online": true}`)) {
+        t.Errorf("gateway_check_response_size_bytes = %v", got)
+    }
+    if got := gaugeValue(t, m.GatewayLastUpdate.WithLabelValues(labels...)); got != float64(testTime.Unix()) {
+        t.Errorf("gateway_last_update = %v, want the fake clock's time", got)
+    }
+}
+
+func TestFetchGatewayLinkStatusParseFailure(t *testing.T) {
+    server := serve(t, http.StatusOK, `{"online": tru`)
+    c, m, _ := newTestChecker(&http.Client{Timeout: time.Second})
+    gateway, check := httpGateway(server.URL)
+
+    online, err := c.FetchGatewayLinkStatus(context.Background(), gateway, check)
+    var parseErr *ParseError
+    if online || !errors.As(err, &parseErr) {
+        t.Fatalf("got online %v, error %v; want a ParseError", online, err)
+    }
+    labels := []string{"gw1", "p", server.URL, "http"}
+    if got := gaugeValue(t, m.GatewayLinkStatus.WithLabelValues(labels...)); got != 0 {
+        t.Errorf("gateway_link_status = %v, want 0", got)
+    }
+    if got := counterValue(t, m.GatewayCheckTotal.WithLabelValues(append(labels, "parse_error")...)); got != 1 {
+        t.Errorf("gateway_check_total{result=parse_error} = %v, want 1", got)
+    }
+}
+
+func TestGatewayStatusExportsOverallStatus(t *testing.T) {
+    online := serve(t, http.StatusOK, `{"online": true}`)
+    offline := serve(t, http.StatusOK, `{"gw1": {"online": false}}`)
+    c, m, _ := newTestChecker(&http.Client{Timeout: time.Second})
+    gateway := config.Gateway{Name: "gw1", Project: "p", StatusPolicy: config.PolicyAll, Checks: []config.Check{
+        {Type: "http", URL: online.URL},
+        {Type: "http", URL: offline.URL},
+    }}
+
+    if c.GatewayStatus(context.Background(), gateway) {
+        t.Fatal("gateway with an offline check is online under the all policy")
+    }
+    if got := gaugeValue(t, m.GatewayOverallStatus.WithLabelValues("gw1", "p")); got != 0 {
+        t.Errorf("gateway_overall_status = %v, want 0", got)
+    }
+    if got := gaugeValue(t, m.GatewayLinkStatus.WithLabelValues("gw1", "p", online.URL, "http")); got != 1 {
+        t.Errorf("gateway_link_status of the online check = %v, want 1", got)
+    }
+}
+
+func TestCheckResultClassification(t *testing.T) {
+    release := make(chan struct{})
+    hung := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        select {
+        case <-r.Context().Done():
+        case <-release:
+        }
+    }))
+    t.Cleanup(hung.Close)
+    t.Cleanup(func() { close(release) })
+    refused := httptest.NewServer(http.NotFoundHandler())
+    refused.Close()
+    untrusted := httptest.NewTLSServer(http.NotFoundHandler())
+    t.Cleanup(untrusted.Close)
+    stale := testTime.Add(-2 * time.Hour).Format(time.RFC3339)
+
+    // unresolvable fails every lookup, so the test doesn't depend on the resolver of the host
+    unresolvable := &http.Transport{DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+        host, _, _ := net.SplitHostPort(address)
+        return nil, &net.OpError{Op: "dial", Net: network, Err: &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}}
+    }}
+
+    tests := []struct {
+        name      string
+        url       string
+        transport http.RoundTripper
+        maxAge    string
+        result    string
+        // class is the error class counted by gateway_check_errors_total, empty when none is
+        class string
+    }{
+        {name: "timeout", url: hung.URL, result: "timeout", class: "timeout"},
+        {name: "dns failure", url: "http://gateway.invalid/status", transport: unresolvable, result: "http_error", class: "dns"},
+        {name: "connection refused", url: refused.URL, result: "http_error", class: "connection"},
+        {name: "tls error", url: untrusted.URL, result: "http_error", class: "other"},
+        {name: "non-2xx status", url: serve(t, http.StatusServiceUnavailable, `{"online": true}`).URL, result: "http_error", class: "http_status"},
+        {name: "parse error", url: serve(t, http.StatusOK, `online`).URL, result: "parse_error", class: "parse_error"},
+        {name: "stale data", url: serve(t, http.StatusOK, `{"online": true, "updatedAt": "`+stale+`"}`).URL, maxAge: "1h", result: "success"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            c, m, _ := newTestChecker(&http.Client{Timeout: 200 * time.Millisecond, Transport: tt.transport})
+            gateway, check := httpGateway(tt.url)
+            check.MaxAge = tt.maxAge
+
+            online, _ := c.FetchGatewayLinkStatus(context.Background(), gateway, check)
+            if online {
+                t.Error("gateway reported online")
+            }
+            labels := []string{"gw1", "p", tt.url, "http"}
+            for _, result := range []string{"success", "http_error", "timeout", "parse_error"} {
+                want := 0.0
+                if result == tt.result {
+                    want = 1
+                }
+                if got := counterValue(t, m.GatewayCheckTotal.WithLabelValues(append(labels, result)...)); got != want {
+                    t.Errorf("gateway_check_total{result=%q} = %v, want %v", result, got, want)
+                }
+            }
+            for _, class := range []string{"timeout", "dns", "http_status", "parse_error", "connection", "other"} {
+                want := 0.0
+                if class == tt.class {
+                    want = 1
+                }
+                if got := counterValue(t, m.GatewayCheckErrors.WithLabelValues(append(labels, class)...)); got != want {
+                    t.Errorf("gateway_check_errors_total{class=%q} = %v, want %v", class, got, want)
+                }
+            }
+            if tt.maxAge != "" {
+                if got := gaugeValue(t, m.GatewayStatusStale.WithLabelValues(labels...)); got != 1 {
+                    t.Errorf("gateway_status_stale = %v, want 1", got)
+                }
+            }
+        })
+    }
+}
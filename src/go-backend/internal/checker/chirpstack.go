@@ -0,0 +1,67 @@
+package checker
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "time"
+
+    "gateway-monitor/internal/config"
+)
+
+// chirpStackGateway is the part of the ChirpStack REST API gateway response the check uses
+type chirpStackGateway struct {
+    LastSeenAt *time.Time `json:"lastSeenAt"`
+}
+
+// fetchChirpStack reports the gateway online when ChirpStack heard from it within the check window.
+// The token is sent the way the ChirpStack REST API gateway expects it.
+func (c *Checker) fetchChirpStack(ctx context.Context, gateway config.Gateway, check config.Check) (bool, int, error) {
+    gatewayURL := check.ChirpStackGatewayURL()
+    c.logger.Printf("Fetching ChirpStack gateway %s from URL: %s", gateway.Name, gatewayURL)
+
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, gatewayURL, nil)
+    if err != nil {
+        return false, -1, fmt.Errorf("invalid request for URL %s: %w", gatewayURL, err)
+    }
+    if key := check.ResolveAPIKey(); key != "" {
+        req.Header.Set("Grpc-Metadata-Authorization", "Bearer "+key)
+    }
+    authorize(req, check)
+
+    client, err := c.httpClient(check)
+    if err != nil {
+        return false, -1, err
+    }
+    resp, err := client.Do(req)
+    if err != nil {
+        return false, -1, fmt.Errorf("failed to fetch data from URL %s: %w", gatewayURL, err)
+    }
+    defer resp.Body.Close()
+
+    body, err := io.ReadAll(resp.Body)
+    if err != nil {
+        return false, len(body), fmt.Errorf("failed to read response body from URL %s: %w", gatewayURL, err)
+    }
+    if resp.StatusCode != http.StatusOK {
+        return false, len(body), &StatusError{Code: resp.StatusCode, URL: gatewayURL}
+    }
+
+    var result chirpStackGateway
+    if err := json.Unmarshal(body, &result); err != nil {
+        return false, len(body), &ParseError{Err: err}
+    }
+    if result.LastSeenAt == nil {
+        c.logger.Printf("ChirpStack has never seen gateway %s", gateway.Name)
+        return false, len(body), nil
+    }
+    c.markSeen(gateway, check, *result.LastSeenAt)
+
+    window, err := check.StatusWindow()
+    if err != nil {
+        return false, len(body), err
+    }
+    return c.clock.Now().Sub(*result.LastSeenAt) <= window, len(body), nil
+}
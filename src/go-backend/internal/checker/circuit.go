@@ -0,0 +1,74 @@
+package checker
+
+import (
+    "errors"
+    "fmt"
+    "time"
+
+    "gateway-monitor/internal/config"
+    "gateway-monitor/internal/metrics"
+)
+
+// ErrCircuitOpen is returned instead of running a check whose circuit is open
+var ErrCircuitOpen = errors.New("circuit open")
+
+// circuit tracks how long a check has been failing
+type circuit struct {
+    failingSince time.Time
+    lastAttempt  time.Time
+    open         bool
+}
+
+// circuitOpen reports whether the check's circuit is open and it should be skipped this time.
+// An open circuit lets a single probe through every CircuitProbeInterval.
+func (c *Checker) circuitOpen(gateway config.Gateway, check config.Check) bool {
+    if c.CircuitOpenAfter <= 0 {
+        return false
+    }
+    c.circuitsMu.Lock()
+    defer c.circuitsMu.Unlock()
+    state, ok := c.circuits[checkKey{gateway: gateway.Name, target: check.Target()}]
+    return ok && state.open && c.clock.Now().Sub(state.lastAttempt) < c.CircuitProbeInterval
+}
+
+// updateCircuit records the outcome of a check, opening its circuit once it has failed for
+// CircuitOpenAfter and closing it again on the first success. Offline answers and responses
+// without a status are successes here: the endpoint works, it's the gateway that is down.
+func (c *Checker) updateCircuit(gateway config.Gateway, check config.Check, labels []string, err error) {
+    if c.CircuitOpenAfter <= 0 {
+        return
+    }
+    now := c.clock.Now()
+    key := checkKey{gateway: gateway.Name, target: check.Target()}
+
+    c.circuitsMu.Lock()
+    defer c.circuitsMu.Unlock()
+    state := c.circuits[key]
+    switch {
+    case err == nil || errors.Is(err, ErrNoStatus):
+        if state.open {
+            c.logger.Printf("Check %s of %s succeeded again, closing its circuit", check.Target(), gateway.Name)
+        }
+        delete(c.circuits, key)
+        c.metrics.GatewayCheckCircuitOpen.WithLabelValues(labels...).Set(0)
+        return
+    case state.failingSince.IsZero():
+        state.failingSince = now
+    case !state.open && now.Sub(state.failingSince) >= c.CircuitOpenAfter:
+        state.open = true
+        c.logger.Printf("WARNING: check %s of %s has failed for %s, probing it every %s until it recovers: %v",
+            check.Target(), gateway.Name, now.Sub(state.failingSince).Round(time.Second), c.CircuitProbeInterval, err)
+    }
+    state.lastAttempt = now
+    c.circuits[key] = state
+    c.metrics.GatewayCheckCircuitOpen.WithLabelValues(labels...).Set(metrics.Bool(state.open))
+}
+
+// circuitError describes the skipped check of an open circuit
+func (c *Checker) circuitError(gateway config.Gateway, check config.Check) error {
+    c.circuitsMu.Lock()
+    defer c.circuitsMu.Unlock()
+    state := c.circuits[checkKey{gateway: gateway.Name, target: check.Target()}]
+    return fmt.Errorf("%w: failing since %s, next probe at %s", ErrCircuitOpen,
+        state.failingSince.Format(time.RFC3339), state.lastAttempt.Add(c.CircuitProbeInterval).Format(time.RFC3339))
+}
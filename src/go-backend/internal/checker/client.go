@@ -0,0 +1,162 @@
+package checker
+
+import (
+    "context"
+    "net/http"
+    "net/url"
+    "time"
+
+    "gateway-monitor/internal/config"
+)
+
+// transportKey identifies the transport settings a check needs
+type transportKey struct {
+    connectTimeout time.Duration
+    proxy          string
+    tls            tlsKey
+    network        networkKey
+}
+
+// transportKeyOf returns the transport settings of a check, the zero key when it uses the
+// shared transport
+func transportKeyOf(check config.Check) (transportKey, error) {
+    connectTimeout, err := check.DialTimeout()
+    if err != nil {
+        return transportKey{}, err
+    }
+    if _, err := check.ProxyURL(); err != nil {
+        return transportKey{}, err
+    }
+    tlsSettings, err := tlsKeyOf(check.TLS)
+    if err != nil {
+        return transportKey{}, err
+    }
+    return transportKey{connectTimeout: connectTimeout, proxy: check.Proxy, tls: tlsSettings, network: networkKeyOf(check)}, nil
+}
+
+// statusKey is the context key of the *int HTTP responses record their status code in
+type statusKey struct{}
+
+// withStatusRecorder returns a context in which the status code of every response received
+// by an HTTP based check is stored in code
+func withStatusRecorder(ctx context.Context, code *int) context.Context {
+    return context.WithValue(ctx, statusKey{}, code)
+}
+
+// recordingTransport stores the status code of each response in the request's recorder
+type recordingTransport struct {
+    next http.RoundTripper
+}
+
+func (t recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+    resp, err := t.next.RoundTrip(req)
+    if code, ok := req.Context().Value(statusKey{}).(*int); ok && resp != nil {
+        *code = resp.StatusCode
+    }
+    return resp, err
+}
+
+// httpClient returns the client for an HTTP based check: the shared one with the check's own
+// timeout and transport settings applied and a transport recording the response status.
+// Transports are shared between checks with the same settings so connections are still reused.
+func (c *Checker) httpClient(check config.Check) (*http.Client, error) {
+    timeout, err := check.CheckTimeout(c.client.Timeout)
+    if err != nil {
+        return nil, err
+    }
+    key, err := transportKeyOf(check)
+    if err != nil {
+        return nil, err
+    }
+
+    client := *c.client
+    client.Timeout = timeout
+    var transport http.RoundTripper = c.baseTransport()
+    if c.client.Transport != nil {
+        transport = c.client.Transport
+    }
+    if key != (transportKey{}) {
+        if transport, err = c.transport(key); err != nil {
+            return nil, err
+        }
+    }
+    if c.RateLimiter != nil {
+        transport = rateLimitedTransport{next: transport, limiter: c.RateLimiter}
+    }
+    client.Transport = recordingTransport{next: transport}
+    return &client, nil
+}
+
+// baseTransport is the transport of the shared client
+func (c *Checker) baseTransport() *http.Transport {
+    if transport, ok := c.client.Transport.(*http.Transport); ok {
+        return transport
+    }
+    return http.DefaultTransport.(*http.Transport)
+}
+
+// proxy returns the proxy function for a check's proxy setting, the global one when it is empty
+func (c *Checker) proxy(setting string) func(*http.Request) (*url.URL, error) {
+    switch setting {
+    case "":
+        return c.baseTransport().Proxy
+    case config.ProxyDirect:
+        return nil
+    }
+    // Validated with the configuration
+    proxyURL, _ := url.Parse(setting)
+    return http.ProxyURL(proxyURL)
+}
+
+// authorize adds the check's headers and credentials to a request
+func authorize(req *http.Request, check config.Check) {
+    for name, value := range check.Headers {
+        req.Header.Set(name, value)
+    }
+    if check.Auth == nil {
+        return
+    }
+    switch check.Auth.Type {
+    case "basic":
+        req.SetBasicAuth(check.Auth.Username, check.Auth.ResolveSecret())
+    case "bearer":
+        req.Header.Set("Authorization", "Bearer "+check.Auth.ResolveSecret())
+    }
+}
+
+// transport returns the shared transport for the settings, replacing the one of an earlier
+// version of the same TLS files
+func (c *Checker) transport(key transportKey) (http.RoundTripper, error) {
+    c.transportsMu.Lock()
+    defer c.transportsMu.Unlock()
+    if transport, ok := c.transports[key]; ok {
+        return transport, nil
+    }
+
+    tlsConfig, err := c.clientTLS(key.tls)
+    if err != nil {
+        return nil, err
+    }
+    for existing, transport := range c.transports {
+        if existing.tls != (tlsKey{}) && existing.connectTimeout == key.connectTimeout && existing.proxy == key.proxy &&
+            existing.network == key.network &&
+            existing.tls.certFile == key.tls.certFile && existing.tls.keyFile == key.tls.keyFile && existing.tls.caFile == key.tls.caFile {
+            transport.CloseIdleConnections()
+            delete(c.transports, existing)
+        }
+    }
+
+    transport := c.baseTransport().Clone()
+    if tlsConfig != nil {
+        transport.TLSClientConfig = tlsConfig
+    }
+    if key.connectTimeout > 0 || key.network != (networkKey{}) {
+        transport.DialContext = key.network.dial(key.connectTimeout)
+    }
+    if key.connectTimeout > 0 {
+        transport.TLSHandshakeTimeout = key.connectTimeout
+    }
+    transport.Proxy = c.proxy(key.proxy)
+    c.transports[key] = transport
+    return transport, nil
+}
@@ -0,0 +1,77 @@
+package checker
+
+import (
+    "context"
+    "net"
+    "strings"
+
+    "gateway-monitor/internal/config"
+)
+
+// resolveDNS reports the gateway online when the check hostname resolves, and, if a record is
+// expected, when it is among the answers. The resolution time is exported per gateway.
+func (c *Checker) resolveDNS(ctx context.Context, gateway config.Gateway, check config.Check) (bool, error) {
+    timeout, err := check.CheckTimeout(c.client.Timeout)
+    if err != nil {
+        return false, err
+    }
+    ctx, cancel := context.WithTimeout(ctx, timeout)
+    defer cancel()
+
+    start := c.clock.Now()
+    answers, err := lookup(ctx, networkKeyOf(check).netResolver(), check.DNSRecordType(), check.Host)
+    elapsed := c.clock.Now().Sub(start)
+    c.metrics.GatewayDNSResolution.WithLabelValues(gateway.Name, gateway.Project, check.Host).Set(elapsed.Seconds())
+    if err != nil {
+        c.logger.Printf("DNS lookup of %s for %s failed: %v", check.Host, gateway.Name, err)
+        return false, nil
+    }
+
+    if check.ExpectRecord == "" {
+        c.logger.Printf("DNS lookup of %s for %s returned %s in %s", check.Host, gateway.Name, strings.Join(answers, ", "), elapsed)
+        return true, nil
+    }
+    for _, answer := range answers {
+        if strings.EqualFold(strings.TrimSuffix(answer, "."), strings.TrimSuffix(check.ExpectRecord, ".")) {
+            return true, nil
+        }
+    }
+    c.logger.Printf("DNS lookup of %s for %s returned %s, expected %s", check.Host, gateway.Name, strings.Join(answers, ", "), check.ExpectRecord)
+    return false, nil
+}
+
+// lookup resolves host for one record type and returns the answers as strings
+func lookup(ctx context.Context, resolver *net.Resolver, recordType, host string) ([]string, error) {
+    var answers []string
+    switch recordType {
+    case "A", "AAAA":
+        network := "ip4"
+        if recordType == "AAAA" {
+            network = "ip6"
+        }
+        ips, err := resolver.LookupIP(ctx, network, host)
+        if err != nil {
+            return nil, err
+        }
+        for _, ip := range ips {
+            answers = append(answers, ip.String())
+        }
+    case "CNAME":
+        cname, err := resolver.LookupCNAME(ctx, host)
+        if err != nil {
+            return nil, err
+        }
+        answers = append(answers, cname)
+    case "MX":
+        records, err := resolver.LookupMX(ctx, host)
+        if err != nil {
+            return nil, err
+        }
+        for _, mx := range records {
+            answers = append(answers, mx.Host)
+        }
+    case "TXT":
+        return resolver.LookupTXT(ctx, host)
+    }
+    return answers, nil
+}
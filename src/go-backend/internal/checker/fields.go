@@ -0,0 +1,159 @@
+package checker
+
+import (
+    "encoding/json"
+    "fmt"
+    "sort"
+    "time"
+
+    "gateway-monitor/internal/config"
+)
+
+// sample is one observed value of a field
+type sample struct {
+    at    time.Time
+    value float64
+}
+
+// fieldKey identifies the samples of one field of one check
+type fieldKey struct {
+    gateway string
+    target  string
+    field   string
+}
+
+// evaluateFields exports the numeric fields of a check response and records which rules are violated.
+// Samples are kept per field only as long as the rule's window or max_delta_age needs them.
+func (c *Checker) evaluateFields(gateway config.Gateway, check config.Check, body []byte) {
+    if len(check.Fields) == 0 {
+        return
+    }
+
+    var doc interface{}
+    if err := json.Unmarshal(body, &doc); err != nil {
+        doc = nil
+    }
+
+    now := c.clock.Now()
+    c.fieldsMu.Lock()
+    defer c.fieldsMu.Unlock()
+
+    // Forget fields that were removed from the check
+    configured := map[string]bool{}
+    for _, rule := range check.Fields {
+        configured[rule.Label()] = true
+    }
+    for key := range c.samples {
+        if key.gateway == gateway.Name && key.target == check.Target() && !configured[key.field] {
+            delete(c.samples, key)
+            delete(c.violations, key)
+        }
+    }
+
+    for _, rule := range check.Fields {
+        key := fieldKey{gateway: gateway.Name, target: check.Target(), field: rule.Label()}
+
+        value, ok := lookupField(doc, gateway.Name, rule.Path)
+        if !ok {
+            c.metrics.GatewayCheckValue.DeleteLabelValues(gateway.Name, gateway.Project, rule.Label())
+            c.setViolation(key, fmt.Sprintf("%s missing from response", rule.Label()))
+            continue
+        }
+        c.metrics.GatewayCheckValue.WithLabelValues(gateway.Name, gateway.Project, rule.Label()).Set(value)
+
+        window, maxDeltaAge, _ := rule.Durations()
+        samples := pruneSamples(append(c.samples[key], sample{at: now, value: value}), now, maxDuration(window, maxDeltaAge))
+        c.samples[key] = samples
+        c.setViolation(key, violation(rule, samples, now, window, maxDeltaAge))
+    }
+}
+
+// Degraded reports the violated field rules of the gateway, sorted; none means not degraded
+func (c *Checker) Degraded(gateway config.Gateway) []string {
+    c.fieldsMu.Lock()
+    defer c.fieldsMu.Unlock()
+
+    var reasons []string
+    for key, reason := range c.violations {
+        if key.gateway == gateway.Name {
+            reasons = append(reasons, reason)
+        }
+    }
+    sort.Strings(reasons)
+    return reasons
+}
+
+// setViolation records the reason a rule fails, or clears it when reason is empty
+func (c *Checker) setViolation(key fieldKey, reason string) {
+    if reason == "" {
+        delete(c.violations, key)
+        return
+    }
+    c.violations[key] = reason
+}
+
+// violation evaluates a rule against the samples, newest last
+func violation(rule config.FieldRule, samples []sample, now time.Time, window, maxDeltaAge time.Duration) string {
+    latest := samples[len(samples)-1]
+
+    // min and max fail only when no sample within the window is in range
+    inRange := false
+    for _, s := range samples {
+        if now.Sub(s.at) > window && s != latest {
+            continue
+        }
+        if (rule.Min == nil || s.value >= *rule.Min) && (rule.Max == nil || s.value <= *rule.Max) {
+            inRange = true
+            break
+        }
+    }
+    if !inRange {
+        if rule.Min != nil && latest.value < *rule.Min {
+            return fmt.Sprintf("%s is %g, below minimum %g", rule.Label(), latest.value, *rule.Min)
+        }
+        return fmt.Sprintf("%s is %g, above maximum %g", rule.Label(), latest.value, *rule.Max)
+    }
+
+    if maxDeltaAge > 0 {
+        // Only judge a counter once it has been observed for at least max_delta_age
+        if now.Sub(samples[0].at) < maxDeltaAge {
+            return ""
+        }
+        for i := 1; i < len(samples); i++ {
+            if samples[i].value > samples[i-1].value && now.Sub(samples[i].at) <= maxDeltaAge {
+                return ""
+            }
+        }
+        return fmt.Sprintf("%s has not increased in %s", rule.Label(), maxDeltaAge)
+    }
+    return ""
+}
+
+// pruneSamples drops samples older than keep, retaining the newest of them as a baseline
+func pruneSamples(samples []sample, now time.Time, keep time.Duration) []sample {
+    first := 0
+    for first < len(samples)-1 && now.Sub(samples[first+1].at) >= keep {
+        first++
+    }
+    return samples[first:]
+}
+
+// lookupField finds a numeric value at path, at the top level or under the gateway name
+func lookupField(doc interface{}, gatewayName, path string) (float64, bool) {
+    value, ok := lookupJSONPath(doc, path)
+    if !ok {
+        value, ok = lookupJSONPath(doc, gatewayName+"."+path)
+    }
+    if !ok {
+        return 0, false
+    }
+    number, ok := value.(float64)
+    return number, ok
+}
+
+func maxDuration(a, b time.Duration) time.Duration {
+    if a > b {
+        return a
+    }
+    return b
+}
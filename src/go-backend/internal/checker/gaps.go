@@ -0,0 +1,98 @@
+package checker
+
+import (
+    "fmt"
+    "sort"
+    "time"
+
+    "gateway-monitor/internal/config"
+    "gateway-monitor/internal/metrics"
+)
+
+// minGapSamples is how many gaps between updates are learned before a gap can be anomalous
+const minGapSamples = 5
+
+// gapSmoothing is the weight of the latest gap in the baseline
+const gapSmoothing = 0.2
+
+// updateGap is the learned interval between the updates a check's status source reports
+type updateGap struct {
+    last     time.Time
+    baseline time.Duration
+    samples  int
+}
+
+// observeUpdate learns from a time the status source reports the gateway was last updated.
+// Gaps are capped at the anomaly threshold before they enter the baseline, so one long
+// outage does not teach that long gaps are normal while a lasting slowdown still does.
+func (c *Checker) observeUpdate(gateway config.Gateway, check config.Check, at time.Time) {
+    if c.UpdateGapFactor <= 0 || at.IsZero() {
+        return
+    }
+    key := checkKey{gateway: gateway.Name, target: check.Target()}
+
+    c.gapsMu.Lock()
+    defer c.gapsMu.Unlock()
+    gap := c.gaps[key]
+    if !at.After(gap.last) {
+        return
+    }
+    if !gap.last.IsZero() {
+        d := at.Sub(gap.last)
+        if gap.samples == 0 {
+            gap.baseline = d
+        } else {
+            d = min(d, c.gapThreshold(gap))
+            gap.baseline += time.Duration(gapSmoothing * float64(d-gap.baseline))
+        }
+        gap.samples++
+    }
+    gap.last = at
+    c.gaps[key] = gap
+}
+
+// gapThreshold is how long a gap may grow before it is anomalous
+func (c *Checker) gapThreshold(gap updateGap) time.Duration {
+    return time.Duration(c.UpdateGapFactor * float64(gap.baseline))
+}
+
+// updateGapAnomaly returns how long the check's status source has not reported an update and
+// whether that is well beyond the learned baseline
+func (c *Checker) updateGapAnomaly(gap updateGap) (time.Duration, bool) {
+    current := c.clock.Now().Sub(gap.last)
+    return current, gap.samples >= minGapSamples && current > c.gapThreshold(gap)
+}
+
+// exportUpdateGap sets the anomaly and baseline gauges of a check that reports its updates
+func (c *Checker) exportUpdateGap(gateway config.Gateway, check config.Check, labels []string) {
+    c.gapsMu.Lock()
+    gap, ok := c.gaps[checkKey{gateway: gateway.Name, target: check.Target()}]
+    c.gapsMu.Unlock()
+    if !ok || gap.samples == 0 {
+        return
+    }
+    _, anomalous := c.updateGapAnomaly(gap)
+    c.metrics.GatewayUpdateGapAnomaly.WithLabelValues(labels...).Set(metrics.Bool(anomalous))
+    c.metrics.GatewayUpdateGapBaseline.WithLabelValues(labels...).Set(gap.baseline.Seconds())
+}
+
+// UpdateGapAnomalies describes the checks of the gateway whose status source has gone
+// unusually long without an update
+func (c *Checker) UpdateGapAnomalies(gateway config.Gateway) []string {
+    c.gapsMu.Lock()
+    defer c.gapsMu.Unlock()
+
+    var reasons []string
+    for _, check := range gateway.Checks {
+        gap, ok := c.gaps[checkKey{gateway: gateway.Name, target: check.Target()}]
+        if !ok {
+            continue
+        }
+        if current, anomalous := c.updateGapAnomaly(gap); anomalous {
+            reasons = append(reasons, fmt.Sprintf("%s check %s has not been updated for %s, usually every %s",
+                check.Type, check.Target(), current.Round(time.Second), gap.baseline.Round(time.Second)))
+        }
+    }
+    sort.Strings(reasons)
+    return reasons
+}
@@ -0,0 +1,81 @@
+package checker
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "time"
+
+    "gateway-monitor/internal/config"
+)
+
+// heliumHotspot is the part of the Helium API hotspot response the check uses
+type heliumHotspot struct {
+    Data struct {
+        Status struct {
+            Online    string     `json:"online"`
+            Timestamp *time.Time `json:"timestamp"`
+        } `json:"status"`
+        Block           int64 `json:"block"`
+        LastChangeBlock int64 `json:"last_change_block"`
+    } `json:"data"`
+}
+
+// fetchHelium reports the hotspot online when the Helium API says so. With a window, a status
+// update older than the window also counts as offline, catching hotspots that went silent.
+func (c *Checker) fetchHelium(ctx context.Context, gateway config.Gateway, check config.Check) (bool, int, error) {
+    hotspotURL := check.HeliumHotspotURL()
+    c.logger.Printf("Fetching Helium hotspot %s from URL: %s", gateway.Name, hotspotURL)
+
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, hotspotURL, nil)
+    if err != nil {
+        return false, -1, fmt.Errorf("invalid request for URL %s: %w", hotspotURL, err)
+    }
+    authorize(req, check)
+
+    client, err := c.httpClient(check)
+    if err != nil {
+        return false, -1, err
+    }
+    resp, err := client.Do(req)
+    if err != nil {
+        return false, -1, fmt.Errorf("failed to fetch data from URL %s: %w", hotspotURL, err)
+    }
+    defer resp.Body.Close()
+
+    body, err := io.ReadAll(resp.Body)
+    if err != nil {
+        return false, len(body), fmt.Errorf("failed to read response body from URL %s: %w", hotspotURL, err)
+    }
+    if resp.StatusCode != http.StatusOK {
+        return false, len(body), &StatusError{Code: resp.StatusCode, URL: hotspotURL}
+    }
+
+    var hotspot heliumHotspot
+    if err := json.Unmarshal(body, &hotspot); err != nil {
+        return false, len(body), &ParseError{Err: err}
+    }
+    status := hotspot.Data.Status
+    if status.Online == "" {
+        return false, len(body), ErrNoStatus
+    }
+    c.logger.Printf("Helium hotspot %s is %s, last changed at block %d (current %d)",
+        gateway.Name, status.Online, hotspot.Data.LastChangeBlock, hotspot.Data.Block)
+
+    online := status.Online == "online"
+    if status.Timestamp != nil {
+        if online {
+            c.markSeen(gateway, check, *status.Timestamp)
+        }
+        if check.Window != "" {
+            window, err := check.StatusWindow()
+            if err != nil {
+                return false, len(body), err
+            }
+            online = online && c.clock.Now().Sub(*status.Timestamp) <= window
+        }
+    }
+    return online, len(body), nil
+}
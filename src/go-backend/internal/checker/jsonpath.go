@@ -0,0 +1,32 @@
+package checker
+
+import (
+    "strconv"
+    "strings"
+)
+
+// lookupJSONPath follows a dotted path such as "data.gateways.0.online" through a decoded JSON document.
+// Numeric segments index into arrays; a leading "$." as in JSONPath is accepted.
+func lookupJSONPath(doc interface{}, path string) (interface{}, bool) {
+    path = strings.TrimPrefix(path, "$.")
+    current := doc
+    for _, segment := range strings.Split(path, ".") {
+        switch node := current.(type) {
+        case map[string]interface{}:
+            value, ok := node[segment]
+            if !ok {
+                return nil, false
+            }
+            current = value
+        case []interface{}:
+            index, err := strconv.Atoi(segment)
+            if err != nil || index < 0 || index >= len(node) {
+                return nil, false
+            }
+            current = node[index]
+        default:
+            return nil, false
+        }
+    }
+    return current, true
+}
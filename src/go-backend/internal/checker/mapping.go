@@ -0,0 +1,98 @@
+package checker
+
+import (
+    "encoding/json"
+    "fmt"
+    "strconv"
+    "time"
+
+    "gateway-monitor/internal/config"
+)
+
+// parseMappedStatus interprets a response with the check's status_field and timestamp_field
+// instead of the 'online' flag. Like the flag, each field is looked up at the top level of the
+// document first and under the gateway name otherwise. It returns the zero time when the check
+// has no timestamp_field.
+func parseMappedStatus(body []byte, gatewayName string, check config.Check) (bool, time.Time, error) {
+    var doc interface{}
+    if err := json.Unmarshal(body, &doc); err != nil {
+        return false, time.Time{}, &ParseError{Err: err}
+    }
+
+    var timestamp time.Time
+    if check.TimestampField != "" {
+        value, ok := lookupMapped(doc, gatewayName, check.TimestampField)
+        if !ok {
+            return false, time.Time{}, &ParseError{Err: fmt.Errorf("no timestamp at %s", check.TimestampField)}
+        }
+        var err error
+        if timestamp, err = parseTimestamp(value); err != nil {
+            return false, time.Time{}, &ParseError{Err: fmt.Errorf("%s: %w", check.TimestampField, err)}
+        }
+    }
+
+    if check.StatusField == "" {
+        online, err := ParseOnlineStatus(body, gatewayName)
+        return online, timestamp, err
+    }
+    value, ok := lookupMapped(doc, gatewayName, check.StatusField)
+    if !ok {
+        return false, timestamp, ErrNoStatus
+    }
+    if check.StatusTrueValue == "" {
+        online, ok := value.(bool)
+        if !ok {
+            return false, timestamp, &ParseError{Err: fmt.Errorf("%s is %v, not a boolean; set status_true_value", check.StatusField, value)}
+        }
+        return online, timestamp, nil
+    }
+    return formatJSONValue(value) == check.StatusTrueValue, timestamp, nil
+}
+
+// lookupMapped finds a value at path, at the top level or under the gateway name
+func lookupMapped(doc interface{}, gatewayName, path string) (interface{}, bool) {
+    if value, ok := lookupJSONPath(doc, path); ok {
+        return value, true
+    }
+    return lookupJSONPath(doc, gatewayName+"."+path)
+}
+
+// parseTimestamp accepts RFC 3339 strings and Unix timestamps in seconds or milliseconds
+func parseTimestamp(value interface{}) (time.Time, error) {
+    switch v := value.(type) {
+    case string:
+        if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+            return t, nil
+        }
+        seconds, err := strconv.ParseFloat(v, 64)
+        if err != nil {
+            return time.Time{}, fmt.Errorf("unrecognised timestamp %q", v)
+        }
+        return unixTimestamp(seconds), nil
+    case float64:
+        return unixTimestamp(v), nil
+    }
+    return time.Time{}, fmt.Errorf("unrecognised timestamp %v", value)
+}
+
+// unixTimestamp treats values too large to be seconds as milliseconds
+func unixTimestamp(value float64) time.Time {
+    if value > 1e11 {
+        return time.UnixMilli(int64(value))
+    }
+    return time.Unix(0, int64(value*float64(time.Second)))
+}
+
+// formatJSONValue renders a decoded JSON scalar the way it appears in the document
+func formatJSONValue(value interface{}) string {
+    switch v := value.(type) {
+    case string:
+        return v
+    case float64:
+        return strconv.FormatFloat(v, 'f', -1, 64)
+    case nil:
+        return "null"
+    default:
+        return fmt.Sprint(v)
+    }
+}
@@ -0,0 +1,226 @@
+package checker
+
+import (
+    "fmt"
+    "log"
+    "net"
+    "sync"
+    "time"
+
+    mqtt "github.com/eclipse/paho.mqtt.golang"
+
+    "gateway-monitor/internal/clock"
+    "gateway-monitor/internal/config"
+    "gateway-monitor/internal/metrics"
+)
+
+// mqttTimeout bounds connecting, subscribing and unsubscribing
+const mqttTimeout = 10 * time.Second
+
+// Subscriptions owns the MQTT connections used by mqtt checks, one per broker and credentials
+type Subscriptions struct {
+    metrics *metrics.Metrics
+    clock   clock.Clock
+    logger  *log.Logger
+
+    mu      sync.Mutex
+    brokers map[brokerKey]*broker
+}
+
+// brokerKey identifies a connection; checks with different credentials or network settings
+// need their own
+type brokerKey struct {
+    url      string
+    username string
+    password string
+    network  networkKey
+}
+
+// brokerKeyOf returns the key of the connection an mqtt check uses
+func brokerKeyOf(check config.Check) brokerKey {
+    return brokerKey{url: check.URL, username: check.Username, password: check.ResolveAPIKey(), network: networkKeyOf(check)}
+}
+
+// broker records when a message last arrived on each subscribed topic
+type broker struct {
+    client mqtt.Client
+    logger *log.Logger
+    clock  clock.Clock
+
+    mu       sync.RWMutex
+    topics   map[string]bool
+    lastSeen map[string]time.Time
+}
+
+// NewSubscriptions creates an empty set of MQTT connections
+func NewSubscriptions(m *metrics.Metrics, clk clock.Clock, logger *log.Logger) *Subscriptions {
+    return &Subscriptions{
+        metrics: m,
+        clock:   clk,
+        logger:  logger,
+        brokers: map[brokerKey]*broker{},
+    }
+}
+
+// Apply connects to every broker used by the mqtt checks and subscribes to their topics.
+// Brokers that are unreachable are retried in the background; brokers no longer used are disconnected.
+func (s *Subscriptions) Apply(gatewaysFile *config.GatewaysFile) error {
+    wanted := map[brokerKey]map[string]bool{}
+    for _, gateway := range gatewaysFile.Gateways {
+        for _, check := range gateway.Checks {
+            if check.Type != "mqtt" {
+                continue
+            }
+            key := brokerKeyOf(check)
+            if wanted[key] == nil {
+                wanted[key] = map[string]bool{}
+            }
+            wanted[key][check.Topic] = true
+        }
+    }
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    for key, b := range s.brokers {
+        if wanted[key] == nil {
+            b.client.Disconnect(250)
+            delete(s.brokers, key)
+            s.logger.Printf("Disconnected from MQTT broker %s", key.url)
+        }
+    }
+    for key, topics := range wanted {
+        b, ok := s.brokers[key]
+        if !ok {
+            dialer, err := key.network.dialer("tcp", mqttTimeout)
+            if err != nil {
+                // Tried again with the next configuration applied
+                s.logger.Printf("WARNING: Not connecting to MQTT broker %s: %v", key.url, err)
+                continue
+            }
+            b = s.connect(key, dialer)
+            s.brokers[key] = b
+        }
+        b.setTopics(topics)
+    }
+    return nil
+}
+
+// connect starts a client that keeps reconnecting and resubscribes whenever it connects
+func (s *Subscriptions) connect(key brokerKey, dialer *net.Dialer) *broker {
+    b := &broker{
+        logger:   s.logger,
+        clock:    s.clock,
+        topics:   map[string]bool{},
+        lastSeen: map[string]time.Time{},
+    }
+
+    opts := mqtt.NewClientOptions().
+        AddBroker(key.url).
+        SetClientID(fmt.Sprintf("loracheck-%d", time.Now().UnixNano())).
+        SetUsername(key.username).
+        SetPassword(key.password).
+        SetConnectTimeout(mqttTimeout).
+        SetDialer(dialer).
+        SetConnectRetry(true).
+        SetAutoReconnect(true).
+        SetOnConnectHandler(func(mqtt.Client) {
+            s.logger.Printf("Connected to MQTT broker %s", key.url)
+            b.resubscribe()
+        }).
+        SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+            s.logger.Printf("Lost connection to MQTT broker %s: %v", key.url, err)
+        })
+    b.client = mqtt.NewClient(opts)
+    b.client.Connect()
+    return b
+}
+
+// setTopics subscribes to new topics and unsubscribes from the ones no longer checked
+func (b *broker) setTopics(topics map[string]bool) {
+    b.mu.Lock()
+    var added, removed []string
+    for topic := range topics {
+        if !b.topics[topic] {
+            added = append(added, topic)
+        }
+    }
+    for topic := range b.topics {
+        if !topics[topic] {
+            removed = append(removed, topic)
+            delete(b.lastSeen, topic)
+        }
+    }
+    b.topics = topics
+    b.mu.Unlock()
+
+    if !b.client.IsConnectionOpen() {
+        // The connect handler subscribes once the connection is up
+        return
+    }
+    for _, topic := range added {
+        b.subscribe(topic)
+    }
+    if len(removed) > 0 {
+        b.client.Unsubscribe(removed...).WaitTimeout(mqttTimeout)
+    }
+}
+
+func (b *broker) resubscribe() {
+    b.mu.RLock()
+    topics := make([]string, 0, len(b.topics))
+    for topic := range b.topics {
+        topics = append(topics, topic)
+    }
+    b.mu.RUnlock()
+
+    for _, topic := range topics {
+        b.subscribe(topic)
+    }
+}
+
+// subscribe records the arrival time of every message matching the topic filter
+func (b *broker) subscribe(topic string) {
+    token := b.client.Subscribe(topic, 0, func(_ mqtt.Client, _ mqtt.Message) {
+        b.mu.Lock()
+        if b.topics[topic] {
+            b.lastSeen[topic] = b.clock.Now()
+        }
+        b.mu.Unlock()
+    })
+    if token.WaitTimeout(mqttTimeout) && token.Error() != nil {
+        b.logger.Printf("Failed to subscribe to MQTT topic %s: %v", topic, token.Error())
+    }
+}
+
+// LastSeen returns when a message last arrived on the check's topic
+func (s *Subscriptions) LastSeen(check config.Check) (time.Time, bool) {
+    s.mu.Lock()
+    b, ok := s.brokers[brokerKeyOf(check)]
+    s.mu.Unlock()
+    if !ok {
+        return time.Time{}, false
+    }
+
+    b.mu.RLock()
+    defer b.mu.RUnlock()
+    seen, ok := b.lastSeen[check.Topic]
+    return seen, ok
+}
+
+// Status reports a gateway online if a message arrived on its topic within the check window
+func (s *Subscriptions) Status(gateway config.Gateway, check config.Check) (bool, error) {
+    window, err := check.StatusWindow()
+    if err != nil {
+        return false, fmt.Errorf("invalid mqtt window: %w", err)
+    }
+
+    seen, ok := s.LastSeen(check)
+    if !ok {
+        return false, fmt.Errorf("no message received on MQTT topic %s yet", check.Topic)
+    }
+
+    since := s.clock.Now().Sub(seen)
+    s.logger.Printf("Gateway %s last published %s ago", gateway.Name, since.Round(time.Second))
+    return since <= window, nil
+}
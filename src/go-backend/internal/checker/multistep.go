@@ -0,0 +1,154 @@
+package checker
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "net/http"
+    "net/http/cookiejar"
+    "reflect"
+    "sort"
+    "strings"
+    "text/template"
+
+    "gateway-monitor/internal/config"
+)
+
+// runSteps executes a multi-step check. The steps share a cookie jar and every variable
+// extracted so far; the assertions of the final step decide whether the gateway is online.
+func (c *Checker) runSteps(ctx context.Context, gateway config.Gateway, check config.Check) (bool, error) {
+    jar, err := cookiejar.New(nil)
+    if err != nil {
+        return false, err
+    }
+    base, err := c.httpClient(check)
+    if err != nil {
+        return false, err
+    }
+    client := *base
+    client.Jar = jar
+
+    vars := map[string]interface{}{}
+    for i, step := range check.Steps {
+        label := step.Label(i)
+        final := i == len(check.Steps)-1
+
+        start := c.clock.Now()
+        status, doc, err := c.runStep(ctx, &client, check, step, vars)
+        elapsed := c.clock.Now().Sub(start)
+        c.metrics.GatewayCheckStepDuration.WithLabelValues(gateway.Name, gateway.Project, label).Set(elapsed.Seconds())
+        c.logger.Printf("Step %d (%s) for %s finished in %s", i+1, label, gateway.Name, elapsed)
+
+        if err != nil {
+            return false, fmt.Errorf("step %d (%s): %w", i+1, label, err)
+        }
+        if !statusMatches(step.ExpectedStatus, status) {
+            return false, fmt.Errorf("step %d (%s): unexpected HTTP status %d", i+1, label, status)
+        }
+
+        for variable, path := range step.Extract {
+            value, ok := lookupJSONPath(doc, path)
+            if !ok {
+                return false, fmt.Errorf("step %d (%s): %w", i+1, label, &ParseError{Err: fmt.Errorf("no value at %s to extract into %s", path, variable)})
+            }
+            vars[variable] = value
+        }
+
+        if mismatches := unmetExpectations(doc, step.Expect); len(mismatches) > 0 {
+            if final {
+                c.logger.Printf("Final step (%s) for %s reports offline: %s", label, gateway.Name, strings.Join(mismatches, "; "))
+                return false, nil
+            }
+            return false, fmt.Errorf("step %d (%s): %w", i+1, label, &ParseError{Err: errors.New(strings.Join(mismatches, "; "))})
+        }
+    }
+
+    return true, nil
+}
+
+// runStep renders and sends one request, returning the status and the decoded JSON body when needed
+func (c *Checker) runStep(ctx context.Context, client *http.Client, check config.Check, step config.Step, vars map[string]interface{}) (int, interface{}, error) {
+    url, err := render(step.URL, vars)
+    if err != nil {
+        return 0, nil, fmt.Errorf("url: %w", err)
+    }
+    body, err := render(step.Body, vars)
+    if err != nil {
+        return 0, nil, fmt.Errorf("body: %w", err)
+    }
+
+    req, err := http.NewRequestWithContext(ctx, step.HTTPMethod(), url, strings.NewReader(body))
+    if err != nil {
+        return 0, nil, fmt.Errorf("invalid request: %w", err)
+    }
+    authorize(req, check)
+    for name, value := range step.Headers {
+        rendered, err := render(value, vars)
+        if err != nil {
+            return 0, nil, fmt.Errorf("header %s: %w", name, err)
+        }
+        req.Header.Set(name, rendered)
+    }
+
+    resp, err := client.Do(req)
+    if err != nil {
+        return 0, nil, fmt.Errorf("request to %s failed: %w", url, err)
+    }
+    defer resp.Body.Close()
+
+    data, err := io.ReadAll(resp.Body)
+    if err != nil {
+        return resp.StatusCode, nil, fmt.Errorf("failed to read response body: %w", err)
+    }
+
+    if len(step.Extract) == 0 && len(step.Expect) == 0 {
+        return resp.StatusCode, nil, nil
+    }
+    var doc interface{}
+    if err := json.Unmarshal(data, &doc); err != nil {
+        return resp.StatusCode, nil, &ParseError{Err: err}
+    }
+    return resp.StatusCode, doc, nil
+}
+
+// render executes a step template against the extracted variables
+func render(text string, vars map[string]interface{}) (string, error) {
+    if !strings.Contains(text, "{{") {
+        return text, nil
+    }
+    tmpl, err := template.New("step").Option("missingkey=error").Parse(text)
+    if err != nil {
+        return "", err
+    }
+    var buf bytes.Buffer
+    if err := tmpl.Execute(&buf, vars); err != nil {
+        return "", err
+    }
+    return buf.String(), nil
+}
+
+// statusMatches accepts any 2xx status unless a specific one is expected
+func statusMatches(expected, status int) bool {
+    if expected == 0 {
+        return status >= 200 && status < 300
+    }
+    return status == expected
+}
+
+// unmetExpectations lists the JSON paths whose values differ from the expected ones
+func unmetExpectations(doc interface{}, expect map[string]interface{}) []string {
+    var mismatches []string
+    for path, want := range expect {
+        got, ok := lookupJSONPath(doc, path)
+        if !ok {
+            mismatches = append(mismatches, fmt.Sprintf("%s missing", path))
+        } else if !reflect.DeepEqual(got, want) {
+            mismatches = append(mismatches, fmt.Sprintf("%s is %v, expected %v", path, got, want))
+        }
+    }
+    sort.Strings(mismatches)
+    return mismatches
+}
@@ -0,0 +1,165 @@
+package checker
+
+import (
+    "context"
+    "fmt"
+    "net"
+    "strings"
+    "syscall"
+    "time"
+
+    "gateway-monitor/internal/config"
+)
+
+// networkKey identifies the settings binding the connections of a check to an IP version,
+// resolver and local address
+type networkKey struct {
+    ipVersion string
+    resolver  string
+    sourceIP  string
+    iface     string
+}
+
+// networkKeyOf returns the network settings of a check; the zero key uses the system defaults
+func networkKeyOf(check config.Check) networkKey {
+    return networkKey{ipVersion: check.IPVersion, resolver: check.Resolver, sourceIP: check.SourceIP, iface: check.Interface}
+}
+
+// dialer returns a dialer for connections on network with the settings applied. The address
+// of an interface is looked up each time, so a VPN reconnecting with a new one is followed.
+func (k networkKey) dialer(network string, timeout time.Duration) (*net.Dialer, error) {
+    dialer := &net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second, Resolver: k.netResolver()}
+    if k.ipVersion != "" {
+        dialer.Control = k.control
+    }
+    source, err := k.source()
+    if err != nil {
+        return nil, err
+    }
+    if source != nil {
+        if strings.HasPrefix(network, "udp") {
+            dialer.LocalAddr = &net.UDPAddr{IP: source}
+        } else {
+            dialer.LocalAddr = &net.TCPAddr{IP: source}
+        }
+    }
+    return dialer, nil
+}
+
+// dial is a DialContext with the settings applied
+func (k networkKey) dial(timeout time.Duration) func(ctx context.Context, network, address string) (net.Conn, error) {
+    return func(ctx context.Context, network, address string) (net.Conn, error) {
+        dialer, err := k.dialer(network, timeout)
+        if err != nil {
+            return nil, err
+        }
+        return dialer.DialContext(ctx, network, address)
+    }
+}
+
+// control refuses connections of the other IP version, so the dialer moves on to the next
+// address of a name that has both
+func (k networkKey) control(network, address string, _ syscall.RawConn) error {
+    if !strings.HasSuffix(network, k.ipVersion) {
+        return fmt.Errorf("connection to %s is not IPv%s", address, k.ipVersion)
+    }
+    return nil
+}
+
+// netResolver returns the resolver for the check: the system one unless a resolver or local
+// address is set, in which case queries leave from the same local address as the connections.
+// The IP version only applies to the answers, the resolver keeps its own.
+func (k networkKey) netResolver() *net.Resolver {
+    if k.resolver == "" && k.sourceIP == "" && k.iface == "" {
+        return net.DefaultResolver
+    }
+    queries := networkKey{sourceIP: k.sourceIP, iface: k.iface}
+    return &net.Resolver{
+        PreferGo: true,
+        Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+            if k.resolver != "" {
+                address = k.resolver
+            }
+            dialer, err := queries.dialer(network, 0)
+            if err != nil {
+                return nil, err
+            }
+            return dialer.DialContext(ctx, network, address)
+        },
+    }
+}
+
+// source returns the local address connections are bound to, nil for any
+func (k networkKey) source() (net.IP, error) {
+    if k.sourceIP != "" {
+        // Validated with the configuration
+        return net.ParseIP(k.sourceIP), nil
+    }
+    if k.iface == "" {
+        return nil, nil
+    }
+    iface, err := net.InterfaceByName(k.iface)
+    if err != nil {
+        return nil, fmt.Errorf("interface %s: %w", k.iface, err)
+    }
+    addrs, err := iface.Addrs()
+    if err != nil {
+        return nil, fmt.Errorf("interface %s: %w", k.iface, err)
+    }
+    var ipv6 net.IP
+    for _, addr := range addrs {
+        ipNet, ok := addr.(*net.IPNet)
+        if !ok || ipNet.IP.IsLinkLocalUnicast() {
+            continue
+        }
+        if ipNet.IP.To4() != nil {
+            if k.ipVersion != config.IPv6 {
+                return ipNet.IP, nil
+            }
+        } else if ipv6 == nil && k.ipVersion != config.IPv4 {
+            ipv6 = ipNet.IP
+        }
+    }
+    if ipv6 == nil {
+        return nil, fmt.Errorf("interface %s has no usable %s address", k.iface, k.versionName())
+    }
+    return ipv6, nil
+}
+
+// lookupIP resolves host to one address of the IP version, of the source address's when only
+// that is set, preferring IPv4 when either will do
+func (k networkKey) lookupIP(ctx context.Context, host string) (net.IP, net.IP, error) {
+    source, err := k.source()
+    if err != nil {
+        return nil, nil, err
+    }
+    version := k.ipVersion
+    if version == "" && source != nil {
+        version = config.IPv6
+        if source.To4() != nil {
+            version = config.IPv4
+        }
+    }
+
+    ips, err := k.netResolver().LookupIP(ctx, "ip"+version, host)
+    if err != nil {
+        return nil, nil, fmt.Errorf("failed to resolve %s: %w", host, err)
+    }
+    for _, ip := range ips {
+        if ip.To4() != nil {
+            return ip, source, nil
+        }
+    }
+    if len(ips) == 0 {
+        return nil, nil, fmt.Errorf("no addresses found for %s", host)
+    }
+    return ips[0], source, nil
+}
+
+// versionName describes the IP version for errors
+func (k networkKey) versionName() string {
+    if k.ipVersion == "" {
+        return "IP"
+    }
+    return "IPv" + k.ipVersion
+}
@@ -0,0 +1,99 @@
+package checker
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "net/http"
+    "time"
+
+    "gateway-monitor/internal/config"
+    "gateway-monitor/internal/metrics"
+)
+
+// packetBrokerGateway is the part of the Packet Broker Mapper gateway response the check uses
+type packetBrokerGateway struct {
+    Online    *bool      `json:"online"`
+    UpdatedAt *time.Time `json:"updatedAt"`
+    ClusterID string     `json:"clusterID"`
+    RxRate    *float64   `json:"rxRate"`
+    TxRate    *float64   `json:"txRate"`
+}
+
+// fetchPacketBroker reports the gateway peered when the Packet Broker Mapper lists it as online.
+// A gateway its network does not share with Packet Broker is unknown to the Mapper, so it
+// counts as not peered even when its own network sees it online.
+func (c *Checker) fetchPacketBroker(ctx context.Context, gateway config.Gateway, check config.Check) (bool, int, error) {
+    peered, size, err := c.queryPacketBroker(ctx, gateway, check)
+    if !errors.Is(err, ErrNoStatus) {
+        c.metrics.GatewayPeeringStatus.WithLabelValues(gateway.Name, gateway.Project).Set(metrics.Bool(peered && err == nil))
+    }
+    return peered, size, err
+}
+
+func (c *Checker) queryPacketBroker(ctx context.Context, gateway config.Gateway, check config.Check) (bool, int, error) {
+    gatewayURL := check.PacketBrokerGatewayURL()
+    c.logger.Printf("Fetching Packet Broker gateway %s from URL: %s", gateway.Name, gatewayURL)
+
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, gatewayURL, nil)
+    if err != nil {
+        return false, -1, fmt.Errorf("invalid request for URL %s: %w", gatewayURL, err)
+    }
+    authorize(req, check)
+
+    client, err := c.httpClient(check)
+    if err != nil {
+        return false, -1, err
+    }
+    resp, err := client.Do(req)
+    if err != nil {
+        return false, -1, fmt.Errorf("failed to fetch data from URL %s: %w", gatewayURL, err)
+    }
+    defer resp.Body.Close()
+
+    body, err := io.ReadAll(resp.Body)
+    if err != nil {
+        return false, len(body), fmt.Errorf("failed to read response body from URL %s: %w", gatewayURL, err)
+    }
+    if resp.StatusCode == http.StatusNotFound {
+        return false, len(body), fmt.Errorf("gateway %s is not known to Packet Broker, its traffic is not peered", check.GatewayID)
+    }
+    if resp.StatusCode != http.StatusOK {
+        return false, len(body), &StatusError{Code: resp.StatusCode, URL: gatewayURL}
+    }
+
+    var pbGateway packetBrokerGateway
+    if err := json.Unmarshal(body, &pbGateway); err != nil {
+        return false, len(body), &ParseError{Err: err}
+    }
+    if pbGateway.Online == nil {
+        return false, len(body), ErrNoStatus
+    }
+    online := *pbGateway.Online
+    c.logger.Printf("Packet Broker lists gateway %s in cluster %s as online: %v, uplinks/h: %s, downlinks/h: %s",
+        gateway.Name, pbGateway.ClusterID, online, formatRate(pbGateway.RxRate), formatRate(pbGateway.TxRate))
+
+    if pbGateway.UpdatedAt != nil {
+        if online {
+            c.markSeen(gateway, check, *pbGateway.UpdatedAt)
+        }
+        if check.Window != "" {
+            window, err := check.StatusWindow()
+            if err != nil {
+                return false, len(body), err
+            }
+            online = online && c.clock.Now().Sub(*pbGateway.UpdatedAt) <= window
+        }
+    }
+    return online, len(body), nil
+}
+
+// formatRate formats a traffic rate the Mapper may leave out
+func formatRate(rate *float64) string {
+    if rate == nil {
+        return "unknown"
+    }
+    return fmt.Sprintf("%.1f", *rate)
+}
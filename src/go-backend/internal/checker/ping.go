@@ -0,0 +1,126 @@
+package checker
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "net"
+    "os"
+    "time"
+
+    "golang.org/x/net/icmp"
+    "golang.org/x/net/ipv4"
+    "golang.org/x/net/ipv6"
+
+    "gateway-monitor/internal/config"
+)
+
+// pingCount is how many echo requests a ping check sends before giving up
+const pingCount = 3
+
+// ping sends ICMP echo requests to the check host and reports it online on the first reply.
+// Unprivileged ICMP sockets are used where the kernel allows them, raw sockets otherwise.
+func (c *Checker) ping(ctx context.Context, gateway config.Gateway, check config.Check) (bool, error) {
+    timeout, err := check.CheckTimeout(c.client.Timeout)
+    if err != nil {
+        return false, err
+    }
+    ctx, cancel := context.WithTimeout(ctx, timeout)
+    defer cancel()
+
+    ip, source, err := networkKeyOf(check).lookupIP(ctx, check.Host)
+    if err != nil {
+        return false, err
+    }
+
+    conn, privileged, err := listenICMP(ip, source)
+    if err != nil {
+        return false, err
+    }
+    defer conn.Close()
+
+    var dst net.Addr = &net.UDPAddr{IP: ip}
+    if privileged {
+        dst = &net.IPAddr{IP: ip}
+    }
+    var echoType, replyType icmp.Type = ipv4.ICMPTypeEcho, ipv4.ICMPTypeEchoReply
+    protocol := 1
+    if ip.To4() == nil {
+        echoType, replyType, protocol = ipv6.ICMPTypeEchoRequest, ipv6.ICMPTypeEchoReply, 58
+    }
+
+    deadline, _ := ctx.Deadline()
+    perAttempt := time.Until(deadline) / pingCount
+    id := os.Getpid() & 0xffff
+    buf := make([]byte, 1500)
+    for seq := 1; seq <= pingCount; seq++ {
+        msg := icmp.Message{Type: echoType, Body: &icmp.Echo{ID: id, Seq: seq, Data: []byte("loracheck")}}
+        data, err := msg.Marshal(nil)
+        if err != nil {
+            return false, err
+        }
+
+        start := c.clock.Now()
+        if _, err := conn.WriteTo(data, dst); err != nil {
+            return false, fmt.Errorf("failed to send echo request to %s: %w", ip, err)
+        }
+        conn.SetReadDeadline(start.Add(perAttempt))
+        for {
+            n, peer, err := conn.ReadFrom(buf)
+            if err != nil {
+                var netErr net.Error
+                if errors.As(err, &netErr) && netErr.Timeout() {
+                    break
+                }
+                return false, fmt.Errorf("failed to read echo reply from %s: %w", ip, err)
+            }
+            if !sameIP(peer, ip) {
+                continue
+            }
+            reply, err := icmp.ParseMessage(protocol, buf[:n])
+            if err != nil || reply.Type != replyType {
+                continue
+            }
+            // Unprivileged sockets rewrite the identifier, so only the sequence number is compared there
+            if echo, ok := reply.Body.(*icmp.Echo); ok && echo.Seq == seq && (!privileged || echo.ID == id) {
+                rtt := c.clock.Now().Sub(start)
+                c.metrics.GatewayPingRTT.WithLabelValues(gateway.Name, gateway.Project, check.Host).Set(rtt.Seconds())
+                c.logger.Printf("Ping reply from %s (%s) for %s in %s", check.Host, ip, gateway.Name, rtt)
+                return true, nil
+            }
+        }
+    }
+
+    c.logger.Printf("No ping reply from %s (%s) for %s", check.Host, ip, gateway.Name)
+    return false, nil
+}
+
+// listenICMP opens an ICMP socket for the address family of ip, bound to source when it is set
+func listenICMP(ip, source net.IP) (*icmp.PacketConn, bool, error) {
+    network, raw, address := "udp4", "ip4:icmp", "0.0.0.0"
+    if ip.To4() == nil {
+        network, raw, address = "udp6", "ip6:ipv6-icmp", "::"
+    }
+    if source != nil {
+        address = source.String()
+    }
+    if conn, err := icmp.ListenPacket(network, address); err == nil {
+        return conn, false, nil
+    }
+    conn, err := icmp.ListenPacket(raw, address)
+    if err != nil {
+        return nil, false, fmt.Errorf("failed to open ICMP socket (needs net.ipv4.ping_group_range or CAP_NET_RAW): %w", err)
+    }
+    return conn, true, nil
+}
+
+// sameIP reports whether a packet came from ip
+func sameIP(addr net.Addr, ip net.IP) bool {
+    switch a := addr.(type) {
+    case *net.UDPAddr:
+        return a.IP.Equal(ip)
+    case *net.IPAddr:
+        return a.IP.Equal(ip)
+    }
+    return false
+}
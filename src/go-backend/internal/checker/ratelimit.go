@@ -0,0 +1,127 @@
+package checker
+
+import (
+    "context"
+    "fmt"
+    "net/http"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+
+    "golang.org/x/time/rate"
+
+    "gateway-monitor/internal/metrics"
+)
+
+// RateLimiter throttles the requests of HTTP based checks, in total and per host, so large
+// configurations stay below the rate limits of shared APIs such as The Things Network's.
+// Requests wait for their turn rather than failing, bounded by the check's timeout.
+type RateLimiter struct {
+    global  *rate.Limiter
+    perHost rate.Limit
+    // hosts are the limits of specific hosts, which also apply to their subdomains
+    hosts   map[string]rate.Limit
+    metrics *metrics.Metrics
+
+    mu       sync.Mutex
+    limiters map[string]*rate.Limiter
+}
+
+// NewRateLimiter creates a limiter allowing global requests per second in total and perHost
+// to each host, unless hosts sets a limit for it; zero leaves requests unlimited
+func NewRateLimiter(global, perHost float64, hosts map[string]float64, m *metrics.Metrics) *RateLimiter {
+    r := &RateLimiter{
+        perHost:  rate.Limit(perHost),
+        hosts:    map[string]rate.Limit{},
+        metrics:  m,
+        limiters: map[string]*rate.Limiter{},
+    }
+    if global > 0 {
+        r.global = rate.NewLimiter(rate.Limit(global), 1)
+    }
+    for host, limit := range hosts {
+        r.hosts[strings.ToLower(host)] = rate.Limit(limit)
+    }
+    return r
+}
+
+// ParseHostLimits parses per-host limits written as host=requests per second pairs separated by commas
+func ParseHostLimits(s string) (map[string]float64, error) {
+    limits := map[string]float64{}
+    for _, pair := range strings.Split(s, ",") {
+        pair = strings.TrimSpace(pair)
+        if pair == "" {
+            continue
+        }
+        host, value, ok := strings.Cut(pair, "=")
+        host = strings.TrimSpace(host)
+        limit, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+        if !ok || host == "" || err != nil || limit <= 0 {
+            return nil, fmt.Errorf("invalid limit %q, expected host=requests per second", pair)
+        }
+        limits[host] = limit
+    }
+    return limits, nil
+}
+
+// Wait blocks until a request to host may be sent or the context is done
+func (r *RateLimiter) Wait(ctx context.Context, host string) error {
+    key, limiter := r.limiter(strings.ToLower(host))
+    start := time.Now()
+    defer func() {
+        if waited := time.Since(start); waited > time.Millisecond {
+            r.metrics.RateLimitWait.WithLabelValues(key).Add(waited.Seconds())
+        }
+    }()
+
+    if limiter != nil {
+        if err := limiter.Wait(ctx); err != nil {
+            return fmt.Errorf("rate limit of %s: %w", key, err)
+        }
+    }
+    if r.global != nil {
+        if err := r.global.Wait(ctx); err != nil {
+            return fmt.Errorf("global rate limit: %w", err)
+        }
+    }
+    return nil
+}
+
+// limiter returns the limiter of a host and the name it is shared under: the configured host
+// covering it, or the host itself for the per-host limit. It returns nil when the host is unlimited.
+func (r *RateLimiter) limiter(host string) (string, *rate.Limiter) {
+    key, limit := host, r.perHost
+    matched := ""
+    for configured, configuredLimit := range r.hosts {
+        // The most specific configured host wins
+        if (host == configured || strings.HasSuffix(host, "."+configured)) && len(configured) > len(matched) {
+            matched, key, limit = configured, configured, configuredLimit
+        }
+    }
+    if limit <= 0 {
+        return key, nil
+    }
+
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    limiter, ok := r.limiters[key]
+    if !ok {
+        limiter = rate.NewLimiter(limit, 1)
+        r.limiters[key] = limiter
+    }
+    return key, limiter
+}
+
+// rateLimitedTransport waits for the rate limiter before every request
+type rateLimitedTransport struct {
+    next    http.RoundTripper
+    limiter *RateLimiter
+}
+
+func (t rateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+    if err := t.limiter.Wait(req.Context(), req.URL.Hostname()); err != nil {
+        return nil, err
+    }
+    return t.next.RoundTrip(req)
+}
@@ -0,0 +1,112 @@
+package checker
+
+import (
+    "errors"
+    "time"
+
+    "gateway-monitor/internal/config"
+)
+
+// CheckResult is the outcome of the last run of a check
+type CheckResult struct {
+    Status    string        `json:"status"`
+    Error     string        `json:"error,omitempty"`
+    CheckedAt time.Time     `json:"checked_at"`
+    Duration  time.Duration `json:"-"`
+}
+
+// StatusOf names the outcome of a check: "online", "offline", "error" or "no status"
+func StatusOf(online bool, err error) string {
+    switch {
+    case errors.Is(err, ErrNoStatus):
+        return "no status"
+    case err != nil:
+        return "error"
+    case online:
+        return "online"
+    }
+    return "offline"
+}
+
+// Result returns the outcome of the last run of the check
+func (c *Checker) Result(gateway config.Gateway, check config.Check) (CheckResult, bool) {
+    c.resultsMu.Lock()
+    defer c.resultsMu.Unlock()
+    result, ok := c.results[checkKey{gateway: gateway.Name, target: check.Target()}]
+    return result, ok
+}
+
+func (c *Checker) recordResult(gateway config.Gateway, check config.Check, online bool, err error, start time.Time) {
+    result := CheckResult{
+        Status:    StatusOf(online, err),
+        CheckedAt: c.clock.Now(),
+    }
+    result.Duration = result.CheckedAt.Sub(start)
+    if err != nil {
+        result.Error = err.Error()
+    }
+
+    c.resultsMu.Lock()
+    defer c.resultsMu.Unlock()
+    c.results[checkKey{gateway: gateway.Name, target: check.Target()}] = result
+}
+
+// Prune drops the results, last seen times, circuits and field samples of checks that are no longer
+// configured for any of the gateways
+func (c *Checker) Prune(gateways []config.Gateway) {
+    configured := map[checkKey]bool{}
+    for _, gateway := range gateways {
+        for _, check := range gateway.Checks {
+            configured[checkKey{gateway: gateway.Name, target: check.Target()}] = true
+        }
+    }
+
+    c.resultsMu.Lock()
+    for key := range c.results {
+        if !configured[key] {
+            delete(c.results, key)
+        }
+    }
+    c.resultsMu.Unlock()
+
+    c.seenMu.Lock()
+    for key := range c.seen {
+        if !configured[key] {
+            delete(c.seen, key)
+        }
+    }
+    c.seenMu.Unlock()
+
+    c.circuitsMu.Lock()
+    for key := range c.circuits {
+        if !configured[key] {
+            delete(c.circuits, key)
+        }
+    }
+    c.circuitsMu.Unlock()
+
+    c.trafficMu.Lock()
+    for key := range c.traffic {
+        if !configured[key] {
+            delete(c.traffic, key)
+        }
+    }
+    c.trafficMu.Unlock()
+
+    c.gapsMu.Lock()
+    for key := range c.gaps {
+        if !configured[key] {
+            delete(c.gaps, key)
+        }
+    }
+    c.gapsMu.Unlock()
+
+    c.fieldsMu.Lock()
+    for key := range c.samples {
+        if !configured[checkKey{gateway: key.gateway, target: key.target}] {
+            delete(c.samples, key)
+            delete(c.violations, key)
+        }
+    }
+    c.fieldsMu.Unlock()
+}
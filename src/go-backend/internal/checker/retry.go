@@ -0,0 +1,41 @@
+package checker
+
+import (
+    "context"
+    "errors"
+    "math/rand"
+    "time"
+
+    "gateway-monitor/internal/config"
+)
+
+// attempt runs one try of a check and returns its status, response size and error
+type attempt func() (bool, int, error)
+
+// withRetries runs try until the check reports online or its retries are exhausted, waiting an
+// exponentially growing, jittered delay between attempts. Responses that cannot be interpreted
+// are not retried since another attempt would not change them.
+func (c *Checker) withRetries(ctx context.Context, gateway config.Gateway, check config.Check, labels []string, try attempt) (bool, int, error) {
+    backoff, err := check.RetryBackoffDuration()
+    if err != nil {
+        return false, -1, err
+    }
+
+    for i := 0; ; i++ {
+        online, size, err := try()
+        var parseErr *ParseError
+        if (online && err == nil) || errors.Is(err, ErrNoStatus) || errors.As(err, &parseErr) || i >= check.Retries {
+            return online, size, err
+        }
+
+        delay := backoff << i
+        delay = delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+        c.metrics.GatewayCheckRetries.WithLabelValues(labels...).Inc()
+        c.logger.Printf("Attempt %d of %d for %s did not report online, retrying in %s", i+1, check.Retries+1, gateway.Name, delay.Round(time.Millisecond))
+        select {
+        case <-ctx.Done():
+            return online, size, err
+        case <-c.clock.After(delay):
+        }
+    }
+}
@@ -0,0 +1,116 @@
+package checker
+
+import (
+    "context"
+    "fmt"
+    "io"
+    "net/http"
+    "sort"
+    "strings"
+    "time"
+
+    "gateway-monitor/internal/config"
+)
+
+// sharedKey identifies the requests of http checks that get the same response: the URL, the
+// headers once the check's credentials are added and the client settings
+type sharedKey struct {
+    url       string
+    headers   string
+    timeout   time.Duration
+    transport transportKey
+}
+
+// sharedResponse is a response fetched once per cycle for every http check requesting it
+type sharedResponse struct {
+    done   chan struct{}
+    status int
+    body   []byte
+    err    error
+}
+
+// NewCycle forgets the responses shared during the previous cycle, so each URL is fetched
+// again once per cycle
+func (c *Checker) NewCycle() {
+    c.responsesMu.Lock()
+    defer c.responsesMu.Unlock()
+    c.responses = map[sharedKey]*sharedResponse{}
+}
+
+// sharedKeyOf returns the key of an http check's request
+func sharedKeyOf(req *http.Request, check config.Check, client *http.Client) (sharedKey, error) {
+    transport, err := transportKeyOf(check)
+    if err != nil {
+        return sharedKey{}, err
+    }
+    headers := make([]string, 0, len(req.Header))
+    for name, values := range req.Header {
+        headers = append(headers, name+": "+strings.Join(values, ", "))
+    }
+    sort.Strings(headers)
+    return sharedKey{
+        url:       req.URL.String(),
+        headers:   strings.Join(headers, "\n"),
+        timeout:   client.Timeout,
+        transport: transport,
+    }, nil
+}
+
+// fetchShared sends the request of an http check, unless another check sent the same request
+// in this cycle, in which case it waits for that response and returns it too. Failed requests
+// and unsuccessful statuses are not kept, so retries and later checks try again.
+func (c *Checker) fetchShared(ctx context.Context, req *http.Request, check config.Check, client *http.Client) (int, []byte, error) {
+    key, err := sharedKeyOf(req, check, client)
+    if err != nil {
+        return 0, nil, err
+    }
+
+    c.responsesMu.Lock()
+    shared, ok := c.responses[key]
+    if !ok {
+        shared = &sharedResponse{done: make(chan struct{})}
+        c.responses[key] = shared
+    }
+    c.responsesMu.Unlock()
+
+    if ok {
+        select {
+        case <-shared.done:
+        case <-ctx.Done():
+            return 0, nil, fmt.Errorf("failed to fetch data from URL %s: %w", check.URL, ctx.Err())
+        }
+        if shared.err == nil {
+            c.logger.Printf("Reusing the response from URL %s fetched by another check in this cycle", check.URL)
+            if code, recording := ctx.Value(statusKey{}).(*int); recording {
+                *code = shared.status
+            }
+        }
+        return shared.status, shared.body, shared.err
+    }
+
+    shared.status, shared.body, shared.err = fetch(client, req, check)
+    if shared.err != nil || shared.status < 200 || shared.status >= 300 {
+        c.responsesMu.Lock()
+        if c.responses[key] == shared {
+            delete(c.responses, key)
+        }
+        c.responsesMu.Unlock()
+    }
+    close(shared.done)
+    return shared.status, shared.body, shared.err
+}
+
+// fetch sends the request and reads the whole response
+func fetch(client *http.Client, req *http.Request, check config.Check) (int, []byte, error) {
+    resp, err := client.Do(req)
+    if err != nil {
+        return 0, nil, fmt.Errorf("failed to fetch data from URL %s: %w", check.URL, err)
+    }
+    defer resp.Body.Close()
+
+    body, err := io.ReadAll(resp.Body)
+    if err != nil {
+        return resp.StatusCode, body, fmt.Errorf("failed to read response body from URL %s: %w", check.URL, err)
+    }
+    return resp.StatusCode, body, nil
+}
@@ -0,0 +1,144 @@
+package checker
+
+import (
+    "context"
+    "fmt"
+    "math/big"
+    "net"
+    "os"
+    "strconv"
+    "strings"
+
+    "github.com/gosnmp/gosnmp"
+
+    "gateway-monitor/internal/config"
+)
+
+// snmpAuthProtocols and snmpPrivProtocols map the configured names to gosnmp's constants
+var (
+    snmpAuthProtocols = map[string]gosnmp.SnmpV3AuthProtocol{
+        "":       gosnmp.NoAuth,
+        "MD5":    gosnmp.MD5,
+        "SHA":    gosnmp.SHA,
+        "SHA224": gosnmp.SHA224,
+        "SHA256": gosnmp.SHA256,
+        "SHA384": gosnmp.SHA384,
+        "SHA512": gosnmp.SHA512,
+    }
+    snmpPrivProtocols = map[string]gosnmp.SnmpV3PrivProtocol{
+        "":       gosnmp.NoPriv,
+        "DES":    gosnmp.DES,
+        "AES":    gosnmp.AES,
+        "AES192": gosnmp.AES192,
+        "AES256": gosnmp.AES256,
+    }
+)
+
+// pollSNMP reads the check OID from the router at host. The gateway's backhaul is online when
+// the router answers and, if expect_value is set, the value matches it, e.g. 1 for ifOperStatus up.
+// No answer counts as offline rather than as a failed check.
+func (c *Checker) pollSNMP(ctx context.Context, gateway config.Gateway, check config.Check) (bool, error) {
+    client, err := c.snmpClient(ctx, check)
+    if err != nil {
+        return false, err
+    }
+    if err := client.Connect(); err != nil {
+        return false, fmt.Errorf("failed to open SNMP connection to %s: %w", check.Host, err)
+    }
+    defer client.Conn.Close()
+
+    result, err := client.Get([]string{check.OID})
+    if err != nil {
+        c.logger.Printf("SNMP request to %s for %s failed: %v", check.Host, gateway.Name, err)
+        return false, nil
+    }
+    if len(result.Variables) != 1 {
+        return false, &ParseError{Err: fmt.Errorf("expected one SNMP variable, got %d", len(result.Variables))}
+    }
+    variable := result.Variables[0]
+    switch variable.Type {
+    case gosnmp.NoSuchObject, gosnmp.NoSuchInstance, gosnmp.EndOfMibView:
+        return false, fmt.Errorf("OID %s not available on %s: %s", check.OID, check.Host, variable.Type)
+    }
+
+    value := snmpValue(variable)
+    if number, err := strconv.ParseFloat(value, 64); err == nil {
+        c.metrics.GatewaySNMPValue.WithLabelValues(gateway.Name, gateway.Project, check.OID).Set(number)
+    }
+    c.logger.Printf("SNMP %s on %s for %s is %s", check.OID, check.Host, gateway.Name, value)
+    return check.ExpectValue == "" || value == check.ExpectValue, nil
+}
+
+// snmpClient builds the v2c or v3 session described by the check
+func (c *Checker) snmpClient(ctx context.Context, check config.Check) (*gosnmp.GoSNMP, error) {
+    timeout, err := check.CheckTimeout(c.client.Timeout)
+    if err != nil {
+        return nil, err
+    }
+    host, port, err := check.SNMPAddress()
+    if err != nil {
+        return nil, err
+    }
+
+    client := &gosnmp.GoSNMP{
+        Context: ctx,
+        Target:  host,
+        Port:    port,
+        Timeout: timeout,
+        Retries: 1,
+        MaxOids: gosnmp.MaxOids,
+    }
+    if network := networkKeyOf(check); network != (networkKey{}) {
+        // gosnmp resolves the target with the system resolver, so it is given the address
+        ip, source, err := network.lookupIP(ctx, host)
+        if err != nil {
+            return nil, err
+        }
+        client.Target = ip.String()
+        if source != nil {
+            client.LocalAddr = net.JoinHostPort(source.String(), "0")
+        }
+    }
+    if check.SNMPVersion != "3" {
+        client.Version = gosnmp.Version2c
+        client.Community = check.ResolveAPIKey()
+        if client.Community == "" {
+            client.Community = "public"
+        }
+        return client, nil
+    }
+
+    auth, priv := snmpAuthProtocols[strings.ToUpper(check.AuthProtocol)], snmpPrivProtocols[strings.ToUpper(check.PrivProtocol)]
+    flags := gosnmp.NoAuthNoPriv
+    if auth != gosnmp.NoAuth {
+        flags = gosnmp.AuthNoPriv
+        if priv != gosnmp.NoPriv {
+            flags = gosnmp.AuthPriv
+        }
+    }
+    client.Version = gosnmp.Version3
+    client.SecurityModel = gosnmp.UserSecurityModel
+    client.MsgFlags = flags
+    client.SecurityParameters = &gosnmp.UsmSecurityParameters{
+        UserName:                 check.Username,
+        AuthenticationProtocol:   auth,
+        AuthenticationPassphrase: check.ResolveAPIKey(),
+        PrivacyProtocol:          priv,
+        PrivacyPassphrase:        os.Getenv(check.PrivPassphraseEnv),
+    }
+    return client, nil
+}
+
+// snmpValue formats a variable for comparison with expect_value
+func snmpValue(variable gosnmp.SnmpPDU) string {
+    switch value := variable.Value.(type) {
+    case []byte:
+        return string(value)
+    case net.IP:
+        return value.String()
+    case *big.Int:
+        return value.String()
+    default:
+        return fmt.Sprint(value)
+    }
+}
@@ -0,0 +1,30 @@
+package checker
+
+import (
+    "context"
+
+    "gateway-monitor/internal/config"
+)
+
+// dialTCP reports the gateway online when a TCP connection to host:port can be established.
+// A refused or timed out connection counts as offline rather than as a failed check.
+func (c *Checker) dialTCP(ctx context.Context, gateway config.Gateway, check config.Check) (bool, error) {
+    timeout, err := check.CheckTimeout(c.client.Timeout)
+    if err != nil {
+        return false, err
+    }
+
+    dialer, err := networkKeyOf(check).dialer("tcp", timeout)
+    if err != nil {
+        return false, err
+    }
+    conn, err := dialer.DialContext(ctx, "tcp", check.Host)
+    if err != nil {
+        c.logger.Printf("TCP connection to %s for %s failed: %v", check.Host, gateway.Name, err)
+        return false, nil
+    }
+    conn.Close()
+
+    c.logger.Printf("TCP connection to %s for %s succeeded", check.Host, gateway.Name)
+    return true, nil
+}
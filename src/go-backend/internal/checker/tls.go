@@ -0,0 +1,75 @@
+package checker
+
+import (
+    "crypto/tls"
+    "crypto/x509"
+    "fmt"
+    "os"
+    "time"
+
+    "gateway-monitor/internal/config"
+)
+
+// tlsKey identifies the TLS settings of a check, including when its files last changed so a
+// renewed certificate or CA bundle gets a new transport
+type tlsKey struct {
+    certFile string
+    keyFile  string
+    caFile   string
+    modified time.Time
+}
+
+// tlsKeyOf returns the key of the check's TLS settings, the zero key when it has none
+func tlsKeyOf(settings *config.TLSClient) (tlsKey, error) {
+    if settings == nil {
+        return tlsKey{}, nil
+    }
+    key := tlsKey{certFile: settings.CertFile, keyFile: settings.KeyFile, caFile: settings.CAFile}
+    for _, path := range []string{settings.CertFile, settings.KeyFile, settings.CAFile} {
+        if path == "" {
+            continue
+        }
+        info, err := os.Stat(path)
+        if err != nil {
+            return tlsKey{}, fmt.Errorf("tls: %w", err)
+        }
+        if info.ModTime().After(key.modified) {
+            key.modified = info.ModTime()
+        }
+    }
+    return key, nil
+}
+
+// clientTLS loads the client certificate and CA bundle of a check into a TLS configuration
+// based on the shared transport's; it returns nil when the check has no TLS settings
+func (c *Checker) clientTLS(key tlsKey) (*tls.Config, error) {
+    if key == (tlsKey{}) {
+        return nil, nil
+    }
+
+    var tlsConfig *tls.Config
+    if base := c.baseTransport().TLSClientConfig; base != nil {
+        tlsConfig = base.Clone()
+    } else {
+        tlsConfig = &tls.Config{}
+    }
+    if key.certFile != "" {
+        cert, err := tls.LoadX509KeyPair(key.certFile, key.keyFile)
+        if err != nil {
+            return nil, fmt.Errorf("loading client certificate %s: %w", key.certFile, err)
+        }
+        tlsConfig.Certificates = []tls.Certificate{cert}
+    }
+    if key.caFile != "" {
+        bundle, err := os.ReadFile(key.caFile)
+        if err != nil {
+            return nil, fmt.Errorf("loading CA bundle: %w", err)
+        }
+        pool := x509.NewCertPool()
+        if !pool.AppendCertsFromPEM(bundle) {
+            return nil, fmt.Errorf("CA bundle %s contains no PEM certificates", key.caFile)
+        }
+        tlsConfig.RootCAs = pool
+    }
+    return tlsConfig, nil
+}
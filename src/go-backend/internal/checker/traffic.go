@@ -0,0 +1,88 @@
+package checker
+
+import (
+    "encoding/json"
+
+    "gateway-monitor/internal/config"
+)
+
+// trafficCount holds the message counts read from the connection stats of a tts check
+type trafficCount struct {
+    uplinks   uint64
+    downlinks uint64
+}
+
+// recordTraffic adds the messages counted since the previous check to the traffic counters.
+// The Gateway Server counts from the moment the gateway connected, so a count lower than the
+// previous one means it reconnected and is added as a whole.
+func (c *Checker) recordTraffic(gateway config.Gateway, check config.Check, count trafficCount) {
+    key := checkKey{gateway: gateway.Name, target: check.Target()}
+    c.trafficMu.Lock()
+    previous := c.traffic[key]
+    c.traffic[key] = count
+    c.trafficMu.Unlock()
+
+    labels := []string{gateway.Name, gateway.Project, check.Target(), check.Type}
+    c.metrics.GatewayUplinkMessages.WithLabelValues(labels...).Add(float64(increase(previous.uplinks, count.uplinks)))
+    c.metrics.GatewayDownlinkMessages.WithLabelValues(labels...).Add(float64(increase(previous.downlinks, count.downlinks)))
+}
+
+// increase returns how much a counter grew, treating a lower value as a reset
+func increase(previous, current uint64) uint64 {
+    if current < previous {
+        return current
+    }
+    return current - previous
+}
+
+// pushData is the part of a PUSH_DATA payload the traffic metrics use: the received packets
+// and the statistics the forwarder sends periodically
+type pushData struct {
+    RXPK []struct {
+        RSSI *float64 `json:"rssi"`
+        LSNR *float64 `json:"lsnr"`
+        // RSig holds the per-antenna signal of protocol version 2 forwarders
+        RSig []struct {
+            RSSIC *float64 `json:"rssic"`
+            LSNR  *float64 `json:"lsnr"`
+        } `json:"rsig"`
+    } `json:"rxpk"`
+    Stat *struct {
+        TXNb uint64 `json:"txnb"`
+    } `json:"stat"`
+}
+
+// recordTraffic counts the uplinks and emitted downlinks of a PUSH_DATA payload and exports
+// the signal of its last uplink; labels are those of the gateway's udp-forwarder check
+func (l *ForwarderListener) recordTraffic(labels []string, payload []byte) {
+    m := l.forwarders.metrics
+    var data pushData
+    if err := json.Unmarshal(payload, &data); err != nil {
+        l.forwarders.logger.Printf("Ignoring unparsable PUSH_DATA payload from %s: %v", labels[0], err)
+        return
+    }
+
+    if len(data.RXPK) > 0 {
+        m.GatewayUplinkMessages.WithLabelValues(labels...).Add(float64(len(data.RXPK)))
+        last := data.RXPK[len(data.RXPK)-1]
+        rssi, snr := last.RSSI, last.LSNR
+        if len(last.RSig) > 0 {
+            if rssi == nil {
+                rssi = last.RSig[0].RSSIC
+            }
+            if snr == nil {
+                snr = last.RSig[0].LSNR
+            }
+        }
+        if rssi != nil {
+            m.GatewayUplinkRSSI.WithLabelValues(labels...).Set(*rssi)
+        }
+        if snr != nil {
+            m.GatewayUplinkSNR.WithLabelValues(labels...).Set(*snr)
+        }
+    }
+    if data.Stat != nil {
+        // txnb counts the packets emitted since the previous statistics
+        m.GatewayDownlinkMessages.WithLabelValues(labels...).Add(float64(data.Stat.TXNb))
+    }
+}
@@ -0,0 +1,80 @@
+package checker
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "time"
+
+    "gateway-monitor/internal/config"
+)
+
+// connectionStats is the part of the Gateway Server connection stats the check uses.
+// The message counts are 64-bit integers, which the API encodes as strings.
+type connectionStats struct {
+    ConnectedAt          *time.Time `json:"connected_at"`
+    LastStatusReceivedAt *time.Time `json:"last_status_received_at"`
+    UplinkCount          uint64     `json:"uplink_count,string"`
+    DownlinkCount        uint64     `json:"downlink_count,string"`
+}
+
+// fetchTTS asks The Things Stack whether the gateway is connected and has reported recently,
+// using the last status message or, before the first one, the time it connected.
+// The cluster answers 404 for gateways that are not connected, which counts as offline.
+func (c *Checker) fetchTTS(ctx context.Context, gateway config.Gateway, check config.Check) (bool, int, error) {
+    statsURL := check.TTSStatsURL()
+    c.logger.Printf("Fetching connection stats for %s from URL: %s", gateway.Name, statsURL)
+
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, statsURL, nil)
+    if err != nil {
+        return false, -1, fmt.Errorf("invalid request for URL %s: %w", statsURL, err)
+    }
+    if key := check.ResolveAPIKey(); key != "" {
+        req.Header.Set("Authorization", "Bearer "+key)
+    }
+    authorize(req, check)
+
+    client, err := c.httpClient(check)
+    if err != nil {
+        return false, -1, err
+    }
+    resp, err := client.Do(req)
+    if err != nil {
+        return false, -1, fmt.Errorf("failed to fetch data from URL %s: %w", statsURL, err)
+    }
+    defer resp.Body.Close()
+
+    body, err := io.ReadAll(resp.Body)
+    if err != nil {
+        return false, len(body), fmt.Errorf("failed to read response body from URL %s: %w", statsURL, err)
+    }
+    if resp.StatusCode == http.StatusNotFound {
+        c.logger.Printf("Gateway %s is not connected (%s)", gateway.Name, statsURL)
+        return false, len(body), nil
+    }
+    if resp.StatusCode != http.StatusOK {
+        return false, len(body), &StatusError{Code: resp.StatusCode, URL: statsURL}
+    }
+
+    var stats connectionStats
+    if err := json.Unmarshal(body, &stats); err != nil {
+        return false, len(body), &ParseError{Err: err}
+    }
+    c.recordTraffic(gateway, check, trafficCount{uplinks: stats.UplinkCount, downlinks: stats.DownlinkCount})
+
+    window, err := check.StatusWindow()
+    if err != nil {
+        return false, len(body), err
+    }
+    last := stats.LastStatusReceivedAt
+    if last == nil {
+        last = stats.ConnectedAt
+    }
+    if last == nil {
+        return false, len(body), ErrNoStatus
+    }
+    c.markSeen(gateway, check, *last)
+    return c.clock.Now().Sub(*last) <= window, len(body), nil
+}
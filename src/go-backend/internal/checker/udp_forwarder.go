@@ -0,0 +1,263 @@
+package checker
+
+import (
+    "encoding/hex"
+    "fmt"
+    "log"
+    "net"
+    "strconv"
+    "sync"
+    "time"
+
+    "github.com/prometheus/client_golang/prometheus"
+
+    "gateway-monitor/internal/clock"
+    "gateway-monitor/internal/config"
+    "gateway-monitor/internal/metrics"
+)
+
+// Semtech UDP packet forwarder protocol identifiers
+const (
+    semtechPushData = 0x00
+    semtechPushAck  = 0x01
+    semtechPullData = 0x02
+    semtechPullAck  = 0x04
+    semtechTxAck    = 0x05
+
+    semtechHeaderLen = 12
+)
+
+// semtechPacketTypes names the identifiers for the udp_forwarder_packets_total type label
+var semtechPacketTypes = map[byte]string{
+    semtechPushData: "push_data",
+    semtechPullData: "pull_data",
+    semtechTxAck:    "tx_ack",
+}
+
+// Forwarders owns the UDP listeners used by udp-forwarder checks, one per port
+type Forwarders struct {
+    metrics *metrics.Metrics
+    clock   clock.Clock
+    logger  *log.Logger
+
+    mu        sync.Mutex
+    listeners map[int]*ForwarderListener
+}
+
+// ForwarderListener receives Semtech UDP datagrams and records when each configured gateway EUI was last heard
+type ForwarderListener struct {
+    port       string
+    conn       *net.UDPConn
+    forwarders *Forwarders
+
+    mu sync.RWMutex
+    // known holds the check labels of each configured gateway EUI
+    known    map[string][]string
+    lastSeen map[string]time.Time
+}
+
+// NewForwarders creates an empty set of listeners
+func NewForwarders(m *metrics.Metrics, clk clock.Clock, logger *log.Logger) *Forwarders {
+    return &Forwarders{
+        metrics:   m,
+        clock:     clk,
+        logger:    logger,
+        listeners: map[int]*ForwarderListener{},
+    }
+}
+
+// Apply opens one UDP listener per port used by the udp-forwarder checks
+// and replaces the set of EUIs each listener accepts. Listeners for ports that are no longer
+// configured keep running but no longer recognise any gateway.
+func (f *Forwarders) Apply(gatewaysFile *config.GatewaysFile) error {
+    expected := map[int]map[string][]string{}
+    for _, gateway := range gatewaysFile.Gateways {
+        for _, check := range gateway.Checks {
+            if check.Type == "udp-forwarder" {
+                port := check.ForwarderPort()
+                if expected[port] == nil {
+                    expected[port] = map[string][]string{}
+                }
+                expected[port][check.EUI] = []string{gateway.Name, gateway.Project, check.Target(), check.Type}
+            }
+        }
+    }
+
+    f.mu.Lock()
+    defer f.mu.Unlock()
+
+    for port := range expected {
+        if _, ok := f.listeners[port]; ok {
+            continue
+        }
+        listener, err := f.listen(port)
+        if err != nil {
+            return err
+        }
+        f.listeners[port] = listener
+        go listener.Serve()
+    }
+
+    for port, listener := range f.listeners {
+        listener.Expect(expected[port])
+    }
+    return nil
+}
+
+// listen binds a UDP socket on the given port
+func (f *Forwarders) listen(port int) (*ForwarderListener, error) {
+    conn, err := net.ListenUDP("udp", &net.UDPAddr{Port: port})
+    if err != nil {
+        return nil, fmt.Errorf("failed to listen on UDP port %d: %v", port, err)
+    }
+
+    f.logger.Printf("Listening for packet forwarder traffic on UDP port %d", port)
+    return &ForwarderListener{
+        port:       strconv.Itoa(port),
+        conn:       conn,
+        forwarders: f,
+        known:      map[string][]string{},
+        lastSeen:   map[string]time.Time{},
+    }, nil
+}
+
+// LastSeen returns when the check's EUI last sent a datagram
+func (f *Forwarders) LastSeen(check config.Check) (time.Time, bool) {
+    f.mu.Lock()
+    listener, ok := f.listeners[check.ForwarderPort()]
+    f.mu.Unlock()
+    if !ok {
+        return time.Time{}, false
+    }
+    return listener.LastSeen(check.EUI)
+}
+
+// Status reports a gateway online if its EUI was heard within the check window
+func (f *Forwarders) Status(gateway config.Gateway, check config.Check) (bool, error) {
+    f.mu.Lock()
+    listener, ok := f.listeners[check.ForwarderPort()]
+    f.mu.Unlock()
+    if !ok {
+        return false, fmt.Errorf("no udp-forwarder listener running on port %d", check.ForwarderPort())
+    }
+
+    window, err := check.ForwarderWindow()
+    if err != nil {
+        return false, fmt.Errorf("invalid udp-forwarder window: %w", err)
+    }
+
+    seen, ok := listener.LastSeen(check.EUI)
+    if !ok {
+        return false, fmt.Errorf("gateway EUI %s has not been heard yet", check.EUI)
+    }
+
+    since := f.clock.Now().Sub(seen)
+    f.logger.Printf("Gateway %s last heard %s ago", gateway.Name, since.Round(time.Second))
+    return since <= window, nil
+}
+
+// Expect sets the EUIs belonging to configured gateways, each with the labels of its check
+func (l *ForwarderListener) Expect(checks map[string][]string) {
+    known := map[string][]string{}
+    for eui, labels := range checks {
+        known[config.NormalizeEUI(eui)] = labels
+    }
+
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    l.known = known
+    for eui := range l.lastSeen {
+        if _, ok := known[eui]; !ok {
+            delete(l.lastSeen, eui)
+            l.forwarders.metrics.UDPForwarderPackets.DeletePartialMatch(prometheus.Labels{"eui": eui})
+        }
+    }
+}
+
+// LastSeen returns when a datagram from the EUI was last received
+func (l *ForwarderListener) LastSeen(eui string) (time.Time, bool) {
+    l.mu.RLock()
+    defer l.mu.RUnlock()
+    seen, ok := l.lastSeen[config.NormalizeEUI(eui)]
+    return seen, ok
+}
+
+// Serve reads datagrams until the socket is closed
+func (l *ForwarderListener) Serve() {
+    buf := make([]byte, 65535)
+    for {
+        n, addr, err := l.conn.ReadFromUDP(buf)
+        if err != nil {
+            l.forwarders.logger.Printf("UDP listener on port %s stopped: %v", l.port, err)
+            return
+        }
+        l.handleDatagram(buf[:n], addr)
+    }
+}
+
+// handleDatagram parses the Semtech header, acknowledges it and records the gateway as heard
+func (l *ForwarderListener) handleDatagram(data []byte, addr *net.UDPAddr) {
+    logger := l.forwarders.logger
+
+    version, token, ident, eui, err := parseSemtechHeader(data)
+    if err != nil {
+        l.forwarders.metrics.UDPForwarderMalformed.WithLabelValues(l.port).Inc()
+        logger.Printf("Ignoring malformed datagram from %s: %v", addr, err)
+        return
+    }
+
+    switch ident {
+    case semtechPushData:
+        l.reply(addr, version, token, semtechPushAck)
+    case semtechPullData:
+        l.reply(addr, version, token, semtechPullAck)
+    }
+
+    l.mu.Lock()
+    labels, known := l.known[eui]
+    if known {
+        l.lastSeen[eui] = l.forwarders.clock.Now()
+    }
+    l.mu.Unlock()
+
+    if !known {
+        l.forwarders.metrics.UDPForwarderUnknownEUI.WithLabelValues(l.port).Inc()
+        logger.Printf("Received datagram from unknown gateway EUI %s (%s)", eui, addr)
+        return
+    }
+    l.forwarders.metrics.UDPForwarderPackets.WithLabelValues(eui, semtechPacketTypes[ident]).Inc()
+    if ident == semtechPushData {
+        l.recordTraffic(labels, data[semtechHeaderLen:])
+    }
+}
+
+// reply sends a 4-byte acknowledgement carrying the token of the original datagram
+func (l *ForwarderListener) reply(addr *net.UDPAddr, version byte, token [2]byte, ident byte) {
+    ack := []byte{version, token[0], token[1], ident}
+    if _, err := l.conn.WriteToUDP(ack, addr); err != nil {
+        l.forwarders.logger.Printf("Failed to acknowledge datagram from %s: %v", addr, err)
+    }
+}
+
+// parseSemtechHeader extracts the fields of the 12-byte header sent by gateways
+func parseSemtechHeader(data []byte) (version byte, token [2]byte, ident byte, eui string, err error) {
+    if len(data) < semtechHeaderLen {
+        return 0, token, 0, "", fmt.Errorf("datagram too short: %d bytes", len(data))
+    }
+
+    version = data[0]
+    if version != 1 && version != 2 {
+        return 0, token, 0, "", fmt.Errorf("unsupported protocol version %d", version)
+    }
+
+    ident = data[3]
+    switch ident {
+    case semtechPushData, semtechPullData, semtechTxAck:
+    default:
+        return 0, token, 0, "", fmt.Errorf("unexpected identifier 0x%02x", ident)
+    }
+
+    token = [2]byte{data[1], data[2]}
+    eui = hex.EncodeToString(data[4:semtechHeaderLen])
+    return version, token, ident, eui, nil
+}
@@ -0,0 +1,86 @@
+// Package clock abstracts time so the monitor can be driven by a fake clock.
+package clock
+
+import (
+    "sync"
+    "time"
+)
+
+// Clock tells the time and waits for durations to pass
+type Clock interface {
+    Now() time.Time
+    After(d time.Duration) <-chan time.Time
+}
+
+// Real is the wall clock
+type Real struct{}
+
+// Now returns the current time
+func (Real) Now() time.Time {
+    return time.Now()
+}
+
+// After waits for the duration to elapse
+func (Real) After(d time.Duration) <-chan time.Time {
+    return time.After(d)
+}
+
+// Fake is a clock that only moves when advanced, for tests
+type Fake struct {
+    mu      sync.Mutex
+    now     time.Time
+    waiters []waiter
+}
+
+type waiter struct {
+    at time.Time
+    ch chan time.Time
+}
+
+// NewFake returns a fake clock set to now
+func NewFake(now time.Time) *Fake {
+    return &Fake{now: now}
+}
+
+// Now returns the time the clock was set or advanced to
+func (f *Fake) Now() time.Time {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    return f.now
+}
+
+// After fires once the clock has been advanced by d
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    ch := make(chan time.Time, 1)
+    if d <= 0 {
+        ch <- f.now
+        return ch
+    }
+    f.waiters = append(f.waiters, waiter{at: f.now.Add(d), ch: ch})
+    return ch
+}
+
+// Advance moves the clock forward, firing the waits that elapse
+func (f *Fake) Advance(d time.Duration) {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    f.now = f.now.Add(d)
+    pending := f.waiters[:0]
+    for _, w := range f.waiters {
+        if w.at.After(f.now) {
+            pending = append(pending, w)
+            continue
+        }
+        w.ch <- f.now
+    }
+    f.waiters = pending
+}
+
+// Waiters returns how many waits have not elapsed yet
+func (f *Fake) Waiters() int {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    return len(f.waiters)
+}
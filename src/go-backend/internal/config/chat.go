@@ -0,0 +1,123 @@
+package config
+
+import (
+    "fmt"
+    "net/url"
+    "os"
+    "text/template"
+    "time"
+)
+
+// DefaultChatTemplate is used by chat notifications without a template of their own
+const DefaultChatTemplate = `Gateway {{.Gateway}}{{if .Project}} ({{.Project}}){{end}} is {{if .Reminder}}still {{end}}{{.State}}` +
+    `{{if eq .State "online"}} again after {{.Downtime}}{{else}} since {{.Since.Format "2006-01-02 15:04 MST"}}{{end}}` +
+    `{{if .Reason}}: {{.Reason}}{{end}}`
+
+// ChatNotification posts outages and recoveries to a Slack or Discord channel or a Telegram chat
+type ChatNotification struct {
+    // Type is "slack", "discord" or "telegram"
+    Type string `json:"type"`
+    // WebhookURL is the incoming webhook of a Slack or Discord channel, read from
+    // WebhookURLEnv when that is set
+    WebhookURLEnv string `json:"webhook_url_env,omitempty"`
+    WebhookURL    string `json:"webhook_url,omitempty"`
+    // BotToken and ChatID address a Telegram chat; the token is read from BotTokenEnv when that is set
+    BotTokenEnv string `json:"bot_token_env,omitempty"`
+    BotToken    string `json:"bot_token,omitempty"`
+    ChatID      string `json:"chat_id,omitempty"`
+    // Projects limits the notifications to the gateways of these projects
+    Projects []string `json:"projects,omitempty"`
+    // Template is a text/template rendered with the notification, see DefaultChatTemplate
+    Template string `json:"template,omitempty"`
+    // MinDowntime is how long a gateway must be offline before it is notified
+    MinDowntime string `json:"min_downtime,omitempty"`
+    // Repeat posts the offline message again at this interval while the gateway stays offline;
+    // empty posts it once
+    Repeat string `json:"repeat,omitempty"`
+}
+
+// Routes reports whether the channel is notified about the gateway
+func (c ChatNotification) Routes(gateway Gateway) bool {
+    if len(c.Projects) == 0 {
+        return true
+    }
+    for _, project := range c.Projects {
+        if project == gateway.Project {
+            return true
+        }
+    }
+    return false
+}
+
+// ResolveWebhookURL returns the Slack or Discord webhook
+func (c ChatNotification) ResolveWebhookURL() string {
+    return fromEnv(c.WebhookURLEnv, c.WebhookURL)
+}
+
+// ResolveBotToken returns the Telegram bot token
+func (c ChatNotification) ResolveBotToken() string {
+    return fromEnv(c.BotTokenEnv, c.BotToken)
+}
+
+// MessageTemplate parses the message template
+func (c ChatNotification) MessageTemplate() (*template.Template, error) {
+    message := c.Template
+    if message == "" {
+        message = DefaultChatTemplate
+    }
+    return template.New("message").Option("missingkey=error").Parse(message)
+}
+
+// MinDowntimeDuration returns how long an outage must last to be notified
+func (c ChatNotification) MinDowntimeDuration() (time.Duration, error) {
+    if c.MinDowntime == "" {
+        return 0, nil
+    }
+    return time.ParseDuration(c.MinDowntime)
+}
+
+// RepeatInterval returns how often the offline message is repeated, zero when it is posted once
+func (c ChatNotification) RepeatInterval() (time.Duration, error) {
+    return repeatInterval(c.Repeat)
+}
+
+// Validate checks the settings required by the chat type
+func (c ChatNotification) Validate() error {
+    switch c.Type {
+    case "slack", "discord":
+        if c.WebhookURLEnv == "" {
+            if u, err := url.Parse(c.WebhookURL); err != nil || u.Scheme != "https" && u.Scheme != "http" || u.Host == "" {
+                return fmt.Errorf("%s requires a webhook_url or webhook_url_env", c.Type)
+            }
+        }
+    case "telegram":
+        if c.BotTokenEnv == "" && c.BotToken == "" {
+            return fmt.Errorf("telegram requires a bot_token or bot_token_env")
+        }
+        if c.ChatID == "" {
+            return fmt.Errorf("telegram requires a chat_id")
+        }
+    default:
+        return fmt.Errorf("unsupported chat type %q", c.Type)
+    }
+    if _, err := c.MessageTemplate(); err != nil {
+        return fmt.Errorf("invalid template: %v", err)
+    }
+    if d, err := c.MinDowntimeDuration(); err != nil || d < 0 {
+        return fmt.Errorf("invalid min_downtime %q", c.MinDowntime)
+    }
+    if _, err := c.RepeatInterval(); err != nil {
+        return err
+    }
+    return nil
+}
+
+// fromEnv returns the environment variable env when it is set and value otherwise
+func fromEnv(env, value string) string {
+    if env != "" {
+        if v := os.Getenv(env); v != "" {
+            return v
+        }
+    }
+    return value
+}
@@ -0,0 +1,969 @@
+// Package config defines the gateways.json format and how it is loaded and validated.
+package config
+
+import (
+    "bytes"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "math"
+    "net"
+    "net/url"
+    "os"
+    "path/filepath"
+    "regexp"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+
+    "gopkg.in/yaml.v3"
+)
+
+const (
+    defaultForwarderPort   = 1700
+    defaultForwarderWindow = 2 * time.Minute
+    defaultStatusWindow    = 5 * time.Minute
+    defaultRetryBackoff    = time.Second
+
+    // defaultTTSCluster is used by tts and ttn-v3 checks without a url
+    defaultTTSCluster = "https://eu1.cloud.thethings.network"
+    // defaultHeliumAPI is used by helium checks without a url
+    defaultHeliumAPI = "https://api.helium.io"
+    // defaultPacketBrokerMapper, defaultNetID and defaultTenantID are used by packet-broker
+    // checks without a url, net_id or tenant_id: the public Mapper and The Things Network
+    defaultPacketBrokerMapper = "https://mapper.packetbroker.net"
+    defaultNetID              = "000013"
+    defaultTenantID           = "ttn"
+)
+
+// Origins of a monitored gateway
+const (
+    OriginStatic     = "static"
+    OriginDiscovered = "discovered"
+)
+
+// Gateway represents the structure of each gateway in the gateways.json file
+type Gateway struct {
+    Name string `json:"name"`
+    // Project groups gateways on the status page and in notifications
+    Project string `json:"project,omitempty"`
+    // Owner is added as a label to the generated alerting rules
+    Owner    string   `json:"owner,omitempty"`
+    Location Location `json:"location"`
+    // LocationSource is "config" (the default) or "api" to export the location reported by checks
+    LocationSource string              `json:"location_source,omitempty"`
+    Checks         []Check             `json:"checks"`
+    Maintenance    []MaintenanceWindow `json:"maintenance,omitempty"`
+    // OfflineAfter and OnlineAfter are how many consecutive failed or successful checks it
+    // takes to report the gateway offline or back online; zero uses the global setting
+    OfflineAfter int `json:"offline_after,omitempty"`
+    OnlineAfter  int `json:"online_after,omitempty"`
+    // StatusPolicy combines the results of the gateway's checks, see the Policy constants;
+    // empty uses the global STATUS_POLICY
+    StatusPolicy string `json:"status_policy,omitempty"`
+    // Notifications overrides the recipients configured for the gateway's project
+    Notifications *GatewayNotifications `json:"notifications,omitempty"`
+    // Labels are free-form metadata such as site or hardware model, exported on gateway_info
+    Labels map[string]string `json:"labels,omitempty"`
+
+    // Origin is OriginDiscovered for gateways found by discovery; it is never read from a file
+    Origin string `json:"-"`
+}
+
+// labelName matches the label names Prometheus accepts
+var labelName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// reservedLabels are set on gateway_info by the monitor and cannot be used as gateway labels
+var reservedLabels = map[string]bool{"name": true, "project": true, "origin": true}
+
+// validateLabels checks that the gateway labels can be exported as Prometheus labels
+func validateLabels(labels map[string]string) error {
+    names := make([]string, 0, len(labels))
+    for name := range labels {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    for _, name := range names {
+        switch {
+        case !labelName.MatchString(name) || strings.HasPrefix(name, "__"):
+            return fmt.Errorf("invalid label name %q", name)
+        case reservedLabels[name]:
+            return fmt.Errorf("label name %q is reserved", name)
+        }
+    }
+    return nil
+}
+
+// Policies combining the results of a gateway's checks into its status
+const (
+    // PolicyFirst takes the first check that says whether the gateway is online
+    PolicyFirst = "first"
+    // PolicyAny reports the gateway online when at least one check does
+    PolicyAny = "any"
+    // PolicyAll reports the gateway online only when every check does
+    PolicyAll = "all"
+)
+
+// ValidPolicy reports whether policy names a status policy
+func ValidPolicy(policy string) bool {
+    return policy == PolicyFirst || policy == PolicyAny || policy == PolicyAll
+}
+
+// Policy returns the gateway's status policy, fallback when it sets none
+func (g Gateway) Policy(fallback string) string {
+    if g.StatusPolicy == "" {
+        return fallback
+    }
+    return g.StatusPolicy
+}
+
+// Location is the position of a gateway in decimal degrees and metres
+type Location struct {
+    Latitude  float64  `json:"latitude"`
+    Longitude float64  `json:"longitude"`
+    Altitude  *float64 `json:"altitude,omitempty"`
+}
+
+// Located reports whether the location is set; 0, 0 is taken as unset since gateways left
+// without a location decode to it
+func (l Location) Located() bool {
+    return l.Latitude != 0 || l.Longitude != 0
+}
+
+// NormalizeLocation rounds the coordinates to six decimals, about 10 cm, so the label values
+// exported for them stay stable, and maps longitude 180 to -180, the same meridian
+func NormalizeLocation(l Location) Location {
+    round := func(v float64) float64 { return math.Round(v*1e6) / 1e6 }
+    l.Latitude, l.Longitude = round(l.Latitude), round(l.Longitude)
+    if l.Longitude == 180 {
+        l.Longitude = -180
+    }
+    return l
+}
+
+// GatewayOrigin reports whether the gateway was configured statically or discovered
+func (g Gateway) GatewayOrigin() string {
+    if g.Origin == "" {
+        return OriginStatic
+    }
+    return g.Origin
+}
+
+// Check represents a single status source configured for a gateway
+type Check struct {
+    Type string `json:"type"`
+    URL  string `json:"url,omitempty"`
+
+    // Fields used by the "udp-forwarder" check type; Window also applies to "tts", "ttn-v3"
+    // and "chirpstack", and EUI identifies the gateway of "chirpstack" and "basics-station" checks
+    EUI    string `json:"eui,omitempty"`
+    Port   int    `json:"port,omitempty"`
+    Window string `json:"window,omitempty"`
+
+    // Fields used by the "tts" and "ttn-v3" check types, which query the connection stats
+    // of GatewayID on the The Things Stack cluster at URL. The API key is read from
+    // APIKeyEnv or, failing that, taken from APIKey; "chirpstack" and "basics-station" checks
+    // send it as their token.
+    GatewayID string `json:"gateway_id,omitempty"`
+    APIKeyEnv string `json:"api_key_env,omitempty"`
+    APIKey    string `json:"api_key,omitempty"`
+
+    // Address is the hotspot address queried by a "helium" check
+    Address string `json:"address,omitempty"`
+
+    // NetID and TenantID identify the network of GatewayID for a "packet-broker" check, which
+    // asks the Packet Broker Mapper at URL whether the gateway's traffic reaches peering
+    // networks and exports the answer as gateway_peering_status. Listed after the gateway's
+    // main check, it leaves the online status alone under the "first" status policy.
+    NetID    string `json:"net_id,omitempty"`
+    TenantID string `json:"tenant_id,omitempty"`
+
+    // Host is the address probed by a "ping" check, host:port for a "tcp" check,
+    // the name looked up by a "dns" check or the router polled by an "snmp" check
+    Host string `json:"host,omitempty"`
+    // Timeout bounds a single attempt of the check, overriding CHECK_TIMEOUT. ConnectTimeout
+    // additionally bounds establishing the connection of HTTP based checks.
+    Timeout        string `json:"timeout,omitempty"`
+    ConnectTimeout string `json:"connect_timeout,omitempty"`
+    // Headers and Auth are added to the requests of HTTP based checks, which go through
+    // Proxy when set: an http(s) or socks5 URL, or "direct" to bypass the global proxy.
+    // TLS adds a client certificate or a private CA to their connections.
+    Headers map[string]string `json:"headers,omitempty"`
+    Auth    *HTTPAuth         `json:"auth,omitempty"`
+    Proxy   string            `json:"proxy,omitempty"`
+    TLS     *TLSClient        `json:"tls,omitempty"`
+    // IPVersion restricts the connections of a check to IPv4 ("4") or IPv6 ("6"). SourceIP
+    // or Interface binds them to a local address, for an interface its first one of the IP
+    // version, so checks of gateways reached over a VPN leave through its interface.
+    IPVersion string `json:"ip_version,omitempty"`
+    SourceIP  string `json:"source_ip,omitempty"`
+    Interface string `json:"interface,omitempty"`
+
+    // Retries is how many more attempts are made before a check that does not report online
+    // counts as offline, waiting RetryBackoff (1s by default) and then twice as long each time
+    Retries      int    `json:"retries,omitempty"`
+    RetryBackoff string `json:"retry_backoff,omitempty"`
+
+    // Fields used by the "dns" check type. Resolver is a host:port, the system resolver is used
+    // when it is empty, and also resolves the names other check types connect to; RecordType
+    // defaults to A.
+    Resolver     string `json:"resolver,omitempty"`
+    RecordType   string `json:"record_type,omitempty"`
+    ExpectRecord string `json:"expect_record,omitempty"`
+
+    // Fields used by the "snmp" check type, which reads OID from the router at Host (port 161
+    // unless given). SNMPVersion is "2c" (the default) or "3". The API key is the community for
+    // v2c and the authentication passphrase of Username for v3; the privacy passphrase is read
+    // from PrivPassphraseEnv. ExpectValue, when set, is the value that counts as online.
+    OID               string `json:"oid,omitempty"`
+    SNMPVersion       string `json:"snmp_version,omitempty"`
+    AuthProtocol      string `json:"auth_protocol,omitempty"`
+    PrivProtocol      string `json:"priv_protocol,omitempty"`
+    PrivPassphraseEnv string `json:"priv_passphrase_env,omitempty"`
+    ExpectValue       string `json:"expect_value,omitempty"`
+
+    // Topic is subscribed to on the broker at URL by an "mqtt" check; the API key is the password
+    Topic    string `json:"topic,omitempty"`
+    Username string `json:"username,omitempty"`
+
+    // Steps of a "multi-step" check, run in order
+    Steps []Step `json:"steps,omitempty"`
+
+    // Fields mapping the response of a generic HTTP check. StatusField is the path of the
+    // status, such as "attributes.state", used instead of the 'online' flag; it must hold a
+    // boolean unless StatusTrueValue gives the value meaning online. TimestampField is the path
+    // of when the gateway was last seen, as RFC 3339 or Unix time.
+    StatusField     string `json:"status_field,omitempty"`
+    StatusTrueValue string `json:"status_true_value,omitempty"`
+    TimestampField  string `json:"timestamp_field,omitempty"`
+    // MaxAge is how old the status timestamp of an http check may be before the gateway
+    // counts as offline, overriding MAX_STATUS_AGE; "0s" disables it for the check
+    MaxAge string `json:"max_age,omitempty"`
+
+    // Fields are numeric values of an HTTP check response to export and assert on
+    Fields []FieldRule `json:"fields,omitempty"`
+}
+
+// GatewaysFile represents the JSON structure for gateways.json
+type GatewaysFile struct {
+    Gateways      []Gateway      `json:"gateways"`
+    Discovery     *Discovery     `json:"discovery,omitempty"`
+    Notifications *Notifications `json:"notifications,omitempty"`
+    // Projects holds the settings of the projects gateways refer to by name
+    Projects map[string]Project `json:"projects,omitempty"`
+    // APITokens grant roles on the management API; without them only changing the gateways
+    // is restricted, to API_ADMIN_TOKEN
+    APITokens []APIToken `json:"api_tokens,omitempty"`
+    // SecretsFile is a JSON or YAML object of names to values; ${NAME} in any string value
+    // of the configuration is replaced with the secret NAME, or the environment variable
+    // NAME when the file does not define it. A relative path is taken relative to the
+    // directory of the configuration file.
+    SecretsFile string `json:"secrets_file,omitempty"`
+
+    // references holds the strings read with ${NAME} references by their path, for Save
+    references map[string]secretReference
+}
+
+// Source describes where a configuration came from
+type Source struct {
+    // Kind is "file", "directory", "url" or "git"
+    Kind string
+    // Path is the file the configuration was read from
+    Path string
+    // Commit is the Git commit the file was read at, if any
+    Commit string
+}
+
+// String describes the source for logs
+func (s Source) String() string {
+    if s.Commit == "" {
+        return s.Kind
+    }
+    return fmt.Sprintf("%s (commit %s)", s.Kind, s.Commit)
+}
+
+// Target identifies what the check talks to, used as the link_url metric label
+func (c Check) Target() string {
+    switch c.Type {
+    case "udp-forwarder":
+        return fmt.Sprintf("udp://:%d/%s", c.ForwarderPort(), NormalizeEUI(c.EUI))
+    case "multi-step":
+        if len(c.Steps) > 0 {
+            return c.Steps[0].URL
+        }
+    case "tts", "ttn-v3":
+        return c.TTSStatsURL()
+    case "chirpstack":
+        return c.ChirpStackGatewayURL()
+    case "helium":
+        return c.HeliumHotspotURL()
+    case "packet-broker":
+        return c.PacketBrokerGatewayURL()
+    case "basics-station":
+        return c.URL + "#" + NormalizeEUI(c.EUI)
+    case "mqtt":
+        return c.URL + "#" + c.Topic
+    case "ping":
+        return "icmp://" + c.Host
+    case "tcp":
+        return "tcp://" + c.Host
+    case "snmp":
+        return "snmp://" + c.Host + "/" + c.OID
+    case "dns":
+        return "dns://" + c.Resolver + "/" + c.Host + "?type=" + c.DNSRecordType()
+    }
+    return c.URL
+}
+
+// TTSStatsURL returns the Gateway Server connection stats endpoint queried by "tts" and "ttn-v3" checks
+func (c Check) TTSStatsURL() string {
+    cluster := c.URL
+    if cluster == "" {
+        cluster = defaultTTSCluster
+    }
+    return strings.TrimSuffix(cluster, "/") + "/api/v3/gs/gateways/" + url.PathEscape(c.GatewayID) + "/connection/stats"
+}
+
+// ChirpStackGatewayURL returns the REST API endpoint of the gateway queried by a "chirpstack" check
+func (c Check) ChirpStackGatewayURL() string {
+    return strings.TrimSuffix(c.URL, "/") + "/api/gateways/" + url.PathEscape(NormalizeEUI(c.EUI))
+}
+
+// HeliumHotspotURL returns the Helium API endpoint of the hotspot queried by a "helium" check
+func (c Check) HeliumHotspotURL() string {
+    api := c.URL
+    if api == "" {
+        api = defaultHeliumAPI
+    }
+    return strings.TrimSuffix(api, "/") + "/v1/hotspots/" + url.PathEscape(c.Address)
+}
+
+// PacketBrokerGatewayURL returns the Packet Broker Mapper endpoint of the gateway queried
+// by a "packet-broker" check
+func (c Check) PacketBrokerGatewayURL() string {
+    mapper, netID, tenantID := c.URL, c.NetID, c.TenantID
+    if mapper == "" {
+        mapper = defaultPacketBrokerMapper
+    }
+    if netID == "" {
+        netID = defaultNetID
+    }
+    if tenantID == "" {
+        tenantID = defaultTenantID
+    }
+    return fmt.Sprintf("%s/api/v2/gateways/netID=%s,tenantID=%s,id=%s",
+        strings.TrimSuffix(mapper, "/"), strings.ToUpper(netID), url.PathEscape(tenantID), url.PathEscape(c.GatewayID))
+}
+
+// DNSRecordType returns the record type looked up by a "dns" check
+func (c Check) DNSRecordType() string {
+    if c.RecordType == "" {
+        return "A"
+    }
+    return strings.ToUpper(c.RecordType)
+}
+
+// SNMPAddress splits the host of an "snmp" check into address and port
+func (c Check) SNMPAddress() (string, uint16, error) {
+    host, port, err := net.SplitHostPort(c.Host)
+    if err != nil {
+        return c.Host, 161, nil
+    }
+    number, err := strconv.ParseUint(port, 10, 16)
+    if err != nil {
+        return "", 0, fmt.Errorf("invalid snmp port %q", port)
+    }
+    return host, uint16(number), nil
+}
+
+// StatusMaxAge returns the configured max_age, or fallback when none is set
+func (c Check) StatusMaxAge(fallback time.Duration) (time.Duration, error) {
+    if c.MaxAge == "" {
+        return fallback, nil
+    }
+    maxAge, err := time.ParseDuration(c.MaxAge)
+    if err != nil {
+        return 0, fmt.Errorf("invalid max_age %q: %v", c.MaxAge, err)
+    }
+    return maxAge, nil
+}
+
+// DialTimeout returns the configured connect_timeout, zero when none is set
+func (c Check) DialTimeout() (time.Duration, error) {
+    if c.ConnectTimeout == "" {
+        return 0, nil
+    }
+    timeout, err := time.ParseDuration(c.ConnectTimeout)
+    if err != nil {
+        return 0, fmt.Errorf("invalid connect_timeout %q: %v", c.ConnectTimeout, err)
+    }
+    return timeout, nil
+}
+
+// RetryBackoffDuration returns the delay before the first retry
+func (c Check) RetryBackoffDuration() (time.Duration, error) {
+    if c.RetryBackoff == "" {
+        return defaultRetryBackoff, nil
+    }
+    backoff, err := time.ParseDuration(c.RetryBackoff)
+    if err != nil || backoff <= 0 {
+        return 0, fmt.Errorf("invalid retry_backoff %q", c.RetryBackoff)
+    }
+    return backoff, nil
+}
+
+// CheckTimeout returns the configured timeout, or fallback when none is set
+func (c Check) CheckTimeout(fallback time.Duration) (time.Duration, error) {
+    if c.Timeout == "" {
+        return fallback, nil
+    }
+    timeout, err := time.ParseDuration(c.Timeout)
+    if err != nil {
+        return 0, fmt.Errorf("invalid timeout %q: %v", c.Timeout, err)
+    }
+    return timeout, nil
+}
+
+// ResolveAPIKey returns the API key or token sent by "tts", "ttn-v3" and "chirpstack" checks
+func (c Check) ResolveAPIKey() string {
+    if c.APIKeyEnv != "" {
+        if key := os.Getenv(c.APIKeyEnv); key != "" {
+            return key
+        }
+    }
+    return c.APIKey
+}
+
+// StatusWindow returns how recent the last gateway status must be for "tts", "ttn-v3",
+// "chirpstack", "mqtt" and (when set) "helium" and "packet-broker" checks to report online
+func (c Check) StatusWindow() (time.Duration, error) {
+    if c.Window == "" {
+        return defaultStatusWindow, nil
+    }
+    return time.ParseDuration(c.Window)
+}
+
+// ForwarderPort returns the UDP port the check listens on
+func (c Check) ForwarderPort() int {
+    if c.Port == 0 {
+        return defaultForwarderPort
+    }
+    return c.Port
+}
+
+// ForwarderWindow returns how recently a datagram must have arrived for the gateway to count as online
+func (c Check) ForwarderWindow() (time.Duration, error) {
+    if c.Window == "" {
+        return defaultForwarderWindow, nil
+    }
+    return time.ParseDuration(c.Window)
+}
+
+// NormalizeEUI makes EUIs comparable regardless of case
+func NormalizeEUI(eui string) string {
+    return strings.ToLower(strings.TrimSpace(eui))
+}
+
+// Load loads and validates the gateway configuration from a JSON file or a directory of JSON fragments
+func Load(filePath string) (*GatewaysFile, error) {
+    gatewaysFile, err := Read(filePath)
+    if err != nil {
+        return nil, err
+    }
+
+    if err := Validate(gatewaysFile); err != nil {
+        return nil, err
+    }
+    return gatewaysFile, nil
+}
+
+// Read loads the gateway configuration from a JSON or YAML file or a directory of fragments without validating it
+func Read(filePath string) (*GatewaysFile, error) {
+    return read(filePath, false)
+}
+
+// ReadStrict is like Read but also rejects fields that are not part of the format,
+// which are otherwise ignored
+func ReadStrict(filePath string) (*GatewaysFile, error) {
+    return read(filePath, true)
+}
+
+func read(filePath string, strict bool) (*GatewaysFile, error) {
+    info, err := os.Stat(filePath)
+    if err != nil {
+        return nil, err
+    }
+    if info.IsDir() {
+        return readDir(filePath, strict)
+    }
+
+    data, err := os.ReadFile(filePath)
+    if err != nil {
+        return nil, err
+    }
+    return decode(data, filepath.Dir(filePath), strict)
+}
+
+// LoadDir merges every JSON and YAML fragment in dir into one configuration.
+// A gateway name, an API token or a project may only be defined in one fragment.
+func LoadDir(dir string) (*GatewaysFile, error) {
+    merged, err := readDir(dir, false)
+    if err != nil {
+        return nil, err
+    }
+
+    if err := Validate(merged); err != nil {
+        return nil, err
+    }
+    return merged, nil
+}
+
+// fragmentPaths lists the *.json, *.yaml and *.yml files of dir in name order
+func fragmentPaths(dir string) ([]string, error) {
+    var paths []string
+    for _, pattern := range []string{"*.json", "*.yaml", "*.yml"} {
+        matches, err := filepath.Glob(filepath.Join(dir, pattern))
+        if err != nil {
+            return nil, err
+        }
+        paths = append(paths, matches...)
+    }
+    sort.Strings(paths)
+    return paths, nil
+}
+
+// readDir merges the fragments in dir, failing only on conflicts between them
+func readDir(dir string, strict bool) (*GatewaysFile, error) {
+    paths, err := fragmentPaths(dir)
+    if err != nil {
+        return nil, err
+    }
+
+    merged := &GatewaysFile{}
+    definedIn := map[string]string{}
+    tokenNamesIn, tokensIn := map[string]string{}, map[string]string{}
+    projectsIn := map[string]string{}
+    for _, path := range paths {
+        data, err := os.ReadFile(path)
+        if err != nil {
+            return nil, err
+        }
+        fragment, err := decode(data, dir, strict)
+        if err != nil {
+            return nil, fmt.Errorf("%s: %v", filepath.Base(path), err)
+        }
+
+        for _, gateway := range fragment.Gateways {
+            if other, ok := definedIn[gateway.Name]; ok && other != path {
+                return nil, fmt.Errorf("gateway %s is defined in both %s and %s", gateway.Name, filepath.Base(other), filepath.Base(path))
+            }
+            definedIn[gateway.Name] = path
+        }
+        merged.Gateways = append(merged.Gateways, fragment.Gateways...)
+        // Gateways and API tokens are addressed by name and projects by key, so the paths stay
+        // valid in the merged configuration
+        for path, ref := range fragment.references {
+            if merged.references == nil {
+                merged.references = map[string]secretReference{}
+            }
+            merged.references[path] = ref
+        }
+
+        for _, token := range fragment.APITokens {
+            if other, ok := tokenNamesIn[token.Name]; ok && other != path {
+                return nil, fmt.Errorf("api token %s is defined in both %s and %s", token.Name, filepath.Base(other), filepath.Base(path))
+            }
+            if other, ok := tokensIn[token.Token]; ok && other != path {
+                return nil, fmt.Errorf("api token %s in %s reuses a token of %s", token.Name, filepath.Base(path), filepath.Base(other))
+            }
+            tokenNamesIn[token.Name] = path
+            tokensIn[token.Token] = path
+        }
+        merged.APITokens = append(merged.APITokens, fragment.APITokens...)
+        for name, project := range fragment.Projects {
+            if other, ok := projectsIn[name]; ok {
+                return nil, fmt.Errorf("project %s is defined in both %s and %s", name, filepath.Base(other), filepath.Base(path))
+            }
+            projectsIn[name] = path
+            if merged.Projects == nil {
+                merged.Projects = map[string]Project{}
+            }
+            merged.Projects[name] = project
+        }
+
+        if fragment.Discovery != nil {
+            if merged.Discovery != nil {
+                return nil, fmt.Errorf("discovery is configured in more than one fragment, including %s", filepath.Base(path))
+            }
+            merged.Discovery = fragment.Discovery
+        }
+        if fragment.Notifications != nil {
+            if merged.Notifications != nil {
+                return nil, fmt.Errorf("notifications are configured in more than one fragment, including %s", filepath.Base(path))
+            }
+            merged.Notifications = fragment.Notifications
+        }
+    }
+
+    return merged, nil
+}
+
+// Save writes the configuration to filePath, replacing the file atomically.
+// The file is always written as JSON, which YAML readers accept too. Values read with
+// ${NAME} references are written with the references rather than the secrets.
+func Save(filePath string, gatewaysFile *GatewaysFile) error {
+    data, err := json.MarshalIndent(gatewaysFile, "", "  ")
+    if err != nil {
+        return err
+    }
+    if data, err = restoreReferences(data, gatewaysFile.references); err != nil {
+        return err
+    }
+
+    tmp, err := os.CreateTemp(filepath.Dir(filePath), ".gateways-*.json")
+    if err != nil {
+        return err
+    }
+    defer os.Remove(tmp.Name())
+
+    if _, err := tmp.Write(append(data, '\n')); err != nil {
+        tmp.Close()
+        return err
+    }
+    if err := tmp.Close(); err != nil {
+        return err
+    }
+    return os.Rename(tmp.Name(), filePath)
+}
+
+// Parse decodes and validates a gateway configuration
+func Parse(data []byte) (*GatewaysFile, error) {
+    gateways, err := Decode(data)
+    if err != nil {
+        return nil, err
+    }
+
+    if err := Validate(gateways); err != nil {
+        return nil, err
+    }
+
+    return gateways, nil
+}
+
+// Decode decodes a JSON or YAML gateway configuration without validating it.
+// Documents starting with '{' are JSON, anything else is YAML. A relative secrets_file is
+// read from the working directory; see Read for configuration files.
+func Decode(data []byte) (*GatewaysFile, error) {
+    return decode(data, "", false)
+}
+
+// decode is Decode with a relative secrets_file taken relative to dir, when it is set
+func decode(data []byte, dir string, strict bool) (*GatewaysFile, error) {
+    if trimmed := bytes.TrimSpace(data); len(trimmed) == 0 || trimmed[0] != '{' {
+        // YAML is converted to JSON so both formats share the field names and types
+        var doc interface{}
+        if err := yaml.Unmarshal(data, &doc); err != nil {
+            return nil, err
+        }
+        var err error
+        if data, err = json.Marshal(doc); err != nil {
+            return nil, fmt.Errorf("unsupported YAML: %v", err)
+        }
+    }
+    data, references, err := expandReferences(data, dir)
+    if err != nil {
+        return nil, err
+    }
+
+    decoder := json.NewDecoder(bytes.NewReader(data))
+    if strict {
+        decoder.DisallowUnknownFields()
+    }
+    var gateways GatewaysFile
+    if err := decoder.Decode(&gateways); err != nil {
+        if strings.HasPrefix(err.Error(), "json: unknown field") {
+            return nil, errors.New(strings.TrimPrefix(err.Error(), "json: "))
+        }
+        return nil, err
+    }
+    for i := range gateways.Gateways {
+        gateways.Gateways[i].Location = NormalizeLocation(gateways.Gateways[i].Location)
+    }
+    gateways.references = references
+    return &gateways, nil
+}
+
+// Validate rejects configurations the monitor cannot run with, reporting the first problem
+func Validate(gatewaysFile *GatewaysFile) error {
+    if problems := Problems(gatewaysFile); len(problems) > 0 {
+        return problems[0]
+    }
+    return nil
+}
+
+// Problems lists everything wrong with a configuration
+func Problems(gatewaysFile *GatewaysFile) []error {
+    var problems []error
+    if gatewaysFile.Discovery != nil {
+        if err := gatewaysFile.Discovery.Validate(); err != nil {
+            problems = append(problems, fmt.Errorf("discovery: %v", err))
+        }
+    } else if len(gatewaysFile.Gateways) == 0 {
+        problems = append(problems, fmt.Errorf("no gateways configured"))
+    }
+    if notifications := gatewaysFile.Notifications; notifications != nil {
+        if notifications.Email != nil {
+            if err := notifications.Email.Validate(); err != nil {
+                problems = append(problems, fmt.Errorf("notifications: email: %v", err))
+            }
+        }
+        for i, webhook := range notifications.Webhooks {
+            if err := webhook.Validate(); err != nil {
+                problems = append(problems, fmt.Errorf("notifications: webhook %d: %v", i+1, err))
+            }
+        }
+        for i, chat := range notifications.Chats {
+            if err := chat.Validate(); err != nil {
+                problems = append(problems, fmt.Errorf("notifications: chat %d: %v", i+1, err))
+            }
+        }
+    }
+
+    problems = append(problems, validateAPITokens(gatewaysFile.APITokens)...)
+
+    projects := make([]string, 0, len(gatewaysFile.Projects))
+    for name := range gatewaysFile.Projects {
+        projects = append(projects, name)
+    }
+    sort.Strings(projects)
+    for _, name := range projects {
+        if err := gatewaysFile.Projects[name].Validate(); err != nil {
+            problems = append(problems, fmt.Errorf("project %s: %v", name, err))
+        }
+    }
+
+    names := map[string]bool{}
+    for _, gateway := range gatewaysFile.Gateways {
+        if gateway.Name == "" {
+            problems = append(problems, fmt.Errorf("gateway without a name"))
+        } else if names[gateway.Name] {
+            problems = append(problems, fmt.Errorf("duplicate gateway name %s", gateway.Name))
+        }
+        names[gateway.Name] = true
+
+        if latitude := gateway.Location.Latitude; latitude < -90 || latitude > 90 {
+            problems = append(problems, fmt.Errorf("gateway %s: latitude %v is outside -90 to 90", gateway.Name, latitude))
+        }
+        if longitude := gateway.Location.Longitude; longitude < -180 || longitude > 180 {
+            problems = append(problems, fmt.Errorf("gateway %s: longitude %v is outside -180 to 180", gateway.Name, longitude))
+        }
+
+        if gateway.LocationSource != "" && gateway.LocationSource != "config" && gateway.LocationSource != "api" {
+            problems = append(problems, fmt.Errorf("gateway %s: location_source must be \"config\" or \"api\"", gateway.Name))
+        }
+
+        if gateway.StatusPolicy != "" && !ValidPolicy(gateway.StatusPolicy) {
+            problems = append(problems, fmt.Errorf("gateway %s: status_policy must be \"first\", \"any\" or \"all\"", gateway.Name))
+        }
+
+        if gateway.OfflineAfter < 0 || gateway.OnlineAfter < 0 {
+            problems = append(problems, fmt.Errorf("gateway %s: offline_after and online_after must not be negative", gateway.Name))
+        }
+
+        for _, window := range gateway.Maintenance {
+            if err := window.Validate(); err != nil {
+                problems = append(problems, fmt.Errorf("gateway %s: invalid maintenance window: %v", gateway.Name, err))
+            }
+        }
+
+        if gateway.Notifications != nil {
+            if err := validateAddresses(gateway.Notifications.Email); err != nil {
+                problems = append(problems, fmt.Errorf("gateway %s: notifications: %v", gateway.Name, err))
+            }
+        }
+
+        if err := validateLabels(gateway.Labels); err != nil {
+            problems = append(problems, fmt.Errorf("gateway %s: %v", gateway.Name, err))
+        }
+
+        for i, check := range gateway.Checks {
+            if check.Type == "" {
+                problems = append(problems, fmt.Errorf("gateway %s: check %d has no type", gateway.Name, i+1))
+            } else if err := validateCheck(check); err != nil {
+                problems = append(problems, fmt.Errorf("gateway %s: %v", gateway.Name, err))
+            }
+        }
+    }
+
+    return problems
+}
+
+// ValidateChecks validates every check of a single gateway
+func ValidateChecks(gateway Gateway) error {
+    for _, check := range gateway.Checks {
+        if err := validateCheck(check); err != nil {
+            return fmt.Errorf("gateway %s: %v", gateway.Name, err)
+        }
+    }
+    return nil
+}
+
+// validateCheck checks the fields required by the check type
+func validateCheck(check Check) error {
+    for _, rule := range check.Fields {
+        if err := rule.Validate(); err != nil {
+            return err
+        }
+    }
+    if _, err := check.CheckTimeout(0); err != nil {
+        return err
+    }
+    if _, err := check.DialTimeout(); err != nil {
+        return err
+    }
+    if check.Retries < 0 {
+        return fmt.Errorf("retries must not be negative")
+    }
+    if _, err := check.RetryBackoffDuration(); err != nil {
+        return err
+    }
+    if check.Auth != nil {
+        if err := check.Auth.Validate(); err != nil {
+            return err
+        }
+    }
+    if _, err := check.ProxyURL(); err != nil {
+        return err
+    }
+    if check.TLS != nil {
+        if err := check.TLS.Validate(); err != nil {
+            return err
+        }
+    }
+    if err := check.validateNetwork(); err != nil {
+        return err
+    }
+
+    switch check.Type {
+    case "udp-forwarder":
+        if check.EUI == "" {
+            return fmt.Errorf("udp-forwarder check requires an eui")
+        }
+        if _, err := check.ForwarderWindow(); err != nil {
+            return fmt.Errorf("invalid udp-forwarder window %q: %v", check.Window, err)
+        }
+    case "multi-step":
+        if len(check.Steps) == 0 {
+            return fmt.Errorf("multi-step check requires at least one step")
+        }
+        for i, step := range check.Steps {
+            if err := step.Validate(); err != nil {
+                return fmt.Errorf("multi-step check step %d (%s): %v", i+1, step.Label(i), err)
+            }
+        }
+    case "ping":
+        if check.Host == "" {
+            return fmt.Errorf("ping check requires a host")
+        }
+    case "dns":
+        if check.Host == "" {
+            return fmt.Errorf("dns check requires a host")
+        }
+        switch check.DNSRecordType() {
+        case "A", "AAAA", "CNAME", "MX", "TXT":
+        default:
+            return fmt.Errorf("unsupported dns record_type %q", check.RecordType)
+        }
+    case "snmp":
+        if check.Host == "" || check.OID == "" {
+            return fmt.Errorf("snmp check requires a host and an oid")
+        }
+        if _, _, err := check.SNMPAddress(); err != nil {
+            return err
+        }
+        switch check.SNMPVersion {
+        case "", "2c":
+        case "3":
+            if check.Username == "" {
+                return fmt.Errorf("snmp v3 check requires a username")
+            }
+            switch strings.ToUpper(check.AuthProtocol) {
+            case "", "MD5", "SHA", "SHA224", "SHA256", "SHA384", "SHA512":
+            default:
+                return fmt.Errorf("unsupported snmp auth_protocol %q", check.AuthProtocol)
+            }
+            switch strings.ToUpper(check.PrivProtocol) {
+            case "", "DES", "AES", "AES192", "AES256":
+            default:
+                return fmt.Errorf("unsupported snmp priv_protocol %q", check.PrivProtocol)
+            }
+            if check.PrivProtocol != "" && check.AuthProtocol == "" {
+                return fmt.Errorf("snmp priv_protocol requires an auth_protocol")
+            }
+        default:
+            return fmt.Errorf("unsupported snmp_version %q", check.SNMPVersion)
+        }
+    case "tcp":
+        if _, _, err := net.SplitHostPort(check.Host); err != nil {
+            return fmt.Errorf("tcp check requires a host:port: %v", err)
+        }
+    case "mqtt":
+        if check.URL == "" || check.Topic == "" {
+            return fmt.Errorf("mqtt check requires a broker url and a topic")
+        }
+        if _, err := check.StatusWindow(); err != nil {
+            return fmt.Errorf("invalid mqtt window %q: %v", check.Window, err)
+        }
+    case "basics-station":
+        if !strings.HasPrefix(check.URL, "ws://") && !strings.HasPrefix(check.URL, "wss://") {
+            return fmt.Errorf("basics-station check requires a ws:// or wss:// router-info url")
+        }
+        if check.EUI == "" {
+            return fmt.Errorf("basics-station check requires an eui")
+        }
+    case "helium":
+        if check.Address == "" {
+            return fmt.Errorf("helium check requires an address")
+        }
+        if _, err := check.StatusWindow(); err != nil {
+            return fmt.Errorf("invalid helium window %q: %v", check.Window, err)
+        }
+    case "packet-broker":
+        if check.GatewayID == "" {
+            return fmt.Errorf("packet-broker check requires a gateway_id")
+        }
+        if netID, err := hex.DecodeString(check.NetID); check.NetID != "" && (err != nil || len(netID) != 3) {
+            return fmt.Errorf("invalid packet-broker net_id %q, expected 6 hex digits", check.NetID)
+        }
+        if _, err := check.StatusWindow(); err != nil {
+            return fmt.Errorf("invalid packet-broker window %q: %v", check.Window, err)
+        }
+    case "chirpstack":
+        if check.URL == "" || check.EUI == "" {
+            return fmt.Errorf("chirpstack check requires a url and an eui")
+        }
+        if _, err := check.StatusWindow(); err != nil {
+            return fmt.Errorf("invalid chirpstack window %q: %v", check.Window, err)
+        }
+    case "tts", "ttn-v3":
+        if check.GatewayID == "" {
+            return fmt.Errorf("%s check requires a gateway_id", check.Type)
+        }
+        if _, err := check.StatusWindow(); err != nil {
+            return fmt.Errorf("invalid %s window %q: %v", check.Type, check.Window, err)
+        }
+    default:
+        if check.URL == "" {
+            return fmt.Errorf("%s check requires a url", check.Type)
+        }
+        if check.StatusTrueValue != "" && check.StatusField == "" {
+            return fmt.Errorf("status_true_value requires a status_field")
+        }
+        if _, err := check.StatusMaxAge(0); err != nil {
+            return err
+        }
+    }
+    return nil
+}
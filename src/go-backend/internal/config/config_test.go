@@ -0,0 +1,120 @@
+package config
+
+import (
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+// writeFragments writes the fragments of a directory configuration, by file name, to a
+// temporary directory and returns it
+func writeFragments(t *testing.T, fragments map[string]string) string {
+    t.Helper()
+    dir := t.TempDir()
+    for name, data := range fragments {
+        if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600); err != nil {
+            t.Fatal(err)
+        }
+    }
+    return dir
+}
+
+const testGateway = `{"name": "gw1", "checks": [{"type": "http", "url": "http://127.0.0.1:1/status"}]}`
+
+func TestLoadDirMergesAPITokens(t *testing.T) {
+    dir := writeFragments(t, map[string]string{
+        "gateways.json": `{"gateways": [` + testGateway + `]}`,
+        "tokens.yaml":   "api_tokens:\n  - {name: noc, token: operator-token-0123456, role: operator}\n",
+    })
+    gatewaysFile, err := LoadDir(dir)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(gatewaysFile.APITokens) != 1 || gatewaysFile.APITokens[0].Name != "noc" || gatewaysFile.APITokens[0].Role != RoleOperator {
+        t.Errorf("merged API tokens = %+v, want noc", gatewaysFile.APITokens)
+    }
+}
+
+func TestLoadDirConflicts(t *testing.T) {
+    tests := []struct {
+        name      string
+        fragments map[string]string
+        err       string
+    }{
+        {
+            name: "api token name",
+            fragments: map[string]string{
+                "a.json": `{"api_tokens": [{"name": "noc", "token": "operator-token-0123456", "role": "operator"}]}`,
+                "b.json": `{"api_tokens": [{"name": "noc", "token": "operator-token-6543210", "role": "operator"}]}`,
+            },
+            err: "api token noc is defined in both a.json and b.json",
+        },
+        {
+            name: "api token value",
+            fragments: map[string]string{
+                "a.json": `{"api_tokens": [{"name": "noc", "token": "operator-token-0123456", "role": "operator"}]}`,
+                "b.json": `{"api_tokens": [{"name": "ops", "token": "operator-token-0123456", "role": "admin"}]}`,
+            },
+            err: "api token ops in b.json reuses a token of a.json",
+        },
+        {
+            name: "notifications",
+            fragments: map[string]string{
+                "a.json": `{"notifications": {"email": {"to": ["noc@example.com"]}}}`,
+                "b.json": `{"notifications": {"chats": [{"type": "slack", "webhook_url": "https://hooks.slack.com/services/T/B/x"}]}}`,
+            },
+            err: "notifications are configured in more than one fragment, including b.json",
+        },
+        {
+            name: "project",
+            fragments: map[string]string{
+                "a.json": `{"projects": {"city": {"interval": "5m"}}}`,
+                "b.json": `{"projects": {"city": {"interval": "10m"}}}`,
+            },
+            err: "project city is defined in both a.json and b.json",
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            _, err := Read(writeFragments(t, tt.fragments))
+            if err == nil || !strings.Contains(err.Error(), tt.err) {
+                t.Errorf("got error %v, want %q", err, tt.err)
+            }
+        })
+    }
+}
+
+func TestLoadDirMergesNotifications(t *testing.T) {
+    dir := writeFragments(t, map[string]string{
+        "gateways.json":      `{"gateways": [` + testGateway + `]}`,
+        "notifications.yaml": "notifications:\n  chats:\n    - {type: slack, webhook_url: \"https://hooks.slack.com/services/T/B/x\"}\n",
+    })
+    gatewaysFile, err := LoadDir(dir)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if gatewaysFile.Notifications == nil || len(gatewaysFile.Notifications.Chats) != 1 {
+        t.Fatalf("merged notifications = %+v, want the slack chat", gatewaysFile.Notifications)
+    }
+    if chat := gatewaysFile.Notifications.Chats[0]; chat.Type != "slack" {
+        t.Errorf("merged chat type = %q, want slack", chat.Type)
+    }
+}
+
+func TestLoadDirMergesProjects(t *testing.T) {
+    dir := writeFragments(t, map[string]string{
+        "gateways.json": `{"gateways": [` + testGateway + `], "projects": {"city": {"interval": "5m"}}}`,
+        "rural.yaml":    "projects:\n  rural:\n    notifications: {email: [rural@example.com]}\n",
+    })
+    gatewaysFile, err := LoadDir(dir)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if got := gatewaysFile.Projects["city"].Interval; got != "5m" {
+        t.Errorf("interval of project city = %q, want 5m", got)
+    }
+    if rural := gatewaysFile.Projects["rural"]; rural.Notifications == nil || len(rural.Notifications.Email) != 1 {
+        t.Errorf("project rural = %+v, want its email recipient", rural)
+    }
+}
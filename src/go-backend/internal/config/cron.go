@@ -0,0 +1,113 @@
+package config
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// cronSchedule is a parsed five-field cron expression (minute hour day-of-month month day-of-week)
+type cronSchedule struct {
+    minute, hour, dom, month, dow uint64
+    domRestricted, dowRestricted  bool
+}
+
+// parseCron parses expressions such as "0 2 * * 0" or "*/15 8-17 * * 1-5"
+func parseCron(expr string) (*cronSchedule, error) {
+    fields := strings.Fields(expr)
+    if len(fields) != 5 {
+        return nil, fmt.Errorf("expected 5 fields, got %d", len(fields))
+    }
+
+    var s cronSchedule
+    var err error
+    if s.minute, err = parseCronField(fields[0], 0, 59); err != nil {
+        return nil, fmt.Errorf("minute: %v", err)
+    }
+    if s.hour, err = parseCronField(fields[1], 0, 23); err != nil {
+        return nil, fmt.Errorf("hour: %v", err)
+    }
+    if s.dom, err = parseCronField(fields[2], 1, 31); err != nil {
+        return nil, fmt.Errorf("day of month: %v", err)
+    }
+    if s.month, err = parseCronField(fields[3], 1, 12); err != nil {
+        return nil, fmt.Errorf("month: %v", err)
+    }
+    if s.dow, err = parseCronField(fields[4], 0, 7); err != nil {
+        return nil, fmt.Errorf("day of week: %v", err)
+    }
+
+    // Both 0 and 7 mean Sunday
+    if s.dow&(1<<7) != 0 {
+        s.dow |= 1
+    }
+    s.domRestricted = fields[2] != "*"
+    s.dowRestricted = fields[4] != "*"
+    return &s, nil
+}
+
+// parseCronField turns a comma separated list of values, ranges and steps into a bit set
+func parseCronField(field string, min, max int) (uint64, error) {
+    var bits uint64
+    for _, part := range strings.Split(field, ",") {
+        rangePart, step := part, 1
+        if i := strings.Index(part, "/"); i >= 0 {
+            var err error
+            rangePart = part[:i]
+            if step, err = strconv.Atoi(part[i+1:]); err != nil || step <= 0 {
+                return 0, fmt.Errorf("invalid step in %q", part)
+            }
+        }
+
+        lo, hi := min, max
+        if rangePart != "*" {
+            bounds := strings.SplitN(rangePart, "-", 2)
+            var err error
+            if lo, err = strconv.Atoi(bounds[0]); err != nil {
+                return 0, fmt.Errorf("invalid value %q", part)
+            }
+            hi = lo
+            if len(bounds) == 2 {
+                if hi, err = strconv.Atoi(bounds[1]); err != nil {
+                    return 0, fmt.Errorf("invalid value %q", part)
+                }
+            } else if step > 1 {
+                hi = max
+            }
+        }
+        if lo < min || hi > max || lo > hi {
+            return 0, fmt.Errorf("%q out of range %d-%d", part, min, max)
+        }
+
+        for v := lo; v <= hi; v += step {
+            bits |= 1 << uint(v)
+        }
+    }
+    return bits, nil
+}
+
+// Matches reports whether the schedule fires in the minute containing t
+func (s *cronSchedule) Matches(t time.Time) bool {
+    if s.minute&(1<<uint(t.Minute())) == 0 || s.hour&(1<<uint(t.Hour())) == 0 || s.month&(1<<uint(t.Month())) == 0 {
+        return false
+    }
+
+    domMatch := s.dom&(1<<uint(t.Day())) != 0
+    dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+    if s.domRestricted && s.dowRestricted {
+        return domMatch || dowMatch
+    }
+    return domMatch && dowMatch
+}
+
+// ActiveWithin reports whether the schedule fired at some minute during the duration up to t
+func (s *cronSchedule) ActiveWithin(t time.Time, d time.Duration) bool {
+    start := t.Truncate(time.Minute)
+    for m := start; t.Sub(m) < d; m = m.Add(-time.Minute) {
+        if s.Matches(m) {
+            return true
+        }
+    }
+    return false
+}
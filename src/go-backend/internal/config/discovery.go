@@ -0,0 +1,108 @@
+package config
+
+import (
+    "fmt"
+    "net/url"
+    "path"
+    "strings"
+    "time"
+)
+
+const defaultDiscoveryInterval = 15 * time.Minute
+
+// Discovery configures loading gateways from a The Things Stack cluster.
+// Discovered gateways are monitored with a "tts" check; statically configured
+// gateways with the same name take precedence.
+type Discovery struct {
+    // ClusterURL is the base URL of the cluster, e.g. https://eu1.cloud.thethings.network
+    ClusterURL string `json:"cluster_url"`
+    // APIKeyEnv names the environment variable holding the API key
+    APIKeyEnv string `json:"api_key_env"`
+
+    // Only list the gateways of this user or organisation; all gateways the key can see otherwise
+    UserID         string `json:"user_id,omitempty"`
+    OrganizationID string `json:"organization_id,omitempty"`
+
+    // BoundingBox limits discovery to gateways located inside it
+    BoundingBox *BoundingBox `json:"bounding_box,omitempty"`
+
+    // Include and Exclude are glob patterns such as "acme-*" matched against the gateway ID
+    // and EUI: only gateways matching an Include pattern, if any are given, and no Exclude
+    // pattern are monitored
+    Include []string `json:"include,omitempty"`
+    Exclude []string `json:"exclude,omitempty"`
+
+    Interval string `json:"interval,omitempty"`
+}
+
+// BoundingBox is an area in decimal degrees
+type BoundingBox struct {
+    MinLatitude  float64 `json:"min_latitude"`
+    MinLongitude float64 `json:"min_longitude"`
+    MaxLatitude  float64 `json:"max_latitude"`
+    MaxLongitude float64 `json:"max_longitude"`
+}
+
+// Contains reports whether the coordinates lie inside the box
+func (b BoundingBox) Contains(latitude, longitude float64) bool {
+    return latitude >= b.MinLatitude && latitude <= b.MaxLatitude &&
+        longitude >= b.MinLongitude && longitude <= b.MaxLongitude
+}
+
+// Matches reports whether a gateway with the ID and EUI passes the include and exclude filters
+func (d Discovery) Matches(gatewayID, eui string) bool {
+    if len(d.Include) > 0 && !matchAny(d.Include, gatewayID, eui) {
+        return false
+    }
+    return !matchAny(d.Exclude, gatewayID, eui)
+}
+
+// matchAny reports whether any pattern matches one of the names, ignoring case
+func matchAny(patterns []string, names ...string) bool {
+    for _, pattern := range patterns {
+        for _, name := range names {
+            // Validated with the configuration
+            if matched, _ := path.Match(strings.ToLower(pattern), strings.ToLower(name)); matched && name != "" {
+                return true
+            }
+        }
+    }
+    return false
+}
+
+// DiscoveryInterval returns how often the cluster is queried
+func (d Discovery) DiscoveryInterval() (time.Duration, error) {
+    if d.Interval == "" {
+        return defaultDiscoveryInterval, nil
+    }
+    return time.ParseDuration(d.Interval)
+}
+
+// Validate checks the discovery settings
+func (d Discovery) Validate() error {
+    if _, err := url.ParseRequestURI(d.ClusterURL); err != nil {
+        return fmt.Errorf("invalid cluster_url %q: %v", d.ClusterURL, err)
+    }
+    if d.APIKeyEnv == "" {
+        return fmt.Errorf("api_key_env is required")
+    }
+    if d.UserID != "" && d.OrganizationID != "" {
+        return fmt.Errorf("only one of user_id and organization_id may be set")
+    }
+    if b := d.BoundingBox; b != nil && (b.MinLatitude > b.MaxLatitude || b.MinLongitude > b.MaxLongitude) {
+        return fmt.Errorf("bounding_box minimum exceeds maximum")
+    }
+    for _, pattern := range append(append([]string(nil), d.Include...), d.Exclude...) {
+        if _, err := path.Match(pattern, ""); err != nil {
+            return fmt.Errorf("invalid pattern %q: %v", pattern, err)
+        }
+    }
+    interval, err := d.DiscoveryInterval()
+    if err != nil {
+        return fmt.Errorf("invalid interval %q: %v", d.Interval, err)
+    }
+    if interval <= 0 {
+        return fmt.Errorf("interval must be positive")
+    }
+    return nil
+}
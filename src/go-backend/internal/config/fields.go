@@ -0,0 +1,59 @@
+package config
+
+import (
+    "fmt"
+    "time"
+)
+
+// FieldRule extracts a numeric field from a check response and asserts on it.
+// A gateway whose rules are violated is reported as degraded, not offline.
+type FieldRule struct {
+    // Name labels the exported value; Path is used when it is empty
+    Name string `json:"name,omitempty"`
+    // Path is a dotted JSON path, looked up at the top level and then under the gateway name
+    Path string `json:"path"`
+
+    Min *float64 `json:"min,omitempty"`
+    Max *float64 `json:"max,omitempty"`
+    // Window makes min and max only fail when every sample within it is out of range
+    Window string `json:"window,omitempty"`
+    // MaxDeltaAge requires the value to have increased within this duration, for counters
+    MaxDeltaAge string `json:"max_delta_age,omitempty"`
+}
+
+// Label returns the name the field is exported under
+func (r FieldRule) Label() string {
+    if r.Name != "" {
+        return r.Name
+    }
+    return r.Path
+}
+
+// Durations returns the parsed window and max_delta_age, zero when unset
+func (r FieldRule) Durations() (window, maxDeltaAge time.Duration, err error) {
+    if r.Window != "" {
+        if window, err = time.ParseDuration(r.Window); err != nil {
+            return 0, 0, fmt.Errorf("invalid window %q: %v", r.Window, err)
+        }
+    }
+    if r.MaxDeltaAge != "" {
+        if maxDeltaAge, err = time.ParseDuration(r.MaxDeltaAge); err != nil {
+            return 0, 0, fmt.Errorf("invalid max_delta_age %q: %v", r.MaxDeltaAge, err)
+        }
+    }
+    return window, maxDeltaAge, nil
+}
+
+// Validate checks that the rule can be evaluated
+func (r FieldRule) Validate() error {
+    if r.Path == "" {
+        return fmt.Errorf("field rule requires a path")
+    }
+    if r.Min != nil && r.Max != nil && *r.Min > *r.Max {
+        return fmt.Errorf("field %s: min exceeds max", r.Label())
+    }
+    if _, _, err := r.Durations(); err != nil {
+        return fmt.Errorf("field %s: %v", r.Label(), err)
+    }
+    return nil
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+    "fmt"
+    "net/url"
+    "os"
+)
+
+// ProxyDirect as the proxy of a check bypasses the global proxy
+const ProxyDirect = "direct"
+
+// HTTPAuth authenticates the requests of an HTTP based check
+type HTTPAuth struct {
+    // Type is "basic" or "bearer"
+    Type     string `json:"type"`
+    Username string `json:"username,omitempty"`
+    // The password for basic auth or the token for bearer auth, read from SecretEnv
+    // or, failing that, taken from Secret
+    SecretEnv string `json:"secret_env,omitempty"`
+    Secret    string `json:"secret,omitempty"`
+}
+
+// ResolveSecret returns the password or token
+func (a HTTPAuth) ResolveSecret() string {
+    if a.SecretEnv != "" {
+        if secret := os.Getenv(a.SecretEnv); secret != "" {
+            return secret
+        }
+    }
+    return a.Secret
+}
+
+// Validate reports an unknown auth type
+func (a HTTPAuth) Validate() error {
+    switch a.Type {
+    case "basic":
+        if a.Username == "" {
+            return fmt.Errorf("basic auth requires a username")
+        }
+    case "bearer":
+    default:
+        return fmt.Errorf("unsupported auth type %q", a.Type)
+    }
+    return nil
+}
+
+// TLSClient configures the TLS connections of an HTTP based check to a server that
+// requires mutual TLS or uses a private CA
+type TLSClient struct {
+    // CertFile and KeyFile are a PEM encoded client certificate and its key
+    CertFile string `json:"cert_file,omitempty"`
+    KeyFile  string `json:"key_file,omitempty"`
+    // CAFile is a PEM bundle of the CAs trusted instead of the system roots
+    CAFile string `json:"ca_file,omitempty"`
+}
+
+// Validate reports a certificate without its key or the other way round
+func (t TLSClient) Validate() error {
+    if (t.CertFile == "") != (t.KeyFile == "") {
+        return fmt.Errorf("tls cert_file and key_file must be set together")
+    }
+    if t.CertFile == "" && t.CAFile == "" {
+        return fmt.Errorf("tls requires a cert_file and key_file or a ca_file")
+    }
+    return nil
+}
+
+// ProxyURL parses the proxy of the check; it is nil when the check uses the global proxy
+// or, for ProxyDirect, none
+func (c Check) ProxyURL() (*url.URL, error) {
+    if c.Proxy == "" || c.Proxy == ProxyDirect {
+        return nil, nil
+    }
+    u, err := url.Parse(c.Proxy)
+    if err != nil || u.Host == "" {
+        return nil, fmt.Errorf("invalid proxy %q", c.Proxy)
+    }
+    return u, nil
+}
@@ -0,0 +1,139 @@
+package config
+
+import (
+    "context"
+    "crypto/sha256"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "io"
+    "net/http"
+    "os"
+    "path/filepath"
+)
+
+// ErrNotModified is returned by Loader.Load when a remote configuration has not changed
+var ErrNotModified = errors.New("configuration not modified")
+
+// Loader loads the gateway configuration, JSON or YAML, from a file, a directory of fragments,
+// an http(s) URL, an S3 or GCS object (s3://bucket/key, gs://bucket/object) or a Kubernetes
+// ConfigMap (configmap://[namespace/]name[/key])
+type Loader struct {
+    Location string
+    // Token is sent as a bearer token when fetching a URL or a GCS object
+    Token string
+
+    client *http.Client
+    // cluster is the client for the Kubernetes API, created on first use
+    cluster *http.Client
+    // version is the ETag, or the ConfigMap resource version, of the last configuration loaded
+    version string
+}
+
+// NewLoader creates a loader for location; client is used for remote locations
+func NewLoader(location string, client *http.Client) *Loader {
+    return &Loader{
+        Location: location,
+        client:   client,
+    }
+}
+
+// IsRemote reports whether the configuration is fetched over the network
+func (l *Loader) IsRemote() bool {
+    return remoteKind(l.Location) != ""
+}
+
+// Source describes the location for the monitor
+func (l *Loader) Source() Source {
+    if kind := remoteKind(l.Location); kind != "" {
+        return Source{Kind: kind}
+    }
+    if info, err := os.Stat(l.Location); err == nil && info.IsDir() {
+        return Source{Kind: "directory"}
+    }
+    return Source{Kind: "file", Path: l.Location}
+}
+
+// Load reads and validates the configuration. For remote locations that support it the ETag
+// of the last successful response is sent back, and ErrNotModified is returned if the
+// configuration has not changed.
+func (l *Loader) Load(ctx context.Context) (*GatewaysFile, error) {
+    if !l.IsRemote() {
+        return Load(l.Location)
+    }
+
+    req, err := l.newRequest(ctx)
+    if err != nil {
+        return nil, err
+    }
+    client := l.client
+    if remoteKind(l.Location) == "configmap" {
+        if client, err = l.clusterClient(); err != nil {
+            return nil, err
+        }
+    } else if l.version != "" {
+        req.Header.Set("If-None-Match", l.version)
+    }
+
+    resp, err := client.Do(req)
+    if err != nil {
+        return nil, fmt.Errorf("failed to fetch configuration: %w", err)
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode == http.StatusNotModified {
+        return nil, ErrNotModified
+    }
+    if resp.StatusCode != http.StatusOK {
+        return nil, fmt.Errorf("failed to fetch configuration: HTTP %d", resp.StatusCode)
+    }
+
+    var data []byte
+    version := resp.Header.Get("ETag")
+    if remoteKind(l.Location) == "configmap" {
+        data, version, err = configMapData(resp.Body, l.Location)
+        if err == nil && version != "" && version == l.version {
+            return nil, ErrNotModified
+        }
+    } else {
+        data, err = io.ReadAll(resp.Body)
+    }
+    if err != nil {
+        return nil, fmt.Errorf("failed to read configuration: %w", err)
+    }
+    gatewaysFile, err := Parse(data)
+    if err != nil {
+        return nil, err
+    }
+
+    l.version = version
+    return gatewaysFile, nil
+}
+
+// Fingerprint hashes the contents of a local configuration, so callers can tell
+// when the file or any fragment of the directory has changed
+func (l *Loader) Fingerprint() (string, error) {
+    if l.IsRemote() {
+        return "", fmt.Errorf("remote configurations have no fingerprint")
+    }
+
+    paths := []string{l.Location}
+    if info, err := os.Stat(l.Location); err != nil {
+        return "", err
+    } else if info.IsDir() {
+        if paths, err = fragmentPaths(l.Location); err != nil {
+            return "", err
+        }
+    }
+
+    hash := sha256.New()
+    for _, path := range paths {
+        data, err := os.ReadFile(path)
+        if err != nil {
+            return "", err
+        }
+        fmt.Fprintf(hash, "%s %d\n", filepath.Base(path), len(data))
+        hash.Write(data)
+    }
+    return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,66 @@
+package config
+
+import (
+    "fmt"
+    "time"
+)
+
+// MaintenanceWindow is a planned period in which a gateway is expected to be down.
+// It is either a recurring cron expression with a duration, or an explicit start/end range.
+type MaintenanceWindow struct {
+    Cron     string     `json:"cron,omitempty"`
+    Duration string     `json:"duration,omitempty"`
+    Timezone string     `json:"timezone,omitempty"`
+    Start    *time.Time `json:"start,omitempty"`
+    End      *time.Time `json:"end,omitempty"`
+    Comment  string     `json:"comment,omitempty"`
+}
+
+// Validate checks the window is either a complete cron window or a complete range
+func (m MaintenanceWindow) Validate() error {
+    if m.Cron != "" {
+        if m.Start != nil || m.End != nil {
+            return fmt.Errorf("cron windows cannot also set start/end")
+        }
+        if _, err := parseCron(m.Cron); err != nil {
+            return fmt.Errorf("invalid cron %q: %v", m.Cron, err)
+        }
+        d, err := time.ParseDuration(m.Duration)
+        if err != nil || d <= 0 {
+            return fmt.Errorf("cron windows need a positive duration, got %q", m.Duration)
+        }
+        if _, err := time.LoadLocation(m.Timezone); err != nil {
+            return fmt.Errorf("invalid timezone %q: %v", m.Timezone, err)
+        }
+        return nil
+    }
+
+    if m.Start == nil || m.End == nil {
+        return fmt.Errorf("window needs either cron and duration or start and end")
+    }
+    if !m.End.After(*m.Start) {
+        return fmt.Errorf("window ends before it starts")
+    }
+    return nil
+}
+
+// Active reports whether the window covers the given time
+func (m MaintenanceWindow) Active(now time.Time) bool {
+    if m.Cron == "" {
+        return m.Start != nil && m.End != nil && !now.Before(*m.Start) && now.Before(*m.End)
+    }
+
+    schedule, err := parseCron(m.Cron)
+    if err != nil {
+        return false
+    }
+    d, err := time.ParseDuration(m.Duration)
+    if err != nil {
+        return false
+    }
+    loc, err := time.LoadLocation(m.Timezone)
+    if err != nil {
+        return false
+    }
+    return schedule.ActiveWithin(now.In(loc), d)
+}
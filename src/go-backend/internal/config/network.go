@@ -0,0 +1,50 @@
+package config
+
+import (
+    "fmt"
+    "net"
+)
+
+// IP versions a check can be restricted to
+const (
+    IPv4 = "4"
+    IPv6 = "6"
+)
+
+// SourceAddress parses the source_ip of the check; it is nil when none is set
+func (c Check) SourceAddress() (net.IP, error) {
+    if c.SourceIP == "" {
+        return nil, nil
+    }
+    ip := net.ParseIP(c.SourceIP)
+    if ip == nil {
+        return nil, fmt.Errorf("invalid source_ip %q", c.SourceIP)
+    }
+    return ip, nil
+}
+
+// validateNetwork checks the settings binding the connections of a check to an IP version,
+// resolver and local address
+func (c Check) validateNetwork() error {
+    switch c.IPVersion {
+    case "", IPv4, IPv6:
+    default:
+        return fmt.Errorf("ip_version must be %q or %q, got %q", IPv4, IPv6, c.IPVersion)
+    }
+    if c.Resolver != "" {
+        if _, _, err := net.SplitHostPort(c.Resolver); err != nil {
+            return fmt.Errorf("resolver must be a host:port: %v", err)
+        }
+    }
+    source, err := c.SourceAddress()
+    if err != nil {
+        return err
+    }
+    if source != nil && c.Interface != "" {
+        return fmt.Errorf("source_ip and interface are mutually exclusive")
+    }
+    if source != nil && c.IPVersion != "" && (source.To4() != nil) != (c.IPVersion == IPv4) {
+        return fmt.Errorf("source_ip %s is not an IPv%s address", c.SourceIP, c.IPVersion)
+    }
+    return nil
+}
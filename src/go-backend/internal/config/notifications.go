@@ -0,0 +1,118 @@
+package config
+
+import (
+    "fmt"
+    "net/mail"
+    "text/template"
+    "time"
+)
+
+// DefaultEmailSubject is used by email notifications without a subject template
+const DefaultEmailSubject = "[LoRaCheck] Gateway {{.Gateway}} is {{.State}}"
+
+// Notifications configures who is told about gateway state transitions
+type Notifications struct {
+    Email    *EmailNotifications   `json:"email,omitempty"`
+    Webhooks []WebhookNotification `json:"webhooks,omitempty"`
+    Chats    []ChatNotification    `json:"chats,omitempty"`
+    // UpdateGapAnomalies also tells the email and chat channels when an online gateway's
+    // status updates are overdue compared to their usual interval
+    UpdateGapAnomalies bool `json:"update_gap_anomalies,omitempty"`
+}
+
+// EmailNotifications sends an email when a gateway goes offline and when it recovers
+type EmailNotifications struct {
+    // To receives the notifications of gateways without project or gateway recipients
+    To []string `json:"to"`
+    // Projects overrides To for the gateways of a project
+    Projects map[string][]string `json:"projects,omitempty"`
+    // Subject is a text/template rendered with the notification, see DefaultEmailSubject
+    Subject string `json:"subject,omitempty"`
+    // MinDowntime is how long a gateway must be offline before it is notified;
+    // shorter outages send neither the offline nor the recovery email
+    MinDowntime string `json:"min_downtime,omitempty"`
+    // Repeat sends the offline email again at this interval while the gateway stays offline;
+    // empty sends it once
+    Repeat string `json:"repeat,omitempty"`
+    // MonthlyReport mails each project's recipients the availability of its gateways
+    // in the previous month; it requires the history store
+    MonthlyReport bool `json:"monthly_report,omitempty"`
+}
+
+// GatewayNotifications overrides the notification recipients for a single gateway
+type GatewayNotifications struct {
+    Email []string `json:"email,omitempty"`
+}
+
+// ProjectRecipients returns the addresses notified about the gateways of a project
+func (e EmailNotifications) ProjectRecipients(project string) []string {
+    if to, ok := e.Projects[project]; ok && project != "" {
+        return to
+    }
+    return e.To
+}
+
+// SubjectTemplate parses the subject template
+func (e EmailNotifications) SubjectTemplate() (*template.Template, error) {
+    subject := e.Subject
+    if subject == "" {
+        subject = DefaultEmailSubject
+    }
+    return template.New("subject").Option("missingkey=error").Parse(subject)
+}
+
+// MinDowntimeDuration returns how long an outage must last to be notified
+func (e EmailNotifications) MinDowntimeDuration() (time.Duration, error) {
+    if e.MinDowntime == "" {
+        return 0, nil
+    }
+    return time.ParseDuration(e.MinDowntime)
+}
+
+// RepeatInterval returns how often the offline email is repeated, zero when it is sent once
+func (e EmailNotifications) RepeatInterval() (time.Duration, error) {
+    return repeatInterval(e.Repeat)
+}
+
+// Validate checks the email notification settings
+func (e EmailNotifications) Validate() error {
+    if err := validateAddresses(e.To); err != nil {
+        return err
+    }
+    for project, to := range e.Projects {
+        if err := validateAddresses(to); err != nil {
+            return fmt.Errorf("project %s: %v", project, err)
+        }
+    }
+    if _, err := e.SubjectTemplate(); err != nil {
+        return fmt.Errorf("invalid subject: %v", err)
+    }
+    if d, err := e.MinDowntimeDuration(); err != nil || d < 0 {
+        return fmt.Errorf("invalid min_downtime %q", e.MinDowntime)
+    }
+    if _, err := e.RepeatInterval(); err != nil {
+        return err
+    }
+    return nil
+}
+
+// repeatInterval parses the repeat setting of a notification channel
+func repeatInterval(repeat string) (time.Duration, error) {
+    if repeat == "" {
+        return 0, nil
+    }
+    d, err := time.ParseDuration(repeat)
+    if err != nil || d <= 0 {
+        return 0, fmt.Errorf("invalid repeat %q", repeat)
+    }
+    return d, nil
+}
+
+func validateAddresses(addresses []string) error {
+    for _, address := range addresses {
+        if _, err := mail.ParseAddress(address); err != nil {
+            return fmt.Errorf("invalid email address %q: %v", address, err)
+        }
+    }
+    return nil
+}
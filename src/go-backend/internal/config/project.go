@@ -0,0 +1,165 @@
+package config
+
+import (
+    "fmt"
+    "time"
+)
+
+// Project holds the settings shared by the gateways whose project it is
+type Project struct {
+    // Maintenance applies to every gateway of the project in addition to their own windows
+    Maintenance []MaintenanceWindow `json:"maintenance,omitempty"`
+    // Interval is how often the project's gateways are checked, rounded up to whole cycles;
+    // empty checks them every cycle
+    Interval string `json:"interval,omitempty"`
+    // MaxStatusAge applies to the http checks of the project's gateways without a max_age,
+    // overriding MAX_STATUS_AGE
+    MaxStatusAge string `json:"max_status_age,omitempty"`
+    // Notifications sets where the outages of the project's gateways are reported
+    Notifications *ProjectNotifications `json:"notifications,omitempty"`
+}
+
+// ProjectNotifications are the notification targets of a project
+type ProjectNotifications struct {
+    // Email replaces the recipients of email notifications and monthly reports for the project;
+    // a gateway's own recipients still take precedence
+    Email []string `json:"email,omitempty"`
+    // Webhooks receive the transitions of the project's gateways in addition to the global webhooks
+    Webhooks []WebhookNotification `json:"webhooks,omitempty"`
+    // Escalation overrides when the channels are told about the outages of the project's
+    // gateways, keyed by "email" or a chat type such as "slack"
+    Escalation map[string]Escalation `json:"escalation,omitempty"`
+}
+
+// escalationChannels are the keys of ProjectNotifications.Escalation
+var escalationChannels = map[string]bool{"email": true, "slack": true, "discord": true, "telegram": true}
+
+// Escalation sets when a notification channel is told about an outage; a channel set to
+// notify after 2h while a chat posts right away escalates long outages to email
+type Escalation struct {
+    // After replaces the channel's min_downtime
+    After string `json:"after,omitempty"`
+    // Repeat replaces the channel's repeat
+    Repeat string `json:"repeat,omitempty"`
+}
+
+// AfterDuration returns how long an outage must last before the channel is told, and
+// whether After is set
+func (e Escalation) AfterDuration() (time.Duration, bool, error) {
+    if e.After == "" {
+        return 0, false, nil
+    }
+    d, err := time.ParseDuration(e.After)
+    if err != nil || d < 0 {
+        return 0, false, fmt.Errorf("invalid after %q", e.After)
+    }
+    return d, true, nil
+}
+
+// RepeatInterval returns how often the channel is told again, and whether Repeat is set
+func (e Escalation) RepeatInterval() (time.Duration, bool, error) {
+    d, err := repeatInterval(e.Repeat)
+    return d, e.Repeat != "", err
+}
+
+// Validate checks the project settings
+func (p Project) Validate() error {
+    for _, window := range p.Maintenance {
+        if err := window.Validate(); err != nil {
+            return fmt.Errorf("invalid maintenance window: %v", err)
+        }
+    }
+    if _, err := p.CheckInterval(); err != nil {
+        return err
+    }
+    if _, err := p.StatusMaxAge(); err != nil {
+        return err
+    }
+    if p.Notifications != nil {
+        if err := validateAddresses(p.Notifications.Email); err != nil {
+            return err
+        }
+        for i, webhook := range p.Notifications.Webhooks {
+            if err := webhook.Validate(); err != nil {
+                return fmt.Errorf("webhook %d: %v", i+1, err)
+            }
+        }
+        for channel, escalation := range p.Notifications.Escalation {
+            if !escalationChannels[channel] {
+                return fmt.Errorf("escalation: unknown channel %q, expected email, slack, discord or telegram", channel)
+            }
+            if _, _, err := escalation.AfterDuration(); err != nil {
+                return fmt.Errorf("escalation %s: %v", channel, err)
+            }
+            if _, _, err := escalation.RepeatInterval(); err != nil {
+                return fmt.Errorf("escalation %s: %v", channel, err)
+            }
+        }
+    }
+    return nil
+}
+
+// CheckInterval returns the project's check interval, zero when its gateways are checked every cycle
+func (p Project) CheckInterval() (time.Duration, error) {
+    if p.Interval == "" {
+        return 0, nil
+    }
+    interval, err := time.ParseDuration(p.Interval)
+    if err != nil || interval <= 0 {
+        return 0, fmt.Errorf("invalid interval %q", p.Interval)
+    }
+    return interval, nil
+}
+
+// StatusMaxAge returns the project's max_status_age, zero when it has none
+func (p Project) StatusMaxAge() (time.Duration, error) {
+    if p.MaxStatusAge == "" {
+        return 0, nil
+    }
+    maxAge, err := time.ParseDuration(p.MaxStatusAge)
+    if err != nil || maxAge < 0 {
+        return 0, fmt.Errorf("invalid max_status_age %q", p.MaxStatusAge)
+    }
+    return maxAge, nil
+}
+
+// ProjectOf returns the settings of the gateway's project, empty when it has none
+func (g GatewaysFile) ProjectOf(gateway Gateway) Project {
+    if gateway.Project == "" {
+        return Project{}
+    }
+    return g.Projects[gateway.Project]
+}
+
+// EmailRecipients returns the addresses notified about the gateway: its own, its project's
+// or the default ones
+func (g GatewaysFile) EmailRecipients(gateway Gateway) []string {
+    if gateway.Notifications != nil && len(gateway.Notifications.Email) > 0 {
+        return gateway.Notifications.Email
+    }
+    return g.ProjectEmailRecipients(gateway.Project)
+}
+
+// ProjectEmailRecipients returns the addresses notified about the gateways of a project: those
+// of the project's settings, those listed under email.projects or the default ones
+func (g GatewaysFile) ProjectEmailRecipients(project string) []string {
+    if settings, ok := g.Projects[project]; ok && project != "" && settings.Notifications != nil && len(settings.Notifications.Email) > 0 {
+        return settings.Notifications.Email
+    }
+    if g.Notifications == nil || g.Notifications.Email == nil {
+        return nil
+    }
+    return g.Notifications.Email.ProjectRecipients(project)
+}
+
+// Webhooks returns the webhooks notified about the gateway: the global ones followed by its project's
+func (g GatewaysFile) Webhooks(gateway Gateway) []WebhookNotification {
+    var webhooks []WebhookNotification
+    if g.Notifications != nil {
+        webhooks = append(webhooks, g.Notifications.Webhooks...)
+    }
+    if settings := g.ProjectOf(gateway); settings.Notifications != nil {
+        webhooks = append(webhooks, settings.Notifications.Webhooks...)
+    }
+    return webhooks
+}
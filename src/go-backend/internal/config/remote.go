@@ -0,0 +1,308 @@
+package config
+
+import (
+    "context"
+    "crypto/hmac"
+    "crypto/sha256"
+    "crypto/tls"
+    "crypto/x509"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "net/url"
+    "os"
+    "strings"
+    "time"
+)
+
+// Remote location schemes other than http(s)
+const (
+    schemeS3        = "s3://"
+    schemeGCS       = "gs://"
+    schemeConfigMap = "configmap://"
+)
+
+// In-cluster service account files used for configmap locations
+const (
+    serviceAccountDir   = "/var/run/secrets/kubernetes.io/serviceaccount"
+    defaultConfigMapKey = "gateways.json"
+)
+
+// gcsTokenURL is the metadata server endpoint that issues tokens on GCE and GKE
+const gcsTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+// remoteKind returns the Source kind of a remote location, or "" for local paths
+func remoteKind(location string) string {
+    switch {
+    case strings.HasPrefix(location, "http://"), strings.HasPrefix(location, "https://"):
+        return "url"
+    case strings.HasPrefix(location, schemeS3):
+        return "s3"
+    case strings.HasPrefix(location, schemeGCS):
+        return "gcs"
+    case strings.HasPrefix(location, schemeConfigMap):
+        return "configmap"
+    }
+    return ""
+}
+
+// newRequest builds the request that fetches a remote location
+func (l *Loader) newRequest(ctx context.Context) (*http.Request, error) {
+    switch remoteKind(l.Location) {
+    case "s3":
+        return l.s3Request(ctx)
+    case "gcs":
+        return l.gcsRequest(ctx)
+    case "configmap":
+        return l.configMapRequest(ctx)
+    }
+
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, l.Location, nil)
+    if err != nil {
+        return nil, err
+    }
+    req.Header.Set("Accept", "application/json, application/yaml")
+    if l.Token != "" {
+        req.Header.Set("Authorization", "Bearer "+l.Token)
+    }
+    return req, nil
+}
+
+// splitObject splits "scheme://bucket/path/to/object" into bucket and object
+func splitObject(location, scheme string) (string, string, error) {
+    bucket, object, ok := strings.Cut(strings.TrimPrefix(location, scheme), "/")
+    if !ok || bucket == "" || object == "" {
+        return "", "", fmt.Errorf("location %s must be %sbucket/object", location, scheme)
+    }
+    return bucket, object, nil
+}
+
+// s3Request signs a GET of the object with AWS Signature Version 4, using the standard
+// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN and AWS_REGION variables.
+// AWS_ENDPOINT_URL selects an S3 compatible service such as MinIO, addressed path style.
+// Without credentials the object is fetched anonymously.
+func (l *Loader) s3Request(ctx context.Context) (*http.Request, error) {
+    bucket, object, err := splitObject(l.Location, schemeS3)
+    if err != nil {
+        return nil, err
+    }
+    region := envOrDefault("AWS_REGION", envOrDefault("AWS_DEFAULT_REGION", "us-east-1"))
+
+    objectURL := fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", bucket, region, escapePath(object))
+    if endpoint := os.Getenv("AWS_ENDPOINT_URL"); endpoint != "" {
+        objectURL = strings.TrimSuffix(endpoint, "/") + "/" + bucket + "/" + escapePath(object)
+    }
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, objectURL, nil)
+    if err != nil {
+        return nil, err
+    }
+
+    accessKey, secretKey := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+    if accessKey == "" || secretKey == "" {
+        return req, nil
+    }
+    signV4(req, accessKey, secretKey, os.Getenv("AWS_SESSION_TOKEN"), region, time.Now().UTC())
+    return req, nil
+}
+
+// signV4 adds the Signature Version 4 authorization of an S3 GET request without a body
+func signV4(req *http.Request, accessKey, secretKey, sessionToken, region string, now time.Time) {
+    const payload = "UNSIGNED-PAYLOAD"
+    amzDate, day := now.Format("20060102T150405Z"), now.Format("20060102")
+    req.Header.Set("X-Amz-Date", amzDate)
+    req.Header.Set("X-Amz-Content-Sha256", payload)
+
+    headers := []string{"host:" + req.URL.Host, "x-amz-content-sha256:" + payload, "x-amz-date:" + amzDate}
+    signed := "host;x-amz-content-sha256;x-amz-date"
+    if sessionToken != "" {
+        req.Header.Set("X-Amz-Security-Token", sessionToken)
+        headers = append(headers, "x-amz-security-token:"+sessionToken)
+        signed += ";x-amz-security-token"
+    }
+
+    canonical := strings.Join([]string{
+        req.Method, req.URL.EscapedPath(), req.URL.RawQuery,
+        strings.Join(headers, "\n") + "\n", signed, payload,
+    }, "\n")
+    scope := day + "/" + region + "/s3/aws4_request"
+    digest := sha256.Sum256([]byte(canonical))
+    toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(digest[:])
+
+    key := []byte("AWS4" + secretKey)
+    for _, part := range []string{day, region, "s3", "aws4_request"} {
+        key = hmacSHA256(key, part)
+    }
+    signature := hex.EncodeToString(hmacSHA256(key, toSign))
+    req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", accessKey, scope, signed, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+    mac := hmac.New(sha256.New, key)
+    mac.Write([]byte(data))
+    return mac.Sum(nil)
+}
+
+// escapePath escapes each segment of an object key, keeping the slashes
+func escapePath(object string) string {
+    segments := strings.Split(object, "/")
+    for i, segment := range segments {
+        segments[i] = url.PathEscape(segment)
+    }
+    return strings.Join(segments, "/")
+}
+
+// gcsRequest fetches the object through the Cloud Storage JSON API. The token is
+// GATEWAYS_CONFIG_TOKEN when set, otherwise one from the metadata server when running
+// on Google Cloud; public objects are fetched anonymously elsewhere.
+func (l *Loader) gcsRequest(ctx context.Context) (*http.Request, error) {
+    bucket, object, err := splitObject(l.Location, schemeGCS)
+    if err != nil {
+        return nil, err
+    }
+    objectURL := fmt.Sprintf("https://storage.googleapis.com/storage/v1/b/%s/o/%s?alt=media", url.PathEscape(bucket), url.PathEscape(object))
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, objectURL, nil)
+    if err != nil {
+        return nil, err
+    }
+
+    token := l.Token
+    if token == "" {
+        token = l.metadataToken(ctx)
+    }
+    if token != "" {
+        req.Header.Set("Authorization", "Bearer "+token)
+    }
+    return req, nil
+}
+
+// metadataToken asks the Google Cloud metadata server for an access token, returning ""
+// when it is not reachable
+func (l *Loader) metadataToken(ctx context.Context) string {
+    ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+    defer cancel()
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, gcsTokenURL, nil)
+    if err != nil {
+        return ""
+    }
+    req.Header.Set("Metadata-Flavor", "Google")
+    resp, err := l.client.Do(req)
+    if err != nil {
+        return ""
+    }
+    defer resp.Body.Close()
+
+    var token struct {
+        AccessToken string `json:"access_token"`
+    }
+    if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&token) != nil {
+        return ""
+    }
+    return token.AccessToken
+}
+
+// configMapLocation is a parsed "configmap://[namespace/]name[/key]" location
+type configMapLocation struct {
+    namespace, name, key string
+}
+
+func parseConfigMapLocation(location string) (configMapLocation, error) {
+    parts := strings.Split(strings.TrimPrefix(location, schemeConfigMap), "/")
+    cm := configMapLocation{key: defaultConfigMapKey}
+    switch len(parts) {
+    case 1:
+        cm.name = parts[0]
+    case 2:
+        cm.namespace, cm.name = parts[0], parts[1]
+    case 3:
+        cm.namespace, cm.name, cm.key = parts[0], parts[1], parts[2]
+    }
+    if cm.name == "" || cm.key == "" {
+        return cm, fmt.Errorf("location %s must be %s[namespace/]name[/key]", location, schemeConfigMap)
+    }
+    return cm, nil
+}
+
+// configMapRequest reads the ConfigMap through the Kubernetes API with the pod's service
+// account. The namespace defaults to the pod's own.
+func (l *Loader) configMapRequest(ctx context.Context) (*http.Request, error) {
+    cm, err := parseConfigMapLocation(l.Location)
+    if err != nil {
+        return nil, err
+    }
+    if cm.namespace == "" {
+        namespace, err := os.ReadFile(serviceAccountDir + "/namespace")
+        if err != nil {
+            return nil, fmt.Errorf("no namespace in %s and not running in a cluster: %v", l.Location, err)
+        }
+        cm.namespace = strings.TrimSpace(string(namespace))
+    }
+    token, err := os.ReadFile(serviceAccountDir + "/token")
+    if err != nil {
+        return nil, fmt.Errorf("failed to read service account token: %v", err)
+    }
+
+    host := os.Getenv("KUBERNETES_SERVICE_HOST")
+    port := envOrDefault("KUBERNETES_SERVICE_PORT", "443")
+    if host == "" {
+        return nil, fmt.Errorf("KUBERNETES_SERVICE_HOST is not set, not running in a cluster")
+    }
+    apiURL := fmt.Sprintf("https://%s:%s/api/v1/namespaces/%s/configmaps/%s", host, port, url.PathEscape(cm.namespace), url.PathEscape(cm.name))
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
+    if err != nil {
+        return nil, err
+    }
+    req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+    req.Header.Set("Accept", "application/json")
+    return req, nil
+}
+
+// clusterClient returns a client trusting the cluster CA, for configmap locations
+func (l *Loader) clusterClient() (*http.Client, error) {
+    if l.cluster != nil {
+        return l.cluster, nil
+    }
+    ca, err := os.ReadFile(serviceAccountDir + "/ca.crt")
+    if err != nil {
+        return nil, fmt.Errorf("failed to read cluster CA: %v", err)
+    }
+    pool := x509.NewCertPool()
+    if !pool.AppendCertsFromPEM(ca) {
+        return nil, fmt.Errorf("no certificates in %s/ca.crt", serviceAccountDir)
+    }
+    transport := http.DefaultTransport.(*http.Transport).Clone()
+    transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+    l.cluster = &http.Client{Timeout: l.client.Timeout, Transport: transport}
+    return l.cluster, nil
+}
+
+// configMapData extracts the configuration key of a ConfigMap, with its resource version
+func configMapData(body io.Reader, location string) ([]byte, string, error) {
+    cm, err := parseConfigMapLocation(location)
+    if err != nil {
+        return nil, "", err
+    }
+    var object struct {
+        Metadata struct {
+            ResourceVersion string `json:"resourceVersion"`
+        } `json:"metadata"`
+        Data map[string]string `json:"data"`
+    }
+    if err := json.NewDecoder(body).Decode(&object); err != nil {
+        return nil, "", fmt.Errorf("invalid ConfigMap response: %v", err)
+    }
+    data, ok := object.Data[cm.key]
+    if !ok {
+        return nil, "", fmt.Errorf("ConfigMap %s has no key %s", cm.name, cm.key)
+    }
+    return []byte(data), object.Metadata.ResourceVersion, nil
+}
+
+// envOrDefault returns the environment variable or a fallback when it is unset
+func envOrDefault(key, fallback string) string {
+    if v := os.Getenv(key); v != "" {
+        return v
+    }
+    return fallback
+}
@@ -0,0 +1,262 @@
+package config
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "os"
+    "path/filepath"
+    "regexp"
+    "strconv"
+    "strings"
+
+    "gopkg.in/yaml.v3"
+)
+
+// reference matches the ${NAME} references expanded in the string values of a configuration.
+// A bare $ is left alone, since JSONPath expressions such as $.online start with one.
+var reference = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// secretReference is a string value of a configuration that was read with ${NAME} references
+type secretReference struct {
+    original string
+    expanded string
+}
+
+// expandReferences replaces every ${NAME} in the string values of a JSON configuration with
+// the secret NAME from its secrets_file or, when that has none, the environment variable NAME.
+// A relative secrets_file is taken relative to dir, the directory of the configuration file,
+// when it is known. It returns the expanded document and the expanded strings by their path,
+// so Save can write the references back instead of the secrets.
+func expandReferences(data []byte, dir string) ([]byte, map[string]secretReference, error) {
+    if !reference.Match(data) {
+        return data, nil, nil
+    }
+
+    doc, err := decodeTree(data)
+    if err != nil {
+        return nil, nil, err
+    }
+    secrets := map[string]string{}
+    if path, ok := doc.field("secrets_file").str(); ok && path != "" {
+        if dir != "" && !filepath.IsAbs(path) {
+            path = filepath.Join(dir, path)
+        }
+        if secrets, err = readSecrets(path); err != nil {
+            return nil, nil, err
+        }
+    }
+
+    originals := map[*jsonValue]string{}
+    var missing []string
+    doc.walk("", func(_ string, v *jsonValue) {
+        value, ok := v.str()
+        if !ok || !reference.MatchString(value) {
+            return
+        }
+        v.scalar = reference.ReplaceAllStringFunc(value, func(match string) string {
+            name := reference.FindStringSubmatch(match)[1]
+            if secret, ok := secrets[name]; ok {
+                return secret
+            }
+            if env, ok := os.LookupEnv(name); ok {
+                return env
+            }
+            missing = append(missing, name)
+            return match
+        })
+        originals[v] = value
+    })
+    if len(missing) > 0 {
+        return nil, nil, fmt.Errorf("%s is neither in the secrets file nor set in the environment", missing[0])
+    }
+
+    // Paths are taken once every value is expanded, so they hold the names Save will see
+    references := map[string]secretReference{}
+    doc.walk("", func(path string, v *jsonValue) {
+        if original, ok := originals[v]; ok {
+            references[path] = secretReference{original: original, expanded: v.scalar.(string)}
+        }
+    })
+    return doc.encode(), references, nil
+}
+
+// readSecrets reads a secrets file, a JSON or YAML object of names to values
+func readSecrets(path string) (map[string]string, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, fmt.Errorf("secrets_file: %w", err)
+    }
+    var secrets map[string]string
+    if err := yaml.Unmarshal(data, &secrets); err != nil {
+        return nil, fmt.Errorf("secrets_file %s: %v", path, err)
+    }
+    return secrets, nil
+}
+
+// restoreReferences writes the references back into an indented JSON document. A value is
+// only restored when it is still the expanded secret at the path it was read from, so
+// other values that happen to equal a secret keep their text.
+func restoreReferences(data []byte, references map[string]secretReference) ([]byte, error) {
+    if len(references) == 0 {
+        return data, nil
+    }
+    doc, err := decodeTree(data)
+    if err != nil {
+        return nil, err
+    }
+    doc.walk("", func(path string, v *jsonValue) {
+        if ref, ok := references[path]; ok {
+            if value, ok := v.str(); ok && value == ref.expanded {
+                v.scalar = ref.original
+            }
+        }
+    })
+    var indented bytes.Buffer
+    if err := json.Indent(&indented, doc.encode(), "", "  "); err != nil {
+        return nil, err
+    }
+    return indented.Bytes(), nil
+}
+
+// jsonValue is a decoded JSON value that keeps the order of object fields, so a rewritten
+// document reads like the one it came from
+type jsonValue struct {
+    // kind is '{' for objects, '[' for arrays and 0 for other values, kept in scalar
+    kind   byte
+    fields []jsonField
+    items  []*jsonValue
+    scalar interface{}
+}
+
+type jsonField struct {
+    key   string
+    value *jsonValue
+}
+
+// decodeTree decodes a JSON document, keeping numbers as they were written
+func decodeTree(data []byte) (*jsonValue, error) {
+    decoder := json.NewDecoder(bytes.NewReader(data))
+    decoder.UseNumber()
+    return decodeValue(decoder)
+}
+
+func decodeValue(decoder *json.Decoder) (*jsonValue, error) {
+    token, err := decoder.Token()
+    if err != nil {
+        return nil, err
+    }
+    switch token {
+    case json.Delim('{'):
+        v := &jsonValue{kind: '{'}
+        for decoder.More() {
+            key, err := decoder.Token()
+            if err != nil {
+                return nil, err
+            }
+            value, err := decodeValue(decoder)
+            if err != nil {
+                return nil, err
+            }
+            v.fields = append(v.fields, jsonField{key: key.(string), value: value})
+        }
+        _, err = decoder.Token()
+        return v, err
+    case json.Delim('['):
+        v := &jsonValue{kind: '['}
+        for decoder.More() {
+            item, err := decodeValue(decoder)
+            if err != nil {
+                return nil, err
+            }
+            v.items = append(v.items, item)
+        }
+        _, err = decoder.Token()
+        return v, err
+    }
+    return &jsonValue{scalar: token}, nil
+}
+
+// field returns the value of an object's field, nil when there is none
+func (v *jsonValue) field(key string) *jsonValue {
+    if v == nil {
+        return nil
+    }
+    for _, f := range v.fields {
+        if f.key == key {
+            return f.value
+        }
+    }
+    return nil
+}
+
+// str returns the value of a string
+func (v *jsonValue) str() (string, bool) {
+    if v == nil {
+        return "", false
+    }
+    value, ok := v.scalar.(string)
+    return value, ok
+}
+
+// pathEscaper escapes object keys as in a JSON pointer
+var pathEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// walk calls fn with every value of the document and its path. Array items that are
+// objects with a unique name, such as gateways, are addressed by "name=<name>" rather than
+// their index, so gateways added or removed at runtime don't move the paths of the others.
+func (v *jsonValue) walk(path string, fn func(path string, v *jsonValue)) {
+    fn(path, v)
+    for _, f := range v.fields {
+        f.value.walk(path+"/"+pathEscaper.Replace(f.key), fn)
+    }
+    names := map[string]int{}
+    for _, item := range v.items {
+        if name, ok := item.field("name").str(); ok {
+            names[name]++
+        }
+    }
+    for i, item := range v.items {
+        segment := strconv.Itoa(i)
+        if name, ok := item.field("name").str(); ok && names[name] == 1 {
+            segment = "name=" + pathEscaper.Replace(name)
+        }
+        item.walk(path+"/"+segment, fn)
+    }
+}
+
+// encode writes the value as compact JSON
+func (v *jsonValue) encode() []byte {
+    var buf bytes.Buffer
+    v.encodeTo(&buf)
+    return buf.Bytes()
+}
+
+func (v *jsonValue) encodeTo(buf *bytes.Buffer) {
+    switch v.kind {
+    case '{':
+        buf.WriteByte('{')
+        for i, f := range v.fields {
+            if i > 0 {
+                buf.WriteByte(',')
+            }
+            key, _ := json.Marshal(f.key)
+            buf.Write(key)
+            buf.WriteByte(':')
+            f.value.encodeTo(buf)
+        }
+        buf.WriteByte('}')
+    case '[':
+        buf.WriteByte('[')
+        for i, item := range v.items {
+            if i > 0 {
+                buf.WriteByte(',')
+            }
+            item.encodeTo(buf)
+        }
+        buf.WriteByte(']')
+    default:
+        scalar, _ := json.Marshal(v.scalar)
+        buf.Write(scalar)
+    }
+}
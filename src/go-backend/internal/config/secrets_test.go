@@ -0,0 +1,130 @@
+package config
+
+import (
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+// readWithSecrets writes the configuration and its secrets file to a temporary directory
+// and reads it back
+func readWithSecrets(t *testing.T, gateways string) (*GatewaysFile, string) {
+    t.Helper()
+    dir := t.TempDir()
+    secrets := filepath.Join(dir, "secrets.yaml")
+    if err := os.WriteFile(secrets, []byte("TTN_KEY: abc\nAPI_KEY: NNSXS.secret\n"), 0o600); err != nil {
+        t.Fatal(err)
+    }
+    path := filepath.Join(dir, "gateways.json")
+    data := strings.ReplaceAll(gateways, "SECRETS_FILE", secrets)
+    if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+        t.Fatal(err)
+    }
+    gatewaysFile, err := Read(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return gatewaysFile, path
+}
+
+func TestSaveRestoresReferencesByPath(t *testing.T) {
+    gatewaysFile, path := readWithSecrets(t, `{
+  "secrets_file": "SECRETS_FILE",
+  "gateways": [
+    {"name": "gw1", "owner": "abc", "location": {"latitude": 1, "longitude": 2},
+     "checks": [{"type": "tts", "url": "https://eu1.cloud.thethings.network", "gateway_id": "abc", "api_key": "${TTN_KEY}"}]},
+    {"name": "gw2", "location": {"latitude": 3, "longitude": 4},
+     "checks": [{"type": "http", "url": "https://example.com/abc"}, {"type": "tts", "url": "https://eu1.cloud.thethings.network", "gateway_id": "gw2", "api_key": "${API_KEY}"}]}
+  ]
+}`)
+    if got := gatewaysFile.Gateways[0].Checks[0].APIKey; got != "abc" {
+        t.Fatalf("api_key = %q, want the secret", got)
+    }
+
+    // Removing the first gateway must not lose the reference of the second one
+    gatewaysFile.Gateways = gatewaysFile.Gateways[1:]
+    if err := Save(path, gatewaysFile); err != nil {
+        t.Fatal(err)
+    }
+    saved, err := os.ReadFile(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !strings.Contains(string(saved), `"api_key": "${API_KEY}"`) {
+        t.Errorf("saved configuration lost the ${API_KEY} reference:\n%s", saved)
+    }
+    if strings.Contains(string(saved), "NNSXS.secret") {
+        t.Errorf("saved configuration holds the secret:\n%s", saved)
+    }
+    if strings.Contains(string(saved), "${TTN_KEY}") {
+        t.Errorf("a value equal to a secret was turned into a reference:\n%s", saved)
+    }
+}
+
+func TestSaveKeepsValuesEqualToSecrets(t *testing.T) {
+    gatewaysFile, path := readWithSecrets(t, `{
+  "secrets_file": "SECRETS_FILE",
+  "gateways": [
+    {"name": "abc", "owner": "abc", "location": {"latitude": 1, "longitude": 2},
+     "checks": [{"type": "tts", "url": "https://eu1.cloud.thethings.network", "gateway_id": "abc", "api_key": "${TTN_KEY}"}]}
+  ]
+}`)
+    if err := Save(path, gatewaysFile); err != nil {
+        t.Fatal(err)
+    }
+    data := string(mustRead(t, path))
+    saved, err := Decode([]byte(data))
+    if err != nil {
+        t.Fatal(err)
+    }
+    gateway := saved.Gateways[0]
+    check := gateway.Checks[0]
+    if gateway.Name != "abc" || gateway.Owner != "abc" || check.GatewayID != "abc" {
+        t.Errorf("values equal to the secret were rewritten: name %q, owner %q, gateway_id %q", gateway.Name, gateway.Owner, check.GatewayID)
+    }
+    if !strings.Contains(data, `"api_key": "${TTN_KEY}"`) {
+        t.Errorf("saved configuration lost the ${TTN_KEY} reference:\n%s", data)
+    }
+    if strings.Index(data, `"name"`) > strings.Index(data, `"checks"`) {
+        t.Error("saved configuration changed the field order")
+    }
+}
+
+func mustRead(t *testing.T, path string) []byte {
+    t.Helper()
+    data, err := os.ReadFile(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return data
+}
+
+func TestRelativeSecretsFile(t *testing.T) {
+    dir := t.TempDir()
+    if err := os.WriteFile(filepath.Join(dir, "secrets.yaml"), []byte("TTN_KEY: abc\n"), 0o600); err != nil {
+        t.Fatal(err)
+    }
+    path := filepath.Join(dir, "gateways.json")
+    data := `{"secrets_file": "secrets.yaml", "gateways": [{"name": "gw1", "checks": [{"type": "tts", "gateway_id": "gw1", "api_key": "${TTN_KEY}"}]}]}`
+    if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+        t.Fatal(err)
+    }
+
+    // The working directory is another one
+    wd, err := os.Getwd()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := os.Chdir(t.TempDir()); err != nil {
+        t.Fatal(err)
+    }
+    defer os.Chdir(wd)
+    gatewaysFile, err := Read(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if got := gatewaysFile.Gateways[0].Checks[0].APIKey; got != "abc" {
+        t.Errorf("api_key = %q, want the secret from secrets.yaml next to gateways.json", got)
+    }
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+    "fmt"
+    "net/http"
+    "strconv"
+    "strings"
+    "text/template"
+)
+
+// Step is one HTTP request of a multi-step check. URL, header values and body are
+// templates that can refer to variables extracted by earlier steps, e.g. {{.session}}.
+type Step struct {
+    Name    string            `json:"name,omitempty"`
+    Method  string            `json:"method,omitempty"`
+    URL     string            `json:"url"`
+    Headers map[string]string `json:"headers,omitempty"`
+    Body    string            `json:"body,omitempty"`
+
+    // Extract maps a variable name to a dotted JSON path in the response, e.g. "data.token"
+    Extract map[string]string `json:"extract,omitempty"`
+
+    // ExpectedStatus is the required HTTP status; any 2xx status passes when it is 0
+    ExpectedStatus int `json:"expected_status,omitempty"`
+
+    // Expect maps dotted JSON paths to the values they must have. On the final step
+    // these assertions decide whether the gateway is online.
+    Expect map[string]interface{} `json:"expect,omitempty"`
+}
+
+// Label names the step in logs and errors
+func (s Step) Label(index int) string {
+    if s.Name != "" {
+        return s.Name
+    }
+    return "step " + strconv.Itoa(index+1)
+}
+
+// HTTPMethod returns the request method, defaulting to GET
+func (s Step) HTTPMethod() string {
+    if s.Method == "" {
+        return http.MethodGet
+    }
+    return strings.ToUpper(s.Method)
+}
+
+// Validate checks that the step can be executed
+func (s Step) Validate() error {
+    if s.URL == "" {
+        return fmt.Errorf("url is required")
+    }
+    switch s.HTTPMethod() {
+    case http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodHead:
+    default:
+        return fmt.Errorf("unsupported method %s", s.Method)
+    }
+
+    templates := map[string]string{"url": s.URL, "body": s.Body}
+    for name, value := range s.Headers {
+        templates["header "+name] = value
+    }
+    for field, text := range templates {
+        if _, err := template.New(field).Parse(text); err != nil {
+            return fmt.Errorf("invalid template in %s: %v", field, err)
+        }
+    }
+
+    for variable, path := range s.Extract {
+        if variable == "" || path == "" {
+            return fmt.Errorf("extract entries need a variable name and a JSON path")
+        }
+    }
+    return nil
+}
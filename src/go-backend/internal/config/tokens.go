@@ -0,0 +1,57 @@
+package config
+
+import "fmt"
+
+// Roles of API tokens; each role may do everything the roles before it may
+const (
+    // RoleViewer reads the status, history and configuration
+    RoleViewer = "viewer"
+    // RoleOperator also silences gateways and saves the running configuration
+    RoleOperator = "operator"
+    // RoleAdmin also adds, changes and removes gateways
+    RoleAdmin = "admin"
+)
+
+// roleRanks orders the roles
+var roleRanks = map[string]int{RoleViewer: 1, RoleOperator: 2, RoleAdmin: 3}
+
+// RoleAllows reports whether role grants the access of required; an unknown role grants nothing
+func RoleAllows(role, required string) bool {
+    return roleRanks[role] > 0 && roleRanks[role] >= roleRanks[required]
+}
+
+// minTokenLength keeps API tokens from being guessable
+const minTokenLength = 16
+
+// APIToken grants its role on the management API to the requests carrying Token as a bearer
+// token. Name identifies the team or tool in logs and errors; Token is best taken from the
+// secrets file as ${NAME}.
+type APIToken struct {
+    Name  string `json:"name"`
+    Token string `json:"token"`
+    Role  string `json:"role"`
+}
+
+// validateAPITokens checks that every token is named, has a known role and is long enough,
+// and that names and tokens are unique
+func validateAPITokens(tokens []APIToken) []error {
+    var problems []error
+    names, values := map[string]bool{}, map[string]bool{}
+    for i, token := range tokens {
+        switch {
+        case token.Name == "":
+            problems = append(problems, fmt.Errorf("api token %d has no name", i+1))
+        case names[token.Name]:
+            problems = append(problems, fmt.Errorf("duplicate api token name %s", token.Name))
+        case roleRanks[token.Role] == 0:
+            problems = append(problems, fmt.Errorf("api token %s: role must be %q, %q or %q", token.Name, RoleViewer, RoleOperator, RoleAdmin))
+        case len(token.Token) < minTokenLength:
+            problems = append(problems, fmt.Errorf("api token %s: token must be at least %d characters", token.Name, minTokenLength))
+        case values[token.Token]:
+            problems = append(problems, fmt.Errorf("api token %s: token is already used by another api token", token.Name))
+        }
+        names[token.Name] = true
+        values[token.Token] = true
+    }
+    return problems
+}
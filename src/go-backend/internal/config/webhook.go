@@ -0,0 +1,46 @@
+package config
+
+import (
+    "fmt"
+    "net/url"
+)
+
+// defaultWebhookRetries is how often a failed webhook delivery is retried unless configured
+const defaultWebhookRetries = 3
+
+// WebhookNotification posts every gateway state transition as JSON to URL
+type WebhookNotification struct {
+    URL string `json:"url"`
+    // The HMAC-SHA256 signing key, read from SecretEnv or, failing that, taken from Secret.
+    // Deliveries are unsigned without one.
+    SecretEnv string `json:"secret_env,omitempty"`
+    Secret    string `json:"secret,omitempty"`
+    // Retries is how often a failed delivery is retried; nil uses the default, 0 disables retries
+    Retries *int              `json:"retries,omitempty"`
+    Headers map[string]string `json:"headers,omitempty"`
+}
+
+// ResolveSecret returns the signing key
+func (w WebhookNotification) ResolveSecret() string {
+    return fromEnv(w.SecretEnv, w.Secret)
+}
+
+// DeliveryRetries returns how often a failed delivery is retried
+func (w WebhookNotification) DeliveryRetries() int {
+    if w.Retries == nil {
+        return defaultWebhookRetries
+    }
+    return *w.Retries
+}
+
+// Validate checks the webhook settings
+func (w WebhookNotification) Validate() error {
+    u, err := url.Parse(w.URL)
+    if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+        return fmt.Errorf("invalid url %q", w.URL)
+    }
+    if w.Retries != nil && *w.Retries < 0 {
+        return fmt.Errorf("retries must not be negative")
+    }
+    return nil
+}
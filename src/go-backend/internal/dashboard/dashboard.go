@@ -0,0 +1,203 @@
+// Package dashboard writes a Grafana dashboard file for each monitored gateway and one for
+// the whole fleet.
+package dashboard
+
+import (
+    "fmt"
+    "log"
+    "os"
+    "path/filepath"
+    "text/template"
+
+    "gateway-monitor/internal/config"
+)
+
+// DefaultDir is where Grafana picks up provisioned dashboards
+const DefaultDir = "/var/lib/grafana/dashboards"
+
+// dashboardTemplate is rendered once per gateway
+const dashboardTemplate = `
+{
+  "id": null,
+  "title": "Gateway Monitor Dashboard",
+  "tags": [],
+  "timezone": "browser",
+  "schemaVersion": 16,
+  "version": 1,
+  "panels": [
+    {
+      "type": "stat",
+      "title": "Gateway Uptime (Last 7 Days)",
+      "targets": [
+        {
+          "expr": "rate(up{job='gateway'}[1w])",
+          "legendFormat": "{{.Name}} uptime",
+          "refId": "A"
+        }
+      ],
+      "options": {
+        "reduceOptions": {
+          "calcs": ["mean"],
+          "fields": "",
+          "values": false
+        },
+        "orientation": "auto",
+        "textMode": "value"
+      },
+      "datasource": "Prometheus",
+      "fieldConfig": {
+        "defaults": {
+          "thresholds": {
+            "mode": "percentage",
+            "steps": [
+              {"color": "red", "value": 0},
+              {"color": "green", "value": 95}
+            ]
+          }
+        }
+      },
+      "gridPos": {
+        "h": 6,
+        "w": 8,
+        "x": 0,
+        "y": 0
+      }
+    },
+    {
+      "type": "status-history",
+      "title": "Gateway Status History",
+      "targets": [
+        {
+          "expr": "up{job='gateway', instance='{{.Name}}'}",
+          "refId": "A"
+        }
+      ],
+      "options": {
+        "reduceOptions": {
+          "calcs": ["lastNotNull"],
+          "fields": "",
+          "values": false
+        },
+        "orientation": "auto",
+        "showValue": "auto"
+      },
+      "fieldConfig": {
+        "defaults": {
+          "thresholds": {
+            "steps": [
+              {"color": "red", "value": 0},
+              {"color": "orange", "value": 1},
+              {"color": "green", "value": 2}
+            ]
+          }
+        }
+      },
+      "datasource": "Prometheus",
+      "gridPos": {
+        "h": 6,
+        "w": 8,
+        "x": 8,
+        "y": 0
+      }
+    },
+    {
+      "type": "geomap",
+      "title": "Gateway Geomap",
+      "targets": [
+        {
+          "expr": "gateway_geo_status{name='{{.Name}}'}",
+          "format": "table",
+          "instant": true,
+          "refId": "A"
+        }
+      ],
+      "fieldConfig": {
+        "defaults": {
+          "color": {"mode": "thresholds"},
+          "thresholds": {
+            "mode": "absolute",
+            "steps": [
+              {"color": "red", "value": null},
+              {"color": "green", "value": 1},
+              {"color": "blue", "value": 2}
+            ]
+          }
+        }
+      },
+      "options": {
+        "layers": [
+          {
+            "type": "markers",
+            "name": "Gateways",
+            "location": {"mode": "geohash", "geohash": "geohash"},
+            "config": {
+              "style": {
+                "color": {"field": "Value", "fixed": "green"},
+                "size": {"fixed": 8}
+              }
+            }
+          }
+        ],
+        "mapView": {
+          "lat": {{.Location.Latitude}},
+          "lon": {{.Location.Longitude}},
+          "zoom": 6
+        }
+      },
+      "gridPos": {
+        "h": 10,
+        "w": 16,
+        "x": 0,
+        "y": 6
+      }
+    }
+  ]
+}
+`
+
+// Generator creates dashboard files in Dir
+type Generator struct {
+    Dir    string
+    logger *log.Logger
+}
+
+// New creates a Generator writing to dir
+func New(dir string, logger *log.Logger) *Generator {
+    return &Generator{Dir: dir, logger: logger}
+}
+
+// Create creates the dashboard JSON for a gateway
+func (g *Generator) Create(gateway config.Gateway) error {
+    g.logger.Printf("Creating dashboard for %s", gateway.Name)
+
+    filePath := filepath.Join(g.Dir, fmt.Sprintf("%s-dashboard.json", gateway.Name))
+    file, err := os.Create(filePath)
+    if err != nil {
+        return fmt.Errorf("failed to create dashboard file: %v", err)
+    }
+    defer file.Close()
+
+    tmpl, err := template.New("dashboard").Parse(dashboardTemplate)
+    if err != nil {
+        return fmt.Errorf("failed to parse template: %v", err)
+    }
+
+    if err := tmpl.Execute(file, gateway); err != nil {
+        return fmt.Errorf("failed to execute template: %v", err)
+    }
+
+    g.logger.Printf("Dashboard created for %s", gateway.Name)
+    return nil
+}
+
+// CreateFleet writes the fleet dashboard for the gateways, replacing the previous one
+func (g *Generator) CreateFleet(gateways []config.Gateway) error {
+    body, err := Fleet(gateways, Options{}).Marshal()
+    if err != nil {
+        return fmt.Errorf("failed to encode fleet dashboard: %v", err)
+    }
+    if err := os.WriteFile(filepath.Join(g.Dir, FleetFile), body, 0o644); err != nil {
+        return fmt.Errorf("failed to create fleet dashboard file: %v", err)
+    }
+    return nil
+}
@@ -0,0 +1,336 @@
+package dashboard
+
+import (
+    "encoding/json"
+    "fmt"
+    "sort"
+    "strconv"
+
+    "gateway-monitor/internal/config"
+)
+
+// FleetFile is the file the fleet dashboard is written to next to the per-gateway ones
+const FleetFile = "loracheck-fleet.json"
+
+// Options of the fleet dashboard
+type Options struct {
+    // Title of the dashboard, "LoRaCheck Fleet" by default
+    Title string
+    // Datasource is the name of the Prometheus datasource, "Prometheus" by default
+    Datasource string
+}
+
+// DefaultOptions are used for the options left empty
+var DefaultOptions = Options{
+    Title:      "LoRaCheck Fleet",
+    Datasource: "Prometheus",
+}
+
+// Dashboard is a Grafana dashboard as imported through the UI or provisioned from a file
+type Dashboard struct {
+    ID            *int      `json:"id"`
+    UID           string    `json:"uid"`
+    Title         string    `json:"title"`
+    Tags          []string  `json:"tags"`
+    Timezone      string    `json:"timezone"`
+    SchemaVersion int       `json:"schemaVersion"`
+    Version       int       `json:"version"`
+    Refresh       string    `json:"refresh"`
+    Time          TimeRange `json:"time"`
+    Panels        []Panel   `json:"panels"`
+}
+
+// TimeRange is the default time range of a dashboard
+type TimeRange struct {
+    From string `json:"from"`
+    To   string `json:"to"`
+}
+
+// Panel is a panel or, with type "row", a row heading the panels below it
+type Panel struct {
+    ID              int                      `json:"id"`
+    Type            string                   `json:"type"`
+    Title           string                   `json:"title"`
+    Datasource      string                   `json:"datasource,omitempty"`
+    Targets         []Target                 `json:"targets,omitempty"`
+    FieldConfig     map[string]interface{}   `json:"fieldConfig,omitempty"`
+    Options         map[string]interface{}   `json:"options,omitempty"`
+    Transformations []map[string]interface{} `json:"transformations,omitempty"`
+    Collapsed       *bool                    `json:"collapsed,omitempty"`
+    GridPos         GridPos                  `json:"gridPos"`
+}
+
+// Target is a Prometheus query of a panel
+type Target struct {
+    Expr         string `json:"expr"`
+    LegendFormat string `json:"legendFormat,omitempty"`
+    Format       string `json:"format,omitempty"`
+    Instant      bool   `json:"instant,omitempty"`
+    RefID        string `json:"refId"`
+}
+
+// GridPos places a panel on the 24 column grid
+type GridPos struct {
+    H int `json:"h"`
+    W int `json:"w"`
+    X int `json:"x"`
+    Y int `json:"y"`
+}
+
+// statusMappings show the 0 and 1 of gateway_online_status as words
+var statusMappings = []interface{}{map[string]interface{}{
+    "type": "value",
+    "options": map[string]interface{}{
+        "0": map[string]interface{}{"text": "Offline", "color": "red", "index": 0},
+        "1": map[string]interface{}{"text": "Online", "color": "green", "index": 1},
+    },
+}}
+
+// Fleet builds a dashboard for the gateways: stats, a status table, a map and the
+// availability of the whole fleet, then a row per project with the status history and
+// availability of its gateways. Gateways without a project share a row at the end.
+func Fleet(gateways []config.Gateway, opts Options) Dashboard {
+    opts = opts.withDefaults()
+    b := builder{datasource: opts.Datasource}
+
+    b.add(stat("Online", "sum(gateway_online_status)", "green"), 6, 4)
+    b.add(stat("Offline", "count(gateway_online_status == 0) or vector(0)", "red"), 6, 4)
+    b.add(stat("In maintenance", "sum(gateway_in_maintenance) or vector(0)", "blue"), 6, 4)
+    b.add(stat("Gateways", "count(max by (name) (gateway_online_status)) or vector(0)", "text"), 6, 4)
+    b.add(statusTable(), 10, 12)
+    b.add(geomap(gateways), 14, 12)
+    b.add(availability("Availability", ""), 24, 8)
+
+    projects := map[string][]config.Gateway{}
+    var names []string
+    for _, gateway := range gateways {
+        if _, ok := projects[gateway.Project]; !ok {
+            names = append(names, gateway.Project)
+        }
+        projects[gateway.Project] = append(projects[gateway.Project], gateway)
+    }
+    sort.Slice(names, func(i, j int) bool {
+        // The gateways without a project come last
+        if names[i] == "" || names[j] == "" {
+            return names[j] == ""
+        }
+        return names[i] < names[j]
+    })
+    for _, project := range names {
+        title := "Project " + project
+        if project == "" {
+            title = "No project"
+        }
+        count := len(projects[project])
+        b.add(row(fmt.Sprintf("%s (%d %s)", title, count, plural(count, "gateway"))), 24, 1)
+        selector := "project=" + strconv.Quote(project)
+        b.add(statusHistory(selector), 14, 8)
+        b.add(availability("Availability", ","+selector), 10, 8)
+    }
+
+    return Dashboard{
+        UID:           "loracheck-fleet",
+        Title:         opts.Title,
+        Tags:          []string{"loracheck"},
+        Timezone:      "browser",
+        SchemaVersion: 39,
+        Version:       1,
+        Refresh:       "1m",
+        Time:          TimeRange{From: "now-7d", To: "now"},
+        Panels:        b.panels,
+    }
+}
+
+// Marshal encodes the dashboard as indented JSON
+func (d Dashboard) Marshal() ([]byte, error) {
+    body, err := json.MarshalIndent(d, "", "  ")
+    if err != nil {
+        return nil, err
+    }
+    return append(body, '\n'), nil
+}
+
+// builder lays out panels left to right, starting a new line when one does not fit
+type builder struct {
+    datasource string
+    panels     []Panel
+    x, y, h    int
+}
+
+func (b *builder) add(panel Panel, w, h int) {
+    if b.x+w > 24 {
+        b.x, b.y, b.h = 0, b.y+b.h, 0
+    }
+    panel.ID = len(b.panels) + 1
+    if panel.Type != "row" {
+        panel.Datasource = b.datasource
+    }
+    panel.GridPos = GridPos{H: h, W: w, X: b.x, Y: b.y}
+    b.panels = append(b.panels, panel)
+    b.x += w
+    b.h = max(b.h, h)
+}
+
+func stat(title, expr, color string) Panel {
+    return Panel{
+        Type:    "stat",
+        Title:   title,
+        Targets: []Target{{Expr: expr, Instant: true, RefID: "A"}},
+        FieldConfig: map[string]interface{}{"defaults": map[string]interface{}{
+            "color": map[string]interface{}{"mode": "fixed", "fixedColor": color},
+        }},
+        Options: map[string]interface{}{
+            "reduceOptions": map[string]interface{}{"calcs": []string{"lastNotNull"}, "fields": "", "values": false},
+            "colorMode":     "value",
+            "textMode":      "value",
+        },
+    }
+}
+
+func statusTable() Panel {
+    return Panel{
+        Type:    "table",
+        Title:   "Gateway status",
+        Targets: []Target{{Expr: "max by (name, project) (gateway_online_status)", Format: "table", Instant: true, RefID: "A"}},
+        FieldConfig: map[string]interface{}{
+            "defaults": map[string]interface{}{},
+            "overrides": []interface{}{map[string]interface{}{
+                "matcher": map[string]interface{}{"id": "byName", "options": "Value"},
+                "properties": []interface{}{
+                    map[string]interface{}{"id": "displayName", "value": "Status"},
+                    map[string]interface{}{"id": "mappings", "value": statusMappings},
+                    map[string]interface{}{"id": "custom.cellOptions", "value": map[string]interface{}{"type": "color-background"}},
+                },
+            }},
+        },
+        Options: map[string]interface{}{
+            "sortBy": []interface{}{map[string]interface{}{"displayName": "Status", "desc": false}},
+        },
+        Transformations: []map[string]interface{}{{
+            "id": "organize",
+            "options": map[string]interface{}{
+                "excludeByName": map[string]interface{}{"Time": true},
+                "renameByName":  map[string]interface{}{"name": "Gateway", "project": "Project"},
+            },
+        }},
+    }
+}
+
+// geomap shows the located gateways coloured by state, centred on their average position
+func geomap(gateways []config.Gateway) Panel {
+    var latitude, longitude float64
+    located := 0
+    for _, gateway := range gateways {
+        if gateway.Location.Located() {
+            latitude += gateway.Location.Latitude
+            longitude += gateway.Location.Longitude
+            located++
+        }
+    }
+    view := map[string]interface{}{"id": "zero", "lat": 0, "lon": 0, "zoom": 1}
+    if located > 0 {
+        view = map[string]interface{}{"id": "coords", "lat": latitude / float64(located), "lon": longitude / float64(located), "zoom": 6}
+    }
+
+    return Panel{
+        Type:    "geomap",
+        Title:   "Gateway map",
+        Targets: []Target{{Expr: "gateway_geo_status", Format: "table", Instant: true, RefID: "A"}},
+        FieldConfig: map[string]interface{}{"defaults": map[string]interface{}{
+            "color": map[string]interface{}{"mode": "thresholds"},
+            "thresholds": map[string]interface{}{
+                "mode": "absolute",
+                "steps": []interface{}{
+                    map[string]interface{}{"color": "red", "value": nil},
+                    map[string]interface{}{"color": "green", "value": 1},
+                    map[string]interface{}{"color": "blue", "value": 2},
+                },
+            },
+        }},
+        Options: map[string]interface{}{
+            "layers": []interface{}{map[string]interface{}{
+                "type":     "markers",
+                "name":     "Gateways",
+                "location": map[string]interface{}{"mode": "geohash", "geohash": "geohash"},
+                "config": map[string]interface{}{"style": map[string]interface{}{
+                    "color": map[string]interface{}{"field": "Value", "fixed": "green"},
+                    "size":  map[string]interface{}{"fixed": 8},
+                }},
+            }},
+            "mapView": view,
+        },
+    }
+}
+
+// availability shows the share of the dashboard's time range each gateway was online,
+// leaving out maintenance; selector adds label matchers to the queries
+func availability(title, selector string) Panel {
+    expr := fmt.Sprintf(`100 * sum by (name) (increase(gateway_availability_seconds_total{state="online"%s}[$__range]))`+
+        ` / sum by (name) (increase(gateway_availability_seconds_total{state=~"online|offline"%s}[$__range]))`, selector, selector)
+    return Panel{
+        Type:    "bargauge",
+        Title:   title,
+        Targets: []Target{{Expr: expr, LegendFormat: "{{name}}", Instant: true, RefID: "A"}},
+        FieldConfig: map[string]interface{}{"defaults": map[string]interface{}{
+            "unit": "percent",
+            "min":  0,
+            "max":  100,
+            "thresholds": map[string]interface{}{
+                "mode": "absolute",
+                "steps": []interface{}{
+                    map[string]interface{}{"color": "red", "value": nil},
+                    map[string]interface{}{"color": "orange", "value": 95},
+                    map[string]interface{}{"color": "green", "value": 99},
+                },
+            },
+        }},
+        Options: map[string]interface{}{
+            "orientation":   "horizontal",
+            "displayMode":   "gradient",
+            "reduceOptions": map[string]interface{}{"calcs": []string{"lastNotNull"}, "fields": "", "values": false},
+        },
+    }
+}
+
+func statusHistory(selector string) Panel {
+    return Panel{
+        Type:    "status-history",
+        Title:   "Status history",
+        Targets: []Target{{Expr: fmt.Sprintf("max by (name) (gateway_online_status{%s})", selector), LegendFormat: "{{name}}", RefID: "A"}},
+        FieldConfig: map[string]interface{}{"defaults": map[string]interface{}{
+            "mappings": statusMappings,
+            "color":    map[string]interface{}{"mode": "thresholds"},
+            "thresholds": map[string]interface{}{
+                "mode": "absolute",
+                "steps": []interface{}{
+                    map[string]interface{}{"color": "red", "value": nil},
+                    map[string]interface{}{"color": "green", "value": 1},
+                },
+            },
+        }},
+        Options: map[string]interface{}{"showValue": "never"},
+    }
+}
+
+func row(title string) Panel {
+    collapsed := false
+    return Panel{Type: "row", Title: title, Collapsed: &collapsed}
+}
+
+// plural returns the noun for count things
+func plural(count int, noun string) string {
+    if count == 1 {
+        return noun
+    }
+    return noun + "s"
+}
+
+func (o Options) withDefaults() Options {
+    if o.Title == "" {
+        o.Title = DefaultOptions.Title
+    }
+    if o.Datasource == "" {
+        o.Datasource = DefaultOptions.Datasource
+    }
+    return o
+}
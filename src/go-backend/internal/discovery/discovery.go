@@ -0,0 +1,136 @@
+// Package discovery lists the gateways registered on a The Things Stack cluster.
+package discovery
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "net/url"
+    "os"
+    "strconv"
+    "strings"
+
+    "gateway-monitor/internal/config"
+)
+
+// pageSize is the number of gateways requested per page
+const pageSize = 100
+
+// TTS discovers gateways through the /api/v3/gateways listing endpoints
+type TTS struct {
+    client *http.Client
+}
+
+// New creates a TTS discoverer using client
+func New(client *http.Client) *TTS {
+    return &TTS{client: client}
+}
+
+// listResponse is the part of a gateway listing page the discoverer uses
+type listResponse struct {
+    Gateways []struct {
+        IDs struct {
+            GatewayID string `json:"gateway_id"`
+            EUI       string `json:"eui"`
+        } `json:"ids"`
+        Antennas []struct {
+            Location *config.Location `json:"location"`
+        } `json:"antennas"`
+    } `json:"gateways"`
+}
+
+// List returns every gateway matching the discovery filters, each with a generated "tts" check.
+// An error on any page fails the whole listing so a partial result never replaces a complete one.
+func (t *TTS) List(ctx context.Context, d config.Discovery) ([]config.Gateway, error) {
+    var gateways []config.Gateway
+    for page := 1; ; page++ {
+        resp, total, err := t.fetchPage(ctx, d, page)
+        if err != nil {
+            return nil, fmt.Errorf("page %d: %w", page, err)
+        }
+
+        for _, listed := range resp.Gateways {
+            if !d.Matches(listed.IDs.GatewayID, listed.IDs.EUI) {
+                continue
+            }
+            gateway := config.Gateway{
+                Name:   listed.IDs.GatewayID,
+                Origin: config.OriginDiscovered,
+                Checks: []config.Check{{
+                    Type:      "tts",
+                    URL:       d.ClusterURL,
+                    GatewayID: listed.IDs.GatewayID,
+                    EUI:       listed.IDs.EUI,
+                    APIKeyEnv: d.APIKeyEnv,
+                }},
+            }
+            located := false
+            for _, antenna := range listed.Antennas {
+                if antenna.Location != nil {
+                    gateway.Location = *antenna.Location
+                    located = true
+                    break
+                }
+            }
+
+            if d.BoundingBox != nil && (!located || !d.BoundingBox.Contains(gateway.Location.Latitude, gateway.Location.Longitude)) {
+                continue
+            }
+            gateways = append(gateways, gateway)
+        }
+
+        if len(resp.Gateways) < pageSize || (total >= 0 && page*pageSize >= total) {
+            return gateways, nil
+        }
+    }
+}
+
+// fetchPage requests one page of the listing, returning the X-Total-Count header or -1
+func (t *TTS) fetchPage(ctx context.Context, d config.Discovery, page int) (*listResponse, int, error) {
+    path := "/api/v3/gateways"
+    if d.UserID != "" {
+        path = "/api/v3/users/" + url.PathEscape(d.UserID) + "/gateways"
+    } else if d.OrganizationID != "" {
+        path = "/api/v3/organizations/" + url.PathEscape(d.OrganizationID) + "/gateways"
+    }
+    query := url.Values{}
+    query.Set("field_mask", "antennas")
+    query.Set("limit", strconv.Itoa(pageSize))
+    query.Set("page", strconv.Itoa(page))
+    listURL := strings.TrimSuffix(d.ClusterURL, "/") + path + "?" + query.Encode()
+
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, listURL, nil)
+    if err != nil {
+        return nil, 0, err
+    }
+    req.Header.Set("Authorization", "Bearer "+os.Getenv(d.APIKeyEnv))
+
+    resp, err := t.client.Do(req)
+    if err != nil {
+        return nil, 0, err
+    }
+    defer resp.Body.Close()
+
+    body, err := io.ReadAll(resp.Body)
+    if err != nil {
+        return nil, 0, err
+    }
+    if resp.StatusCode != http.StatusOK {
+        return nil, 0, fmt.Errorf("unexpected HTTP status %d from %s", resp.StatusCode, listURL)
+    }
+
+    var listed listResponse
+    if err := json.Unmarshal(body, &listed); err != nil {
+        return nil, 0, fmt.Errorf("failed to parse gateway listing: %v", err)
+    }
+
+    total := -1
+    if header := resp.Header.Get("X-Total-Count"); header != "" {
+        if n, err := strconv.Atoi(header); err == nil {
+            total = n
+        }
+    }
+    return &listed, total, nil
+}
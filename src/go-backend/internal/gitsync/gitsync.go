@@ -0,0 +1,242 @@
+// Package gitsync keeps gateways.json in sync with a file in a Git repository.
+package gitsync
+
+import (
+    "bytes"
+    "context"
+    "crypto/hmac"
+    "crypto/sha256"
+    "crypto/subtle"
+    "encoding/base64"
+    "encoding/hex"
+    "fmt"
+    "log"
+    "net/http"
+    "os"
+    "os/exec"
+    "os/signal"
+    "path/filepath"
+    "strings"
+    "sync"
+    "syscall"
+    "time"
+
+    "gateway-monitor/internal/clock"
+    "gateway-monitor/internal/config"
+    "gateway-monitor/internal/metrics"
+)
+
+// Applier swaps in a new gateway configuration
+type Applier interface {
+    Apply(gatewaysFile *config.GatewaysFile, source config.Source) error
+}
+
+// GitSync keeps the gateway configuration in sync with a file in a Git repository
+type GitSync struct {
+    Repo     string
+    Branch   string
+    Path     string
+    Dir      string
+    SSHKey   string
+    Username string
+    Token    string
+    Secret   string
+    Interval time.Duration
+
+    applier Applier
+    metrics *metrics.Metrics
+    clock   clock.Clock
+    logger  *log.Logger
+
+    mu sync.Mutex
+    // lastApplied is the commit whose configuration is running; a commit that failed to
+    // apply is tried again on the next sync
+    lastApplied string
+}
+
+// NewFromEnv configures Git sync from GIT_SYNC_* environment variables.
+// It returns nil when GIT_SYNC_REPO is not set.
+func NewFromEnv(applier Applier, m *metrics.Metrics, clk clock.Clock, logger *log.Logger) (*GitSync, error) {
+    repo := os.Getenv("GIT_SYNC_REPO")
+    if repo == "" {
+        return nil, nil
+    }
+
+    g := &GitSync{
+        Repo:     repo,
+        Branch:   envOrDefault("GIT_SYNC_BRANCH", "main"),
+        Path:     envOrDefault("GIT_SYNC_PATH", "gateways.json"),
+        Dir:      envOrDefault("GIT_SYNC_DIR", filepath.Join(os.TempDir(), "loracheck-config")),
+        SSHKey:   os.Getenv("GIT_SYNC_SSH_KEY"),
+        Username: envOrDefault("GIT_SYNC_USERNAME", "x-access-token"),
+        Token:    os.Getenv("GIT_SYNC_TOKEN"),
+        Secret:   os.Getenv("GIT_SYNC_WEBHOOK_SECRET"),
+        Interval: 5 * time.Minute,
+        applier:  applier,
+        metrics:  m,
+        clock:    clk,
+        logger:   logger,
+    }
+
+    if v := os.Getenv("GIT_SYNC_INTERVAL"); v != "" {
+        interval, err := time.ParseDuration(v)
+        if err != nil {
+            return nil, fmt.Errorf("invalid GIT_SYNC_INTERVAL %q: %v", v, err)
+        }
+        g.Interval = interval
+    }
+
+    return g, nil
+}
+
+// envOrDefault returns the environment variable or a fallback when it is unset
+func envOrDefault(key, fallback string) string {
+    if v := os.Getenv(key); v != "" {
+        return v
+    }
+    return fallback
+}
+
+// Run syncs on the configured interval until ctx is cancelled; an interval of 0 only syncs
+// on webhook calls
+func (g *GitSync) Run(ctx context.Context) {
+    if g.Interval <= 0 {
+        return
+    }
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-g.clock.After(g.Interval):
+        }
+        if _, err := g.Sync(); err != nil {
+            g.logger.Printf("Git sync failed, keeping current configuration: %v", err)
+        }
+    }
+}
+
+// Sync fetches the branch and applies the configuration file if the commit changed.
+// It returns the commit the repository is at.
+func (g *GitSync) Sync() (string, error) {
+    g.mu.Lock()
+    defer g.mu.Unlock()
+
+    if err := g.fetch(); err != nil {
+        return "", err
+    }
+
+    commit, err := g.git(g.Dir, "rev-parse", "HEAD")
+    if err != nil {
+        return "", err
+    }
+    if commit == g.lastApplied {
+        return commit, nil
+    }
+
+    // Loaded from the checkout, so a relative secrets_file is found next to the configuration
+    gatewaysFile, err := config.Load(filepath.Join(g.Dir, g.Path))
+    if err != nil {
+        g.metrics.ConfigReloads.WithLabelValues("git", "failure").Inc()
+        return commit, fmt.Errorf("invalid %s at commit %s: %v", g.Path, commit, err)
+    }
+
+    if err := g.applier.Apply(gatewaysFile, config.Source{Kind: "git", Path: filepath.Join(g.Dir, g.Path), Commit: commit}); err != nil {
+        return commit, err
+    }
+    g.lastApplied = commit
+    return commit, nil
+}
+
+// SyncOnSignal syncs on every SIGHUP until ctx is cancelled
+func (g *GitSync) SyncOnSignal(ctx context.Context) {
+    signals := make(chan os.Signal, 1)
+    signal.Notify(signals, syscall.SIGHUP)
+    defer signal.Stop(signals)
+
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-signals:
+            g.logger.Printf("Received SIGHUP, syncing configuration from Git")
+            if _, err := g.Sync(); err != nil {
+                g.logger.Printf("Git sync failed, keeping current configuration: %v", err)
+            }
+        }
+    }
+}
+
+// fetch clones the repository on first use and resets it to the remote branch afterwards
+func (g *GitSync) fetch() error {
+    if _, err := os.Stat(filepath.Join(g.Dir, ".git")); os.IsNotExist(err) {
+        if err := os.MkdirAll(filepath.Dir(g.Dir), 0o755); err != nil {
+            return err
+        }
+        _, err := g.git("", "clone", "--depth", "1", "--single-branch", "--branch", g.Branch, g.Repo, g.Dir)
+        return err
+    }
+
+    if _, err := g.git(g.Dir, "fetch", "--depth", "1", "origin", g.Branch); err != nil {
+        return err
+    }
+    _, err := g.git(g.Dir, "reset", "--hard", "FETCH_HEAD")
+    return err
+}
+
+// git runs a git command with the configured credentials and returns its trimmed output
+func (g *GitSync) git(dir string, args ...string) (string, error) {
+    cmd := g.command(dir, args...)
+    var stderr bytes.Buffer
+    cmd.Stderr = &stderr
+    out, err := cmd.Output()
+    if err != nil {
+        return "", fmt.Errorf("git %s failed: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+    }
+    return strings.TrimSpace(string(out)), nil
+}
+
+// command prepares a git command. The token is passed in the environment as configuration
+// rather than on the command line, which every local user can read.
+func (g *GitSync) command(dir string, args ...string) *exec.Cmd {
+    cmd := exec.Command("git", args...)
+    cmd.Dir = dir
+    cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+    if g.SSHKey != "" {
+        cmd.Env = append(cmd.Env, "GIT_SSH_COMMAND=ssh -i "+g.SSHKey+" -o IdentitiesOnly=yes -o StrictHostKeyChecking=accept-new")
+    }
+    if g.Token != "" {
+        auth := base64.StdEncoding.EncodeToString([]byte(g.Username + ":" + g.Token))
+        cmd.Env = append(cmd.Env,
+            "GIT_CONFIG_COUNT=1",
+            "GIT_CONFIG_KEY_0=http.extraHeader",
+            "GIT_CONFIG_VALUE_0=Authorization: Basic "+auth,
+        )
+    }
+    return cmd
+}
+
+// WebhookEnabled reports whether a shared secret is configured for the sync webhook
+func (g *GitSync) WebhookEnabled() bool {
+    return g.Secret != ""
+}
+
+// Authorized accepts the shared secret as a bearer token, a GitLab token header,
+// or an HMAC signature of the body as sent by GitHub and Gitea
+func (g *GitSync) Authorized(r *http.Request, body []byte) bool {
+    if g.Secret == "" {
+        return false
+    }
+
+    if sig := r.Header.Get("X-Hub-Signature-256"); sig != "" {
+        mac := hmac.New(sha256.New, []byte(g.Secret))
+        mac.Write(body)
+        expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+        return hmac.Equal([]byte(sig), []byte(expected))
+    }
+
+    token := r.Header.Get("X-Gitlab-Token")
+    if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+        token = strings.TrimPrefix(auth, "Bearer ")
+    }
+    return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(g.Secret)) == 1
+}
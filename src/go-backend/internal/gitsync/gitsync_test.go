@@ -0,0 +1,135 @@
+package gitsync
+
+import (
+    "context"
+    "errors"
+    "io"
+    "log"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/prometheus/client_golang/prometheus"
+
+    "gateway-monitor/internal/clock"
+    "gateway-monitor/internal/config"
+    "gateway-monitor/internal/metrics"
+)
+
+// applier records the configurations applied, failing with err while it is set
+type applier struct {
+    err     error
+    applied []config.Source
+}
+
+func (a *applier) Apply(gatewaysFile *config.GatewaysFile, source config.Source) error {
+    if a.err != nil {
+        return a.err
+    }
+    a.applied = append(a.applied, source)
+    return nil
+}
+
+// newTestRepo creates a repository with gateways.json on its main branch
+func newTestRepo(t *testing.T) string {
+    t.Helper()
+    if _, err := exec.LookPath("git"); err != nil {
+        t.Skip("git is not installed")
+    }
+    repo := filepath.Join(t.TempDir(), "config")
+    data := `{"gateways": [{"name": "gw1", "checks": [{"type": "http", "url": "http://127.0.0.1:1/status"}]}]}`
+    if err := os.MkdirAll(repo, 0o755); err != nil {
+        t.Fatal(err)
+    }
+    if err := os.WriteFile(filepath.Join(repo, "gateways.json"), []byte(data), 0o644); err != nil {
+        t.Fatal(err)
+    }
+    for _, args := range [][]string{
+        {"init", "-q", "-b", "main"},
+        {"add", "gateways.json"},
+        {"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "Add gw1"},
+    } {
+        cmd := exec.Command("git", args...)
+        cmd.Dir = repo
+        if out, err := cmd.CombinedOutput(); err != nil {
+            t.Fatalf("git %v: %v: %s", args, err, out)
+        }
+    }
+    return repo
+}
+
+func TestSyncRetriesCommitThatFailedToApply(t *testing.T) {
+    repo := newTestRepo(t)
+    target := &applier{err: errors.New("error creating dashboard for gw1")}
+    g := &GitSync{
+        Repo:    repo,
+        Branch:  "main",
+        Path:    "gateways.json",
+        Dir:     filepath.Join(t.TempDir(), "clone"),
+        applier: target,
+        metrics: metrics.New(prometheus.NewRegistry()),
+        logger:  log.New(io.Discard, "", 0),
+    }
+
+    commit, err := g.Sync()
+    if err == nil {
+        t.Fatal("Sync succeeded while the configuration failed to apply")
+    }
+
+    // Once the cause is fixed the same commit is applied
+    target.err = nil
+    if _, err := g.Sync(); err != nil {
+        t.Fatal(err)
+    }
+    if len(target.applied) != 1 || target.applied[0].Commit != commit {
+        t.Fatalf("applied %+v, want commit %s", target.applied, commit)
+    }
+
+    // and not applied again while it is running
+    if _, err := g.Sync(); err != nil {
+        t.Fatal(err)
+    }
+    if len(target.applied) != 1 {
+        t.Errorf("commit %s applied %d times", commit, len(target.applied))
+    }
+}
+
+func TestCommandKeepsTokenOffCommandLine(t *testing.T) {
+    g := &GitSync{Username: "x-access-token", Token: "ghp_secret"}
+    cmd := g.command("", "fetch", "--depth", "1", "origin", "main")
+    for _, arg := range cmd.Args {
+        if strings.Contains(arg, "Authorization") {
+            t.Errorf("git argument %q carries the credentials", arg)
+        }
+    }
+    env := strings.Join(cmd.Env, "\n")
+    for _, want := range []string{"GIT_CONFIG_COUNT=1", "GIT_CONFIG_KEY_0=http.extraHeader", "GIT_CONFIG_VALUE_0=Authorization: Basic "} {
+        if !strings.Contains(env, want) {
+            t.Errorf("environment lacks %s", want)
+        }
+    }
+}
+
+func TestRunStopsWithContext(t *testing.T) {
+    clk := clock.NewFake(time.Date(2026, 1, 5, 12, 0, 0, 0, time.UTC))
+    g := &GitSync{Interval: 5 * time.Minute, clock: clk}
+    ctx, cancel := context.WithCancel(context.Background())
+    done := make(chan struct{})
+    go func() {
+        g.Run(ctx)
+        close(done)
+    }()
+
+    for clk.Waiters() == 0 {
+        time.Sleep(time.Millisecond)
+    }
+    cancel()
+    select {
+    case <-done:
+    case <-time.After(5 * time.Second):
+        t.Fatal("Run kept going after its context was cancelled")
+    }
+}
@@ -0,0 +1,356 @@
+// Package grpcapi serves the gRPC API defined in proto/loracheck.proto, which mirrors the
+// status, gateway management and silence endpoints of the JSON API.
+package grpcapi
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "errors"
+    "log"
+    "net/http"
+    "time"
+
+    "google.golang.org/grpc"
+    "google.golang.org/grpc/codes"
+    "google.golang.org/grpc/metadata"
+    "google.golang.org/grpc/status"
+    "google.golang.org/protobuf/types/known/timestamppb"
+
+    "gateway-monitor/internal/api"
+    "gateway-monitor/internal/config"
+    "gateway-monitor/internal/grpcapi/pb"
+    "gateway-monitor/internal/monitor"
+)
+
+// Server implements the LoRaCheck gRPC service
+type Server struct {
+    pb.UnimplementedLoRaCheckServer
+
+    monitor *monitor.Monitor
+    logger  *log.Logger
+
+    // AdminToken is the bootstrap bearer token with the admin role, as for the HTTP server
+    AdminToken string
+    // Auth protects every RPC when set, with the credentials of the HTTP server
+    Auth api.Auth
+    // MaxSilence is the longest silence CreateSilence accepts; zero leaves it unbounded
+    MaxSilence time.Duration
+}
+
+// New creates the gRPC server
+func New(mon *monitor.Monitor, logger *log.Logger) *Server {
+    return &Server{monitor: mon, logger: logger}
+}
+
+// Register adds the service to a gRPC server created with the Options
+func (s *Server) Register(server *grpc.Server) {
+    pb.RegisterLoRaCheckServer(server, s)
+}
+
+// Options are the server options that enforce Auth on every RPC
+func (s *Server) Options() []grpc.ServerOption {
+    return []grpc.ServerOption{
+        grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+            if err := s.authenticate(ctx); err != nil {
+                return nil, err
+            }
+            return handler(ctx, req)
+        }),
+        grpc.StreamInterceptor(func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+            if err := s.authenticate(stream.Context()); err != nil {
+                return err
+            }
+            return handler(srv, stream)
+        }),
+    }
+}
+
+// authenticate checks the authorization metadata against Auth, the way the HTTP server does,
+// and requires the viewer role every RPC needs once API tokens are configured
+func (s *Server) authenticate(ctx context.Context) error {
+    access := s.access()
+    if s.Auth.Enabled() {
+        if _, role := access.Caller(header(ctx)); role == "" {
+            return status.Error(codes.Unauthenticated, "authentication required")
+        }
+    }
+    return s.require(ctx, config.RoleViewer)
+}
+
+// require only lets calls whose role allows role through, see api.Access.Authorize, and
+// only the leader make changes
+func (s *Server) require(ctx context.Context, role string) error {
+    access := s.access()
+    if err := access.Authorize(header(ctx), role); err != nil {
+        if errors.Is(err, api.ErrUnauthenticated) {
+            return status.Error(codes.Unauthenticated, err.Error())
+        }
+        return status.Error(codes.PermissionDenied, err.Error())
+    }
+    if role != config.RoleViewer && !s.monitor.Leading() {
+        // Changes would only reach this replica's running configuration, see api.Server
+        message := "this replica is standing by, send changes to the leader"
+        if leader := s.monitor.LeaderIdentity(); leader != "" {
+            message += " " + leader
+        }
+        return status.Error(codes.Unavailable, message)
+    }
+    if role != config.RoleViewer {
+        if name, _ := access.Caller(header(ctx)); name != "" {
+            s.logger.Printf("gRPC call requiring the %s role by %s", role, name)
+        }
+    }
+    return nil
+}
+
+// access returns the access rules with the API tokens of the running configuration
+func (s *Server) access() api.Access {
+    gatewaysFile, _ := s.monitor.Current()
+    return api.Access{AdminToken: s.AdminToken, Auth: s.Auth, Tokens: gatewaysFile.APITokens}
+}
+
+// header returns the authorization metadata of a call as an HTTP header
+func header(ctx context.Context) http.Header {
+    md, _ := metadata.FromIncomingContext(ctx)
+    return http.Header{"Authorization": md.Get("authorization")}
+}
+
+// ListStatus implements pb.LoRaCheckServer
+func (s *Server) ListStatus(ctx context.Context, req *pb.ListStatusRequest) (*pb.ListStatusResponse, error) {
+    statuses := s.monitor.Status()
+    response := &pb.ListStatusResponse{Gateways: make([]*pb.GatewayStatus, 0, len(statuses))}
+    for _, gatewayStatus := range statuses {
+        response.Gateways = append(response.Gateways, newGatewayStatus(gatewayStatus))
+    }
+    return response, nil
+}
+
+// GetStatus implements pb.LoRaCheckServer
+func (s *Server) GetStatus(ctx context.Context, req *pb.GetStatusRequest) (*pb.GatewayStatus, error) {
+    gateway, ok := s.monitor.Gateway(req.GetName())
+    if !ok {
+        return nil, status.Error(codes.NotFound, "unknown gateway "+req.GetName())
+    }
+    return newGatewayStatus(s.monitor.GatewayStatus(gateway)), nil
+}
+
+// WatchTransitions implements pb.LoRaCheckServer, streaming until the client goes away
+func (s *Server) WatchTransitions(req *pb.WatchTransitionsRequest, stream pb.LoRaCheck_WatchTransitionsServer) error {
+    events, unsubscribe := s.monitor.Events().Subscribe()
+    defer unsubscribe()
+
+    for {
+        select {
+        case <-stream.Context().Done():
+            return nil
+        case transition, ok := <-events:
+            if !ok {
+                return nil
+            }
+            err := stream.Send(&pb.Transition{
+                Gateway: transition.Gateway,
+                From:    transition.From,
+                To:      transition.To,
+                At:      timestamppb.New(transition.At),
+            })
+            if err != nil {
+                return err
+            }
+        }
+    }
+}
+
+// ListGateways implements pb.LoRaCheckServer
+func (s *Server) ListGateways(ctx context.Context, req *pb.ListGatewaysRequest) (*pb.ListGatewaysResponse, error) {
+    gateways := s.monitor.Gateways()
+    response := &pb.ListGatewaysResponse{Gateways: make([]*pb.Gateway, 0, len(gateways))}
+    for _, gateway := range gateways {
+        response.Gateways = append(response.Gateways, newGateway(gateway))
+    }
+    return response, nil
+}
+
+// GetGateway implements pb.LoRaCheckServer
+func (s *Server) GetGateway(ctx context.Context, req *pb.GetGatewayRequest) (*pb.Gateway, error) {
+    gateway, ok := s.monitor.Gateway(req.GetName())
+    if !ok {
+        return nil, status.Error(codes.NotFound, "unknown gateway "+req.GetName())
+    }
+    return newGateway(gateway), nil
+}
+
+// CreateGateway implements pb.LoRaCheckServer
+func (s *Server) CreateGateway(ctx context.Context, req *pb.CreateGatewayRequest) (*pb.ChangeResponse, error) {
+    if err := s.require(ctx, config.RoleAdmin); err != nil {
+        return nil, err
+    }
+    gateway, err := decodeGateway(req.GetDefinition(), "")
+    if err != nil {
+        return nil, err
+    }
+    return s.applyChange(&gateway, s.monitor.AddGateway(gateway))
+}
+
+// UpdateGateway implements pb.LoRaCheckServer, replacing the gateway definition
+func (s *Server) UpdateGateway(ctx context.Context, req *pb.UpdateGatewayRequest) (*pb.ChangeResponse, error) {
+    if err := s.require(ctx, config.RoleAdmin); err != nil {
+        return nil, err
+    }
+    gateway, err := decodeGateway(req.GetDefinition(), req.GetName())
+    if err != nil {
+        return nil, err
+    }
+    return s.applyChange(&gateway, s.monitor.UpdateGateway(gateway))
+}
+
+// DeleteGateway implements pb.LoRaCheckServer
+func (s *Server) DeleteGateway(ctx context.Context, req *pb.DeleteGatewayRequest) (*pb.ChangeResponse, error) {
+    if err := s.require(ctx, config.RoleAdmin); err != nil {
+        return nil, err
+    }
+    return s.applyChange(nil, s.monitor.RemoveGateway(req.GetName()))
+}
+
+// CreateSilence implements pb.LoRaCheckServer, with the validation of the JSON API
+func (s *Server) CreateSilence(ctx context.Context, req *pb.CreateSilenceRequest) (*pb.Silence, error) {
+    if err := s.require(ctx, config.RoleOperator); err != nil {
+        return nil, err
+    }
+    if _, ok := s.monitor.Gateway(req.GetName()); !ok {
+        return nil, status.Error(codes.NotFound, "unknown gateway "+req.GetName())
+    }
+    d, err := time.ParseDuration(req.GetDuration())
+    if err != nil || d <= 0 {
+        return nil, status.Error(codes.InvalidArgument, "duration must be a positive Go duration such as 2h30m")
+    }
+    if s.MaxSilence > 0 && d > s.MaxSilence {
+        return nil, status.Error(codes.InvalidArgument, "duration must not exceed "+s.MaxSilence.String())
+    }
+    return newSilence(s.monitor.Silence(req.GetName(), d, req.GetComment())), nil
+}
+
+// ListSilences implements pb.LoRaCheckServer, for all gateways unless a name is given
+func (s *Server) ListSilences(ctx context.Context, req *pb.ListSilencesRequest) (*pb.ListSilencesResponse, error) {
+    if name := req.GetName(); name != "" {
+        if _, ok := s.monitor.Gateway(name); !ok {
+            return nil, status.Error(codes.NotFound, "unknown gateway "+name)
+        }
+    }
+    silences := s.monitor.Silences().Active(req.GetName())
+    response := &pb.ListSilencesResponse{Silences: make([]*pb.Silence, 0, len(silences))}
+    for _, silence := range silences {
+        response.Silences = append(response.Silences, newSilence(silence))
+    }
+    return response, nil
+}
+
+// decodeGateway reads a gateway definition; its name defaults to, and must match, name when set
+func decodeGateway(definition []byte, name string) (config.Gateway, error) {
+    var gateway config.Gateway
+    decoder := json.NewDecoder(bytes.NewReader(definition))
+    decoder.DisallowUnknownFields()
+    if err := decoder.Decode(&gateway); err != nil {
+        return gateway, status.Error(codes.InvalidArgument, "invalid gateway definition: "+err.Error())
+    }
+    if name != "" {
+        if gateway.Name == "" {
+            gateway.Name = name
+        } else if gateway.Name != name {
+            return gateway, status.Error(codes.InvalidArgument, "gateway name does not match the request; delete and recreate it to rename")
+        }
+    }
+    if gateway.Name == "" {
+        return gateway, status.Error(codes.InvalidArgument, "gateway name is required")
+    }
+    return gateway, nil
+}
+
+// applyChange reports the outcome of a change and writes the configuration back to its file
+func (s *Server) applyChange(gateway *config.Gateway, err error) (*pb.ChangeResponse, error) {
+    switch {
+    case errors.Is(err, monitor.ErrGatewayExists):
+        return nil, status.Error(codes.AlreadyExists, err.Error())
+    case errors.Is(err, monitor.ErrGatewayNotFound):
+        return nil, status.Error(codes.NotFound, err.Error())
+    case err != nil:
+        return nil, status.Error(codes.InvalidArgument, err.Error())
+    }
+
+    response := &pb.ChangeResponse{Saved: true}
+    if gateway != nil {
+        response.Gateway = newGateway(*gateway)
+    }
+    if err := s.monitor.Save(); err != nil {
+        s.logger.Printf("WARNING: gateway change applied but not saved: %v", err)
+        response.Saved, response.SaveError = false, err.Error()
+    }
+    return response, nil
+}
+
+func newGateway(gateway config.Gateway) *pb.Gateway {
+    checks := make([]string, 0, len(gateway.Checks))
+    for _, check := range gateway.Checks {
+        checks = append(checks, check.Type)
+    }
+    return &pb.Gateway{
+        Name:      gateway.Name,
+        Origin:    gateway.GatewayOrigin(),
+        Latitude:  gateway.Location.Latitude,
+        Longitude: gateway.Location.Longitude,
+        Checks:    checks,
+    }
+}
+
+func newGatewayStatus(gatewayStatus monitor.GatewayStatus) *pb.GatewayStatus {
+    response := &pb.GatewayStatus{
+        Name:    gatewayStatus.Name,
+        Project: gatewayStatus.Project,
+        Origin:  gatewayStatus.Origin,
+        Labels:  gatewayStatus.Labels,
+        Location: &pb.Location{
+            Latitude:  gatewayStatus.Location.Latitude,
+            Longitude: gatewayStatus.Location.Longitude,
+            Altitude:  gatewayStatus.Location.Altitude,
+        },
+        State:         gatewayStatus.State,
+        Online:        gatewayStatus.Online,
+        InMaintenance: gatewayStatus.InMaintenance,
+        Degraded:      gatewayStatus.Degraded,
+        LastChecked:   timestamp(gatewayStatus.LastChecked),
+        LastSeen:      timestamp(gatewayStatus.LastSeen),
+    }
+    for _, silence := range gatewayStatus.Silences {
+        response.Silences = append(response.Silences, newSilence(silence))
+    }
+    for _, check := range gatewayStatus.Checks {
+        response.Checks = append(response.Checks, &pb.CheckStatus{
+            Type:            check.Type,
+            Target:          check.Target,
+            Status:          check.Status,
+            LastChecked:     timestamp(check.LastChecked),
+            LastSeen:        timestamp(check.LastSeen),
+            LastError:       check.LastError,
+            DurationSeconds: check.DurationSeconds,
+        })
+    }
+    return response
+}
+
+func newSilence(silence monitor.Silence) *pb.Silence {
+    return &pb.Silence{
+        Id:      silence.ID,
+        Gateway: silence.Gateway,
+        Start:   timestamppb.New(silence.Start),
+        End:     timestamppb.New(silence.End),
+        Comment: silence.Comment,
+    }
+}
+
+// timestamp converts an optional time, leaving the field unset when it is nil
+func timestamp(t *time.Time) *timestamppb.Timestamp {
+    if t == nil {
+        return nil
+    }
+    return timestamppb.New(*t)
+}
@@ -0,0 +1,144 @@
+package grpcapi
+
+import (
+    "context"
+    "io"
+    "log"
+    "net"
+    "net/http"
+    "testing"
+    "time"
+
+    "github.com/prometheus/client_golang/prometheus"
+    "google.golang.org/grpc"
+    "google.golang.org/grpc/codes"
+    "google.golang.org/grpc/credentials/insecure"
+    "google.golang.org/grpc/metadata"
+    "google.golang.org/grpc/status"
+    "google.golang.org/grpc/test/bufconn"
+
+    "gateway-monitor/internal/checker"
+    "gateway-monitor/internal/clock"
+    "gateway-monitor/internal/config"
+    "gateway-monitor/internal/grpcapi/pb"
+    "gateway-monitor/internal/metrics"
+    "gateway-monitor/internal/monitor"
+)
+
+var testTokens = []config.APIToken{
+    {Name: "dashboards", Token: "viewer-token-0123456", Role: config.RoleViewer},
+    {Name: "noc", Token: "operator-token-0123456", Role: config.RoleOperator},
+}
+
+// dial serves a monitor of one gateway, gw1, over an in-memory connection
+func dial(t *testing.T, maxSilence time.Duration) pb.LoRaCheckClient {
+    t.Helper()
+    clk := clock.NewFake(time.Date(2026, 1, 5, 12, 0, 0, 0, time.UTC))
+    logger := log.New(io.Discard, "", 0)
+    m := metrics.New(prometheus.NewRegistry())
+    forwarders := checker.NewForwarders(m, clk, logger)
+    mon := monitor.New(monitor.Options{
+        Checker:    checker.New(&http.Client{Timeout: time.Second}, forwarders, checker.NewSubscriptions(m, clk, logger), m, clk, logger),
+        Forwarders: forwarders,
+        Metrics:    m,
+        Clock:      clk,
+        Logger:     logger,
+        Interval:   time.Minute,
+    })
+    gatewaysFile := &config.GatewaysFile{
+        Gateways:  []config.Gateway{{Name: "gw1", Checks: []config.Check{{Type: "http", URL: "http://127.0.0.1:1/status"}}}},
+        APITokens: testTokens,
+    }
+    if err := mon.Apply(gatewaysFile, config.Source{Kind: "file"}); err != nil {
+        t.Fatal(err)
+    }
+
+    server := New(mon, logger)
+    server.MaxSilence = maxSilence
+    grpcServer := grpc.NewServer(server.Options()...)
+    server.Register(grpcServer)
+    listener := bufconn.Listen(1 << 20)
+    go grpcServer.Serve(listener)
+    t.Cleanup(grpcServer.Stop)
+
+    conn, err := grpc.NewClient("passthrough:///bufconn",
+        grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+        grpc.WithTransportCredentials(insecure.NewCredentials()))
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { conn.Close() })
+    return pb.NewLoRaCheckClient(conn)
+}
+
+func withToken(token string) context.Context {
+    return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+func TestCreateSilence(t *testing.T) {
+    client := dial(t, 24*time.Hour)
+    tests := []struct {
+        name  string
+        token string
+        req   *pb.CreateSilenceRequest
+        code  codes.Code
+    }{
+        {name: "operator", token: "operator-token-0123456",
+            req: &pb.CreateSilenceRequest{Name: "gw1", Duration: "2h", Comment: "antenna swap"}, code: codes.OK},
+        {name: "viewer", token: "viewer-token-0123456",
+            req: &pb.CreateSilenceRequest{Name: "gw1", Duration: "2h"}, code: codes.PermissionDenied},
+        {name: "anonymous", req: &pb.CreateSilenceRequest{Name: "gw1", Duration: "2h"}, code: codes.Unauthenticated},
+        {name: "unknown gateway", token: "operator-token-0123456",
+            req: &pb.CreateSilenceRequest{Name: "gw2", Duration: "2h"}, code: codes.NotFound},
+        {name: "invalid duration", token: "operator-token-0123456",
+            req: &pb.CreateSilenceRequest{Name: "gw1", Duration: "soon"}, code: codes.InvalidArgument},
+        {name: "negative duration", token: "operator-token-0123456",
+            req: &pb.CreateSilenceRequest{Name: "gw1", Duration: "-1h"}, code: codes.InvalidArgument},
+        {name: "over the limit", token: "operator-token-0123456",
+            req: &pb.CreateSilenceRequest{Name: "gw1", Duration: "25h"}, code: codes.InvalidArgument},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            silence, err := client.CreateSilence(withToken(tt.token), tt.req)
+            if code := status.Code(err); code != tt.code {
+                t.Fatalf("CreateSilence returned %v, want %v", err, tt.code)
+            }
+            if err != nil {
+                return
+            }
+            if silence.GetGateway() != "gw1" || silence.GetComment() != "antenna swap" ||
+                silence.GetEnd().AsTime().Sub(silence.GetStart().AsTime()) != 2*time.Hour {
+                t.Errorf("CreateSilence returned %v", silence)
+            }
+        })
+    }
+
+    list, err := client.ListSilences(withToken("viewer-token-0123456"), &pb.ListSilencesRequest{})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(list.GetSilences()) != 1 || list.GetSilences()[0].GetGateway() != "gw1" {
+        t.Errorf("ListSilences returned %v, want the silence of gw1", list.GetSilences())
+    }
+}
+
+func TestListSilences(t *testing.T) {
+    client := dial(t, 0)
+    if _, err := client.CreateSilence(withToken("operator-token-0123456"), &pb.CreateSilenceRequest{Name: "gw1", Duration: "1h"}); err != nil {
+        t.Fatal(err)
+    }
+
+    list, err := client.ListSilences(withToken("viewer-token-0123456"), &pb.ListSilencesRequest{Name: "gw1"})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(list.GetSilences()) != 1 {
+        t.Errorf("ListSilences of gw1 returned %d silences, want 1", len(list.GetSilences()))
+    }
+    if _, err := client.ListSilences(withToken("viewer-token-0123456"), &pb.ListSilencesRequest{Name: "gw2"}); status.Code(err) != codes.NotFound {
+        t.Errorf("ListSilences of an unknown gateway returned %v, want NotFound", err)
+    }
+    if _, err := client.ListSilences(context.Background(), &pb.ListSilencesRequest{}); status.Code(err) != codes.Unauthenticated {
+        t.Errorf("anonymous ListSilences returned %v, want Unauthenticated", err)
+    }
+}
@@ -0,0 +1,511 @@
+// The LoRaCheck gRPC API mirrors the status, gateway management and silence endpoints
+// of the JSON API under /api/v1, plus a stream of gateway state transitions.
+//
+// Regenerate the Go code in ../pb with
+//   protoc --go_out=.. --go_opt=module=gateway-monitor/internal/grpcapi \
+//     --go-grpc_out=.. --go-grpc_opt=module=gateway-monitor/internal/grpcapi loracheck.proto
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.4.0
+// - protoc             (unknown)
+// source: loracheck.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.62.0 or later.
+const _ = grpc.SupportPackageIsVersion8
+
+const (
+	LoRaCheck_ListStatus_FullMethodName       = "/loracheck.v1.LoRaCheck/ListStatus"
+	LoRaCheck_GetStatus_FullMethodName        = "/loracheck.v1.LoRaCheck/GetStatus"
+	LoRaCheck_WatchTransitions_FullMethodName = "/loracheck.v1.LoRaCheck/WatchTransitions"
+	LoRaCheck_ListGateways_FullMethodName     = "/loracheck.v1.LoRaCheck/ListGateways"
+	LoRaCheck_GetGateway_FullMethodName       = "/loracheck.v1.LoRaCheck/GetGateway"
+	LoRaCheck_CreateGateway_FullMethodName    = "/loracheck.v1.LoRaCheck/CreateGateway"
+	LoRaCheck_UpdateGateway_FullMethodName    = "/loracheck.v1.LoRaCheck/UpdateGateway"
+	LoRaCheck_DeleteGateway_FullMethodName    = "/loracheck.v1.LoRaCheck/DeleteGateway"
+	LoRaCheck_CreateSilence_FullMethodName    = "/loracheck.v1.LoRaCheck/CreateSilence"
+	LoRaCheck_ListSilences_FullMethodName     = "/loracheck.v1.LoRaCheck/ListSilences"
+)
+
+// LoRaCheckClient is the client API for LoRaCheck service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type LoRaCheckClient interface {
+	// ListStatus returns the current state of every gateway, like GET /api/v1/status
+	ListStatus(ctx context.Context, in *ListStatusRequest, opts ...grpc.CallOption) (*ListStatusResponse, error)
+	// GetStatus returns the current state of one gateway, like GET /api/v1/status/{name}
+	GetStatus(ctx context.Context, in *GetStatusRequest, opts ...grpc.CallOption) (*GatewayStatus, error)
+	// WatchTransitions streams a transition whenever a gateway changes state, like the
+	// "transition" events of GET /api/v1/events
+	WatchTransitions(ctx context.Context, in *WatchTransitionsRequest, opts ...grpc.CallOption) (LoRaCheck_WatchTransitionsClient, error)
+	// ListGateways returns the monitored gateways, like GET /api/v1/gateways
+	ListGateways(ctx context.Context, in *ListGatewaysRequest, opts ...grpc.CallOption) (*ListGatewaysResponse, error)
+	// GetGateway returns one gateway, like GET /api/v1/gateways/{name}
+	GetGateway(ctx context.Context, in *GetGatewayRequest, opts ...grpc.CallOption) (*Gateway, error)
+	// CreateGateway, UpdateGateway and DeleteGateway change the configuration like the
+	// POST, PUT and DELETE /api/v1/gateways endpoints; they require the admin token
+	CreateGateway(ctx context.Context, in *CreateGatewayRequest, opts ...grpc.CallOption) (*ChangeResponse, error)
+	UpdateGateway(ctx context.Context, in *UpdateGatewayRequest, opts ...grpc.CallOption) (*ChangeResponse, error)
+	DeleteGateway(ctx context.Context, in *DeleteGatewayRequest, opts ...grpc.CallOption) (*ChangeResponse, error)
+	// CreateSilence mutes a gateway for a while, like POST /api/v1/gateways/{name}/silence;
+	// it requires the operator role
+	CreateSilence(ctx context.Context, in *CreateSilenceRequest, opts ...grpc.CallOption) (*Silence, error)
+	// ListSilences returns the active silences, like GET /api/v1/silences, or those of one
+	// gateway, like GET /api/v1/gateways/{name}/silence
+	ListSilences(ctx context.Context, in *ListSilencesRequest, opts ...grpc.CallOption) (*ListSilencesResponse, error)
+}
+
+type loRaCheckClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewLoRaCheckClient(cc grpc.ClientConnInterface) LoRaCheckClient {
+	return &loRaCheckClient{cc}
+}
+
+func (c *loRaCheckClient) ListStatus(ctx context.Context, in *ListStatusRequest, opts ...grpc.CallOption) (*ListStatusResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListStatusResponse)
+	err := c.cc.Invoke(ctx, LoRaCheck_ListStatus_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *loRaCheckClient) GetStatus(ctx context.Context, in *GetStatusRequest, opts ...grpc.CallOption) (*GatewayStatus, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GatewayStatus)
+	err := c.cc.Invoke(ctx, LoRaCheck_GetStatus_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *loRaCheckClient) WatchTransitions(ctx context.Context, in *WatchTransitionsRequest, opts ...grpc.CallOption) (LoRaCheck_WatchTransitionsClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &LoRaCheck_ServiceDesc.Streams[0], LoRaCheck_WatchTransitions_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &loRaCheckWatchTransitionsClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type LoRaCheck_WatchTransitionsClient interface {
+	Recv() (*Transition, error)
+	grpc.ClientStream
+}
+
+type loRaCheckWatchTransitionsClient struct {
+	grpc.ClientStream
+}
+
+func (x *loRaCheckWatchTransitionsClient) Recv() (*Transition, error) {
+	m := new(Transition)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *loRaCheckClient) ListGateways(ctx context.Context, in *ListGatewaysRequest, opts ...grpc.CallOption) (*ListGatewaysResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListGatewaysResponse)
+	err := c.cc.Invoke(ctx, LoRaCheck_ListGateways_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *loRaCheckClient) GetGateway(ctx context.Context, in *GetGatewayRequest, opts ...grpc.CallOption) (*Gateway, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Gateway)
+	err := c.cc.Invoke(ctx, LoRaCheck_GetGateway_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *loRaCheckClient) CreateGateway(ctx context.Context, in *CreateGatewayRequest, opts ...grpc.CallOption) (*ChangeResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ChangeResponse)
+	err := c.cc.Invoke(ctx, LoRaCheck_CreateGateway_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *loRaCheckClient) UpdateGateway(ctx context.Context, in *UpdateGatewayRequest, opts ...grpc.CallOption) (*ChangeResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ChangeResponse)
+	err := c.cc.Invoke(ctx, LoRaCheck_UpdateGateway_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *loRaCheckClient) DeleteGateway(ctx context.Context, in *DeleteGatewayRequest, opts ...grpc.CallOption) (*ChangeResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ChangeResponse)
+	err := c.cc.Invoke(ctx, LoRaCheck_DeleteGateway_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *loRaCheckClient) CreateSilence(ctx context.Context, in *CreateSilenceRequest, opts ...grpc.CallOption) (*Silence, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Silence)
+	err := c.cc.Invoke(ctx, LoRaCheck_CreateSilence_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *loRaCheckClient) ListSilences(ctx context.Context, in *ListSilencesRequest, opts ...grpc.CallOption) (*ListSilencesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListSilencesResponse)
+	err := c.cc.Invoke(ctx, LoRaCheck_ListSilences_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// LoRaCheckServer is the server API for LoRaCheck service.
+// All implementations must embed UnimplementedLoRaCheckServer
+// for forward compatibility
+type LoRaCheckServer interface {
+	// ListStatus returns the current state of every gateway, like GET /api/v1/status
+	ListStatus(context.Context, *ListStatusRequest) (*ListStatusResponse, error)
+	// GetStatus returns the current state of one gateway, like GET /api/v1/status/{name}
+	GetStatus(context.Context, *GetStatusRequest) (*GatewayStatus, error)
+	// WatchTransitions streams a transition whenever a gateway changes state, like the
+	// "transition" events of GET /api/v1/events
+	WatchTransitions(*WatchTransitionsRequest, LoRaCheck_WatchTransitionsServer) error
+	// ListGateways returns the monitored gateways, like GET /api/v1/gateways
+	ListGateways(context.Context, *ListGatewaysRequest) (*ListGatewaysResponse, error)
+	// GetGateway returns one gateway, like GET /api/v1/gateways/{name}
+	GetGateway(context.Context, *GetGatewayRequest) (*Gateway, error)
+	// CreateGateway, UpdateGateway and DeleteGateway change the configuration like the
+	// POST, PUT and DELETE /api/v1/gateways endpoints; they require the admin token
+	CreateGateway(context.Context, *CreateGatewayRequest) (*ChangeResponse, error)
+	UpdateGateway(context.Context, *UpdateGatewayRequest) (*ChangeResponse, error)
+	DeleteGateway(context.Context, *DeleteGatewayRequest) (*ChangeResponse, error)
+	// CreateSilence mutes a gateway for a while, like POST /api/v1/gateways/{name}/silence;
+	// it requires the operator role
+	CreateSilence(context.Context, *CreateSilenceRequest) (*Silence, error)
+	// ListSilences returns the active silences, like GET /api/v1/silences, or those of one
+	// gateway, like GET /api/v1/gateways/{name}/silence
+	ListSilences(context.Context, *ListSilencesRequest) (*ListSilencesResponse, error)
+	mustEmbedUnimplementedLoRaCheckServer()
+}
+
+// UnimplementedLoRaCheckServer must be embedded to have forward compatible implementations.
+type UnimplementedLoRaCheckServer struct {
+}
+
+func (UnimplementedLoRaCheckServer) ListStatus(context.Context, *ListStatusRequest) (*ListStatusResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListStatus not implemented")
+}
+func (UnimplementedLoRaCheckServer) GetStatus(context.Context, *GetStatusRequest) (*GatewayStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStatus not implemented")
+}
+func (UnimplementedLoRaCheckServer) WatchTransitions(*WatchTransitionsRequest, LoRaCheck_WatchTransitionsServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchTransitions not implemented")
+}
+func (UnimplementedLoRaCheckServer) ListGateways(context.Context, *ListGatewaysRequest) (*ListGatewaysResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListGateways not implemented")
+}
+func (UnimplementedLoRaCheckServer) GetGateway(context.Context, *GetGatewayRequest) (*Gateway, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetGateway not implemented")
+}
+func (UnimplementedLoRaCheckServer) CreateGateway(context.Context, *CreateGatewayRequest) (*ChangeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateGateway not implemented")
+}
+func (UnimplementedLoRaCheckServer) UpdateGateway(context.Context, *UpdateGatewayRequest) (*ChangeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateGateway not implemented")
+}
+func (UnimplementedLoRaCheckServer) DeleteGateway(context.Context, *DeleteGatewayRequest) (*ChangeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteGateway not implemented")
+}
+func (UnimplementedLoRaCheckServer) CreateSilence(context.Context, *CreateSilenceRequest) (*Silence, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateSilence not implemented")
+}
+func (UnimplementedLoRaCheckServer) ListSilences(context.Context, *ListSilencesRequest) (*ListSilencesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListSilences not implemented")
+}
+func (UnimplementedLoRaCheckServer) mustEmbedUnimplementedLoRaCheckServer() {}
+
+// UnsafeLoRaCheckServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to LoRaCheckServer will
+// result in compilation errors.
+type UnsafeLoRaCheckServer interface {
+	mustEmbedUnimplementedLoRaCheckServer()
+}
+
+func RegisterLoRaCheckServer(s grpc.ServiceRegistrar, srv LoRaCheckServer) {
+	s.RegisterService(&LoRaCheck_ServiceDesc, srv)
+}
+
+func _LoRaCheck_ListStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LoRaCheckServer).ListStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LoRaCheck_ListStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LoRaCheckServer).ListStatus(ctx, req.(*ListStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LoRaCheck_GetStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LoRaCheckServer).GetStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LoRaCheck_GetStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LoRaCheckServer).GetStatus(ctx, req.(*GetStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LoRaCheck_WatchTransitions_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchTransitionsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(LoRaCheckServer).WatchTransitions(m, &loRaCheckWatchTransitionsServer{ServerStream: stream})
+}
+
+type LoRaCheck_WatchTransitionsServer interface {
+	Send(*Transition) error
+	grpc.ServerStream
+}
+
+type loRaCheckWatchTransitionsServer struct {
+	grpc.ServerStream
+}
+
+func (x *loRaCheckWatchTransitionsServer) Send(m *Transition) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _LoRaCheck_ListGateways_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListGatewaysRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LoRaCheckServer).ListGateways(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LoRaCheck_ListGateways_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LoRaCheckServer).ListGateways(ctx, req.(*ListGatewaysRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LoRaCheck_GetGateway_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetGatewayRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LoRaCheckServer).GetGateway(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LoRaCheck_GetGateway_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LoRaCheckServer).GetGateway(ctx, req.(*GetGatewayRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LoRaCheck_CreateGateway_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateGatewayRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LoRaCheckServer).CreateGateway(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LoRaCheck_CreateGateway_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LoRaCheckServer).CreateGateway(ctx, req.(*CreateGatewayRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LoRaCheck_UpdateGateway_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateGatewayRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LoRaCheckServer).UpdateGateway(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LoRaCheck_UpdateGateway_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LoRaCheckServer).UpdateGateway(ctx, req.(*UpdateGatewayRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LoRaCheck_DeleteGateway_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteGatewayRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LoRaCheckServer).DeleteGateway(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LoRaCheck_DeleteGateway_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LoRaCheckServer).DeleteGateway(ctx, req.(*DeleteGatewayRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LoRaCheck_CreateSilence_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateSilenceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LoRaCheckServer).CreateSilence(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LoRaCheck_CreateSilence_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LoRaCheckServer).CreateSilence(ctx, req.(*CreateSilenceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LoRaCheck_ListSilences_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSilencesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LoRaCheckServer).ListSilences(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LoRaCheck_ListSilences_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LoRaCheckServer).ListSilences(ctx, req.(*ListSilencesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// LoRaCheck_ServiceDesc is the grpc.ServiceDesc for LoRaCheck service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var LoRaCheck_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "loracheck.v1.LoRaCheck",
+	HandlerType: (*LoRaCheckServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListStatus",
+			Handler:    _LoRaCheck_ListStatus_Handler,
+		},
+		{
+			MethodName: "GetStatus",
+			Handler:    _LoRaCheck_GetStatus_Handler,
+		},
+		{
+			MethodName: "ListGateways",
+			Handler:    _LoRaCheck_ListGateways_Handler,
+		},
+		{
+			MethodName: "GetGateway",
+			Handler:    _LoRaCheck_GetGateway_Handler,
+		},
+		{
+			MethodName: "CreateGateway",
+			Handler:    _LoRaCheck_CreateGateway_Handler,
+		},
+		{
+			MethodName: "UpdateGateway",
+			Handler:    _LoRaCheck_UpdateGateway_Handler,
+		},
+		{
+			MethodName: "DeleteGateway",
+			Handler:    _LoRaCheck_DeleteGateway_Handler,
+		},
+		{
+			MethodName: "CreateSilence",
+			Handler:    _LoRaCheck_CreateSilence_Handler,
+		},
+		{
+			MethodName: "ListSilences",
+			Handler:    _LoRaCheck_ListSilences_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchTransitions",
+			Handler:       _LoRaCheck_WatchTransitions_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "loracheck.proto",
+}
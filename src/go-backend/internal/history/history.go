@@ -0,0 +1,151 @@
+// Package history keeps every check result and gateway state in a SQLite database so
+// that reports don't depend on the retention of Prometheus.
+package history
+
+import (
+    "context"
+    "database/sql"
+    "fmt"
+    "log"
+    "time"
+
+    _ "modernc.org/sqlite"
+
+    "gateway-monitor/internal/checker"
+    "gateway-monitor/internal/clock"
+    "gateway-monitor/internal/config"
+)
+
+// pruneInterval is how often results older than the retention are deleted
+const pruneInterval = 1 * time.Hour
+
+const schema = `
+CREATE TABLE IF NOT EXISTS check_results (
+    gateway          TEXT    NOT NULL,
+    check_type       TEXT    NOT NULL,
+    target           TEXT    NOT NULL,
+    status           TEXT    NOT NULL,
+    error            TEXT    NOT NULL DEFAULT '',
+    duration_seconds REAL    NOT NULL,
+    checked_at       INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS check_results_gateway_checked_at ON check_results (gateway, checked_at);
+CREATE INDEX IF NOT EXISTS check_results_checked_at ON check_results (checked_at);
+CREATE TABLE IF NOT EXISTS gateway_states (
+    gateway    TEXT    NOT NULL,
+    state      TEXT    NOT NULL,
+    checked_at INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS gateway_states_gateway_checked_at ON gateway_states (gateway, checked_at);
+CREATE INDEX IF NOT EXISTS gateway_states_checked_at ON gateway_states (checked_at);
+CREATE TABLE IF NOT EXISTS reports (
+    name    TEXT    PRIMARY KEY,
+    sent_at INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS leases (
+    name       TEXT    PRIMARY KEY,
+    holder     TEXT    NOT NULL,
+    expires_at INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS notification_events (
+    id            TEXT    PRIMARY KEY,
+    gateway       TEXT    NOT NULL,
+    state         TEXT    NOT NULL,
+    offline_since INTEGER NOT NULL,
+    at            INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS notification_events_outage ON notification_events (gateway, offline_since);
+CREATE TABLE IF NOT EXISTS notification_receipts (
+    event_id     TEXT    NOT NULL,
+    channel      TEXT    NOT NULL,
+    delivered_at INTEGER NOT NULL,
+    PRIMARY KEY (event_id, channel)
+);
+`
+
+// Store is a SQLite database of check results
+type Store struct {
+    db        *sql.DB
+    retention time.Duration
+    clock     clock.Clock
+    logger    *log.Logger
+}
+
+// Open opens or creates the database at path. Results older than retention are pruned by Run.
+func Open(path string, retention time.Duration, clk clock.Clock, logger *log.Logger) (*Store, error) {
+    db, err := sql.Open("sqlite", "file:"+path+"?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)")
+    if err != nil {
+        return nil, err
+    }
+    // SQLite allows a single writer; one connection avoids "database is locked" errors
+    db.SetMaxOpenConns(1)
+    if _, err := db.Exec(schema); err != nil {
+        db.Close()
+        return nil, fmt.Errorf("creating schema in %s: %v", path, err)
+    }
+    return &Store{db: db, retention: retention, clock: clk, logger: logger}, nil
+}
+
+// Close closes the database
+func (s *Store) Close() error {
+    return s.db.Close()
+}
+
+// Record stores the result of a check
+func (s *Store) Record(gateway config.Gateway, check config.Check, result checker.CheckResult) error {
+    _, err := s.db.Exec(
+        `INSERT INTO check_results (gateway, check_type, target, status, error, duration_seconds, checked_at)
+         VALUES (?, ?, ?, ?, ?, ?, ?)`,
+        gateway.Name, check.Type, check.Target(), result.Status, result.Error,
+        result.Duration.Seconds(), result.CheckedAt.UnixMilli(),
+    )
+    return err
+}
+
+// RecordState stores the state a gateway was reported in after a cycle
+func (s *Store) RecordState(gateway config.Gateway, state string, at time.Time) error {
+    _, err := s.db.Exec(`INSERT INTO gateway_states (gateway, state, checked_at) VALUES (?, ?, ?)`,
+        gateway.Name, state, at.UnixMilli())
+    return err
+}
+
+// MarkReported records that the named report was sent and reports whether it had not been before
+func (s *Store) MarkReported(name string) (bool, error) {
+    res, err := s.db.Exec(`INSERT OR IGNORE INTO reports (name, sent_at) VALUES (?, ?)`, name, s.clock.Now().UnixMilli())
+    if err != nil {
+        return false, err
+    }
+    n, err := res.RowsAffected()
+    return n > 0, err
+}
+
+// Run prunes expired results until ctx is cancelled
+func (s *Store) Run(ctx context.Context) {
+    for {
+        s.prune()
+        select {
+        case <-ctx.Done():
+            return
+        case <-s.clock.After(pruneInterval):
+        }
+    }
+}
+
+func (s *Store) prune() {
+    if s.retention <= 0 {
+        return
+    }
+    cutoff := s.clock.Now().Add(-s.retention).UnixMilli()
+    if _, err := s.db.Exec(`DELETE FROM gateway_states WHERE checked_at < ?`, cutoff); err != nil {
+        s.logger.Printf("WARNING: failed to prune the gateway state history: %v", err)
+    }
+    s.pruneNotifications(cutoff)
+    res, err := s.db.Exec(`DELETE FROM check_results WHERE checked_at < ?`, cutoff)
+    if err != nil {
+        s.logger.Printf("WARNING: failed to prune the check history: %v", err)
+        return
+    }
+    if n, _ := res.RowsAffected(); n > 0 {
+        s.logger.Printf("Pruned %d check results older than %s from the history", n, s.retention)
+    }
+}
@@ -0,0 +1,41 @@
+package history
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "time"
+)
+
+// AcquireLease takes the named lease for holder until ttl from now, or renews it, and
+// reports whether holder has it, making the store a leader.Lock. The replicas must open the
+// same database file, which SQLite only locks reliably on a local file system.
+func (s *Store) AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (bool, error) {
+    now := s.clock.Now()
+    res, err := s.db.ExecContext(ctx,
+        `INSERT INTO leases (name, holder, expires_at) VALUES (?, ?, ?)
+         ON CONFLICT (name) DO UPDATE SET holder = excluded.holder, expires_at = excluded.expires_at
+         WHERE leases.holder = excluded.holder OR leases.expires_at <= ?`,
+        name, holder, now.Add(ttl).UnixMilli(), now.UnixMilli())
+    if err != nil {
+        return false, err
+    }
+    n, err := res.RowsAffected()
+    return n > 0, err
+}
+
+// LeaseHolder returns the holder of the named lease, empty when it is free or expired
+func (s *Store) LeaseHolder(ctx context.Context, name string) (string, error) {
+    var holder string
+    err := s.db.QueryRowContext(ctx, `SELECT holder FROM leases WHERE name = ? AND expires_at > ?`, name, s.clock.Now().UnixMilli()).Scan(&holder)
+    if errors.Is(err, sql.ErrNoRows) {
+        return "", nil
+    }
+    return holder, err
+}
+
+// ReleaseLease gives up the named lease if holder has it
+func (s *Store) ReleaseLease(ctx context.Context, name, holder string) error {
+    _, err := s.db.ExecContext(ctx, `DELETE FROM leases WHERE name = ? AND holder = ?`, name, holder)
+    return err
+}
@@ -0,0 +1,123 @@
+package history
+
+import "time"
+
+// NotificationEvent is a gateway going offline or recovering, recorded before the channels
+// are told about it so a restart neither drops nor repeats the notifications
+type NotificationEvent struct {
+    // ID is derived from the other fields, so the same outage always gets the same IDs
+    ID      string
+    Gateway string
+    // State is "offline" or "online"
+    State        string
+    OfflineSince time.Time
+    At           time.Time
+    // Delivered holds when each channel was last told about the event, by channel name
+    Delivered map[string]time.Time
+}
+
+// RecordNotificationEvent stores an event; recording it again changes nothing
+func (s *Store) RecordNotificationEvent(event NotificationEvent) error {
+    _, err := s.db.Exec(
+        `INSERT OR IGNORE INTO notification_events (id, gateway, state, offline_since, at) VALUES (?, ?, ?, ?, ?)`,
+        event.ID, event.Gateway, event.State, event.OfflineSince.UnixMilli(), event.At.UnixMilli())
+    return err
+}
+
+// ForgetNotificationEvent deletes an event and its receipts, for outages that ended without
+// any channel being told
+func (s *Store) ForgetNotificationEvent(id string) error {
+    if _, err := s.db.Exec(`DELETE FROM notification_receipts WHERE event_id = ?`, id); err != nil {
+        return err
+    }
+    _, err := s.db.Exec(`DELETE FROM notification_events WHERE id = ?`, id)
+    return err
+}
+
+// RecordDelivery stores the receipt of a channel told about an event at at, replacing an
+// earlier one such as that of a reminder
+func (s *Store) RecordDelivery(eventID, channel string, at time.Time) error {
+    _, err := s.db.Exec(
+        `INSERT INTO notification_receipts (event_id, channel, delivered_at) VALUES (?, ?, ?)
+         ON CONFLICT (event_id, channel) DO UPDATE SET delivered_at = excluded.delivered_at`,
+        eventID, channel, at.UnixMilli())
+    return err
+}
+
+// Deliveries returns when each channel was last told about an event, by channel name
+func (s *Store) Deliveries(eventID string) (map[string]time.Time, error) {
+    rows, err := s.db.Query(`SELECT channel, delivered_at FROM notification_receipts WHERE event_id = ?`, eventID)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    delivered := map[string]time.Time{}
+    for rows.Next() {
+        var channel string
+        var at int64
+        if err := rows.Scan(&channel, &at); err != nil {
+            return nil, err
+        }
+        delivered[channel] = time.UnixMilli(at).UTC()
+    }
+    return delivered, rows.Err()
+}
+
+// UnfinishedNotificationEvents returns the outages that have not ended, as their offline
+// events, and the recoveries that did not reach every channel told about their outage
+func (s *Store) UnfinishedNotificationEvents() ([]NotificationEvent, error) {
+    rows, err := s.db.Query(
+        `SELECT e.id, e.gateway, e.state, e.offline_since, e.at FROM notification_events e
+         WHERE (e.state = 'offline' AND NOT EXISTS (
+                SELECT 1 FROM notification_events r
+                WHERE r.gateway = e.gateway AND r.offline_since = e.offline_since AND r.state = 'online'))
+            OR (e.state = 'online' AND EXISTS (
+                SELECT 1 FROM notification_events o JOIN notification_receipts told ON told.event_id = o.id
+                WHERE o.gateway = e.gateway AND o.offline_since = e.offline_since AND o.state = 'offline'
+                  AND NOT EXISTS (SELECT 1 FROM notification_receipts d WHERE d.event_id = e.id AND d.channel = told.channel)))
+         ORDER BY e.at`)
+    if err != nil {
+        return nil, err
+    }
+    var events []NotificationEvent
+    for rows.Next() {
+        var event NotificationEvent
+        var since, at int64
+        if err := rows.Scan(&event.ID, &event.Gateway, &event.State, &since, &at); err != nil {
+            rows.Close()
+            return nil, err
+        }
+        event.OfflineSince, event.At = time.UnixMilli(since).UTC(), time.UnixMilli(at).UTC()
+        events = append(events, event)
+    }
+    rows.Close()
+    if err := rows.Err(); err != nil {
+        return nil, err
+    }
+
+    // The single connection is free again once rows is closed
+    for i := range events {
+        if events[i].Delivered, err = s.Deliveries(events[i].ID); err != nil {
+            return nil, err
+        }
+    }
+    return events, nil
+}
+
+// endedOutages selects the events of the outages that ended before the cutoff: both their
+// offline and their online event
+const endedOutages = `SELECT e.id FROM notification_events e JOIN notification_events r
+    ON r.gateway = e.gateway AND r.offline_since = e.offline_since AND r.state = 'online'
+    WHERE r.at < ?`
+
+// pruneNotifications deletes the events of the outages that ended before cutoff, with their receipts
+func (s *Store) pruneNotifications(cutoff int64) {
+    if _, err := s.db.Exec(`DELETE FROM notification_receipts WHERE event_id IN (`+endedOutages+`)`, cutoff); err != nil {
+        s.logger.Printf("WARNING: failed to prune the notification receipts: %v", err)
+        return
+    }
+    if _, err := s.db.Exec(`DELETE FROM notification_events WHERE id IN (`+endedOutages+`)`, cutoff); err != nil {
+        s.logger.Printf("WARNING: failed to prune the notification events: %v", err)
+    }
+}
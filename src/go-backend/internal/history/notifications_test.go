@@ -0,0 +1,48 @@
+package history
+
+import (
+    "io"
+    "log"
+    "path/filepath"
+    "testing"
+    "time"
+
+    "gateway-monitor/internal/clock"
+)
+
+func TestPruneKeepsOngoingOutages(t *testing.T) {
+    start := time.Date(2026, 1, 5, 12, 0, 0, 0, time.UTC)
+    clk := clock.NewFake(start)
+    store, err := Open(filepath.Join(t.TempDir(), "history.db"), 24*time.Hour, clk, log.New(io.Discard, "", 0))
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer store.Close()
+
+    events := []NotificationEvent{
+        {ID: "gw1-offline", Gateway: "gw1", State: "offline", OfflineSince: start, At: start},
+        {ID: "gw1-online", Gateway: "gw1", State: "online", OfflineSince: start, At: start.Add(time.Hour)},
+        {ID: "gw2-offline", Gateway: "gw2", State: "offline", OfflineSince: start, At: start},
+    }
+    for _, event := range events {
+        if err := store.RecordNotificationEvent(event); err != nil {
+            t.Fatal(err)
+        }
+        if err := store.RecordDelivery(event.ID, "email", event.At); err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    clk.Advance(48 * time.Hour)
+    store.prune()
+    unfinished, err := store.UnfinishedNotificationEvents()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(unfinished) != 1 || unfinished[0].ID != "gw2-offline" || !unfinished[0].Delivered["email"].Equal(start) {
+        t.Fatalf("unfinished events = %+v, want the ongoing outage of gw2 delivered by email", unfinished)
+    }
+    if delivered, _ := store.Deliveries("gw1-offline"); len(delivered) != 0 {
+        t.Errorf("the receipts of the ended outage of gw1 were kept: %v", delivered)
+    }
+}
@@ -0,0 +1,56 @@
+package history
+
+import (
+    "sort"
+    "time"
+)
+
+// Period is a stretch of time a gateway stayed in one state
+type Period struct {
+    Gateway         string    `json:"gateway"`
+    State           string    `json:"state"`
+    From            time.Time `json:"from"`
+    To              time.Time `json:"to"`
+    DurationSeconds float64   `json:"duration_seconds"`
+}
+
+// Periods returns the periods of the states recorded between from and to, ordered by gateway
+// and time, for all gateways when gateway is empty. Consecutive samples of the same state form
+// one period; like for Uptime each sample lasts at most maxSampleGap, so a longer gap
+// splits a period and the time in between is left out as unmonitored.
+func (s *Store) Periods(gateway string, from, to time.Time) ([]Period, error) {
+    samples, err := s.states(gateway, from, to)
+    if err != nil {
+        return nil, err
+    }
+    names := make([]string, 0, len(samples))
+    for name := range samples {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+
+    periods := []Period{}
+    for _, name := range names {
+        list := samples[name]
+        var current *Period
+        for i, sample := range list {
+            end := to
+            if i+1 < len(list) {
+                end = list[i+1].at
+            }
+            if limit := sample.at.Add(maxSampleGap); end.After(limit) {
+                end = limit
+            }
+            if current != nil && current.State == sample.state && current.To.Equal(sample.at) {
+                current.To = end
+                continue
+            }
+            periods = append(periods, Period{Gateway: name, State: sample.state, From: sample.at, To: end})
+            current = &periods[len(periods)-1]
+        }
+    }
+    for i := range periods {
+        periods[i].DurationSeconds = periods[i].To.Sub(periods[i].From).Seconds()
+    }
+    return periods, nil
+}
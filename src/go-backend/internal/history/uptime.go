@@ -0,0 +1,118 @@
+package history
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// maxSampleGap is the longest a recorded state is assumed to last. Longer gaps between
+// two samples, such as while the monitor was not running, count as unmonitored.
+const maxSampleGap = 15 * time.Minute
+
+// Uptime summarises the availability of a gateway over a time window
+type Uptime struct {
+    Gateway string `json:"gateway"`
+    // AvailabilityPercent is the share of the monitored time outside maintenance the gateway
+    // was online; nil when there is no such time
+    AvailabilityPercent *float64 `json:"availability_percent"`
+    Outages             int      `json:"outages"`
+    DowntimeSeconds     float64  `json:"downtime_seconds"`
+    MaintenanceSeconds  float64  `json:"maintenance_seconds"`
+    MonitoredSeconds    float64  `json:"monitored_seconds"`
+}
+
+// Uptime computes the availability of every gateway with states recorded between from and to.
+// Each state counts until the next sample of the gateway, at most maxSampleGap.
+func (s *Store) Uptime(from, to time.Time) (map[string]*Uptime, error) {
+    samples, err := s.states("", from, to)
+    if err != nil {
+        return nil, err
+    }
+
+    uptimes := map[string]*Uptime{}
+    for gateway, list := range samples {
+        var online, offline, maintenance time.Duration
+        outages := 0
+        for i, current := range list {
+            end := to
+            if i+1 < len(list) {
+                end = list[i+1].at
+            }
+            d := min(end.Sub(current.at), maxSampleGap)
+            switch current.state {
+            case "online":
+                online += d
+            case "offline":
+                offline += d
+                if i == 0 || list[i-1].state != "offline" {
+                    outages++
+                }
+            case "maintenance":
+                maintenance += d
+            }
+        }
+
+        uptime := &Uptime{
+            Gateway:            gateway,
+            Outages:            outages,
+            DowntimeSeconds:    offline.Seconds(),
+            MaintenanceSeconds: maintenance.Seconds(),
+            MonitoredSeconds:   (online + offline + maintenance).Seconds(),
+        }
+        if online+offline > 0 {
+            percent := 100 * online.Seconds() / (online + offline).Seconds()
+            uptime.AvailabilityPercent = &percent
+        }
+        uptimes[gateway] = uptime
+    }
+    return uptimes, nil
+}
+
+// stateSample is a state a gateway was recorded in
+type stateSample struct {
+    state string
+    at    time.Time
+}
+
+// states returns the states recorded between from and to in order, per gateway, for all
+// gateways when gateway is empty
+func (s *Store) states(gateway string, from, to time.Time) (map[string][]stateSample, error) {
+    rows, err := s.db.Query(
+        `SELECT gateway, state, checked_at FROM gateway_states
+         WHERE checked_at >= ? AND checked_at < ? AND (? = '' OR gateway = ?) ORDER BY gateway, checked_at`,
+        from.UnixMilli(), to.UnixMilli(), gateway, gateway,
+    )
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    samples := map[string][]stateSample{}
+    for rows.Next() {
+        var name, state string
+        var at int64
+        if err := rows.Scan(&name, &state, &at); err != nil {
+            return nil, err
+        }
+        samples[name] = append(samples[name], stateSample{state: state, at: time.UnixMilli(at)})
+    }
+    return samples, rows.Err()
+}
+
+// ParseWindow parses a Go duration that may also be a number of days such as "30d"
+func ParseWindow(window string) (time.Duration, error) {
+    if days, ok := strings.CutSuffix(window, "d"); ok {
+        n, err := strconv.Atoi(days)
+        if err != nil || n <= 0 {
+            return 0, fmt.Errorf("invalid window %q", window)
+        }
+        return time.Duration(n) * 24 * time.Hour, nil
+    }
+    d, err := time.ParseDuration(window)
+    if err != nil || d <= 0 {
+        return 0, fmt.Errorf("invalid window %q", window)
+    }
+    return d, nil
+}
@@ -0,0 +1,247 @@
+package leader
+
+import (
+    "bytes"
+    "context"
+    "crypto/tls"
+    "crypto/x509"
+    "encoding/json"
+    "fmt"
+    "io"
+    "math"
+    "net/http"
+    "net/url"
+    "os"
+    "strings"
+    "time"
+
+    "gateway-monitor/internal/clock"
+)
+
+// serviceAccountDir holds the credentials of the pod's service account
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// microTime is the format of the times of a Lease
+const microTime = "2006-01-02T15:04:05.000000Z07:00"
+
+// Kubernetes is a Lock on coordination.k8s.io Leases, taken with the pod's service account.
+// Lease names are "[namespace/]name", in the pod's namespace by default.
+type Kubernetes struct {
+    client    *http.Client
+    clock     clock.Clock
+    apiURL    string
+    namespace string
+}
+
+// NewKubernetes returns a Lock on Leases of the cluster the process runs in
+func NewKubernetes(clk clock.Clock) (*Kubernetes, error) {
+    host := os.Getenv("KUBERNETES_SERVICE_HOST")
+    if host == "" {
+        return nil, fmt.Errorf("KUBERNETES_SERVICE_HOST is not set, not running in a cluster")
+    }
+    port := os.Getenv("KUBERNETES_SERVICE_PORT")
+    if port == "" {
+        port = "443"
+    }
+    namespace, err := os.ReadFile(serviceAccountDir + "/namespace")
+    if err != nil {
+        return nil, fmt.Errorf("failed to read the pod's namespace: %v", err)
+    }
+    ca, err := os.ReadFile(serviceAccountDir + "/ca.crt")
+    if err != nil {
+        return nil, fmt.Errorf("failed to read cluster CA: %v", err)
+    }
+    pool := x509.NewCertPool()
+    if !pool.AppendCertsFromPEM(ca) {
+        return nil, fmt.Errorf("no certificates in %s/ca.crt", serviceAccountDir)
+    }
+    transport := http.DefaultTransport.(*http.Transport).Clone()
+    transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+    return &Kubernetes{
+        client:    &http.Client{Timeout: 10 * time.Second, Transport: transport},
+        clock:     clk,
+        apiURL:    fmt.Sprintf("https://%s:%s/apis/coordination.k8s.io/v1", host, port),
+        namespace: strings.TrimSpace(string(namespace)),
+    }, nil
+}
+
+// lease is the part of a coordination.k8s.io/v1 Lease the lock uses
+type lease struct {
+    APIVersion string        `json:"apiVersion"`
+    Kind       string        `json:"kind"`
+    Metadata   leaseMetadata `json:"metadata"`
+    Spec       leaseSpec     `json:"spec"`
+}
+
+type leaseMetadata struct {
+    Name            string `json:"name"`
+    Namespace       string `json:"namespace,omitempty"`
+    ResourceVersion string `json:"resourceVersion,omitempty"`
+}
+
+type leaseSpec struct {
+    HolderIdentity       string `json:"holderIdentity,omitempty"`
+    LeaseDurationSeconds int    `json:"leaseDurationSeconds,omitempty"`
+    AcquireTime          string `json:"acquireTime,omitempty"`
+    RenewTime            string `json:"renewTime,omitempty"`
+    LeaseTransitions     int    `json:"leaseTransitions,omitempty"`
+}
+
+// expired reports whether the lease's holder failed to renew it in time. The renewal time
+// is compared with the local clock, so the ttl must be well above the clock skew.
+func (s leaseSpec) expired(now time.Time) bool {
+    if s.HolderIdentity == "" {
+        return true
+    }
+    renewed, err := time.Parse(time.RFC3339Nano, s.RenewTime)
+    if err != nil {
+        return true
+    }
+    return !now.Before(renewed.Add(time.Duration(s.LeaseDurationSeconds) * time.Second))
+}
+
+// AcquireLease creates the Lease or updates it for holder. Updates carry the resource
+// version read, so of two replicas taking an expired Lease at once only one succeeds.
+func (k *Kubernetes) AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (bool, error) {
+    current, found, err := k.get(ctx, name)
+    if err != nil {
+        return false, err
+    }
+    now := k.clock.Now()
+    if found && current.Spec.HolderIdentity != holder && !current.Spec.expired(now) {
+        return false, nil
+    }
+
+    spec := current.Spec
+    if spec.HolderIdentity != holder {
+        spec.AcquireTime = now.UTC().Format(microTime)
+        if found {
+            spec.LeaseTransitions++
+        }
+    }
+    spec.HolderIdentity = holder
+    spec.LeaseDurationSeconds = int(math.Ceil(ttl.Seconds()))
+    spec.RenewTime = now.UTC().Format(microTime)
+    current.Spec = spec
+
+    method := http.MethodPut
+    if !found {
+        method = http.MethodPost
+    }
+    status, err := k.write(ctx, method, current)
+    if err != nil {
+        return false, err
+    }
+    // Conflict: another replica wrote the Lease since it was read
+    return status != http.StatusConflict, nil
+}
+
+// ReleaseLease clears the holder of the Lease if it is holder
+func (k *Kubernetes) ReleaseLease(ctx context.Context, name, holder string) error {
+    current, found, err := k.get(ctx, name)
+    if err != nil || !found || current.Spec.HolderIdentity != holder {
+        return err
+    }
+    current.Spec.HolderIdentity = ""
+    current.Spec.RenewTime = ""
+    _, err = k.write(ctx, http.MethodPut, current)
+    return err
+}
+
+// LeaseHolder returns the holder of the Lease, empty when it does not exist or expired
+func (k *Kubernetes) LeaseHolder(ctx context.Context, name string) (string, error) {
+    current, found, err := k.get(ctx, name)
+    if err != nil || !found || current.Spec.expired(k.clock.Now()) {
+        return "", err
+    }
+    return current.Spec.HolderIdentity, nil
+}
+
+// get reads the Lease, returning a new one to create when it does not exist
+func (k *Kubernetes) get(ctx context.Context, name string) (lease, bool, error) {
+    namespace, name := k.locate(name)
+    fresh := lease{
+        APIVersion: "coordination.k8s.io/v1",
+        Kind:       "Lease",
+        Metadata:   leaseMetadata{Name: name, Namespace: namespace},
+    }
+    resp, err := k.do(ctx, http.MethodGet, k.leaseURL(namespace, name), nil)
+    if err != nil {
+        return fresh, false, err
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode == http.StatusNotFound {
+        return fresh, false, nil
+    }
+    if resp.StatusCode != http.StatusOK {
+        return fresh, false, apiError(resp)
+    }
+    var current lease
+    if err := json.NewDecoder(resp.Body).Decode(&current); err != nil {
+        return fresh, false, fmt.Errorf("invalid Lease response: %v", err)
+    }
+    current.APIVersion, current.Kind = fresh.APIVersion, fresh.Kind
+    return current, true, nil
+}
+
+// write creates or replaces the Lease and returns the status, a conflict not being an error
+func (k *Kubernetes) write(ctx context.Context, method string, l lease) (int, error) {
+    body, err := json.Marshal(l)
+    if err != nil {
+        return 0, err
+    }
+    target := k.leaseURL(l.Metadata.Namespace, l.Metadata.Name)
+    if method == http.MethodPost {
+        target = fmt.Sprintf("%s/namespaces/%s/leases", k.apiURL, url.PathEscape(l.Metadata.Namespace))
+    }
+    resp, err := k.do(ctx, method, target, body)
+    if err != nil {
+        return 0, err
+    }
+    defer resp.Body.Close()
+    switch resp.StatusCode {
+    case http.StatusOK, http.StatusCreated, http.StatusConflict:
+        return resp.StatusCode, nil
+    }
+    return resp.StatusCode, apiError(resp)
+}
+
+func (k *Kubernetes) do(ctx context.Context, method, target string, body []byte) (*http.Response, error) {
+    var reader io.Reader
+    if body != nil {
+        reader = bytes.NewReader(body)
+    }
+    req, err := http.NewRequestWithContext(ctx, method, target, reader)
+    if err != nil {
+        return nil, err
+    }
+    // The token is read on every request since projected tokens are rotated
+    token, err := os.ReadFile(serviceAccountDir + "/token")
+    if err != nil {
+        return nil, fmt.Errorf("failed to read service account token: %v", err)
+    }
+    req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+    req.Header.Set("Accept", "application/json")
+    if body != nil {
+        req.Header.Set("Content-Type", "application/json")
+    }
+    return k.client.Do(req)
+}
+
+// locate splits a "[namespace/]name" lease name
+func (k *Kubernetes) locate(name string) (string, string) {
+    if namespace, name, ok := strings.Cut(name, "/"); ok {
+        return namespace, name
+    }
+    return k.namespace, name
+}
+
+func (k *Kubernetes) leaseURL(namespace, name string) string {
+    return fmt.Sprintf("%s/namespaces/%s/leases/%s", k.apiURL, url.PathEscape(namespace), url.PathEscape(name))
+}
+
+// apiError describes an unexpected response of the Kubernetes API
+func apiError(resp *http.Response) error {
+    body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+    return fmt.Errorf("kubernetes API returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+}
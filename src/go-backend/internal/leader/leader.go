@@ -0,0 +1,141 @@
+// Package leader elects which of several replicas runs the checks and sends the
+// notifications, so a second replica can take over when the first one's host fails.
+package leader
+
+import (
+    "context"
+    "log"
+    "sync"
+    "time"
+
+    "gateway-monitor/internal/clock"
+    "gateway-monitor/internal/metrics"
+)
+
+// Lock is a lease only one holder has at a time, such as a row in the history database or
+// a Kubernetes Lease
+type Lock interface {
+    // AcquireLease takes the named lease for holder until ttl from now, or renews it, and
+    // reports whether holder has it. A lease held by another holder is only taken once it expired.
+    AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (bool, error)
+    // ReleaseLease gives up the lease if holder has it
+    ReleaseLease(ctx context.Context, name, holder string) error
+    // LeaseHolder returns the holder of the named lease, empty when it is free or expired
+    LeaseHolder(ctx context.Context, name string) (string, error)
+}
+
+// Elector holds the leadership while it can renew the lease
+type Elector struct {
+    lock     Lock
+    name     string
+    identity string
+    ttl      time.Duration
+    metrics  *metrics.Metrics
+    clock    clock.Clock
+    logger   *log.Logger
+
+    mu        sync.Mutex
+    leading   bool
+    decided   bool
+    heldUntil time.Time
+    // holder is the replica holding the lease at the last renewal, empty when unknown
+    holder string
+}
+
+// New creates an Elector competing for the named lease as identity, which must differ
+// between the replicas. The lease expires ttl after the last renewal.
+func New(lock Lock, name, identity string, ttl time.Duration, m *metrics.Metrics, clk clock.Clock, logger *log.Logger) *Elector {
+    return &Elector{lock: lock, name: name, identity: identity, ttl: ttl, metrics: m, clock: clk, logger: logger}
+}
+
+// Leading reports whether this replica holds the lease. It stops leading as soon as the
+// lease it could not renew expires, even before the next renewal attempt fails.
+func (e *Elector) Leading() bool {
+    e.mu.Lock()
+    defer e.mu.Unlock()
+    return e.leading && e.clock.Now().Before(e.heldUntil)
+}
+
+// Holder returns the identity of the replica that held the lease at the last renewal,
+// empty when none or unknown
+func (e *Elector) Holder() string {
+    if e.Leading() {
+        return e.identity
+    }
+    e.mu.Lock()
+    defer e.mu.Unlock()
+    return e.holder
+}
+
+// Run renews the lease three times per ttl until ctx is cancelled. Call Renew once before
+// so the replica knows its role before the first cycle.
+func (e *Elector) Run(ctx context.Context) {
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-e.clock.After(e.ttl / 3):
+        }
+        e.Renew(ctx)
+    }
+}
+
+// Renew takes or renews the lease once. An error keeps the lease held until it expires,
+// so a short outage of the lock's store doesn't cause a failover.
+func (e *Elector) Renew(ctx context.Context) {
+    start := e.clock.Now()
+    ctx, cancel := context.WithTimeout(ctx, e.ttl/3)
+    held, err := e.lock.AcquireLease(ctx, e.name, e.identity, e.ttl)
+    holder := e.identity
+    if err == nil && !held {
+        // Standby replicas point the API clients changing the configuration to the leader
+        var lookupErr error
+        if holder, lookupErr = e.lock.LeaseHolder(ctx, e.name); lookupErr != nil {
+            e.logger.Printf("WARNING: failed to look up the holder of the %s lease: %v", e.name, lookupErr)
+        }
+    }
+    cancel()
+    if err != nil {
+        e.logger.Printf("WARNING: failed to renew the %s lease: %v", e.name, err)
+    }
+
+    e.mu.Lock()
+    defer e.mu.Unlock()
+    if err == nil {
+        e.heldUntil = time.Time{}
+        if held {
+            e.heldUntil = start.Add(e.ttl)
+        }
+        e.holder = holder
+    }
+    leading := e.clock.Now().Before(e.heldUntil)
+    if leading != e.leading || !e.decided {
+        if leading {
+            e.logger.Printf("Elected leader as %s, running the checks and sending the notifications", e.identity)
+        } else if e.leading {
+            e.logger.Printf("WARNING: %s lost the %s lease, standing by", e.identity, e.name)
+        } else {
+            e.logger.Printf("Standing by as %s while another replica holds the %s lease", e.identity, e.name)
+        }
+    }
+    e.leading, e.decided = leading, true
+    e.metrics.Leader.Set(metrics.Bool(leading))
+}
+
+// Release gives up the lease on shutdown so another replica takes over without waiting
+// for it to expire
+func (e *Elector) Release(ctx context.Context) {
+    e.mu.Lock()
+    leading := e.leading
+    e.leading = false
+    e.mu.Unlock()
+    e.metrics.Leader.Set(0)
+    if !leading {
+        return
+    }
+    if err := e.lock.ReleaseLease(ctx, e.name, e.identity); err != nil {
+        e.logger.Printf("WARNING: failed to release the %s lease: %v", e.name, err)
+        return
+    }
+    e.logger.Printf("Released the %s lease", e.name)
+}
@@ -0,0 +1,79 @@
+package metrics
+
+import (
+    "sort"
+    "sync"
+
+    "github.com/prometheus/client_golang/prometheus"
+)
+
+// GatewayInfo exports gateway_info, which is always 1 and carries each gateway's metadata
+// labels. Gateways choose their own label names, so the collector is unchecked and every
+// series gets the union of the names, left empty where a gateway doesn't set one.
+type GatewayInfo struct {
+    mu       sync.Mutex
+    gateways map[string]gatewayInfo
+}
+
+type gatewayInfo struct {
+    project string
+    origin  string
+    labels  map[string]string
+}
+
+// NewGatewayInfo returns an empty gateway_info collector
+func NewGatewayInfo() *GatewayInfo {
+    return &GatewayInfo{gateways: map[string]gatewayInfo{}}
+}
+
+// Set replaces the labels exported for a gateway
+func (g *GatewayInfo) Set(name, project, origin string, labels map[string]string) {
+    g.mu.Lock()
+    defer g.mu.Unlock()
+    g.gateways[name] = gatewayInfo{project: project, origin: origin, labels: labels}
+}
+
+// Delete drops the series of a gateway
+func (g *GatewayInfo) Delete(name string) {
+    g.mu.Lock()
+    defer g.mu.Unlock()
+    delete(g.gateways, name)
+}
+
+// Describe implements prometheus.Collector; it sends nothing since the label names vary
+func (g *GatewayInfo) Describe(chan<- *prometheus.Desc) {}
+
+// Collect implements prometheus.Collector
+func (g *GatewayInfo) Collect(ch chan<- prometheus.Metric) {
+    g.mu.Lock()
+    defer g.mu.Unlock()
+    if len(g.gateways) == 0 {
+        return
+    }
+
+    seen := map[string]bool{}
+    var names []string
+    for _, info := range g.gateways {
+        for name := range info.labels {
+            if !seen[name] {
+                seen[name] = true
+                names = append(names, name)
+            }
+        }
+    }
+    sort.Strings(names)
+
+    desc := prometheus.NewDesc(
+        "gateway_info",
+        "Metadata of a monitored gateway as labels; the value is always 1",
+        append([]string{"name", "project", "origin"}, names...),
+        nil,
+    )
+    for name, info := range g.gateways {
+        values := []string{name, info.project, info.origin}
+        for _, label := range names {
+            values = append(values, info.labels[label])
+        }
+        ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, 1, values...)
+    }
+}
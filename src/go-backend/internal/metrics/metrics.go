@@ -0,0 +1,630 @@
+// Package metrics holds the Prometheus metrics exported by the backend.
+package metrics
+
+import (
+    "github.com/prometheus/client_golang/prometheus"
+)
+
+// checkLabels identify a single check of a gateway. Every per-gateway metric carries the
+// gateway's project, empty when it has none, so rules and dashboards can select by project.
+var checkLabels = []string{"gateway_name", "project", "link_url", "check_type"}
+
+// Metrics groups every collector so they can be registered on any registry
+type Metrics struct {
+    GatewayOnlineStatus        *prometheus.GaugeVec
+    GatewayOverallStatus       *prometheus.GaugeVec
+    GatewayGeoStatus           *prometheus.GaugeVec
+    GatewayInMaintenance       *prometheus.GaugeVec
+    GatewayActiveSilences      *prometheus.GaugeVec
+    GatewayAvailabilitySeconds *prometheus.CounterVec
+    GatewayFlapping            *prometheus.GaugeVec
+    GatewayStatusTransitions   *prometheus.CounterVec
+    GatewayCurrentDowntime     *prometheus.GaugeVec
+    GatewayCheckStepDuration   *prometheus.GaugeVec
+    GatewayInfo                *GatewayInfo
+
+    GatewayCheckValue    *prometheus.GaugeVec
+    GatewayCheckDegraded *prometheus.GaugeVec
+
+    GatewayLatitude  *prometheus.GaugeVec
+    GatewayLongitude *prometheus.GaugeVec
+    GatewayAltitude  *prometheus.GaugeVec
+
+    GatewayLinkStatus        *prometheus.GaugeVec
+    GatewayStatusStale       *prometheus.GaugeVec
+    GatewayLastUpdate        *prometheus.GaugeVec
+    GatewayUpdateGapAnomaly  *prometheus.GaugeVec
+    GatewayUpdateGapBaseline *prometheus.GaugeVec
+    GatewayLNSConnected      *prometheus.GaugeVec
+    GatewayPeeringStatus     *prometheus.GaugeVec
+    GatewayPingRTT           *prometheus.GaugeVec
+    GatewayDNSResolution     *prometheus.GaugeVec
+    GatewaySNMPValue         *prometheus.GaugeVec
+    GatewayCheckDuration     *prometheus.HistogramVec
+    GatewayCheckTotal        *prometheus.CounterVec
+    GatewayCheckRetries      *prometheus.CounterVec
+    GatewayCheckErrors       *prometheus.CounterVec
+    GatewayCheckResponseSize *prometheus.GaugeVec
+    GatewayCheckHTTPStatus   *prometheus.GaugeVec
+    GatewayCheckCircuitOpen  *prometheus.GaugeVec
+    GatewayUplinkMessages    *prometheus.CounterVec
+    GatewayDownlinkMessages  *prometheus.CounterVec
+    GatewayUplinkRSSI        *prometheus.GaugeVec
+    GatewayUplinkSNR         *prometheus.GaugeVec
+
+    UDPForwarderUnknownEUI *prometheus.CounterVec
+    UDPForwarderMalformed  *prometheus.CounterVec
+    UDPForwarderPackets    *prometheus.CounterVec
+
+    ConfigReloads    *prometheus.CounterVec
+    ConfigLastReload *prometheus.GaugeVec
+    ConfigDrift      *prometheus.GaugeVec
+    ConfigLoadErrors *prometheus.CounterVec
+
+    LastCycleCompleted prometheus.Gauge
+    LastCycleDuration  prometheus.Gauge
+    CycleOverruns      prometheus.Counter
+
+    Leader prometheus.Gauge
+
+    DiscoveryRuns      *prometheus.CounterVec
+    DiscoveredGateways prometheus.Gauge
+
+    RateLimitWait *prometheus.CounterVec
+
+    BuildInfo *prometheus.GaugeVec
+}
+
+// New creates the metrics and registers them with reg
+func New(reg prometheus.Registerer) *Metrics {
+    m := &Metrics{
+        GatewayOnlineStatus: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Name: "gateway_online_status",
+                Help: "Shows whether the gateway is online: 1 for online, 0 for offline",
+            },
+            []string{"name", "project", "latitude", "longitude"},
+        ),
+
+        GatewayOverallStatus: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Name: "gateway_overall_status",
+                Help: "Result of the gateway's checks combined with its status policy, before offline_after and online_after apply: 1 for online, 0 for offline",
+            },
+            []string{"name", "project"},
+        ),
+
+        GatewayGeoStatus: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Name: "gateway_geo_status",
+                Help: "Status of a located gateway with its coordinates as labels, for map panels: 0 for offline, 1 for online, 2 for maintenance",
+            },
+            []string{"name", "project", "latitude", "longitude", "geohash"},
+        ),
+
+        GatewayInMaintenance: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Name: "gateway_in_maintenance",
+                Help: "Shows whether the gateway is in a maintenance window or silenced: 1 for yes, 0 for no",
+            },
+            []string{"name", "project"},
+        ),
+
+        GatewayFlapping: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Name: "gateway_flapping",
+                Help: "Shows whether the gateway's checks keep alternating between online and offline: 1 for yes, 0 for no",
+            },
+            []string{"name", "project"},
+        ),
+
+        GatewayActiveSilences: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Name: "gateway_active_silences",
+                Help: "Number of silences created through the API that currently mute the gateway",
+            },
+            []string{"name", "project"},
+        ),
+
+        GatewayStatusTransitions: prometheus.NewCounterVec(
+            prometheus.CounterOpts{
+                Name: "gateway_status_transitions_total",
+                Help: "Changes of the gateway's state between online, offline and maintenance",
+            },
+            []string{"name", "project", "from", "to"},
+        ),
+
+        GatewayCurrentDowntime: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Name: "gateway_current_downtime_seconds",
+                Help: "How long the gateway has been offline, 0 while it is online or in maintenance",
+            },
+            []string{"name", "project"},
+        ),
+
+        GatewayAvailabilitySeconds: prometheus.NewCounterVec(
+            prometheus.CounterOpts{
+                Name: "gateway_availability_seconds_total",
+                Help: "Time the gateway spent online, offline or in maintenance",
+            },
+            []string{"name", "project", "state"},
+        ),
+
+        GatewayCheckStepDuration: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Name: "gateway_check_step_duration_seconds",
+                Help: "Duration of the last run of each step of a multi-step check",
+            },
+            []string{"name", "project", "step"},
+        ),
+
+        GatewayInfo: NewGatewayInfo(),
+
+        GatewayCheckValue: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Name: "gateway_check_value",
+                Help: "Last value of a numeric field extracted from a check response",
+            },
+            []string{"gateway_name", "project", "field"},
+        ),
+
+        GatewayCheckDegraded: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Name: "gateway_check_degraded",
+                Help: "Shows whether a field rule of the gateway is violated: 1 for degraded, 0 otherwise",
+            },
+            []string{"gateway_name", "project"},
+        ),
+
+        GatewayLatitude: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Name: "gateway_latitude",
+                Help: "Latitude of the gateway in decimal degrees",
+            },
+            []string{"gateway_name", "project"},
+        ),
+
+        GatewayLongitude: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Name: "gateway_longitude",
+                Help: "Longitude of the gateway in decimal degrees",
+            },
+            []string{"gateway_name", "project"},
+        ),
+
+        GatewayAltitude: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Name: "gateway_altitude",
+                Help: "Altitude of the gateway in metres, when known",
+            },
+            []string{"gateway_name", "project"},
+        ),
+
+        GatewayLinkStatus: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Name: "gateway_link_status",
+                Help: "Shows whether a single check reports the gateway online: 1 for online, 0 for offline",
+            },
+            checkLabels,
+        ),
+
+        GatewayStatusStale: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Name: "gateway_status_stale",
+                Help: "Shows whether the status timestamp of a check is older than its max age: 1 for stale, 0 for fresh",
+            },
+            checkLabels,
+        ),
+
+        GatewayLastUpdate: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Name: "gateway_last_update_timestamp_seconds",
+                Help: "Unix time the check last found the gateway online, as reported by its status source where available",
+            },
+            checkLabels,
+        ),
+
+        GatewayUpdateGapAnomaly: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Name: "gateway_update_gap_anomaly",
+                Help: "Shows whether the status source of a check has gone without an update for well beyond its usual interval: 1 for yes, 0 for no",
+            },
+            checkLabels,
+        ),
+
+        GatewayUpdateGapBaseline: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Name: "gateway_update_gap_baseline_seconds",
+                Help: "Learned interval between the updates the status source of a check reports",
+            },
+            checkLabels,
+        ),
+
+        GatewayLNSConnected: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Name: "gateway_lns_connected",
+                Help: "Shows whether the LNS accepts the gateway's Basics Station connection: 1 for yes, 0 for no",
+            },
+            []string{"gateway_name", "project"},
+        ),
+
+        GatewayPeeringStatus: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Name: "gateway_peering_status",
+                Help: "Shows whether Packet Broker lists the gateway as online, so roaming partners see its traffic: 1 for yes, 0 for no",
+            },
+            []string{"gateway_name", "project"},
+        ),
+
+        GatewayPingRTT: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Name: "gateway_ping_rtt_seconds",
+                Help: "Round trip time of the last answered ping to the gateway host",
+            },
+            []string{"gateway_name", "project", "host"},
+        ),
+
+        GatewayDNSResolution: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Name: "gateway_dns_resolution_seconds",
+                Help: "Time taken by the last DNS lookup of a dns check",
+            },
+            []string{"gateway_name", "project", "host"},
+        ),
+
+        GatewaySNMPValue: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Name: "gateway_snmp_value",
+                Help: "Last numeric value read by an snmp check from the gateway's site router",
+            },
+            []string{"gateway_name", "project", "oid"},
+        ),
+
+        GatewayCheckDuration: prometheus.NewHistogramVec(
+            prometheus.HistogramOpts{
+                Name:    "gateway_check_duration_seconds",
+                Help:    "Duration of each check request, including connection setup and TLS handshake",
+                Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2, 5, 10, 20, 30, 60},
+            },
+            checkLabels,
+        ),
+
+        GatewayCheckTotal: prometheus.NewCounterVec(
+            prometheus.CounterOpts{
+                Name: "gateway_check_total",
+                Help: "Number of checks performed by result: success, http_error, timeout or parse_error",
+            },
+            append(checkLabels, "result"),
+        ),
+
+        GatewayCheckErrors: prometheus.NewCounterVec(
+            prometheus.CounterOpts{
+                Name: "gateway_check_errors_total",
+                Help: "Failed checks by error class: timeout, dns, http_status, parse_error, connection or other",
+            },
+            append(checkLabels, "class"),
+        ),
+
+        GatewayCheckRetries: prometheus.NewCounterVec(
+            prometheus.CounterOpts{
+                Name: "gateway_check_retries_total",
+                Help: "Number of times a check was retried after it did not report the gateway online",
+            },
+            checkLabels,
+        ),
+
+        GatewayCheckResponseSize: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Name: "gateway_check_response_size_bytes",
+                Help: "Size of the last response body returned by the check",
+            },
+            checkLabels,
+        ),
+
+        GatewayCheckHTTPStatus: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Name: "gateway_check_http_status_code",
+                Help: "HTTP status code of the last response received by an HTTP based check",
+            },
+            checkLabels,
+        ),
+
+        GatewayCheckCircuitOpen: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Name: "gateway_check_circuit_open",
+                Help: "Shows whether a persistently failing check is only probed occasionally: 1 for yes, 0 for no",
+            },
+            checkLabels,
+        ),
+
+        GatewayUplinkMessages: prometheus.NewCounterVec(
+            prometheus.CounterOpts{
+                Name: "gateway_uplink_messages_total",
+                Help: "Number of uplink messages the gateway received, as reported by tts, ttn-v3 and udp-forwarder checks",
+            },
+            checkLabels,
+        ),
+
+        GatewayDownlinkMessages: prometheus.NewCounterVec(
+            prometheus.CounterOpts{
+                Name: "gateway_downlink_messages_total",
+                Help: "Number of downlink messages sent to or emitted by the gateway, as reported by tts, ttn-v3 and udp-forwarder checks",
+            },
+            checkLabels,
+        ),
+
+        GatewayUplinkRSSI: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Name: "gateway_uplink_rssi_dbm",
+                Help: "Signal strength of the last uplink forwarded by the gateway to an udp-forwarder check",
+            },
+            checkLabels,
+        ),
+
+        GatewayUplinkSNR: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Name: "gateway_uplink_snr_db",
+                Help: "Signal to noise ratio of the last LoRa uplink forwarded by the gateway to an udp-forwarder check",
+            },
+            checkLabels,
+        ),
+
+        UDPForwarderUnknownEUI: prometheus.NewCounterVec(
+            prometheus.CounterOpts{
+                Name: "udp_forwarder_unknown_eui_total",
+                Help: "Number of packet forwarder datagrams received from gateway EUIs that are not in the configuration",
+            },
+            []string{"port"},
+        ),
+
+        UDPForwarderMalformed: prometheus.NewCounterVec(
+            prometheus.CounterOpts{
+                Name: "udp_forwarder_malformed_total",
+                Help: "Number of packet forwarder datagrams that could not be parsed",
+            },
+            []string{"port"},
+        ),
+
+        UDPForwarderPackets: prometheus.NewCounterVec(
+            prometheus.CounterOpts{
+                Name: "udp_forwarder_packets_total",
+                Help: "Number of packet forwarder datagrams received from configured gateways by type",
+            },
+            []string{"eui", "type"},
+        ),
+
+        ConfigReloads: prometheus.NewCounterVec(
+            prometheus.CounterOpts{
+                Name: "loracheck_config_reloads_total",
+                Help: "Number of attempts to apply a new gateway configuration",
+            },
+            []string{"source", "result"},
+        ),
+
+        ConfigLastReload: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Name: "loracheck_config_last_reload_success_timestamp_seconds",
+                Help: "Unix time the running gateway configuration was applied, labeled with its source and commit",
+            },
+            []string{"source", "commit"},
+        ),
+
+        ConfigDrift: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Name: "loracheck_config_drift",
+                Help: "Number of gateways that differ between the running configuration and its file",
+            },
+            []string{"type"},
+        ),
+
+        ConfigLoadErrors: prometheus.NewCounterVec(
+            prometheus.CounterOpts{
+                Name: "loracheck_config_load_errors_total",
+                Help: "Number of failed periodic configuration re-fetches",
+            },
+            []string{"source"},
+        ),
+
+        BuildInfo: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Name: "loracheck_build_info",
+                Help: "Always 1, labelled with the version, commit and build date of the running binary",
+            },
+            []string{"version", "commit", "build_date", "goversion"},
+        ),
+
+        LastCycleCompleted: prometheus.NewGauge(
+            prometheus.GaugeOpts{
+                Name: "loracheck_last_cycle_completed_timestamp_seconds",
+                Help: "Unix time at which the last full check cycle completed",
+            },
+        ),
+
+        LastCycleDuration: prometheus.NewGauge(
+            prometheus.GaugeOpts{
+                Name: "loracheck_last_cycle_duration_seconds",
+                Help: "Time taken by the last full check cycle",
+            },
+        ),
+
+        CycleOverruns: prometheus.NewCounter(
+            prometheus.CounterOpts{
+                Name: "loracheck_cycle_overrun_total",
+                Help: "Number of check cycles that ran longer than the check interval",
+            },
+        ),
+
+        Leader: prometheus.NewGauge(
+            prometheus.GaugeOpts{
+                Name: "loracheck_leader",
+                Help: "Shows whether this replica runs the checks and sends the notifications: 1 for the elected leader or without leader election, 0 while standing by",
+            },
+        ),
+
+        DiscoveryRuns: prometheus.NewCounterVec(
+            prometheus.CounterOpts{
+                Name: "loracheck_discovery_runs_total",
+                Help: "Number of gateway discovery runs by result",
+            },
+            []string{"result"},
+        ),
+
+        DiscoveredGateways: prometheus.NewGauge(
+            prometheus.GaugeOpts{
+                Name: "loracheck_discovered_gateways",
+                Help: "Number of gateways found by the last successful discovery run",
+            },
+        ),
+
+        RateLimitWait: prometheus.NewCounterVec(
+            prometheus.CounterOpts{
+                Name: "loracheck_rate_limit_wait_seconds_total",
+                Help: "Time check requests spent waiting for the rate limit of an upstream host",
+            },
+            []string{"host"},
+        ),
+    }
+
+    reg.MustRegister(
+        m.GatewayOnlineStatus,
+        m.GatewayOverallStatus,
+        m.GatewayGeoStatus,
+        m.GatewayInMaintenance,
+        m.GatewayActiveSilences,
+        m.GatewayAvailabilitySeconds,
+        m.GatewayFlapping,
+        m.GatewayStatusTransitions,
+        m.GatewayCurrentDowntime,
+        m.GatewayCheckStepDuration,
+        m.GatewayCheckValue,
+        m.GatewayCheckDegraded,
+        m.GatewayLatitude,
+        m.GatewayLongitude,
+        m.GatewayAltitude,
+        m.GatewayLinkStatus,
+        m.GatewayStatusStale,
+        m.GatewayLastUpdate,
+        m.GatewayUpdateGapAnomaly,
+        m.GatewayUpdateGapBaseline,
+        m.GatewayLNSConnected,
+        m.GatewayPeeringStatus,
+        m.GatewayPingRTT,
+        m.GatewayDNSResolution,
+        m.GatewaySNMPValue,
+        m.GatewayCheckDuration,
+        m.GatewayCheckTotal,
+        m.GatewayCheckRetries,
+        m.GatewayCheckErrors,
+        m.GatewayCheckResponseSize,
+        m.GatewayCheckHTTPStatus,
+        m.GatewayCheckCircuitOpen,
+        m.GatewayUplinkMessages,
+        m.GatewayDownlinkMessages,
+        m.GatewayUplinkRSSI,
+        m.GatewayUplinkSNR,
+        m.UDPForwarderUnknownEUI,
+        m.UDPForwarderMalformed,
+        m.UDPForwarderPackets,
+        m.ConfigReloads,
+        m.ConfigLastReload,
+        m.ConfigDrift,
+        m.ConfigLoadErrors,
+        m.BuildInfo,
+        m.LastCycleCompleted,
+        m.LastCycleDuration,
+        m.CycleOverruns,
+        m.Leader,
+        m.DiscoveryRuns,
+        m.DiscoveredGateways,
+        m.GatewayInfo,
+        m.RateLimitWait,
+    )
+    return m
+}
+
+// DeleteGateway drops every series belonging to a gateway that is no longer monitored
+func (m *Metrics) DeleteGateway(name string) {
+    labels := prometheus.Labels{"name": name}
+    m.GatewayOnlineStatus.DeletePartialMatch(labels)
+    m.GatewayOverallStatus.DeletePartialMatch(labels)
+    m.GatewayGeoStatus.DeletePartialMatch(labels)
+    m.GatewayInMaintenance.DeletePartialMatch(labels)
+    m.GatewayActiveSilences.DeletePartialMatch(labels)
+    m.GatewayAvailabilitySeconds.DeletePartialMatch(labels)
+    m.GatewayFlapping.DeletePartialMatch(labels)
+    m.GatewayStatusTransitions.DeletePartialMatch(labels)
+    m.GatewayCurrentDowntime.DeletePartialMatch(labels)
+    m.GatewayCheckStepDuration.DeletePartialMatch(labels)
+    m.GatewayInfo.Delete(name)
+
+    checkLabels := prometheus.Labels{"gateway_name": name}
+    m.GatewayCheckValue.DeletePartialMatch(checkLabels)
+    m.GatewayCheckDegraded.DeletePartialMatch(checkLabels)
+    m.GatewayLatitude.DeletePartialMatch(checkLabels)
+    m.GatewayLongitude.DeletePartialMatch(checkLabels)
+    m.GatewayAltitude.DeletePartialMatch(checkLabels)
+    m.GatewayLinkStatus.DeletePartialMatch(checkLabels)
+    m.GatewayStatusStale.DeletePartialMatch(checkLabels)
+    m.GatewayLastUpdate.DeletePartialMatch(checkLabels)
+    m.GatewayUpdateGapAnomaly.DeletePartialMatch(checkLabels)
+    m.GatewayUpdateGapBaseline.DeletePartialMatch(checkLabels)
+    m.GatewayLNSConnected.DeletePartialMatch(checkLabels)
+    m.GatewayPeeringStatus.DeletePartialMatch(checkLabels)
+    m.GatewayPingRTT.DeletePartialMatch(checkLabels)
+    m.GatewayDNSResolution.DeletePartialMatch(checkLabels)
+    m.GatewaySNMPValue.DeletePartialMatch(checkLabels)
+    m.GatewayCheckDuration.DeletePartialMatch(checkLabels)
+    m.GatewayCheckTotal.DeletePartialMatch(checkLabels)
+    m.GatewayCheckRetries.DeletePartialMatch(checkLabels)
+    m.GatewayCheckErrors.DeletePartialMatch(checkLabels)
+    m.GatewayCheckResponseSize.DeletePartialMatch(checkLabels)
+    m.GatewayCheckHTTPStatus.DeletePartialMatch(checkLabels)
+    m.GatewayCheckCircuitOpen.DeletePartialMatch(checkLabels)
+    m.GatewayUplinkMessages.DeletePartialMatch(checkLabels)
+    m.GatewayDownlinkMessages.DeletePartialMatch(checkLabels)
+    m.GatewayUplinkRSSI.DeletePartialMatch(checkLabels)
+    m.GatewayUplinkSNR.DeletePartialMatch(checkLabels)
+}
+
+// Bool converts a bool to float64 for Prometheus gauges
+func Bool(value bool) float64 {
+    if value {
+        return 1.0
+    }
+    return 0.0
+}
+
+// DeleteCheck drops the series of a single check that was removed from a gateway
+func (m *Metrics) DeleteCheck(gateway, target, checkType string) {
+    labels := prometheus.Labels{"gateway_name": gateway, "link_url": target, "check_type": checkType}
+    m.GatewayLinkStatus.DeletePartialMatch(labels)
+    m.GatewayStatusStale.DeletePartialMatch(labels)
+    m.GatewayLastUpdate.DeletePartialMatch(labels)
+    m.GatewayUpdateGapAnomaly.DeletePartialMatch(labels)
+    m.GatewayUpdateGapBaseline.DeletePartialMatch(labels)
+    m.GatewayCheckDuration.DeletePartialMatch(labels)
+    m.GatewayCheckTotal.DeletePartialMatch(labels)
+    m.GatewayCheckRetries.DeletePartialMatch(labels)
+    m.GatewayCheckErrors.DeletePartialMatch(labels)
+    m.GatewayCheckResponseSize.DeletePartialMatch(labels)
+    m.GatewayCheckHTTPStatus.DeletePartialMatch(labels)
+    m.GatewayCheckCircuitOpen.DeletePartialMatch(labels)
+    m.GatewayUplinkMessages.DeletePartialMatch(labels)
+    m.GatewayDownlinkMessages.DeletePartialMatch(labels)
+    m.GatewayUplinkRSSI.DeletePartialMatch(labels)
+    m.GatewayUplinkSNR.DeletePartialMatch(labels)
+}
+
+// DeleteCheckDetails drops the gauges a gateway's checks export without their link_url, such
+// as field values, peering, ping and DNS timings or SNMP values. The next cycle sets them again for the
+// checks that are still configured.
+func (m *Metrics) DeleteCheckDetails(gateway string) {
+    labels := prometheus.Labels{"gateway_name": gateway}
+    m.GatewayCheckValue.DeletePartialMatch(labels)
+    m.GatewayLNSConnected.DeletePartialMatch(labels)
+    m.GatewayPeeringStatus.DeletePartialMatch(labels)
+    m.GatewayPingRTT.DeletePartialMatch(labels)
+    m.GatewayDNSResolution.DeletePartialMatch(labels)
+    m.GatewaySNMPValue.DeletePartialMatch(labels)
+    m.GatewayCheckStepDuration.DeletePartialMatch(prometheus.Labels{"name": gateway})
+}
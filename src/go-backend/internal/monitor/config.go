@@ -0,0 +1,224 @@
+package monitor
+
+import (
+    "errors"
+    "fmt"
+    "reflect"
+
+    "gateway-monitor/internal/config"
+)
+
+// Errors returned by the runtime gateway changes
+var (
+    ErrGatewayExists   = errors.New("gateway already configured")
+    ErrGatewayNotFound = errors.New("gateway not configured")
+)
+
+// Current returns the running configuration and where it came from
+func (m *Monitor) Current() (*config.GatewaysFile, config.Source) {
+    m.configMu.RLock()
+    defer m.configMu.RUnlock()
+    return m.current, m.source
+}
+
+// Gateways returns every monitored gateway: the configured ones followed by the
+// discovered ones. A configured gateway hides a discovered gateway of the same name.
+func (m *Monitor) Gateways() []config.Gateway {
+    m.configMu.RLock()
+    defer m.configMu.RUnlock()
+
+    gateways := append([]config.Gateway(nil), m.current.Gateways...)
+    static := map[string]bool{}
+    for _, gateway := range m.current.Gateways {
+        static[gateway.Name] = true
+    }
+    for _, gateway := range m.discovered {
+        if !static[gateway.Name] {
+            gateways = append(gateways, gateway)
+        }
+    }
+    return gateways
+}
+
+// Gateway looks up a monitored gateway by name
+func (m *Monitor) Gateway(name string) (config.Gateway, bool) {
+    for _, gateway := range m.Gateways() {
+        if gateway.Name == name {
+            return gateway, true
+        }
+    }
+    return config.Gateway{}, false
+}
+
+// Apply validates a new configuration and swaps it in atomically.
+// Nothing changes if validation, dashboard generation or listener setup fails,
+// so the previous configuration keeps running.
+func (m *Monitor) Apply(gatewaysFile *config.GatewaysFile, source config.Source) error {
+    m.changeMu.Lock()
+    defer m.changeMu.Unlock()
+
+    if err := m.apply(gatewaysFile, source); err != nil {
+        m.metrics.ConfigReloads.WithLabelValues(source.Kind, "failure").Inc()
+        return err
+    }
+
+    m.metrics.ConfigReloads.WithLabelValues(source.Kind, "success").Inc()
+    m.metrics.ConfigLastReload.Reset()
+    m.metrics.ConfigLastReload.WithLabelValues(source.Kind, source.Commit).Set(float64(m.clock.Now().Unix()))
+    m.UpdateDrift()
+    return nil
+}
+
+func (m *Monitor) apply(gatewaysFile *config.GatewaysFile, source config.Source) error {
+    if err := config.Validate(gatewaysFile); err != nil {
+        return fmt.Errorf("invalid configuration: %v", err)
+    }
+
+    if m.dashboards != nil {
+        for _, gateway := range gatewaysFile.Gateways {
+            if err := m.dashboards.Create(gateway); err != nil {
+                return fmt.Errorf("error creating dashboard for %s: %v", gateway.Name, err)
+            }
+        }
+    }
+
+    if err := m.forwarders.Apply(gatewaysFile); err != nil {
+        return err
+    }
+    if m.subscriptions != nil {
+        if err := m.subscriptions.Apply(gatewaysFile); err != nil {
+            return err
+        }
+    }
+
+    m.configMu.Lock()
+    previous := m.current
+    m.current = gatewaysFile
+    m.source = source
+    m.saved = gatewaysFile
+    discovered := m.discovered
+    m.configMu.Unlock()
+
+    // Drop the series of gateways that are no longer monitored, those labelled with the
+    // previous project of gateways that moved to another one and those of removed checks
+    configured := map[string]config.Gateway{}
+    names := map[string]bool{}
+    for _, gateway := range gatewaysFile.Gateways {
+        names[gateway.Name] = true
+        configured[gateway.Name] = gateway
+    }
+    for _, gateway := range discovered {
+        names[gateway.Name] = true
+    }
+    for _, gateway := range previous.Gateways {
+        current, ok := configured[gateway.Name]
+        switch {
+        case !names[gateway.Name]:
+            m.metrics.DeleteGateway(gateway.Name)
+            m.forget(gateway.Name)
+        case ok && current.Project != gateway.Project:
+            m.metrics.DeleteGateway(gateway.Name)
+        case ok:
+            m.deleteRemovedChecks(gateway, current)
+        }
+    }
+    m.checker.Prune(m.Gateways())
+    m.updateFleetDashboard()
+
+    m.logger.Printf("Applied configuration from %s with %d gateways", source, len(gatewaysFile.Gateways))
+    return nil
+}
+
+// updateFleetDashboard rewrites the fleet dashboard for the monitored gateways
+func (m *Monitor) updateFleetDashboard() {
+    if m.dashboards == nil {
+        return
+    }
+    if err := m.dashboards.CreateFleet(m.Gateways()); err != nil {
+        m.logger.Printf("WARNING: failed to update the fleet dashboard: %v", err)
+    }
+}
+
+// AddGateway adds a gateway to the running configuration
+func (m *Monitor) AddGateway(gateway config.Gateway) error {
+    return m.change(func(gateways []config.Gateway) ([]config.Gateway, error) {
+        for _, existing := range gateways {
+            if existing.Name == gateway.Name {
+                return nil, ErrGatewayExists
+            }
+        }
+        return append(gateways, gateway), nil
+    })
+}
+
+// UpdateGateway replaces the configured gateway with the same name
+func (m *Monitor) UpdateGateway(gateway config.Gateway) error {
+    return m.change(func(gateways []config.Gateway) ([]config.Gateway, error) {
+        for i, existing := range gateways {
+            if existing.Name == gateway.Name {
+                gateways[i] = gateway
+                return gateways, nil
+            }
+        }
+        return nil, ErrGatewayNotFound
+    })
+}
+
+// RemoveGateway removes a gateway from the running configuration
+func (m *Monitor) RemoveGateway(name string) error {
+    return m.change(func(gateways []config.Gateway) ([]config.Gateway, error) {
+        for i, existing := range gateways {
+            if existing.Name == name {
+                return append(gateways[:i], gateways[i+1:]...), nil
+            }
+        }
+        return nil, ErrGatewayNotFound
+    })
+}
+
+// change applies an edit of the configured gateways on top of the running configuration.
+// The change is not written to disk; see Save.
+func (m *Monitor) change(edit func([]config.Gateway) ([]config.Gateway, error)) error {
+    m.changeMu.Lock()
+    defer m.changeMu.Unlock()
+
+    current, source := m.Current()
+    gateways, err := edit(append([]config.Gateway(nil), current.Gateways...))
+    if err != nil {
+        return err
+    }
+    next := *current
+    next.Gateways = gateways
+
+    m.configMu.RLock()
+    saved := m.saved
+    m.configMu.RUnlock()
+    if err := m.apply(&next, source); err != nil {
+        return err
+    }
+    // Runtime changes are unsaved until written back
+    m.configMu.Lock()
+    m.saved = saved
+    m.configMu.Unlock()
+    m.UpdateDrift()
+    return nil
+}
+
+// deleteRemovedChecks drops the series of the checks of a gateway that are no longer configured,
+// including those of checks whose target changed
+func (m *Monitor) deleteRemovedChecks(previous, current config.Gateway) {
+    if reflect.DeepEqual(previous.Checks, current.Checks) {
+        return
+    }
+    type checkID struct{ target, checkType string }
+    configured := map[checkID]bool{}
+    for _, check := range current.Checks {
+        configured[checkID{check.Target(), check.Type}] = true
+    }
+    for _, check := range previous.Checks {
+        if !configured[checkID{check.Target(), check.Type}] {
+            m.metrics.DeleteCheck(previous.Name, check.Target(), check.Type)
+        }
+    }
+    m.metrics.DeleteCheckDetails(previous.Name)
+}
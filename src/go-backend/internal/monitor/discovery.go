@@ -0,0 +1,107 @@
+package monitor
+
+import (
+    "context"
+    "time"
+
+    "gateway-monitor/internal/config"
+)
+
+// discoveryIdleInterval is how often Discover looks for discovery settings while none are configured
+const discoveryIdleInterval = 1 * time.Minute
+
+// Lister lists the gateways matching a discovery configuration
+type Lister interface {
+    List(ctx context.Context, discovery config.Discovery) ([]config.Gateway, error)
+}
+
+// Discover runs the discovery configured in the running configuration until ctx is cancelled.
+// A failed run keeps the previously discovered gateways.
+func (m *Monitor) Discover(ctx context.Context, lister Lister) {
+    for {
+        interval := discoveryIdleInterval
+        gatewaysFile, _ := m.Current()
+        if discovery := gatewaysFile.Discovery; discovery != nil {
+            interval, _ = discovery.DiscoveryInterval()
+            m.discover(ctx, lister, *discovery)
+        } else {
+            m.SetDiscovered(nil)
+        }
+
+        select {
+        case <-ctx.Done():
+            return
+        case <-m.clock.After(interval):
+        }
+    }
+}
+
+func (m *Monitor) discover(ctx context.Context, lister Lister, discovery config.Discovery) {
+    gateways, err := lister.List(ctx, discovery)
+    if err != nil {
+        m.metrics.DiscoveryRuns.WithLabelValues("failure").Inc()
+        m.logger.Printf("WARNING: gateway discovery from %s failed, keeping previously discovered gateways: %v", discovery.ClusterURL, err)
+        return
+    }
+    m.metrics.DiscoveryRuns.WithLabelValues("success").Inc()
+    m.SetDiscovered(gateways)
+}
+
+// SetDiscovered replaces the discovered gateways. Gateways that fail validation are skipped.
+func (m *Monitor) SetDiscovered(gateways []config.Gateway) {
+    var valid []config.Gateway
+    for _, gateway := range gateways {
+        if err := config.ValidateChecks(gateway); err != nil {
+            m.logger.Printf("Skipping discovered gateway: %v", err)
+            continue
+        }
+        valid = append(valid, gateway)
+    }
+
+    m.configMu.Lock()
+    previous := m.discovered
+    m.discovered = valid
+    static := m.current
+    m.configMu.Unlock()
+
+    known := map[string]bool{}
+    for _, gateway := range previous {
+        known[gateway.Name] = true
+    }
+    monitored := map[string]bool{}
+    for _, gateway := range static.Gateways {
+        monitored[gateway.Name] = true
+    }
+    added := 0
+    for _, gateway := range valid {
+        monitored[gateway.Name] = true
+        if known[gateway.Name] {
+            continue
+        }
+        added++
+        if m.dashboards != nil {
+            if err := m.dashboards.Create(gateway); err != nil {
+                m.logger.Printf("Error creating dashboard for discovered gateway %s: %v", gateway.Name, err)
+            }
+        }
+    }
+
+    removed := 0
+    for _, gateway := range previous {
+        if !monitored[gateway.Name] {
+            m.metrics.DeleteGateway(gateway.Name)
+            removed++
+        }
+    }
+
+    if removed > 0 {
+        m.checker.Prune(m.Gateways())
+    }
+    if added > 0 || removed > 0 {
+        m.updateFleetDashboard()
+    }
+    m.metrics.DiscoveredGateways.Set(float64(len(valid)))
+    if added > 0 || removed > 0 {
+        m.logger.Printf("Discovery found %d gateways: %d added, %d removed", len(valid), added, removed)
+    }
+}
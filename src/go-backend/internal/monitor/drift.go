@@ -0,0 +1,144 @@
+package monitor
+
+import (
+    "encoding/json"
+    "fmt"
+    "sort"
+    "time"
+
+    "gateway-monitor/internal/config"
+)
+
+// DriftEntry is a gateway whose definition differs between two versions of the configuration
+type DriftEntry struct {
+    Gateway string `json:"gateway"`
+    Change  string `json:"change"`
+}
+
+// Drift lists how the running configuration and its file have diverged since they were last in sync
+type Drift struct {
+    // Unsaved are runtime changes that have not been written to the file
+    Unsaved []DriftEntry `json:"unsaved"`
+    // StaleFile are changes on disk that have not been applied
+    StaleFile []DriftEntry `json:"stale_file"`
+    // FileError is set when the file could not be read back
+    FileError string `json:"file_error,omitempty"`
+}
+
+// Drift compares the running configuration, the last saved snapshot and the file on disk
+func (m *Monitor) Drift() Drift {
+    m.configMu.RLock()
+    current, saved, source := m.current, m.saved, m.source
+    m.configMu.RUnlock()
+
+    drift := Drift{
+        Unsaved:   diffGateways(saved, current),
+        StaleFile: []DriftEntry{},
+    }
+    if source.Path == "" {
+        return drift
+    }
+
+    onDisk, err := config.Read(source.Path)
+    if err != nil {
+        drift.FileError = err.Error()
+        return drift
+    }
+    drift.StaleFile = diffGateways(saved, onDisk)
+    return drift
+}
+
+// UpdateDrift exports the drift metrics and warns the administrators when drift has lasted
+// longer than allowed
+func (m *Monitor) UpdateDrift() Drift {
+    drift := m.Drift()
+
+    stale := len(drift.StaleFile)
+    if drift.FileError != "" {
+        stale++
+    }
+    m.metrics.ConfigDrift.WithLabelValues("unsaved").Set(float64(len(drift.Unsaved)))
+    m.metrics.ConfigDrift.WithLabelValues("stale_file").Set(float64(stale))
+
+    m.driftMu.Lock()
+    defer m.driftMu.Unlock()
+
+    if len(drift.Unsaved) == 0 && stale == 0 {
+        m.driftSince = time.Time{}
+        m.driftWarned = false
+        return drift
+    }
+    if m.driftSince.IsZero() {
+        m.driftSince = m.clock.Now()
+    }
+    if m.driftWarnAfter > 0 && !m.driftWarned && m.clock.Now().Sub(m.driftSince) >= m.driftWarnAfter {
+        m.driftWarned = true
+        message := fmt.Sprintf("configuration has drifted from its file for more than %s (%d unsaved, %d stale); use POST /api/v1/config/save or reload to reconcile",
+            m.driftWarnAfter, len(drift.Unsaved), stale)
+        m.logger.Printf("WARNING: %s", message)
+        m.events.Warn(Warning{Subject: "Configuration drift", Message: message, At: m.clock.Now()})
+    }
+    return drift
+}
+
+// Save writes the running configuration back to the file it was loaded from
+func (m *Monitor) Save() error {
+    m.changeMu.Lock()
+    defer m.changeMu.Unlock()
+
+    current, source := m.Current()
+    if source.Kind != "file" || source.Path == "" {
+        return fmt.Errorf("configuration from %s cannot be saved", source.Kind)
+    }
+
+    if err := config.Save(source.Path, current); err != nil {
+        return fmt.Errorf("failed to write %s: %v", source.Path, err)
+    }
+
+    m.configMu.Lock()
+    m.saved = current
+    m.configMu.Unlock()
+
+    m.logger.Printf("Saved configuration with %d gateways to %s", len(current.Gateways), source.Path)
+    m.UpdateDrift()
+    return nil
+}
+
+// differs reports whether a configuration differs from the running one in any setting,
+// not only in its gateways
+func (m *Monitor) differs(gatewaysFile *config.GatewaysFile) bool {
+    current, _ := m.Current()
+    before, _ := json.Marshal(current)
+    after, _ := json.Marshal(gatewaysFile)
+    return string(before) != string(after)
+}
+
+// diffGateways lists the gateways added, removed or changed going from one configuration to another
+func diffGateways(from, to *config.GatewaysFile) []DriftEntry {
+    before := map[string][]byte{}
+    for _, gateway := range from.Gateways {
+        before[gateway.Name], _ = json.Marshal(gateway)
+    }
+
+    entries := []DriftEntry{}
+    seen := map[string]bool{}
+    for _, gateway := range to.Gateways {
+        seen[gateway.Name] = true
+        encoded, _ := json.Marshal(gateway)
+        previous, ok := before[gateway.Name]
+        switch {
+        case !ok:
+            entries = append(entries, DriftEntry{Gateway: gateway.Name, Change: "added"})
+        case string(previous) != string(encoded):
+            entries = append(entries, DriftEntry{Gateway: gateway.Name, Change: "changed"})
+        }
+    }
+    for name := range before {
+        if !seen[name] {
+            entries = append(entries, DriftEntry{Gateway: name, Change: "removed"})
+        }
+    }
+
+    sort.Slice(entries, func(i, j int) bool { return entries[i].Gateway < entries[j].Gateway })
+    return entries
+}
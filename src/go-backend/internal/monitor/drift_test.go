@@ -0,0 +1,45 @@
+package monitor
+
+import (
+    "testing"
+    "time"
+)
+
+func TestUpdateDriftWarnsOnce(t *testing.T) {
+    mon, m, clk := newTestMonitor(t, func(opts *Options) { opts.DriftWarnAfter = 10 * time.Minute })
+    apply(t, mon, httpGateway("gw0", "http://127.0.0.1:1/status"))
+    warnings, unsubscribe := mon.Events().SubscribeWarnings()
+    defer unsubscribe()
+
+    if err := mon.AddGateway(httpGateway("gw1", "http://127.0.0.1:1/status")); err != nil {
+        t.Fatal(err)
+    }
+    if got := gaugeValue(t, m.ConfigDrift.WithLabelValues("unsaved")); got != 1 {
+        t.Errorf("loracheck_config_drift{type=unsaved} = %v, want 1", got)
+    }
+
+    clk.Advance(5 * time.Minute)
+    mon.UpdateDrift()
+    select {
+    case warning := <-warnings:
+        t.Fatalf("warned before the drift lasted 10m: %s", warning.Message)
+    default:
+    }
+
+    clk.Advance(5 * time.Minute)
+    mon.UpdateDrift()
+    mon.UpdateDrift()
+    select {
+    case warning := <-warnings:
+        if warning.Subject != "Configuration drift" || !warning.At.Equal(clk.Now()) {
+            t.Errorf("got warning %+v", warning)
+        }
+    default:
+        t.Fatal("no warning once the drift lasted 10m")
+    }
+    select {
+    case <-warnings:
+        t.Error("warned twice about the same drift")
+    default:
+    }
+}
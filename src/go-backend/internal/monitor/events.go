@@ -0,0 +1,101 @@
+package monitor
+
+import (
+    "sync"
+    "time"
+)
+
+// eventBuffer is how many events a subscriber may fall behind before events are dropped for it
+const eventBuffer = 64
+
+// Transition is published when a gateway's state changes, and when its field rules start
+// or stop being violated: To is then "degraded", or From when it is no longer degraded
+type Transition struct {
+    Gateway string `json:"gateway"`
+    // From is "unknown" for the first check of a gateway
+    From string    `json:"from"`
+    To   string    `json:"to"`
+    At   time.Time `json:"at"`
+    // Reason describes why a gateway became "degraded"
+    Reason string `json:"reason,omitempty"`
+}
+
+// Warning is published when the monitor itself needs the attention of its administrators
+type Warning struct {
+    Subject string
+    Message string
+    At      time.Time
+}
+
+// Events fans state transitions out to subscribers such as the live event stream, and
+// warnings to the notifier
+type Events struct {
+    mu          sync.Mutex
+    subscribers map[chan Transition]struct{}
+    warnings    map[chan Warning]struct{}
+}
+
+// NewEvents creates an event hub without subscribers
+func NewEvents() *Events {
+    return &Events{subscribers: map[chan Transition]struct{}{}, warnings: map[chan Warning]struct{}{}}
+}
+
+// Subscribe returns a channel receiving every transition from now on and a function
+// that ends the subscription
+func (e *Events) Subscribe() (<-chan Transition, func()) {
+    ch := make(chan Transition, eventBuffer)
+    e.mu.Lock()
+    e.subscribers[ch] = struct{}{}
+    e.mu.Unlock()
+
+    return ch, func() {
+        e.mu.Lock()
+        defer e.mu.Unlock()
+        if _, ok := e.subscribers[ch]; ok {
+            delete(e.subscribers, ch)
+            close(ch)
+        }
+    }
+}
+
+// Publish sends a transition to every subscriber without waiting for slow ones
+func (e *Events) Publish(transition Transition) {
+    e.mu.Lock()
+    defer e.mu.Unlock()
+    for ch := range e.subscribers {
+        select {
+        case ch <- transition:
+        default:
+        }
+    }
+}
+
+// SubscribeWarnings returns a channel receiving every warning from now on and a function
+// that ends the subscription
+func (e *Events) SubscribeWarnings() (<-chan Warning, func()) {
+    ch := make(chan Warning, eventBuffer)
+    e.mu.Lock()
+    e.warnings[ch] = struct{}{}
+    e.mu.Unlock()
+
+    return ch, func() {
+        e.mu.Lock()
+        defer e.mu.Unlock()
+        if _, ok := e.warnings[ch]; ok {
+            delete(e.warnings, ch)
+            close(ch)
+        }
+    }
+}
+
+// Warn sends a warning to every subscriber without waiting for slow ones
+func (e *Events) Warn(warning Warning) {
+    e.mu.Lock()
+    defer e.mu.Unlock()
+    for ch := range e.warnings {
+        select {
+        case ch <- warning:
+        default:
+        }
+    }
+}
@@ -0,0 +1,74 @@
+package monitor
+
+import (
+    "gateway-monitor/internal/config"
+    "gateway-monitor/internal/metrics"
+)
+
+// Flap detection looks at the last flapWindow check results of a gateway and considers it
+// flapping when its online status changed at least flapChanges times among them
+const (
+    flapWindow  = 10
+    flapChanges = 4
+)
+
+// hysteresis holds the check results that have not changed the reported status yet
+type hysteresis struct {
+    reported bool
+    // streak counts the consecutive results disagreeing with reported
+    streak int
+    // recent holds the last flapWindow raw results, oldest first
+    recent []bool
+}
+
+// debounce returns the status to report for a check result. A gateway is only reported
+// offline after OfflineAfter consecutive failures and back online after OnlineAfter
+// consecutive successes; the first result of a gateway is reported as is.
+// The caller holds stateMu.
+func (m *Monitor) debounce(gateway config.Gateway, online bool) bool {
+    h, ok := m.hysteresis[gateway.Name]
+    if !ok {
+        h = &hysteresis{reported: online}
+        m.hysteresis[gateway.Name] = h
+    }
+
+    h.recent = append(h.recent, online)
+    if len(h.recent) > flapWindow {
+        h.recent = h.recent[1:]
+    }
+    m.metrics.GatewayFlapping.WithLabelValues(gateway.Name, gateway.Project).Set(metrics.Bool(flapping(h.recent)))
+
+    if online == h.reported {
+        h.streak = 0
+        return h.reported
+    }
+    h.streak++
+    required := m.offlineAfter
+    if gateway.OfflineAfter > 0 {
+        required = gateway.OfflineAfter
+    }
+    if online {
+        required = m.onlineAfter
+        if gateway.OnlineAfter > 0 {
+            required = gateway.OnlineAfter
+        }
+    }
+    if h.streak < required {
+        m.logger.Printf("Gateway %s checked %s %d of %d consecutive times, still reporting it %s",
+            gateway.Name, stateName(online, false), h.streak, required, stateName(h.reported, false))
+        return h.reported
+    }
+    h.reported, h.streak = online, 0
+    return online
+}
+
+// flapping reports whether the results changed at least flapChanges times
+func flapping(results []bool) bool {
+    changes := 0
+    for i := 1; i < len(results); i++ {
+        if results[i] != results[i-1] {
+            changes++
+        }
+    }
+    return changes >= flapChanges
+}
@@ -0,0 +1,76 @@
+package monitor
+
+import (
+    "fmt"
+
+    "github.com/prometheus/client_golang/prometheus"
+
+    "gateway-monitor/internal/config"
+)
+
+// Values of gateway_geo_status
+const (
+    geoOffline     = 0
+    geoOnline      = 1
+    geoMaintenance = 2
+)
+
+// geohashPrecision gives cells of about 5 m, enough to tell neighbouring gateways apart
+const geohashPrecision = 9
+
+// exportGeoStatus sets gateway_geo_status, which carries the coordinates as labels so the
+// Grafana Geomap panel can place and colour the gateways from a single query. Gateways
+// without a location are left out rather than drawn at 0, 0.
+func (m *Monitor) exportGeoStatus(gateway config.Gateway, location config.Location, online, inMaintenance bool) {
+    if !location.Located() {
+        m.metrics.GatewayGeoStatus.DeletePartialMatch(prometheus.Labels{"name": gateway.Name})
+        return
+    }
+    value := geoOffline
+    switch {
+    case inMaintenance:
+        value = geoMaintenance
+    case online:
+        value = geoOnline
+    }
+    m.metrics.GatewayGeoStatus.With(prometheus.Labels{
+        "name":      gateway.Name,
+        "project":   gateway.Project,
+        "latitude":  fmt.Sprintf("%f", location.Latitude),
+        "longitude": fmt.Sprintf("%f", location.Longitude),
+        "geohash":   geohash(location.Latitude, location.Longitude, geohashPrecision),
+    }).Set(float64(value))
+}
+
+// geohashAlphabet is the base32 alphabet of geohashes
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// geohash encodes the coordinates as a geohash of the given number of characters
+func geohash(latitude, longitude float64, precision int) string {
+    latRange := [2]float64{-90, 90}
+    lonRange := [2]float64{-180, 180}
+    hash := make([]byte, 0, precision)
+    even := true
+    bit, ch := 0, 0
+    for len(hash) < precision {
+        // Bits alternate between longitude and latitude, starting with longitude
+        value, r := longitude, &lonRange
+        if !even {
+            value, r = latitude, &latRange
+        }
+        mid := (r[0] + r[1]) / 2
+        ch <<= 1
+        if value >= mid {
+            ch |= 1
+            r[0] = mid
+        } else {
+            r[1] = mid
+        }
+        even = !even
+        if bit++; bit == 5 {
+            hash = append(hash, geohashAlphabet[ch])
+            bit, ch = 0, 0
+        }
+    }
+    return string(hash)
+}
@@ -0,0 +1,126 @@
+package monitor
+
+import (
+    "context"
+    "net/http"
+    "time"
+
+    "gateway-monitor/internal/config"
+)
+
+// heartbeatTimeout bounds each heartbeat request so a slow receiver cannot stall the check loop
+const heartbeatTimeout = 10 * time.Second
+
+// pushTimeout bounds pushing the metrics and the trace after a cycle
+const pushTimeout = 30 * time.Second
+
+// Health describes whether the check loop is still making progress
+type Health struct {
+    Ready bool `json:"ready"`
+    // Standby is set while another replica is the leader; a standby is ready to serve
+    Standby            bool      `json:"standby,omitempty"`
+    LastCycleCompleted time.Time `json:"last_cycle_completed,omitempty"`
+    Deadline           time.Time `json:"deadline"`
+}
+
+// Health reports the loop as ready once the first cycle has completed, and as not ready
+// again once no cycle has completed for MaxMissedCycles intervals. A standby replica is
+// ready as long as it serves.
+func (m *Monitor) Health() Health {
+    m.cycleMu.Lock()
+    last, started := m.lastCycle, m.startedAt
+    m.cycleMu.Unlock()
+
+    since := last
+    if since.IsZero() {
+        since = started
+    }
+    deadline := since.Add(time.Duration(m.maxMissedCycles) * m.interval)
+    if !m.Leading() {
+        return Health{Ready: true, Standby: true, LastCycleCompleted: last, Deadline: deadline}
+    }
+    return Health{
+        Ready:              !last.IsZero() && m.clock.Now().Before(deadline),
+        LastCycleCompleted: last,
+        Deadline:           deadline,
+    }
+}
+
+// watchOverrun counts an overrun when the cycle is still running after one interval,
+// so a cycle stuck on a hung check is noticed before it completes
+func (m *Monitor) watchOverrun(done <-chan struct{}) {
+    select {
+    case <-done:
+    case <-m.clock.After(m.interval):
+        m.metrics.CycleOverruns.Inc()
+        m.logger.Printf("WARNING: check cycle is taking longer than the %s interval", m.interval)
+        m.sendHeartbeatFailure(context.Background())
+    }
+}
+
+// push sends the metrics of the finished cycle to every Pusher
+func (m *Monitor) push(ctx context.Context) {
+    if len(m.pushers) == 0 {
+        return
+    }
+
+    ctx, cancel := context.WithTimeout(ctx, pushTimeout)
+    defer cancel()
+    for _, pusher := range m.pushers {
+        if err := pusher.Push(ctx); err != nil {
+            m.logger.Printf("WARNING: pushing metrics failed: %v", err)
+        }
+    }
+}
+
+// trace exports the finished cycle to the Tracer, if one is configured
+func (m *Monitor) trace(ctx context.Context, gateways []config.Gateway, start, end time.Time) {
+    if m.tracer == nil {
+        return
+    }
+
+    cycle := Cycle{Start: start, End: end, Gateways: len(gateways)}
+    for _, gateway := range gateways {
+        cycle.Checks = append(cycle.Checks, m.resultsSince(gateway, start)...)
+    }
+    ctx, cancel := context.WithTimeout(ctx, pushTimeout)
+    defer cancel()
+    if err := m.tracer.TraceCycle(ctx, cycle); err != nil {
+        m.logger.Printf("WARNING: exporting the cycle trace failed: %v", err)
+    }
+}
+
+// sendHeartbeat pings the heartbeat URL, if one is configured
+func (m *Monitor) sendHeartbeat(ctx context.Context) {
+    m.ping(ctx, m.heartbeatURL)
+}
+
+// sendHeartbeatFailure pings the heartbeat fail URL, if one is configured
+func (m *Monitor) sendHeartbeatFailure(ctx context.Context) {
+    m.ping(ctx, m.heartbeatFailURL)
+}
+
+// ping requests a heartbeat URL, logging rather than returning failures since a missed
+// heartbeat is what the receiving service alerts on
+func (m *Monitor) ping(ctx context.Context, url string) {
+    if url == "" {
+        return
+    }
+
+    ctx, cancel := context.WithTimeout(ctx, heartbeatTimeout)
+    defer cancel()
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+    if err != nil {
+        m.logger.Printf("Invalid heartbeat URL: %v", err)
+        return
+    }
+    resp, err := m.heartbeatClient.Do(req)
+    if err != nil {
+        m.logger.Printf("Heartbeat failed: %v", err)
+        return
+    }
+    resp.Body.Close()
+    if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+        m.logger.Printf("Heartbeat returned HTTP status %d", resp.StatusCode)
+    }
+}
@@ -0,0 +1,90 @@
+package monitor
+
+import (
+    "context"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+)
+
+func TestStuckCycleCountsOverrunAndSuppressesHeartbeat(t *testing.T) {
+    const interval = 300 * time.Millisecond
+
+    entered := make(chan struct{}, 1)
+    release := make(chan struct{})
+    stuck := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        entered <- struct{}{}
+        <-release
+    }))
+    t.Cleanup(stuck.Close)
+    t.Cleanup(func() { close(release) })
+
+    pings := make(chan string, 10)
+    heartbeat := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        pings <- r.URL.Path
+    }))
+    t.Cleanup(heartbeat.Close)
+
+    mon, m, clk := newTestMonitor(t, func(opts *Options) {
+        opts.Interval = interval
+        opts.HeartbeatURL = heartbeat.URL + "/ok"
+        opts.HeartbeatFailURL = heartbeat.URL + "/fail"
+    })
+    apply(t, mon, httpGateway("gw1", stuck.URL))
+
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+    stopped := make(chan struct{})
+    start := time.Now()
+    go func() {
+        mon.Run(ctx)
+        close(stopped)
+    }()
+
+    if ping := receive(t, pings); ping != "/ok" {
+        t.Fatalf("startup heartbeat went to %s, want /ok", ping)
+    }
+    receive(t, entered)
+    // Let the cycle outlast its interval on the fake clock once the overrun watch is waiting
+    for deadline := time.Now().Add(time.Second); clk.Waiters() == 0; {
+        if time.Now().After(deadline) {
+            t.Fatal("the overrun of the cycle is not watched")
+        }
+        time.Sleep(time.Millisecond)
+    }
+    clk.Advance(2 * interval)
+    if ping := receive(t, pings); ping != "/fail" {
+        t.Fatalf("overrun heartbeat went to %s, want /fail", ping)
+    }
+    if got := counterValue(t, m.CycleOverruns); got != 1 {
+        t.Errorf("loracheck_cycle_overrun_total = %v, want 1", got)
+    }
+
+    cancel()
+    receive(t, stopped)
+    if elapsed := time.Since(start); elapsed > interval+time.Second {
+        t.Errorf("the stuck cycle took %s, more than its %s deadline", elapsed, interval)
+    }
+    select {
+    case ping := <-pings:
+        t.Errorf("heartbeat %s sent after the overrun cycle", ping)
+    default:
+    }
+    if got := onlineStatus(t, m, "gw1"); got != 0 {
+        t.Errorf("gateway_online_status of the stuck gateway = %v, want 0", got)
+    }
+}
+
+// receive waits for a value on ch, failing the test after a few seconds
+func receive[T any](t *testing.T, ch <-chan T) T {
+    t.Helper()
+    select {
+    case value := <-ch:
+        return value
+    case <-time.After(5 * time.Second):
+        t.Fatal("timed out waiting")
+    }
+    var zero T
+    return zero
+}
@@ -0,0 +1,553 @@
+// Package monitor runs the check loop and keeps the gateway metrics up to date.
+package monitor
+
+import (
+    "context"
+    "fmt"
+    "log"
+    "net/http"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/prometheus/client_golang/prometheus"
+
+    "gateway-monitor/internal/checker"
+    "gateway-monitor/internal/clock"
+    "gateway-monitor/internal/config"
+    "gateway-monitor/internal/metrics"
+)
+
+// Dashboards creates a dashboard for each applied gateway and one for all of them
+type Dashboards interface {
+    Create(gateway config.Gateway) error
+    CreateFleet(gateways []config.Gateway) error
+}
+
+// History stores the check results and gateway states of every cycle
+type History interface {
+    Record(gateway config.Gateway, check config.Check, result checker.CheckResult) error
+    RecordState(gateway config.Gateway, state string, at time.Time) error
+}
+
+// Leader tells whether this replica was elected to run the checks, and which one was
+type Leader interface {
+    Leading() bool
+    Holder() string
+}
+
+// Pusher sends the metrics somewhere after every cycle
+type Pusher interface {
+    Push(ctx context.Context) error
+}
+
+// Tracer records a trace of every cycle
+type Tracer interface {
+    TraceCycle(ctx context.Context, cycle Cycle) error
+}
+
+// Cycle describes a completed check cycle
+type Cycle struct {
+    Start    time.Time
+    End      time.Time
+    Gateways int
+    // Checks are the results of the checks that ran during the cycle
+    Checks []CycleCheck
+}
+
+// CycleCheck is the result of one check of a cycle
+type CycleCheck struct {
+    Gateway string
+    Check   config.Check
+    Result  checker.CheckResult
+}
+
+// Options configures a Monitor
+type Options struct {
+    Checker    *checker.Checker
+    Forwarders *checker.Forwarders
+    // Subscriptions holds the MQTT connections of mqtt checks
+    Subscriptions *checker.Subscriptions
+    Metrics       *metrics.Metrics
+    Dashboards    Dashboards
+    // History is optional
+    History History
+    // Pushers and Tracer are optional
+    Pushers []Pusher
+    Tracer  Tracer
+    // Leader elects the replica running the checks; without it this one always does
+    Leader   Leader
+    Clock    clock.Clock
+    Logger   *log.Logger
+    Interval time.Duration
+    // Concurrency is how many gateways are checked at the same time
+    Concurrency int
+    // Spread staggers the start of the gateways' checks evenly over this much of each cycle
+    // rather than starting them all at once; zero disables it
+    Spread time.Duration
+    // OfflineAfter and OnlineAfter are how many consecutive failed or successful checks it
+    // takes to report a gateway offline or back online, unless the gateway sets its own
+    OfflineAfter int
+    OnlineAfter  int
+
+    // LocationLabels keeps the latitude and longitude labels on gateway_online_status
+    // for dashboards that predate the gateway_latitude and gateway_longitude gauges
+    LocationLabels bool
+
+    // HeartbeatURL is requested on startup and after every cycle that completes within
+    // the interval, for dead man's switch services such as healthchecks.io
+    HeartbeatURL string
+    // HeartbeatFailURL is requested as soon as a cycle is still running after one interval,
+    // so the dead man's switch alerts without waiting for its own grace period
+    HeartbeatFailURL string
+    HeartbeatClient  *http.Client
+    // MaxMissedCycles is how many intervals may pass without a completed cycle before
+    // Health reports the monitor as not ready
+    MaxMissedCycles int
+
+    // DriftWarnAfter is how long the running configuration may differ from
+    // its file before the administrators are warned
+    DriftWarnAfter time.Duration
+}
+
+// Monitor periodically checks every configured gateway
+type Monitor struct {
+    checker       *checker.Checker
+    forwarders    *checker.Forwarders
+    subscriptions *checker.Subscriptions
+    metrics       *metrics.Metrics
+    dashboards    Dashboards
+    history       History
+    pushers       []Pusher
+    tracer        Tracer
+    leader        Leader
+    clock         clock.Clock
+    logger        *log.Logger
+    interval      time.Duration
+    concurrency   int
+    spread        time.Duration
+    offlineAfter  int
+    onlineAfter   int
+
+    heartbeatURL     string
+    heartbeatFailURL string
+    heartbeatClient  *http.Client
+    maxMissedCycles  int
+
+    cycleMu   sync.Mutex
+    startedAt time.Time
+    lastCycle time.Time
+    // leading records whether the replica led at the last cycle it was due for
+    leading bool
+
+    locationLabels bool
+    // stateMu guards the per-gateway state below, updated by concurrent checks
+    stateMu sync.Mutex
+    // degraded records which gateways last had violated field rules
+    degraded map[string]bool
+    // gapAnomalous records which gateways last had an anomalous gap between status updates
+    gapAnomalous map[string]bool
+    // exported records the location each gateway's series were last exported with
+    exported map[string]config.Location
+    // states records the outcome of each gateway's last check
+    states map[string]gatewayState
+    // hysteresis records the check results not yet reflected in states
+    hysteresis map[string]*hysteresis
+    // downSince records when each offline gateway went offline
+    downSince map[string]time.Time
+
+    configMu sync.RWMutex
+    // changeMu serialises the swaps of the running configuration: applied configurations,
+    // runtime changes to the configured gateways and saves
+    changeMu sync.Mutex
+    current  *config.GatewaysFile
+    source   config.Source
+    // saved is the configuration as last read from or written to source.Path
+    saved *config.GatewaysFile
+    // discovered holds the gateways found by discovery, see Gateways
+    discovered []config.Gateway
+
+    driftWarnAfter time.Duration
+    driftMu        sync.Mutex
+    driftSince     time.Time
+    driftWarned    bool
+
+    silences *Silences
+    events   *Events
+
+    // lastAccounted records when each gateway's availability was last accounted for
+    lastAccounted map[string]time.Time
+}
+
+// New creates a Monitor; it does nothing until a configuration is applied and Run is called
+func New(opts Options) *Monitor {
+    return &Monitor{
+        checker:        opts.Checker,
+        forwarders:     opts.Forwarders,
+        subscriptions:  opts.Subscriptions,
+        metrics:        opts.Metrics,
+        dashboards:     opts.Dashboards,
+        history:        opts.History,
+        pushers:        opts.Pushers,
+        tracer:         opts.Tracer,
+        leader:         opts.Leader,
+        clock:          opts.Clock,
+        logger:         opts.Logger,
+        interval:       opts.Interval,
+        concurrency:    max(opts.Concurrency, 1),
+        spread:         opts.Spread,
+        offlineAfter:   max(opts.OfflineAfter, 1),
+        onlineAfter:    max(opts.OnlineAfter, 1),
+        locationLabels: opts.LocationLabels,
+
+        heartbeatURL:     opts.HeartbeatURL,
+        heartbeatFailURL: opts.HeartbeatFailURL,
+        heartbeatClient:  opts.HeartbeatClient,
+        maxMissedCycles:  opts.MaxMissedCycles,
+        leading:          true,
+
+        exported:       map[string]config.Location{},
+        states:         map[string]gatewayState{},
+        hysteresis:     map[string]*hysteresis{},
+        downSince:      map[string]time.Time{},
+        degraded:       map[string]bool{},
+        gapAnomalous:   map[string]bool{},
+        current:        &config.GatewaysFile{},
+        saved:          &config.GatewaysFile{},
+        driftWarnAfter: opts.DriftWarnAfter,
+        silences:       NewSilences(opts.Clock, opts.Logger),
+        events:         NewEvents(),
+        lastAccounted:  map[string]time.Time{},
+    }
+}
+
+// Silences returns the dynamic silences of the monitored gateways
+func (m *Monitor) Silences() *Silences {
+    return m.silences
+}
+
+// Silence mutes a gateway for the duration; see Silences.Add
+func (m *Monitor) Silence(gateway string, d time.Duration, comment string) Silence {
+    silence := m.silences.Add(gateway, d, comment)
+    if configured, ok := m.Gateway(gateway); ok {
+        m.metrics.GatewayActiveSilences.WithLabelValues(gateway, configured.Project).Set(float64(len(m.silences.Active(gateway))))
+    }
+    return silence
+}
+
+// Events returns the hub publishing gateway state transitions
+func (m *Monitor) Events() *Events {
+    return m.events
+}
+
+// Run runs periodically to update the gateway statuses until ctx is cancelled. A cycle in
+// progress when ctx is cancelled is finished first, so shutting down doesn't cut checks short.
+func (m *Monitor) Run(ctx context.Context) {
+    m.cycleMu.Lock()
+    m.startedAt = m.clock.Now()
+    m.cycleMu.Unlock()
+    cycles := context.WithoutCancel(ctx)
+    if m.Leading() {
+        m.sendHeartbeat(cycles)
+    }
+
+    for {
+        if m.leads() {
+            if m.RunCycle(cycles) {
+                m.sendHeartbeat(cycles)
+            }
+            m.push(cycles)
+        }
+
+        select {
+        case <-ctx.Done():
+            return
+        case <-m.clock.After(m.interval):
+        }
+    }
+}
+
+// RunCycle checks every gateway once, up to Concurrency at a time, and reports whether it
+// finished within the interval. Gateways whose http checks request the same URL share one
+// response per cycle. Checks still running when the interval ends are cancelled
+// and gateways not started by then keep their previous status until the next cycle.
+func (m *Monitor) RunCycle(parent context.Context) bool {
+    start := m.clock.Now()
+    m.checker.NewCycle()
+    done := make(chan struct{})
+    go m.watchOverrun(done)
+
+    ctx, cancel := context.WithTimeout(parent, m.interval)
+    defer cancel()
+
+    gateways := make(chan config.Gateway)
+    var wg sync.WaitGroup
+    for i := 0; i < m.concurrency; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for gateway := range gateways {
+                if ctx.Err() != nil {
+                    m.logger.Printf("Skipping gateway %s, the check cycle ran out of time", gateway.Name)
+                    continue
+                }
+                m.UpdateGatewayStatus(ctx, gateway)
+            }
+        }()
+    }
+    gatewaysFile, _ := m.Current()
+    var checked []config.Gateway
+    for _, gateway := range m.Gateways() {
+        if m.due(gatewaysFile, gateway, start) {
+            checked = append(checked, gateway)
+        }
+    }
+    for i, gateway := range checked {
+        m.stagger(ctx, start, i, len(checked))
+        gateways <- withProjectSettings(gatewaysFile, gateway)
+    }
+    close(gateways)
+    wg.Wait()
+    m.UpdateDrift()
+
+    close(done)
+    completed := m.clock.Now()
+    m.cycleMu.Lock()
+    m.lastCycle = completed
+    m.cycleMu.Unlock()
+    m.metrics.LastCycleCompleted.Set(float64(completed.Unix()))
+    m.metrics.LastCycleDuration.Set(completed.Sub(start).Seconds())
+    m.trace(parent, checked, start, completed)
+
+    return completed.Sub(start) <= m.interval
+}
+
+// stagger waits until the i-th of n gateways is due to start within the spread of a cycle
+// starting at start, so upstream APIs see a steady trickle of requests instead of a burst
+func (m *Monitor) stagger(ctx context.Context, start time.Time, i, n int) {
+    if m.spread <= 0 || i == 0 {
+        return
+    }
+    wait := start.Add(m.spread * time.Duration(i) / time.Duration(n)).Sub(m.clock.Now())
+    if wait <= 0 {
+        return
+    }
+    select {
+    case <-m.clock.After(wait):
+    case <-ctx.Done():
+    }
+}
+
+// UpdateGatewayStatus updates the Prometheus metrics with the gateway's online status
+func (m *Monitor) UpdateGatewayStatus(ctx context.Context, gateway config.Gateway) {
+    start := m.clock.Now()
+    online := m.checker.GatewayStatus(ctx, gateway)
+    now := m.clock.Now()
+    m.recordHistory(gateway, start)
+
+    m.stateMu.Lock()
+    defer m.stateMu.Unlock()
+    online = m.debounce(gateway, online)
+    inMaintenance := m.InMaintenance(gateway, now)
+
+    location := m.checker.Location(gateway)
+    m.exportLocation(gateway, location)
+
+    latitude, longitude := "", ""
+    if m.locationLabels {
+        latitude = fmt.Sprintf("%f", location.Latitude)
+        longitude = fmt.Sprintf("%f", location.Longitude)
+    }
+    m.metrics.GatewayOnlineStatus.With(prometheus.Labels{
+        "name":      gateway.Name,
+        "project":   gateway.Project,
+        "latitude":  latitude,
+        "longitude": longitude,
+    }).Set(metrics.Bool(online))
+    m.exportGeoStatus(gateway, location, online, inMaintenance)
+    m.metrics.GatewayInfo.Set(gateway.Name, gateway.Project, gateway.GatewayOrigin(), gateway.Labels)
+    m.metrics.GatewayInMaintenance.WithLabelValues(gateway.Name, gateway.Project).Set(metrics.Bool(inMaintenance))
+    m.metrics.GatewayActiveSilences.WithLabelValues(gateway.Name, gateway.Project).Set(float64(len(m.silences.Active(gateway.Name))))
+    m.updateGapAnomaly(gateway)
+
+    // Time spent in a maintenance window is not counted as downtime
+    state := stateName(online, inMaintenance)
+
+    previous := "unknown"
+    if last, ok := m.states[gateway.Name]; ok {
+        previous = stateName(last.online, last.inMaintenance)
+    }
+    m.states[gateway.Name] = gatewayState{online: online, inMaintenance: inMaintenance, checkedAt: now}
+    if m.history != nil {
+        if err := m.history.RecordState(gateway, state, now); err != nil {
+            m.logger.Printf("WARNING: failed to record the state of %s in the history: %v", gateway.Name, err)
+        }
+    }
+    if state != previous {
+        m.events.Publish(Transition{Gateway: gateway.Name, From: previous, To: state, At: now})
+        if previous != "unknown" {
+            m.metrics.GatewayStatusTransitions.WithLabelValues(gateway.Name, gateway.Project, previous, state).Inc()
+        }
+    }
+    m.updateDegraded(gateway, state, now)
+    m.updateDowntime(gateway, state, now)
+    if last, ok := m.lastAccounted[gateway.Name]; ok {
+        m.metrics.GatewayAvailabilitySeconds.WithLabelValues(gateway.Name, gateway.Project, state).Add(now.Sub(last).Seconds())
+    }
+    m.lastAccounted[gateway.Name] = now
+
+    m.logger.Printf("Updated Prometheus metrics for gateway %s, online status: %v, maintenance: %v", gateway.Name, online, inMaintenance)
+}
+
+// updateDowntime exports how long the gateway has been offline. Only the first check of an
+// outage starts it, so a gateway that is offline at startup counts from its first check.
+func (m *Monitor) updateDowntime(gateway config.Gateway, state string, now time.Time) {
+    name := gateway.Name
+    if state != "offline" {
+        delete(m.downSince, name)
+        m.metrics.GatewayCurrentDowntime.WithLabelValues(name, gateway.Project).Set(0)
+        return
+    }
+    since, ok := m.downSince[name]
+    if !ok {
+        since = now
+        m.downSince[name] = now
+    }
+    m.metrics.GatewayCurrentDowntime.WithLabelValues(name, gateway.Project).Set(now.Sub(since).Seconds())
+}
+
+// recordHistory stores the results of the checks that ran since start
+func (m *Monitor) recordHistory(gateway config.Gateway, start time.Time) {
+    if m.history == nil {
+        return
+    }
+    for _, result := range m.resultsSince(gateway, start) {
+        if err := m.history.Record(gateway, result.Check, result.Result); err != nil {
+            m.logger.Printf("WARNING: failed to record check result of %s in the history: %v", gateway.Name, err)
+        }
+    }
+}
+
+// resultsSince returns the results of the gateway's checks that ran since start
+func (m *Monitor) resultsSince(gateway config.Gateway, start time.Time) []CycleCheck {
+    var results []CycleCheck
+    for _, check := range gateway.Checks {
+        result, ok := m.checker.Result(gateway, check)
+        if !ok || result.CheckedAt.Before(start) {
+            continue
+        }
+        results = append(results, CycleCheck{Gateway: gateway.Name, Check: check, Result: result})
+    }
+    return results
+}
+
+// InMaintenance reports whether a window of the gateway or its project or a dynamic silence
+// covers now. Checks still run during maintenance; only notifications and downtime accounting
+// are affected.
+func (m *Monitor) InMaintenance(gateway config.Gateway, now time.Time) bool {
+    gatewaysFile, _ := m.Current()
+    for _, windows := range [][]config.MaintenanceWindow{gateway.Maintenance, gatewaysFile.ProjectOf(gateway).Maintenance} {
+        for _, window := range windows {
+            if window.Active(now) {
+                return true
+            }
+        }
+    }
+    return len(m.silences.Active(gateway.Name)) > 0
+}
+
+// Leading reports whether this replica runs the checks and sends the notifications
+func (m *Monitor) Leading() bool {
+    return m.leader == nil || m.leader.Leading()
+}
+
+// LeaderIdentity returns the identity of the replica running the checks, empty when it is
+// unknown or leader election is disabled
+func (m *Monitor) LeaderIdentity() string {
+    if m.leader == nil {
+        return ""
+    }
+    return m.leader.Holder()
+}
+
+// leads reports whether a cycle is due on this replica. A replica that lost the leadership
+// drops the series and state of its gateways, so only the leader exports them and it starts
+// afresh when elected again.
+func (m *Monitor) leads() bool {
+    leading := m.Leading()
+    m.cycleMu.Lock()
+    steppedDown := m.leading && !leading
+    m.leading = leading
+    m.cycleMu.Unlock()
+    if steppedDown {
+        for _, gateway := range m.Gateways() {
+            m.forget(gateway.Name)
+            m.metrics.DeleteGateway(gateway.Name)
+        }
+    }
+    return leading
+}
+
+// forget drops the state kept for a gateway that is no longer monitored
+func (m *Monitor) forget(name string) {
+    m.stateMu.Lock()
+    defer m.stateMu.Unlock()
+    delete(m.states, name)
+    delete(m.hysteresis, name)
+    delete(m.downSince, name)
+    delete(m.exported, name)
+    delete(m.degraded, name)
+    delete(m.gapAnomalous, name)
+    delete(m.lastAccounted, name)
+}
+
+// exportLocation sets the location gauges and drops the online and geo status series
+// labelled with the previous coordinates when the gateway has moved
+func (m *Monitor) exportLocation(gateway config.Gateway, location config.Location) {
+    name := gateway.Name
+    if previous, ok := m.exported[name]; ok && (previous.Latitude != location.Latitude || previous.Longitude != location.Longitude) {
+        m.metrics.GatewayOnlineStatus.DeletePartialMatch(prometheus.Labels{"name": name})
+        m.metrics.GatewayGeoStatus.DeletePartialMatch(prometheus.Labels{"name": name})
+        m.logger.Printf("Location of gateway %s changed to %f, %f", name, location.Latitude, location.Longitude)
+    }
+    m.exported[name] = location
+
+    m.metrics.GatewayLatitude.WithLabelValues(name, gateway.Project).Set(location.Latitude)
+    m.metrics.GatewayLongitude.WithLabelValues(name, gateway.Project).Set(location.Longitude)
+    if location.Altitude != nil {
+        m.metrics.GatewayAltitude.WithLabelValues(name, gateway.Project).Set(*location.Altitude)
+    } else {
+        m.metrics.GatewayAltitude.DeleteLabelValues(name, gateway.Project)
+    }
+}
+
+// updateDegraded exports whether the gateway's field rules are violated and publishes the
+// transitions from and to "degraded", next to the gateway's state
+func (m *Monitor) updateDegraded(gateway config.Gateway, state string, now time.Time) {
+    reasons := m.checker.Degraded(gateway)
+    degraded := len(reasons) > 0
+    m.metrics.GatewayCheckDegraded.WithLabelValues(gateway.Name, gateway.Project).Set(metrics.Bool(degraded))
+
+    if degraded && !m.degraded[gateway.Name] {
+        reason := strings.Join(reasons, "; ")
+        m.logger.Printf("Gateway %s is degraded: %s", gateway.Name, reason)
+        m.events.Publish(Transition{Gateway: gateway.Name, From: state, To: "degraded", At: now, Reason: reason})
+    } else if !degraded && m.degraded[gateway.Name] {
+        m.logger.Printf("Gateway %s is no longer degraded", gateway.Name)
+        m.events.Publish(Transition{Gateway: gateway.Name, From: "degraded", To: state, At: now})
+    }
+    m.degraded[gateway.Name] = degraded
+}
+
+// updateGapAnomaly logs when the status updates of a gateway start and stop arriving much
+// later than usual
+func (m *Monitor) updateGapAnomaly(gateway config.Gateway) {
+    reasons := m.checker.UpdateGapAnomalies(gateway)
+    anomalous := len(reasons) > 0
+    if anomalous && !m.gapAnomalous[gateway.Name] {
+        m.logger.Printf("WARNING: Status updates of gateway %s are overdue: %s", gateway.Name, strings.Join(reasons, "; "))
+    } else if !anomalous && m.gapAnomalous[gateway.Name] {
+        m.logger.Printf("Status updates of gateway %s arrive as usual again", gateway.Name)
+    }
+    m.gapAnomalous[gateway.Name] = anomalous
+}
@@ -0,0 +1,261 @@
+package monitor
+
+import (
+    "context"
+    "io"
+    "log"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "sync/atomic"
+    "testing"
+    "time"
+
+    "github.com/prometheus/client_golang/prometheus"
+    dto "github.com/prometheus/client_model/go"
+
+    "gateway-monitor/internal/checker"
+    "gateway-monitor/internal/clock"
+    "gateway-monitor/internal/config"
+    "gateway-monitor/internal/metrics"
+)
+
+var testTime = time.Date(2026, 1, 5, 12, 0, 0, 0, time.UTC)
+
+// newTestMonitor returns a Monitor on a fake clock checking every minute, with its own
+// metrics registry; opts may adjust the options before it is created
+func newTestMonitor(t *testing.T, opts ...func(*Options)) (*Monitor, *metrics.Metrics, *clock.Fake) {
+    t.Helper()
+    clk := clock.NewFake(testTime)
+    logger := log.New(io.Discard, "", 0)
+    m := metrics.New(prometheus.NewRegistry())
+    forwarders := checker.NewForwarders(m, clk, logger)
+    c := checker.New(&http.Client{Timeout: time.Second}, forwarders, checker.NewSubscriptions(m, clk, logger), m, clk, logger)
+    options := Options{
+        Checker:         c,
+        Forwarders:      forwarders,
+        Metrics:         m,
+        Clock:           clk,
+        Logger:          logger,
+        Interval:        time.Minute,
+        Concurrency:     2,
+        HeartbeatClient: &http.Client{Timeout: time.Second},
+    }
+    for _, opt := range opts {
+        opt(&options)
+    }
+    return New(options), m, clk
+}
+
+// apply runs the monitor with the gateways
+func apply(t *testing.T, mon *Monitor, gateways ...config.Gateway) {
+    t.Helper()
+    if err := mon.Apply(&config.GatewaysFile{Gateways: gateways}, config.Source{Kind: "file"}); err != nil {
+        t.Fatal(err)
+    }
+}
+
+// serve returns a server answering every request with status and body
+func serve(t *testing.T, status int, body string) *httptest.Server {
+    t.Helper()
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        w.WriteHeader(status)
+        io.WriteString(w, body)
+    }))
+    t.Cleanup(server.Close)
+    return server
+}
+
+func httpGateway(name, url string) config.Gateway {
+    return config.Gateway{Name: name, Project: "p", Checks: []config.Check{{Type: "http", URL: url}}}
+}
+
+func gaugeValue(t *testing.T, gauge prometheus.Gauge) float64 {
+    t.Helper()
+    var metric dto.Metric
+    if err := gauge.Write(&metric); err != nil {
+        t.Fatal(err)
+    }
+    return metric.GetGauge().GetValue()
+}
+
+func counterValue(t *testing.T, counter prometheus.Counter) float64 {
+    t.Helper()
+    var metric dto.Metric
+    if err := counter.Write(&metric); err != nil {
+        t.Fatal(err)
+    }
+    return metric.GetCounter().GetValue()
+}
+
+func onlineStatus(t *testing.T, m *metrics.Metrics, name string) float64 {
+    t.Helper()
+    return gaugeValue(t, m.GatewayOnlineStatus.With(prometheus.Labels{"name": name, "project": "p", "latitude": "", "longitude": ""}))
+}
+
+func TestRunCycleExportsOnlineStatus(t *testing.T) {
+    online := serve(t, http.StatusOK, `{"online": true}`)
+    malformed := serve(t, http.StatusOK, `<html>upstream error</html>`)
+    unreachable := httptest.NewServer(http.NotFoundHandler())
+    unreachable.Close()
+
+    mon, m, _ := newTestMonitor(t)
+    apply(t, mon, httpGateway("online", online.URL), httpGateway("malformed", malformed.URL), httpGateway("unreachable", unreachable.URL))
+
+    if !mon.RunCycle(context.Background()) {
+        t.Error("RunCycle reported an overrun")
+    }
+    want := map[string]float64{"online": 1, "malformed": 0, "unreachable": 0}
+    for name, status := range want {
+        if got := onlineStatus(t, m, name); got != status {
+            t.Errorf("gateway_online_status{name=%q} = %v, want %v", name, got, status)
+        }
+    }
+    if got := gaugeValue(t, m.GatewayCurrentDowntime.WithLabelValues("unreachable", "p")); got != 0 {
+        t.Errorf("gateway_current_downtime_seconds at the first offline cycle = %v, want 0", got)
+    }
+    if got := gaugeValue(t, m.LastCycleCompleted); got != float64(testTime.Unix()) {
+        t.Errorf("loracheck_last_cycle_completed_timestamp_seconds = %v, want the fake clock's time", got)
+    }
+}
+
+func TestRunCyclePublishesTransitions(t *testing.T) {
+    var status atomic.Value
+    status.Store(`{"online": true}`)
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        io.WriteString(w, status.Load().(string))
+    }))
+    defer server.Close()
+
+    mon, m, clk := newTestMonitor(t)
+    apply(t, mon, httpGateway("gw1", server.URL))
+    events, unsubscribe := mon.Events().Subscribe()
+    defer unsubscribe()
+
+    mon.RunCycle(context.Background())
+    if got := <-events; got.From != "unknown" || got.To != "online" {
+        t.Errorf("first transition = %s -> %s, want unknown -> online", got.From, got.To)
+    }
+
+    status.Store(`{"online": false}`)
+    clk.Advance(time.Minute)
+    mon.RunCycle(context.Background())
+    if got := <-events; got.From != "online" || got.To != "offline" || !got.At.Equal(testTime.Add(time.Minute)) {
+        t.Errorf("second transition = %s -> %s at %s, want online -> offline a minute later", got.From, got.To, got.At)
+    }
+    if got := onlineStatus(t, m, "gw1"); got != 0 {
+        t.Errorf("gateway_online_status = %v, want 0", got)
+    }
+    if got := counterValue(t, m.GatewayStatusTransitions.WithLabelValues("gw1", "p", "online", "offline")); got != 1 {
+        t.Errorf("gateway_status_transitions_total{online->offline} = %v, want 1", got)
+    }
+}
+
+func TestFieldRulesDegradeGateway(t *testing.T) {
+    tests := []struct {
+        name string
+        // bodies are served one per cycle, ten minutes apart
+        bodies []string
+        // degraded is whether the gateway ends up degraded by its counter
+        degraded bool
+        reason   string
+    }{
+        {
+            name:   "counter increasing",
+            bodies: []string{`{"online": true, "uplinks": 1}`, `{"online": true, "uplinks": 2}`, `{"online": true, "uplinks": 3}`, `{"online": true, "uplinks": 4}`},
+        },
+        {
+            name:     "counter stuck",
+            bodies:   []string{`{"online": true, "uplinks": 5}`, `{"online": true, "uplinks": 5}`, `{"online": true, "uplinks": 5}`, `{"online": true, "uplinks": 5}`},
+            degraded: true,
+            reason:   "uplinks has not increased in 30m0s",
+        },
+        {
+            name:     "field missing",
+            bodies:   []string{`{"online": true}`},
+            degraded: true,
+            reason:   "uplinks missing from response",
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            var body atomic.Value
+            server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+                io.WriteString(w, body.Load().(string))
+            }))
+            defer server.Close()
+
+            mon, m, clk := newTestMonitor(t)
+            gateway := httpGateway("gw1", server.URL)
+            gateway.Checks[0].Fields = []config.FieldRule{{Path: "uplinks", MaxDeltaAge: "30m"}}
+            apply(t, mon, gateway)
+            events, unsubscribe := mon.Events().Subscribe()
+            defer unsubscribe()
+
+            for i, b := range tt.bodies {
+                if i > 0 {
+                    clk.Advance(10 * time.Minute)
+                }
+                body.Store(b)
+                mon.RunCycle(context.Background())
+            }
+
+            if got := gaugeValue(t, m.GatewayCheckDegraded.WithLabelValues("gw1", "p")); got != metrics.Bool(tt.degraded) {
+                t.Errorf("gateway_check_degraded = %v, want %v", got, metrics.Bool(tt.degraded))
+            }
+            if got := onlineStatus(t, m, "gw1"); got != 1 {
+                t.Errorf("gateway_online_status of a degraded gateway = %v, want 1", got)
+            }
+            // The first check's transition to online comes first
+            <-events
+            select {
+            case got := <-events:
+                if !tt.degraded {
+                    t.Fatalf("unexpected transition %s -> %s: %s", got.From, got.To, got.Reason)
+                }
+                if got.From != "online" || got.To != "degraded" || got.Reason != tt.reason {
+                    t.Errorf("got transition %s -> %s: %q, want online -> degraded: %q", got.From, got.To, got.Reason, tt.reason)
+                }
+            default:
+                if tt.degraded {
+                    t.Fatal("no transition to degraded")
+                }
+            }
+        })
+    }
+}
+
+func TestDegradationEndsWithTransition(t *testing.T) {
+    var body atomic.Value
+    body.Store(`{"online": true}`)
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        io.WriteString(w, body.Load().(string))
+    }))
+    defer server.Close()
+
+    mon, m, clk := newTestMonitor(t)
+    gateway := httpGateway("gw1", server.URL)
+    minimum := -120.0
+    gateway.Checks[0].Fields = []config.FieldRule{{Path: "rssi", Min: &minimum}}
+    apply(t, mon, gateway)
+    events, unsubscribe := mon.Events().Subscribe()
+    defer unsubscribe()
+
+    mon.RunCycle(context.Background())
+    body.Store(`{"online": true, "rssi": -80}`)
+    clk.Advance(time.Minute)
+    mon.RunCycle(context.Background())
+
+    var got []string
+    for len(events) > 0 {
+        transition := <-events
+        got = append(got, transition.From+" -> "+transition.To)
+    }
+    want := []string{"unknown -> online", "online -> degraded", "degraded -> online"}
+    if strings.Join(got, ", ") != strings.Join(want, ", ") {
+        t.Errorf("got transitions %v, want %v", got, want)
+    }
+    if value := gaugeValue(t, m.GatewayCheckValue.WithLabelValues("gw1", "p", "rssi")); value != -80 {
+        t.Errorf("gateway_check_value{field=rssi} = %v, want -80", value)
+    }
+}
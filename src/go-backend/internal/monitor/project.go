@@ -0,0 +1,39 @@
+package monitor
+
+import (
+    "time"
+
+    "gateway-monitor/internal/config"
+)
+
+// due reports whether the gateway should be checked in the cycle starting at now. Gateways
+// of a project with a longer interval are skipped until it has nearly elapsed, so an interval
+// that isn't a multiple of the cycle is rounded to the nearest cycle rather than the next one.
+func (m *Monitor) due(gatewaysFile *config.GatewaysFile, gateway config.Gateway, now time.Time) bool {
+    interval, err := gatewaysFile.ProjectOf(gateway).CheckInterval()
+    if err != nil || interval <= m.interval {
+        return true
+    }
+    m.stateMu.Lock()
+    state, ok := m.states[gateway.Name]
+    m.stateMu.Unlock()
+    return !ok || now.Sub(state.checkedAt) >= interval-m.interval/2
+}
+
+// withProjectSettings returns the gateway with its project's max_status_age applied to the
+// checks without a max_age; the configured gateway is left untouched
+func withProjectSettings(gatewaysFile *config.GatewaysFile, gateway config.Gateway) config.Gateway {
+    project := gatewaysFile.ProjectOf(gateway)
+    if project.MaxStatusAge == "" {
+        return gateway
+    }
+    checks := make([]config.Check, len(gateway.Checks))
+    for i, check := range gateway.Checks {
+        if check.MaxAge == "" {
+            check.MaxAge = project.MaxStatusAge
+        }
+        checks[i] = check
+    }
+    gateway.Checks = checks
+    return gateway
+}
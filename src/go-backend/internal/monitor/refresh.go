@@ -0,0 +1,89 @@
+package monitor
+
+import (
+    "context"
+    "errors"
+    "os"
+    "os/signal"
+    "syscall"
+    "time"
+
+    "gateway-monitor/internal/config"
+)
+
+// Refresh re-fetches a remote configuration every interval until ctx is cancelled.
+// Failures keep the last good configuration running.
+func (m *Monitor) Refresh(ctx context.Context, loader *config.Loader, interval time.Duration) {
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-m.clock.After(interval):
+        }
+        m.Reload(ctx, loader)
+    }
+}
+
+// Watch reloads a local configuration whenever the contents of its file, or of any
+// fragment of its directory, change. It polls every interval until ctx is cancelled.
+func (m *Monitor) Watch(ctx context.Context, loader *config.Loader, interval time.Duration) {
+    last, err := loader.Fingerprint()
+    if err != nil {
+        m.logger.Printf("WARNING: failed to read %s for changes: %v", loader.Location, err)
+    }
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-m.clock.After(interval):
+        }
+
+        fingerprint, err := loader.Fingerprint()
+        if err != nil || fingerprint == last {
+            continue
+        }
+        last = fingerprint
+
+        // Writes made by Save or Git sync are already running
+        if loaded, err := config.Read(loader.Location); err == nil && !m.differs(loaded) {
+            continue
+        }
+        m.logger.Printf("Configuration at %s changed, reloading", loader.Location)
+        m.Reload(ctx, loader)
+    }
+}
+
+// ReloadOnSignal reloads the configuration on every SIGHUP until ctx is cancelled
+func (m *Monitor) ReloadOnSignal(ctx context.Context, loader *config.Loader) {
+    signals := make(chan os.Signal, 1)
+    signal.Notify(signals, syscall.SIGHUP)
+    defer signal.Stop(signals)
+
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-signals:
+            m.logger.Printf("Received SIGHUP, reloading configuration from %s", loader.Location)
+            m.Reload(ctx, loader)
+        }
+    }
+}
+
+// Reload loads the configuration and applies it. Failures keep the last good configuration running.
+func (m *Monitor) Reload(ctx context.Context, loader *config.Loader) {
+    gatewaysFile, err := loader.Load(ctx)
+    if errors.Is(err, config.ErrNotModified) {
+        return
+    }
+    if err != nil {
+        m.metrics.ConfigLoadErrors.WithLabelValues(loader.Source().Kind).Inc()
+        m.logger.Printf("WARNING: failed to load configuration from %s, keeping last good configuration: %v", loader.Location, err)
+        return
+    }
+
+    if err := m.Apply(gatewaysFile, loader.Source()); err != nil {
+        m.metrics.ConfigLoadErrors.WithLabelValues(loader.Source().Kind).Inc()
+        m.logger.Printf("WARNING: failed to apply configuration from %s, keeping last good configuration: %v", loader.Location, err)
+    }
+}
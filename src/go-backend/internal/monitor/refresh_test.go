@@ -0,0 +1,54 @@
+package monitor
+
+import (
+    "context"
+    "os"
+    "path/filepath"
+    "testing"
+    "time"
+
+    "gateway-monitor/internal/clock"
+    "gateway-monitor/internal/config"
+)
+
+// waitForWaiter blocks until a goroutine waits on the fake clock
+func waitForWaiter(t *testing.T, clk *clock.Fake) {
+    t.Helper()
+    deadline := time.Now().Add(5 * time.Second)
+    for clk.Waiters() == 0 {
+        if time.Now().After(deadline) {
+            t.Fatal("nothing waits on the clock")
+        }
+        time.Sleep(time.Millisecond)
+    }
+}
+
+func TestWatchAppliesSettingsBesidesGateways(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "gateways.json")
+    gateways := `"gateways": [{"name": "gw1", "checks": [{"type": "http", "url": "http://127.0.0.1:1/status"}]}]`
+    write := func(tokens string) {
+        t.Helper()
+        if err := os.WriteFile(path, []byte(`{`+gateways+`, "api_tokens": [`+tokens+`]}`), 0o600); err != nil {
+            t.Fatal(err)
+        }
+    }
+    write(`{"name": "noc", "token": "operator-token-0123456", "role": "operator"}`)
+
+    mon, _, clk := newTestMonitor(t)
+    loader := config.NewLoader(path, nil)
+    mon.Reload(context.Background(), loader)
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+    go mon.Watch(ctx, loader, 10*time.Second)
+
+    // Revoking the token leaves the gateways as they are
+    waitForWaiter(t, clk)
+    write(`{"name": "dashboards", "token": "viewer-token-0123456", "role": "viewer"}`)
+    clk.Advance(10 * time.Second)
+    waitForWaiter(t, clk)
+
+    current, _ := mon.Current()
+    if len(current.APITokens) != 1 || current.APITokens[0].Name != "dashboards" {
+        t.Errorf("running API tokens = %+v, want only dashboards", current.APITokens)
+    }
+}
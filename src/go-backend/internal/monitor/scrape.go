@@ -0,0 +1,108 @@
+package monitor
+
+import (
+    "context"
+    "sync"
+    "time"
+
+    "github.com/prometheus/client_golang/prometheus"
+
+    "gateway-monitor/internal/clock"
+)
+
+// Scraper runs a check cycle when Prometheus scrapes the metrics instead of on a fixed interval.
+// It registers as the only collector and gathers the metrics registered with it after the
+// cycle completes, so a scrape never reports results older than MaxAge.
+type Scraper struct {
+    // Monitor runs the cycles; it is set once the monitor has been created with the Scraper as registerer
+    Monitor *Monitor
+    // MaxAge is how long the results of a cycle are served before a scrape runs a new one
+    MaxAge time.Duration
+    // Timeout bounds the cycle a scrape waits for, and should stay below the scrape_timeout
+    Timeout time.Duration
+
+    clock clock.Clock
+
+    mu         sync.Mutex
+    collectors []prometheus.Collector
+
+    // runMu makes concurrent scrapes wait for the same cycle
+    runMu   sync.Mutex
+    lastRun time.Time
+}
+
+// NewScraper creates a Scraper serving the results of each cycle for maxAge
+func NewScraper(clk clock.Clock, maxAge, timeout time.Duration) *Scraper {
+    return &Scraper{MaxAge: maxAge, Timeout: timeout, clock: clk}
+}
+
+// Register keeps the collector to gather after each scrape-time cycle
+func (s *Scraper) Register(c prometheus.Collector) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.collectors = append(s.collectors, c)
+    return nil
+}
+
+// MustRegister registers the collectors; it never panics since registration cannot fail
+func (s *Scraper) MustRegister(cs ...prometheus.Collector) {
+    for _, c := range cs {
+        s.Register(c)
+    }
+}
+
+// Unregister removes a collector registered earlier
+func (s *Scraper) Unregister(c prometheus.Collector) bool {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    for i, registered := range s.collectors {
+        if registered == c {
+            s.collectors = append(s.collectors[:i], s.collectors[i+1:]...)
+            return true
+        }
+    }
+    return false
+}
+
+// Describe describes the metrics of every registered collector
+func (s *Scraper) Describe(ch chan<- *prometheus.Desc) {
+    for _, c := range s.registered() {
+        c.Describe(ch)
+    }
+}
+
+// Collect runs a cycle unless the last one is younger than MaxAge, then collects the metrics.
+// Concurrent scrapes wait for the same cycle rather than starting their own.
+func (s *Scraper) Collect(ch chan<- prometheus.Metric) {
+    s.Refresh()
+    for _, c := range s.registered() {
+        c.Collect(ch)
+    }
+}
+
+// registered returns a copy of the collectors so Collect does not hold the lock while gathering
+func (s *Scraper) registered() []prometheus.Collector {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return append([]prometheus.Collector(nil), s.collectors...)
+}
+
+// Refresh runs a cycle when the cached results are older than MaxAge. Calling it on startup
+// makes the monitor ready before the first scrape.
+func (s *Scraper) Refresh() {
+    if s.Monitor == nil || !s.Monitor.leads() {
+        return
+    }
+    s.runMu.Lock()
+    defer s.runMu.Unlock()
+
+    if !s.lastRun.IsZero() && s.clock.Now().Sub(s.lastRun) < s.MaxAge {
+        return
+    }
+    ctx, cancel := context.WithTimeout(context.Background(), s.Timeout)
+    defer cancel()
+    if s.Monitor.RunCycle(ctx) {
+        s.Monitor.sendHeartbeat(ctx)
+    }
+    s.lastRun = s.clock.Now()
+}
@@ -0,0 +1,92 @@
+package monitor
+
+import (
+    "crypto/rand"
+    "encoding/hex"
+    "log"
+    "sort"
+    "sync"
+    "time"
+
+    "gateway-monitor/internal/clock"
+)
+
+// Silence mutes a gateway for a limited time, typically while it is being repaired
+type Silence struct {
+    ID      string    `json:"id"`
+    Gateway string    `json:"gateway"`
+    Start   time.Time `json:"start"`
+    End     time.Time `json:"end"`
+    Comment string    `json:"comment,omitempty"`
+}
+
+// Silences holds the dynamic silences created through the API, keyed by gateway name
+type Silences struct {
+    clock  clock.Clock
+    logger *log.Logger
+
+    mu     sync.Mutex
+    byName map[string][]Silence
+}
+
+// NewSilences creates an empty silence list
+func NewSilences(clk clock.Clock, logger *log.Logger) *Silences {
+    return &Silences{
+        clock:  clk,
+        logger: logger,
+        byName: map[string][]Silence{},
+    }
+}
+
+// Add silences a gateway from now until the duration has passed
+func (s *Silences) Add(gateway string, d time.Duration, comment string) Silence {
+    id := make([]byte, 8)
+    rand.Read(id)
+
+    now := s.clock.Now()
+    silence := Silence{
+        ID:      hex.EncodeToString(id),
+        Gateway: gateway,
+        Start:   now,
+        End:     now.Add(d),
+        Comment: comment,
+    }
+
+    s.mu.Lock()
+    s.byName[gateway] = append(s.byName[gateway], silence)
+    s.mu.Unlock()
+
+    s.logger.Printf("Gateway %s silenced until %s", gateway, silence.End.Format(time.RFC3339))
+    return silence
+}
+
+// Active removes expired silences and returns the remaining ones sorted by end time.
+// An empty gateway name returns the silences of all gateways.
+func (s *Silences) Active(gateway string) []Silence {
+    now := s.clock.Now()
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    active := []Silence{}
+    for name, list := range s.byName {
+        kept := list[:0]
+        for _, silence := range list {
+            if now.Before(silence.End) {
+                kept = append(kept, silence)
+            }
+        }
+        if len(kept) == 0 {
+            delete(s.byName, name)
+            continue
+        }
+        s.byName[name] = kept
+
+        if gateway == "" || gateway == name {
+            active = append(active, kept...)
+        }
+    }
+
+    sort.Slice(active, func(i, j int) bool { return active[i].End.Before(active[j].End) })
+    return active
+}
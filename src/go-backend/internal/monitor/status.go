@@ -0,0 +1,116 @@
+package monitor
+
+import (
+    "time"
+
+    "gateway-monitor/internal/config"
+)
+
+// gatewayState is the outcome of the last check of a gateway
+type gatewayState struct {
+    online        bool
+    inMaintenance bool
+    checkedAt     time.Time
+}
+
+// GatewayStatus is the current state of a monitored gateway
+type GatewayStatus struct {
+    Name     string            `json:"name"`
+    Project  string            `json:"project,omitempty"`
+    Origin   string            `json:"origin"`
+    Labels   map[string]string `json:"labels,omitempty"`
+    Location config.Location   `json:"location"`
+    // State is "online", "offline", "maintenance" or "unknown" until the gateway is first checked
+    State         string   `json:"state"`
+    Online        bool     `json:"online"`
+    InMaintenance bool     `json:"in_maintenance"`
+    Degraded      []string `json:"degraded,omitempty"`
+    // UpdateGapAnomalies describe the checks whose status source is unusually late with an update
+    UpdateGapAnomalies []string `json:"update_gap_anomalies,omitempty"`
+    // Silences are the active silences created through the API
+    Silences    []Silence  `json:"silences,omitempty"`
+    LastChecked *time.Time `json:"last_checked,omitempty"`
+    // LastSeen is the most recent time any check found the gateway online
+    LastSeen *time.Time    `json:"last_seen,omitempty"`
+    Checks   []CheckStatus `json:"checks"`
+}
+
+// CheckStatus is the current state of a single check
+type CheckStatus struct {
+    Type   string `json:"type"`
+    Target string `json:"target"`
+    // Status is "online", "offline", "error", "no status" or "unknown" until the check first runs
+    Status          string     `json:"status"`
+    LastChecked     *time.Time `json:"last_checked,omitempty"`
+    LastSeen        *time.Time `json:"last_seen,omitempty"`
+    LastError       string     `json:"last_error,omitempty"`
+    DurationSeconds float64    `json:"duration_seconds"`
+}
+
+// Status returns the current state of every monitored gateway
+func (m *Monitor) Status() []GatewayStatus {
+    gateways := m.Gateways()
+    statuses := make([]GatewayStatus, 0, len(gateways))
+    for _, gateway := range gateways {
+        statuses = append(statuses, m.GatewayStatus(gateway))
+    }
+    return statuses
+}
+
+// GatewayStatus returns the current state of one gateway
+func (m *Monitor) GatewayStatus(gateway config.Gateway) GatewayStatus {
+    m.stateMu.Lock()
+    state, checked := m.states[gateway.Name]
+    m.stateMu.Unlock()
+
+    status := GatewayStatus{
+        Name:               gateway.Name,
+        Project:            gateway.Project,
+        Origin:             gateway.GatewayOrigin(),
+        Labels:             gateway.Labels,
+        Location:           m.checker.Location(gateway),
+        State:              "unknown",
+        Degraded:           m.checker.Degraded(gateway),
+        UpdateGapAnomalies: m.checker.UpdateGapAnomalies(gateway),
+        Silences:           m.silences.Active(gateway.Name),
+        Checks:             make([]CheckStatus, 0, len(gateway.Checks)),
+    }
+    if checked {
+        status.Online, status.InMaintenance = state.online, state.inMaintenance
+        status.LastChecked = timePtr(state.checkedAt)
+        status.State = stateName(state.online, state.inMaintenance)
+    }
+
+    for _, check := range gateway.Checks {
+        checkStatus := CheckStatus{Type: check.Type, Target: check.Target(), Status: "unknown"}
+        if result, ok := m.checker.Result(gateway, check); ok {
+            checkStatus.Status = result.Status
+            checkStatus.LastChecked = timePtr(result.CheckedAt)
+            checkStatus.LastError = result.Error
+            checkStatus.DurationSeconds = result.Duration.Seconds()
+        }
+        if seen, ok := m.checker.LastSeen(gateway, check); ok {
+            checkStatus.LastSeen = timePtr(seen)
+            if status.LastSeen == nil || seen.After(*status.LastSeen) {
+                status.LastSeen = timePtr(seen)
+            }
+        }
+        status.Checks = append(status.Checks, checkStatus)
+    }
+    return status
+}
+
+// stateName summarises a gateway state, maintenance taking precedence
+func stateName(online, inMaintenance bool) string {
+    switch {
+    case inMaintenance:
+        return "maintenance"
+    case online:
+        return "online"
+    }
+    return "offline"
+}
+
+func timePtr(t time.Time) *time.Time {
+    return &t
+}
@@ -0,0 +1,73 @@
+package notify
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "net/url"
+
+    "gateway-monitor/internal/config"
+)
+
+// telegramAPI is the Telegram Bot API endpoint
+const telegramAPI = "https://api.telegram.org"
+
+// sendChat renders the message template and posts it to the Slack, Discord or Telegram chat
+func (n *Notifier) sendChat(ctx context.Context, chat config.ChatNotification, notification Notification) error {
+    tmpl, err := chat.MessageTemplate()
+    if err != nil {
+        return err
+    }
+    var message bytes.Buffer
+    if err := tmpl.Execute(&message, notification); err != nil {
+        return fmt.Errorf("rendering message: %v", err)
+    }
+    if err := n.postChat(ctx, chat, message.String()); err != nil {
+        return err
+    }
+    n.logger.Printf("Sent %s %s notification for gateway %s", notification.State, chat.Type, notification.Gateway)
+    return nil
+}
+
+// postChat posts a message to the Slack, Discord or Telegram chat
+func (n *Notifier) postChat(ctx context.Context, chat config.ChatNotification, message string) error {
+    var endpoint string
+    var payload interface{}
+    switch chat.Type {
+    case "slack":
+        endpoint, payload = chat.ResolveWebhookURL(), map[string]string{"text": message}
+    case "discord":
+        endpoint, payload = chat.ResolveWebhookURL(), map[string]string{"content": message}
+    case "telegram":
+        endpoint = telegramAPI + "/bot" + url.PathEscape(chat.ResolveBotToken()) + "/sendMessage"
+        payload = map[string]string{"chat_id": chat.ChatID, "text": message}
+    default:
+        return fmt.Errorf("unsupported chat type %q", chat.Type)
+    }
+
+    body, err := json.Marshal(payload)
+    if err != nil {
+        return err
+    }
+    req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+    if err != nil {
+        // The URL holds the webhook or bot token, keep it out of the logs
+        return fmt.Errorf("invalid %s endpoint", chat.Type)
+    }
+    req.Header.Set("Content-Type", "application/json")
+
+    resp, err := n.client.Do(req)
+    if err != nil {
+        if urlErr, ok := err.(*url.Error); ok {
+            err = urlErr.Err
+        }
+        return err
+    }
+    resp.Body.Close()
+    if resp.StatusCode < 200 || resp.StatusCode > 299 {
+        return fmt.Errorf("unexpected status %s", resp.Status)
+    }
+    return nil
+}
@@ -0,0 +1,104 @@
+package notify
+
+import (
+    "bytes"
+    "fmt"
+    "mime"
+    "net"
+    "net/smtp"
+    "strconv"
+    "strings"
+    "time"
+
+    "gateway-monitor/internal/config"
+)
+
+// SMTP is the mail server email notifications are sent through. The connection is
+// upgraded with STARTTLS when the server offers it.
+type SMTP struct {
+    Host string
+    Port int
+    // Username and Password authenticate with PLAIN auth when Username is set
+    Username string
+    Password string
+    From     string
+}
+
+// sendEmail renders the subject template and mails the notification to the gateway's recipients
+func (n *Notifier) sendEmail(gatewaysFile *config.GatewaysFile, email config.EmailNotifications, gateway config.Gateway, notification Notification) error {
+    to := gatewaysFile.EmailRecipients(gateway)
+    if len(to) == 0 {
+        return nil
+    }
+
+    tmpl, err := email.SubjectTemplate()
+    if err != nil {
+        return err
+    }
+    var subject bytes.Buffer
+    if err := tmpl.Execute(&subject, notification); err != nil {
+        return fmt.Errorf("rendering subject: %v", err)
+    }
+
+    var body bytes.Buffer
+    writeBody(&body, notification)
+    if err := n.mail(to, subject.String(), notification.At, body.Bytes()); err != nil {
+        return err
+    }
+    n.logger.Printf("Sent %s email notification for gateway %s to %s", notification.State, gateway.Name, strings.Join(to, ", "))
+    return nil
+}
+
+// mail sends a plain text email through the SMTP server
+func (n *Notifier) mail(to []string, subject string, date time.Time, body []byte) error {
+    var message bytes.Buffer
+    fmt.Fprintf(&message, "From: %s\r\n", n.SMTP.From)
+    fmt.Fprintf(&message, "To: %s\r\n", strings.Join(to, ", "))
+    fmt.Fprintf(&message, "Subject: %s\r\n", encodeSubject(subject))
+    fmt.Fprintf(&message, "Date: %s\r\n", date.Format(time.RFC1123Z))
+    message.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+    message.Write(body)
+
+    var auth smtp.Auth
+    if n.SMTP.Username != "" {
+        auth = smtp.PlainAuth("", n.SMTP.Username, n.SMTP.Password, n.SMTP.Host)
+    }
+    address := net.JoinHostPort(n.SMTP.Host, strconv.Itoa(n.SMTP.Port))
+    return smtp.SendMail(address, auth, n.SMTP.From, to, message.Bytes())
+}
+
+// headerBreaks turns the line breaks a rendered subject may contain into spaces, so
+// gateway names and templates cannot add headers
+var headerBreaks = strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ")
+
+// encodeSubject makes a subject safe for its header, encoding non-ASCII text as RFC 2047 words
+func encodeSubject(subject string) string {
+    return mime.QEncoding.Encode("utf-8", headerBreaks.Replace(subject))
+}
+
+// writeBody describes the outage or degradation and the outcome of each check
+func writeBody(message *bytes.Buffer, notification Notification) {
+    switch notification.State {
+    case "online":
+        fmt.Fprintf(message, "Gateway %s is back online after %s offline.\r\n", notification.Gateway, notification.Downtime)
+    case "degraded":
+        fmt.Fprintf(message, "Gateway %s is degraded: %s.\r\n", notification.Gateway, notification.Reason)
+    case "degrading":
+        fmt.Fprintf(message, "Gateway %s is online but degrading: %s.\r\n", notification.Gateway, notification.Reason)
+    default:
+        fmt.Fprintf(message, "Gateway %s has been offline since %s (%s).\r\n", notification.Gateway,
+            notification.Since.Format(time.RFC3339), notification.Downtime)
+    }
+    if notification.Project != "" {
+        fmt.Fprintf(message, "Project: %s\r\n", notification.Project)
+    }
+
+    message.WriteString("\r\nChecks:\r\n")
+    for _, check := range notification.Status.Checks {
+        fmt.Fprintf(message, "- %s %s: %s", check.Type, check.Target, check.Status)
+        if check.LastError != "" {
+            fmt.Fprintf(message, " (%s)", check.LastError)
+        }
+        message.WriteString("\r\n")
+    }
+}
@@ -0,0 +1,21 @@
+package notify
+
+import "testing"
+
+func TestEncodeSubject(t *testing.T) {
+    tests := []struct {
+        subject string
+        want    string
+    }{
+        {subject: "[LoRaCheck] Gateway gw1 is offline", want: "[LoRaCheck] Gateway gw1 is offline"},
+        {subject: "Gateway gw1\r\nBcc: victim@example.com", want: "Gateway gw1 Bcc: victim@example.com"},
+        {subject: "Gateway gw1\rBcc: victim@example.com", want: "Gateway gw1 Bcc: victim@example.com"},
+        {subject: "Gateway gw1\nBcc: victim@example.com", want: "Gateway gw1 Bcc: victim@example.com"},
+        {subject: "Gateway Zürich is offline", want: "=?utf-8?q?Gateway_Z=C3=BCrich_is_offline?="},
+    }
+    for _, tt := range tests {
+        if got := encodeSubject(tt.subject); got != tt.want {
+            t.Errorf("encodeSubject(%q) = %q, want %q", tt.subject, got, tt.want)
+        }
+    }
+}
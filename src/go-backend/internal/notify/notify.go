@@ -0,0 +1,469 @@
+// Package notify tells people about gateway state transitions.
+package notify
+
+import (
+    "context"
+    "crypto/sha256"
+    "encoding/hex"
+    "fmt"
+    "log"
+    "net/http"
+    "strings"
+    "sync"
+    "time"
+
+    "gateway-monitor/internal/clock"
+    "gateway-monitor/internal/config"
+    "gateway-monitor/internal/history"
+    "gateway-monitor/internal/monitor"
+)
+
+// pendingInterval is how often outages waiting for their minimum downtime are re-evaluated
+const pendingInterval = 10 * time.Second
+
+// Notification describes a gateway going offline or recovering; templates are rendered with it
+type Notification struct {
+    Gateway string
+    Project string
+    // State is "offline", "online", "degraded" when its field rules are violated or
+    // "degrading" when its status updates are overdue
+    State string
+    // Since is when the gateway went offline
+    Since time.Time
+    At    time.Time
+    // Downtime is how long the gateway has been or was offline
+    Downtime time.Duration
+    // Reminder is set on the offline notifications repeated while the gateway stays offline
+    Reminder bool
+    // Reason describes why a gateway is degraded or degrading
+    Reason string
+    Status monitor.GatewayStatus
+}
+
+// outage is a gateway that went offline; each channel is told once the outage lasted its
+// minimum downtime and, when it repeats, again every repeat interval
+type outage struct {
+    since time.Time
+    // notified holds when each channel told about the outage was last told
+    notified map[string]time.Time
+}
+
+// channel is a configured destination for outage and recovery notifications
+type channel struct {
+    name string
+    // kind is "email" or the chat type, the key of the channel in a project's escalation
+    kind        string
+    minDowntime time.Duration
+    repeat      time.Duration
+    routes      func(gateway config.Gateway) bool
+    send        func(gateway config.Gateway, notification Notification) error
+}
+
+// timing returns how long an outage of the gateway must last before the channel is told and
+// how often it is told again, with the escalation of the gateway's project applied
+func (c channel) timing(gatewaysFile *config.GatewaysFile, gateway config.Gateway) (after, repeat time.Duration) {
+    after, repeat = c.minDowntime, c.repeat
+    settings := gatewaysFile.ProjectOf(gateway)
+    if settings.Notifications == nil {
+        return after, repeat
+    }
+    escalation, ok := settings.Notifications.Escalation[c.kind]
+    if !ok {
+        return after, repeat
+    }
+    if d, set, _ := escalation.AfterDuration(); set {
+        after = d
+    }
+    if d, set, _ := escalation.RepeatInterval(); set {
+        repeat = d
+    }
+    return after, repeat
+}
+
+// Notifier delivers the transitions published by the monitor to the notification
+// channels of the running configuration. Email and chats are told about outages that
+// lasted their minimum downtime and about the recoveries; webhooks receive every transition.
+type Notifier struct {
+    monitor *monitor.Monitor
+    client  *http.Client
+    clock   clock.Clock
+    logger  *log.Logger
+
+    // SMTP sends the email notifications; they are skipped while it is nil
+    SMTP *SMTP
+    // History provides the monthly reports and keeps the outages and the channels told about
+    // them across restarts, see reconcile; without it there are no reports and a restart
+    // forgets the outages
+    History *history.Store
+
+    outages map[string]*outage
+    // overdue holds the gateways whose overdue status updates were notified
+    overdue map[string]bool
+    // reported is the last month a monthly report was considered for
+    reported string
+    // reconciled is set once the outages recorded before a restart were picked up
+    reconciled bool
+    // inFlight counts the deliveries running in the background, for Flush
+    inFlight sync.WaitGroup
+}
+
+// New creates a Notifier; it does nothing until Run is called
+func New(mon *monitor.Monitor, client *http.Client, clk clock.Clock, logger *log.Logger) *Notifier {
+    return &Notifier{monitor: mon, client: client, clock: clk, logger: logger, outages: map[string]*outage{}, overdue: map[string]bool{}}
+}
+
+// Run delivers notifications until ctx is cancelled. The transitions already published by
+// then are still handled, and deliveries in progress keep running; see Flush.
+func (n *Notifier) Run(ctx context.Context) {
+    events, unsubscribe := n.monitor.Events().Subscribe()
+    defer unsubscribe()
+    warnings, unsubscribeWarnings := n.monitor.Events().SubscribeWarnings()
+    defer unsubscribeWarnings()
+    deliveries := context.WithoutCancel(ctx)
+    if n.monitor.Leading() {
+        n.reconcile(deliveries)
+    }
+
+    for {
+        select {
+        case <-ctx.Done():
+            n.drain(deliveries, events)
+            return
+        case transition, ok := <-events:
+            if !ok {
+                return
+            }
+            n.handle(deliveries, transition)
+            n.sendWebhooks(deliveries, transition)
+        case warning, ok := <-warnings:
+            // Every replica keeps an eye on its configuration, only the leader warns
+            if ok && n.monitor.Leading() {
+                n.sendWarning(deliveries, warning)
+            }
+        case <-n.clock.After(pendingInterval):
+        }
+        // A standby replica leaves the reminders and reports to the leader
+        if !n.monitor.Leading() {
+            continue
+        }
+        if !n.reconciled {
+            n.reconcile(deliveries)
+        }
+        n.notifyPending(deliveries)
+        n.notifyOverdue(deliveries)
+        n.sendMonthlyReports()
+    }
+}
+
+// drain handles the transitions waiting in events without blocking
+func (n *Notifier) drain(ctx context.Context, events <-chan monitor.Transition) {
+    for {
+        select {
+        case transition, ok := <-events:
+            if !ok {
+                return
+            }
+            n.handle(ctx, transition)
+            n.sendWebhooks(ctx, transition)
+        default:
+            return
+        }
+    }
+}
+
+// Flush waits until the notifications sent in the background have been delivered, or ctx is done
+func (n *Notifier) Flush(ctx context.Context) error {
+    done := make(chan struct{})
+    go func() {
+        n.inFlight.Wait()
+        close(done)
+    }()
+    select {
+    case <-done:
+        return nil
+    case <-ctx.Done():
+        return fmt.Errorf("notifications still in flight: %w", ctx.Err())
+    }
+}
+
+// handle tracks outages: one starts when a gateway goes offline and ends when it is
+// back online. An outage no channel was told about is dropped when maintenance starts.
+// A gateway becoming degraded is notified right away. The start and end of each outage are
+// recorded before any channel is told, see reconcile.
+func (n *Notifier) handle(ctx context.Context, transition monitor.Transition) {
+    if transition.From == "degraded" {
+        // The end of a degradation leaves the gateway's state, published on its own, alone
+        return
+    }
+    current, ok := n.outages[transition.Gateway]
+    switch transition.To {
+    case "degraded":
+        n.notifyDegraded(ctx, transition)
+    case "offline":
+        // An outage picked up after a restart goes on with its start and the channels told
+        if !ok {
+            n.outages[transition.Gateway] = &outage{since: transition.At, notified: map[string]time.Time{}}
+            n.recordEvent(transition.Gateway, "offline", transition.At, transition.At)
+        }
+    case "online":
+        if !ok {
+            return
+        }
+        delete(n.outages, transition.Gateway)
+        n.recordEvent(transition.Gateway, "online", current.since, transition.At)
+        for _, channel := range n.channels(ctx) {
+            if _, notified := current.notified[channel.name]; notified {
+                n.send(channel, transition.Gateway, "online", current.since, transition.At, false)
+            }
+        }
+    default:
+        if ok && len(current.notified) == 0 {
+            delete(n.outages, transition.Gateway)
+            n.forgetEvent(transition.Gateway, current.since)
+        }
+    }
+}
+
+// eventID identifies the notifications of a gateway going offline, state "offline", or
+// recovering, state "online". It only depends on the gateway, the state and the start of the
+// outage, so the same outage reported again after a restart keeps its events. Transitions
+// combine the checks of a gateway, so no check takes part.
+func eventID(gateway, state string, offlineSince time.Time) string {
+    sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%s\x00%d", gateway, state, offlineSince.UnixMilli())))
+    return hex.EncodeToString(sum[:16])
+}
+
+// recordEvent stores the start or end of an outage before any channel is told about it
+func (n *Notifier) recordEvent(gateway, state string, offlineSince, at time.Time) {
+    if n.History == nil {
+        return
+    }
+    event := history.NotificationEvent{ID: eventID(gateway, state, offlineSince), Gateway: gateway, State: state, OfflineSince: offlineSince, At: at}
+    if err := n.History.RecordNotificationEvent(event); err != nil {
+        n.logger.Printf("WARNING: failed to record the %s notification event of gateway %s: %v", state, gateway, err)
+    }
+}
+
+// forgetEvent deletes an outage that ended without being notified
+func (n *Notifier) forgetEvent(gateway string, offlineSince time.Time) {
+    if n.History == nil {
+        return
+    }
+    if err := n.History.ForgetNotificationEvent(eventID(gateway, "offline", offlineSince)); err != nil {
+        n.logger.Printf("WARNING: failed to forget the outage of gateway %s: %v", gateway, err)
+    }
+}
+
+// reconcile picks up the outages recorded before a restart. Those still going on are tracked
+// again with the channels already told about them, which are not told again; the others are
+// told once the outage lasted their minimum downtime. Recoveries are sent to the channels
+// told about the outage that have no receipt for them. A crash between a delivery and its
+// receipt still repeats that notification.
+func (n *Notifier) reconcile(ctx context.Context) {
+    n.reconciled = true
+    if n.History == nil {
+        return
+    }
+    events, err := n.History.UnfinishedNotificationEvents()
+    if err != nil {
+        n.logger.Printf("WARNING: failed to read the notifications recorded before the restart: %v", err)
+        return
+    }
+    for _, event := range events {
+        switch event.State {
+        case "offline":
+            n.outages[event.Gateway] = &outage{since: event.OfflineSince, notified: event.Delivered}
+        case "online":
+            told, err := n.History.Deliveries(eventID(event.Gateway, "offline", event.OfflineSince))
+            if err != nil {
+                n.logger.Printf("WARNING: failed to read the notifications of the outage of gateway %s: %v", event.Gateway, err)
+                continue
+            }
+            for _, channel := range n.channels(ctx) {
+                _, notified := told[channel.name]
+                _, recovered := event.Delivered[channel.name]
+                if notified && !recovered {
+                    n.send(channel, event.Gateway, "online", event.OfflineSince, event.At, false)
+                }
+            }
+        }
+    }
+    if len(events) > 0 {
+        n.logger.Printf("Picked up %d notification events recorded before the restart", len(events))
+    }
+}
+
+// notifyPending tells each channel about the outages that lasted its minimum downtime, and
+// again about those still going on once its repeat interval passed. Gateways in maintenance
+// or silenced are left alone until it ends.
+func (n *Notifier) notifyPending(ctx context.Context) {
+    channels := n.channels(ctx)
+    gatewaysFile, _ := n.monitor.Current()
+    now := n.clock.Now()
+    for name, current := range n.outages {
+        gateway, ok := n.monitor.Gateway(name)
+        if !ok {
+            delete(n.outages, name)
+            n.forgetEvent(name, current.since)
+            continue
+        }
+        if n.monitor.InMaintenance(gateway, now) {
+            continue
+        }
+        for _, channel := range channels {
+            if !channel.routes(gateway) {
+                continue
+            }
+            after, repeat := channel.timing(gatewaysFile, gateway)
+            last, notified := current.notified[channel.name]
+            switch {
+            case !notified && now.Sub(current.since) >= after:
+            case notified && repeat > 0 && now.Sub(last) >= repeat:
+            default:
+                continue
+            }
+            current.notified[channel.name] = now
+            n.send(channel, name, "offline", current.since, now, notified)
+        }
+    }
+}
+
+// send delivers the notification of an outage starting, state "offline", or ending in the
+// background so slow channels don't hold up the transitions, and records its receipt
+func (n *Notifier) send(channel channel, name, state string, since, at time.Time, reminder bool) {
+    gateway, ok := n.monitor.Gateway(name)
+    if !ok {
+        return
+    }
+
+    n.dispatch(channel, gateway, eventID(name, state, since), Notification{
+        Gateway:  gateway.Name,
+        Project:  gateway.Project,
+        State:    state,
+        Since:    since,
+        At:       at,
+        Downtime: at.Sub(since).Round(time.Second),
+        Reminder: reminder,
+        Status:   n.monitor.GatewayStatus(gateway),
+    })
+}
+
+// notifyDegraded tells the channels routing a gateway that its field rules are violated,
+// unless it is in maintenance
+func (n *Notifier) notifyDegraded(ctx context.Context, transition monitor.Transition) {
+    gateway, ok := n.monitor.Gateway(transition.Gateway)
+    if !ok || n.monitor.InMaintenance(gateway, transition.At) {
+        return
+    }
+    status := n.monitor.GatewayStatus(gateway)
+    for _, channel := range n.channels(ctx) {
+        if channel.routes(gateway) {
+            n.dispatch(channel, gateway, "", Notification{
+                Gateway: gateway.Name,
+                Project: gateway.Project,
+                State:   "degraded",
+                Since:   transition.At,
+                At:      transition.At,
+                Reason:  transition.Reason,
+                Status:  status,
+            })
+        }
+    }
+}
+
+// notifyOverdue tells the channels routing a gateway once when its status updates become
+// overdue while it is online, if the configuration asks for it. Offline gateways are left
+// to the outage notifications.
+func (n *Notifier) notifyOverdue(ctx context.Context) {
+    gatewaysFile, _ := n.monitor.Current()
+    if gatewaysFile.Notifications == nil || !gatewaysFile.Notifications.UpdateGapAnomalies {
+        return
+    }
+    channels := n.channels(ctx)
+    now := n.clock.Now()
+    overdue := map[string]bool{}
+    for _, gateway := range n.monitor.Gateways() {
+        status := n.monitor.GatewayStatus(gateway)
+        if status.State != "online" || len(status.UpdateGapAnomalies) == 0 {
+            continue
+        }
+        overdue[gateway.Name] = true
+        if n.overdue[gateway.Name] {
+            continue
+        }
+        for _, channel := range channels {
+            if channel.routes(gateway) {
+                n.dispatch(channel, gateway, "", Notification{
+                    Gateway: gateway.Name,
+                    Project: gateway.Project,
+                    State:   "degrading",
+                    Since:   now,
+                    At:      now,
+                    Reason:  strings.Join(status.UpdateGapAnomalies, "; "),
+                    Status:  status,
+                })
+            }
+        }
+    }
+    n.overdue = overdue
+}
+
+// dispatch sends a notification through the channel in the background. Once it is delivered
+// the receipt of the event is recorded, when there is one.
+func (n *Notifier) dispatch(channel channel, gateway config.Gateway, event string, notification Notification) {
+    n.inFlight.Add(1)
+    go func() {
+        defer n.inFlight.Done()
+        if err := channel.send(gateway, notification); err != nil {
+            n.logger.Printf("WARNING: failed to send %s notification for gateway %s: %v", channel.name, gateway.Name, err)
+            return
+        }
+        if event == "" || n.History == nil {
+            return
+        }
+        if err := n.History.RecordDelivery(event, channel.name, notification.At); err != nil {
+            n.logger.Printf("WARNING: failed to record the delivery of the %s notification for gateway %s: %v", channel.name, gateway.Name, err)
+        }
+    }()
+}
+
+// channels returns the email and chat channels of the running configuration
+func (n *Notifier) channels(ctx context.Context) []channel {
+    gatewaysFile, _ := n.monitor.Current()
+    notifications := gatewaysFile.Notifications
+    if notifications == nil {
+        return nil
+    }
+
+    var channels []channel
+    if email := notifications.Email; email != nil && n.SMTP != nil {
+        minDowntime, _ := email.MinDowntimeDuration()
+        repeat, _ := email.RepeatInterval()
+        channels = append(channels, channel{
+            name:        "email",
+            kind:        "email",
+            minDowntime: minDowntime,
+            repeat:      repeat,
+            routes:      func(config.Gateway) bool { return true },
+            send: func(gateway config.Gateway, notification Notification) error {
+                return n.sendEmail(gatewaysFile, *email, gateway, notification)
+            },
+        })
+    }
+    for i, chat := range notifications.Chats {
+        chat := chat
+        minDowntime, _ := chat.MinDowntimeDuration()
+        repeat, _ := chat.RepeatInterval()
+        channels = append(channels, channel{
+            name:        fmt.Sprintf("%s chat %d", chat.Type, i+1),
+            kind:        chat.Type,
+            minDowntime: minDowntime,
+            repeat:      repeat,
+            routes:      chat.Routes,
+            send: func(gateway config.Gateway, notification Notification) error {
+                return n.sendChat(ctx, chat, notification)
+            },
+        })
+    }
+    return channels
+}
@@ -0,0 +1,155 @@
+package notify
+
+import (
+    "context"
+    "encoding/json"
+    "io"
+    "log"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/prometheus/client_golang/prometheus"
+
+    "gateway-monitor/internal/checker"
+    "gateway-monitor/internal/clock"
+    "gateway-monitor/internal/config"
+    "gateway-monitor/internal/metrics"
+    "gateway-monitor/internal/monitor"
+)
+
+var testTime = time.Date(2026, 1, 5, 12, 0, 0, 0, time.UTC)
+
+// newTestNotifier returns a Notifier on a fake clock for a monitor of gw1, posting to a
+// Slack chat whose messages arrive on the returned channel
+func newTestNotifier(t *testing.T, chat config.ChatNotification) (*Notifier, *monitor.Monitor, *clock.Fake, <-chan string) {
+    t.Helper()
+    messages := make(chan string, 10)
+    slack := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        var payload map[string]string
+        json.NewDecoder(r.Body).Decode(&payload)
+        messages <- payload["text"]
+    }))
+    t.Cleanup(slack.Close)
+
+    clk := clock.NewFake(testTime)
+    logger := log.New(io.Discard, "", 0)
+    m := metrics.New(prometheus.NewRegistry())
+    forwarders := checker.NewForwarders(m, clk, logger)
+    mon := monitor.New(monitor.Options{
+        Checker:    checker.New(&http.Client{Timeout: time.Second}, forwarders, checker.NewSubscriptions(m, clk, logger), m, clk, logger),
+        Forwarders: forwarders,
+        Metrics:    m,
+        Clock:      clk,
+        Logger:     logger,
+        Interval:   time.Minute,
+    })
+
+    chat.Type, chat.WebhookURL = "slack", slack.URL
+    gatewaysFile := &config.GatewaysFile{
+        Gateways:      []config.Gateway{{Name: "gw1", Project: "p", Checks: []config.Check{{Type: "http", URL: "http://127.0.0.1:1/status"}}}},
+        Notifications: &config.Notifications{Chats: []config.ChatNotification{chat}},
+    }
+    if err := mon.Apply(gatewaysFile, config.Source{Kind: "file"}); err != nil {
+        t.Fatal(err)
+    }
+    return New(mon, &http.Client{Timeout: time.Second}, clk, logger), mon, clk, messages
+}
+
+// delivered waits for the notifications in flight and returns the messages posted so far
+func delivered(t *testing.T, n *Notifier, messages <-chan string) []string {
+    t.Helper()
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+    if err := n.Flush(ctx); err != nil {
+        t.Fatal(err)
+    }
+    var posted []string
+    for len(messages) > 0 {
+        posted = append(posted, <-messages)
+    }
+    return posted
+}
+
+func TestDegradedTransitionIsNotified(t *testing.T) {
+    n, mon, _, messages := newTestNotifier(t, config.ChatNotification{})
+    ctx := context.Background()
+
+    n.handle(ctx, monitor.Transition{Gateway: "gw1", From: "online", To: "degraded", At: testTime, Reason: "uplinks has not increased in 30m0s"})
+    posted := delivered(t, n, messages)
+    if len(posted) != 1 || !strings.Contains(posted[0], "is degraded since") || !strings.Contains(posted[0], "uplinks has not increased in 30m0s") {
+        t.Fatalf("got messages %q, want one degraded notification with its reason", posted)
+    }
+    if len(n.outages) != 0 {
+        t.Error("a degraded gateway started an outage")
+    }
+
+    n.handle(ctx, monitor.Transition{Gateway: "gw1", From: "degraded", To: "online", At: testTime.Add(time.Minute)})
+    if posted := delivered(t, n, messages); len(posted) != 0 {
+        t.Errorf("the end of the degradation sent %q", posted)
+    }
+
+    mon.Silence("gw1", time.Hour, "antenna replacement")
+    n.handle(ctx, monitor.Transition{Gateway: "gw1", From: "online", To: "degraded", At: testTime, Reason: "rssi is -130, below minimum -120"})
+    if posted := delivered(t, n, messages); len(posted) != 0 {
+        t.Errorf("a silenced gateway's degradation sent %q", posted)
+    }
+}
+
+func TestRemindersPauseWhileSilenced(t *testing.T) {
+    n, mon, clk, messages := newTestNotifier(t, config.ChatNotification{Repeat: "30m"})
+    ctx := context.Background()
+
+    n.handle(ctx, monitor.Transition{Gateway: "gw1", From: "online", To: "offline", At: testTime})
+    n.notifyPending(ctx)
+    if posted := delivered(t, n, messages); len(posted) != 1 || !strings.Contains(posted[0], "is offline since") {
+        t.Fatalf("got messages %q, want the offline notification", posted)
+    }
+
+    mon.Silence("gw1", 90*time.Minute, "planned outage")
+    for i := 0; i < 2; i++ {
+        clk.Advance(30 * time.Minute)
+        n.notifyPending(ctx)
+        if posted := delivered(t, n, messages); len(posted) != 0 {
+            t.Fatalf("reminder %q sent while the gateway is silenced", posted)
+        }
+    }
+
+    // The silence ended, the reminder is due since the last one was sent an hour ago
+    clk.Advance(31 * time.Minute)
+    n.notifyPending(ctx)
+    if posted := delivered(t, n, messages); len(posted) != 1 || !strings.Contains(posted[0], "is still offline") {
+        t.Fatalf("got messages %q after the silence, want a reminder", posted)
+    }
+    clk.Advance(time.Minute)
+    n.notifyPending(ctx)
+    if posted := delivered(t, n, messages); len(posted) != 0 {
+        t.Errorf("reminder %q sent before the repeat interval passed", posted)
+    }
+}
+
+func TestRemindersPauseDuringMaintenance(t *testing.T) {
+    n, mon, clk, messages := newTestNotifier(t, config.ChatNotification{Repeat: "30m"})
+    ctx := context.Background()
+    gatewaysFile, source := mon.Current()
+    edited := *gatewaysFile
+    edited.Gateways = []config.Gateway{gatewaysFile.Gateways[0]}
+    start, end := testTime.Add(10*time.Minute), testTime.Add(2*time.Hour)
+    edited.Gateways[0].Maintenance = []config.MaintenanceWindow{{Start: &start, End: &end}}
+    if err := mon.Apply(&edited, source); err != nil {
+        t.Fatal(err)
+    }
+
+    n.handle(ctx, monitor.Transition{Gateway: "gw1", From: "online", To: "offline", At: testTime})
+    n.notifyPending(ctx)
+    if posted := delivered(t, n, messages); len(posted) != 1 {
+        t.Fatalf("got messages %q, want the offline notification", posted)
+    }
+    clk.Advance(time.Hour)
+    n.notifyPending(ctx)
+    if posted := delivered(t, n, messages); len(posted) != 0 {
+        t.Errorf("reminder %q sent during the maintenance window", posted)
+    }
+}
@@ -0,0 +1,157 @@
+package notify
+
+import (
+    "context"
+    "io"
+    "log"
+    "net/http"
+    "net/http/httptest"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+
+    "gateway-monitor/internal/config"
+    "gateway-monitor/internal/history"
+    "gateway-monitor/internal/monitor"
+)
+
+// withHistory keeps the notifications of n in a database of its own
+func withHistory(t *testing.T, n *Notifier) {
+    t.Helper()
+    store, err := history.Open(filepath.Join(t.TempDir(), "history.db"), 0, n.clock, log.New(io.Discard, "", 0))
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { store.Close() })
+    n.History = store
+}
+
+// restart simulates a crash of n once its deliveries in flight are done: the new Notifier
+// only knows what the history recorded
+func restart(t *testing.T, n *Notifier) *Notifier {
+    t.Helper()
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+    if err := n.Flush(ctx); err != nil {
+        t.Fatal(err)
+    }
+    restarted := New(n.monitor, n.client, n.clock, n.logger)
+    restarted.History = n.History
+    restarted.reconcile(context.Background())
+    return restarted
+}
+
+// postTo points the chat of the monitor's configuration to url
+func postTo(t *testing.T, mon *monitor.Monitor, url string) {
+    t.Helper()
+    gatewaysFile, source := mon.Current()
+    edited := *gatewaysFile
+    notifications := *gatewaysFile.Notifications
+    notifications.Chats = []config.ChatNotification{notifications.Chats[0]}
+    notifications.Chats[0].WebhookURL = url
+    edited.Notifications = &notifications
+    if err := mon.Apply(&edited, source); err != nil {
+        t.Fatal(err)
+    }
+}
+
+func TestEventIDIsDeterministic(t *testing.T) {
+    offline := eventID("gw1", "offline", testTime)
+    if offline != eventID("gw1", "offline", testTime.Add(100*time.Microsecond)) {
+        t.Error("the event ID depends on more than the recorded millisecond of the outage start")
+    }
+    for _, other := range []string{eventID("gw2", "offline", testTime), eventID("gw1", "online", testTime), eventID("gw1", "offline", testTime.Add(time.Second))} {
+        if other == offline {
+            t.Errorf("different events share the ID %s", offline)
+        }
+    }
+}
+
+// The outage was recorded but the process crashed before it was notified
+func TestRestartSendsUndeliveredOutage(t *testing.T) {
+    n, _, clk, messages := newTestNotifier(t, config.ChatNotification{MinDowntime: "5m"})
+    withHistory(t, n)
+    ctx := context.Background()
+
+    n.handle(ctx, monitor.Transition{Gateway: "gw1", From: "online", To: "offline", At: testTime})
+    clk.Advance(2 * time.Minute)
+    n = restart(t, n)
+
+    // The restarted monitor reports the gateway offline again, the outage keeps its start
+    n.handle(ctx, monitor.Transition{Gateway: "gw1", From: "unknown", To: "offline", At: clk.Now()})
+    n.notifyPending(ctx)
+    if posted := delivered(t, n, messages); len(posted) != 0 {
+        t.Fatalf("got messages %q before the minimum downtime passed", posted)
+    }
+    clk.Advance(3 * time.Minute)
+    n.notifyPending(ctx)
+    if posted := delivered(t, n, messages); len(posted) != 1 || !strings.Contains(posted[0], "is offline since") {
+        t.Fatalf("got messages %q, want the offline notification once", posted)
+    }
+}
+
+// The outage was notified and the process crashed before the gateway recovered
+func TestRestartDoesNotRepeatDeliveredOutage(t *testing.T) {
+    n, _, clk, messages := newTestNotifier(t, config.ChatNotification{})
+    withHistory(t, n)
+    ctx := context.Background()
+
+    n.handle(ctx, monitor.Transition{Gateway: "gw1", From: "online", To: "offline", At: testTime})
+    n.notifyPending(ctx)
+    if posted := delivered(t, n, messages); len(posted) != 1 {
+        t.Fatalf("got messages %q, want the offline notification", posted)
+    }
+    n = restart(t, n)
+
+    clk.Advance(time.Minute)
+    n.handle(ctx, monitor.Transition{Gateway: "gw1", From: "unknown", To: "offline", At: clk.Now()})
+    n.notifyPending(ctx)
+    if posted := delivered(t, n, messages); len(posted) != 0 {
+        t.Fatalf("the outage was notified again after the restart: %q", posted)
+    }
+
+    clk.Advance(time.Minute)
+    n.handle(ctx, monitor.Transition{Gateway: "gw1", From: "offline", To: "online", At: clk.Now()})
+    if posted := delivered(t, n, messages); len(posted) != 1 || !strings.Contains(posted[0], "online") {
+        t.Fatalf("got messages %q, want the recovery", posted)
+    }
+    n = restart(t, n)
+    if posted := delivered(t, n, messages); len(posted) != 0 {
+        t.Errorf("the recovery was sent again after the restart: %q", posted)
+    }
+}
+
+// The recovery was recorded but not delivered before the process crashed
+func TestRestartSendsUndeliveredRecovery(t *testing.T) {
+    n, mon, clk, messages := newTestNotifier(t, config.ChatNotification{})
+    withHistory(t, n)
+    ctx := context.Background()
+    working, _ := mon.Current()
+    workingURL := working.Notifications.Chats[0].WebhookURL
+
+    n.handle(ctx, monitor.Transition{Gateway: "gw1", From: "online", To: "offline", At: testTime})
+    n.notifyPending(ctx)
+    if posted := delivered(t, n, messages); len(posted) != 1 {
+        t.Fatalf("got messages %q, want the offline notification", posted)
+    }
+
+    failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        w.WriteHeader(http.StatusServiceUnavailable)
+    }))
+    defer failing.Close()
+    postTo(t, mon, failing.URL)
+    clk.Advance(time.Minute)
+    n.handle(ctx, monitor.Transition{Gateway: "gw1", From: "offline", To: "online", At: clk.Now()})
+
+    postTo(t, mon, workingURL)
+    n = restart(t, n)
+    posted := delivered(t, n, messages)
+    if len(posted) != 1 || !strings.Contains(posted[0], "online") || !strings.Contains(posted[0], "1m0s") {
+        t.Fatalf("got messages %q, want the recovery after a minute of downtime", posted)
+    }
+    n = restart(t, n)
+    if posted := delivered(t, n, messages); len(posted) != 0 {
+        t.Errorf("the recovery was sent again after the second restart: %q", posted)
+    }
+}
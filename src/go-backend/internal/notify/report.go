@@ -0,0 +1,101 @@
+package notify
+
+import (
+    "bytes"
+    "fmt"
+    "sort"
+    "strings"
+    "text/tabwriter"
+    "time"
+
+    "gateway-monitor/internal/config"
+    "gateway-monitor/internal/history"
+)
+
+// sendMonthlyReports mails the availability of the previous month once the month is over.
+// The history remembers which months were reported so restarts don't send them twice.
+func (n *Notifier) sendMonthlyReports() {
+    if n.History == nil || n.SMTP == nil {
+        return
+    }
+    gatewaysFile, _ := n.monitor.Current()
+    if gatewaysFile.Notifications == nil || gatewaysFile.Notifications.Email == nil || !gatewaysFile.Notifications.Email.MonthlyReport {
+        return
+    }
+
+    now := n.clock.Now()
+    to := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+    from := to.AddDate(0, -1, 0)
+    month := from.Format("2006-01")
+    if n.reported == month {
+        return
+    }
+    n.reported = month
+
+    fresh, err := n.History.MarkReported("monthly-" + month)
+    if err != nil {
+        n.logger.Printf("WARNING: failed to record the monthly report for %s: %v", month, err)
+        return
+    }
+    if !fresh {
+        return
+    }
+    uptimes, err := n.History.Uptime(from, to)
+    if err != nil {
+        n.logger.Printf("WARNING: failed to compute the monthly report for %s: %v", month, err)
+        return
+    }
+    if len(uptimes) == 0 {
+        return
+    }
+
+    // One report per project, sent to the project's recipients
+    projects := map[string][]config.Gateway{}
+    for _, gateway := range n.monitor.Gateways() {
+        projects[gateway.Project] = append(projects[gateway.Project], gateway)
+    }
+    for project, gateways := range projects {
+        recipients := gatewaysFile.ProjectEmailRecipients(project)
+        if len(recipients) == 0 {
+            continue
+        }
+        title := "gateways without a project"
+        if project != "" {
+            title = "project " + project
+        }
+        subject := fmt.Sprintf("[LoRaCheck] Availability of %s in %s", title, from.Format("January 2006"))
+        body := reportBody(title, from, to, gateways, uptimes)
+        n.inFlight.Add(1)
+        go func() {
+            defer n.inFlight.Done()
+            if err := n.mail(recipients, subject, now, body); err != nil {
+                n.logger.Printf("WARNING: failed to send the monthly report of %s: %v", title, err)
+                return
+            }
+            n.logger.Printf("Sent the monthly report of %s to %s", title, strings.Join(recipients, ", "))
+        }()
+    }
+}
+
+// reportBody lists the availability, outages and downtime of each gateway
+func reportBody(title string, from, to time.Time, gateways []config.Gateway, uptimes map[string]*history.Uptime) []byte {
+    sort.Slice(gateways, func(i, j int) bool { return gateways[i].Name < gateways[j].Name })
+
+    var body bytes.Buffer
+    fmt.Fprintf(&body, "Availability of %s from %s to %s\r\n\r\n", title, from.Format("2006-01-02"), to.Format("2006-01-02"))
+    table := tabwriter.NewWriter(&body, 0, 0, 2, ' ', 0)
+    fmt.Fprint(table, "GATEWAY\tAVAILABILITY\tOUTAGES\tDOWNTIME\r\n")
+    for _, gateway := range gateways {
+        availability, outages, downtime := "no data", 0, time.Duration(0)
+        if uptime, ok := uptimes[gateway.Name]; ok {
+            if uptime.AvailabilityPercent != nil {
+                availability = fmt.Sprintf("%.3f%%", *uptime.AvailabilityPercent)
+            }
+            outages = uptime.Outages
+            downtime = time.Duration(uptime.DowntimeSeconds * float64(time.Second)).Round(time.Second)
+        }
+        fmt.Fprintf(table, "%s\t%s\t%d\t%s\r\n", gateway.Name, availability, outages, downtime)
+    }
+    table.Flush()
+    return body.Bytes()
+}
@@ -0,0 +1,48 @@
+package notify
+
+import (
+    "bytes"
+    "context"
+    "fmt"
+
+    "gateway-monitor/internal/monitor"
+)
+
+// sendWarning tells the administrators about a warning of the monitor: the default email
+// recipients and the chats that are not limited to some projects
+func (n *Notifier) sendWarning(ctx context.Context, warning monitor.Warning) {
+    gatewaysFile, _ := n.monitor.Current()
+    notifications := gatewaysFile.Notifications
+    if notifications == nil {
+        return
+    }
+
+    if email := notifications.Email; email != nil && n.SMTP != nil && len(email.To) > 0 {
+        to := email.To
+        var body bytes.Buffer
+        fmt.Fprintf(&body, "LoRaCheck warning: %s.\r\n", warning.Message)
+        n.deliverWarning("email", func() error {
+            return n.mail(to, "[LoRaCheck] "+warning.Subject, warning.At, body.Bytes())
+        })
+    }
+    for i, chat := range notifications.Chats {
+        if len(chat.Projects) > 0 {
+            continue
+        }
+        chat := chat
+        n.deliverWarning(fmt.Sprintf("%s chat %d", chat.Type, i+1), func() error {
+            return n.postChat(ctx, chat, fmt.Sprintf("LoRaCheck warning: %s", warning.Message))
+        })
+    }
+}
+
+// deliverWarning runs a warning delivery in the background
+func (n *Notifier) deliverWarning(channel string, send func() error) {
+    n.inFlight.Add(1)
+    go func() {
+        defer n.inFlight.Done()
+        if err := send(); err != nil {
+            n.logger.Printf("WARNING: failed to send %s warning: %v", channel, err)
+        }
+    }()
+}
@@ -0,0 +1,140 @@
+package notify
+
+import (
+    "bytes"
+    "context"
+    "crypto/hmac"
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "time"
+
+    "gateway-monitor/internal/config"
+    "gateway-monitor/internal/monitor"
+)
+
+// SignatureHeader carries the hex HMAC-SHA256 of the request body, prefixed with "sha256="
+const SignatureHeader = "X-LoRaCheck-Signature"
+
+// webhookBackoff is the delay before the first retry of a failed delivery; it doubles with every retry
+const webhookBackoff = 1 * time.Second
+
+// WebhookPayload is the JSON body posted to webhooks
+type WebhookPayload struct {
+    Gateway string         `json:"gateway"`
+    Project string         `json:"project,omitempty"`
+    Checks  []WebhookCheck `json:"checks"`
+    // From and To are "online", "offline" or "maintenance", or "degraded" while the
+    // gateway's field rules are violated
+    From string    `json:"from"`
+    To   string    `json:"to"`
+    At   time.Time `json:"at"`
+}
+
+// WebhookCheck identifies a check of the gateway
+type WebhookCheck struct {
+    Type   string `json:"type"`
+    Target string `json:"target"`
+}
+
+// sendWebhooks posts the transition to every configured webhook. A gateway's first check
+// is not a transition and is not delivered.
+func (n *Notifier) sendWebhooks(ctx context.Context, transition monitor.Transition) {
+    if transition.From == "unknown" {
+        return
+    }
+    gateway, ok := n.monitor.Gateway(transition.Gateway)
+    if !ok {
+        return
+    }
+    gatewaysFile, _ := n.monitor.Current()
+    webhooks := gatewaysFile.Webhooks(gateway)
+    if len(webhooks) == 0 {
+        return
+    }
+
+    payload := WebhookPayload{
+        Gateway: gateway.Name,
+        Project: gateway.Project,
+        Checks:  []WebhookCheck{},
+        From:    transition.From,
+        To:      transition.To,
+        At:      transition.At,
+    }
+    for _, check := range gateway.Checks {
+        payload.Checks = append(payload.Checks, WebhookCheck{Type: check.Type, Target: check.Target()})
+    }
+    body, err := json.Marshal(payload)
+    if err != nil {
+        n.logger.Printf("WARNING: failed to encode webhook payload for gateway %s: %v", gateway.Name, err)
+        return
+    }
+
+    for _, webhook := range webhooks {
+        n.inFlight.Add(1)
+        go func(webhook config.WebhookNotification) {
+            defer n.inFlight.Done()
+            if err := n.deliver(ctx, webhook, body); err != nil {
+                n.logger.Printf("WARNING: failed to deliver webhook for gateway %s to %s: %v", gateway.Name, webhook.URL, err)
+            }
+        }(webhook)
+    }
+}
+
+// deliver posts body, retrying with exponential backoff on connection errors, 429 and 5xx responses
+func (n *Notifier) deliver(ctx context.Context, webhook config.WebhookNotification, body []byte) error {
+    var signature string
+    if secret := webhook.ResolveSecret(); secret != "" {
+        mac := hmac.New(sha256.New, []byte(secret))
+        mac.Write(body)
+        signature = "sha256=" + hex.EncodeToString(mac.Sum(nil))
+    }
+
+    delay := webhookBackoff
+    for attempt := 0; ; attempt++ {
+        retry, err := n.post(ctx, webhook, body, signature)
+        if err == nil {
+            return nil
+        }
+        if !retry || attempt >= webhook.DeliveryRetries() {
+            return err
+        }
+
+        n.logger.Printf("Webhook delivery to %s failed, retrying in %s: %v", webhook.URL, delay, err)
+        select {
+        case <-ctx.Done():
+            return ctx.Err()
+        case <-n.clock.After(delay):
+        }
+        delay *= 2
+    }
+}
+
+// post makes a single delivery attempt and reports whether a failure is worth retrying
+func (n *Notifier) post(ctx context.Context, webhook config.WebhookNotification, body []byte, signature string) (bool, error) {
+    req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+    if err != nil {
+        return false, err
+    }
+    req.Header.Set("Content-Type", "application/json")
+    req.Header.Set("User-Agent", "LoRaCheck")
+    for name, value := range webhook.Headers {
+        req.Header.Set(name, value)
+    }
+    if signature != "" {
+        req.Header.Set(SignatureHeader, signature)
+    }
+
+    resp, err := n.client.Do(req)
+    if err != nil {
+        return true, err
+    }
+    resp.Body.Close()
+    if resp.StatusCode < 200 || resp.StatusCode > 299 {
+        retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+        return retry, fmt.Errorf("unexpected status %s", resp.Status)
+    }
+    return false, nil
+}
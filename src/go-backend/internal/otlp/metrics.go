@@ -0,0 +1,204 @@
+package otlp
+
+import (
+    "context"
+    "fmt"
+    "math"
+    "strconv"
+
+    dto "github.com/prometheus/client_model/go"
+)
+
+// aggregationCumulative is AGGREGATION_TEMPORALITY_CUMULATIVE, matching Prometheus counters
+const aggregationCumulative = 2
+
+type metricsRequest struct {
+    ResourceMetrics []resourceMetrics `json:"resourceMetrics"`
+}
+
+type resourceMetrics struct {
+    Resource     resource       `json:"resource"`
+    ScopeMetrics []scopeMetrics `json:"scopeMetrics"`
+}
+
+type scopeMetrics struct {
+    Scope   scope    `json:"scope"`
+    Metrics []metric `json:"metrics"`
+}
+
+type metric struct {
+    Name        string     `json:"name"`
+    Description string     `json:"description,omitempty"`
+    Gauge       *gauge     `json:"gauge,omitempty"`
+    Sum         *sum       `json:"sum,omitempty"`
+    Histogram   *histogram `json:"histogram,omitempty"`
+    Summary     *summary   `json:"summary,omitempty"`
+}
+
+type gauge struct {
+    DataPoints []numberPoint `json:"dataPoints"`
+}
+
+type sum struct {
+    DataPoints             []numberPoint `json:"dataPoints"`
+    AggregationTemporality int           `json:"aggregationTemporality"`
+    IsMonotonic            bool          `json:"isMonotonic"`
+}
+
+type numberPoint struct {
+    Attributes        []attribute `json:"attributes,omitempty"`
+    StartTimeUnixNano string      `json:"startTimeUnixNano,omitempty"`
+    TimeUnixNano      string      `json:"timeUnixNano"`
+    AsDouble          float64     `json:"asDouble"`
+}
+
+type histogram struct {
+    DataPoints             []histogramPoint `json:"dataPoints"`
+    AggregationTemporality int              `json:"aggregationTemporality"`
+}
+
+type histogramPoint struct {
+    Attributes        []attribute `json:"attributes,omitempty"`
+    StartTimeUnixNano string      `json:"startTimeUnixNano"`
+    TimeUnixNano      string      `json:"timeUnixNano"`
+    Count             string      `json:"count"`
+    Sum               float64     `json:"sum"`
+    BucketCounts      []string    `json:"bucketCounts"`
+    ExplicitBounds    []float64   `json:"explicitBounds"`
+}
+
+type summary struct {
+    DataPoints []summaryPoint `json:"dataPoints"`
+}
+
+type summaryPoint struct {
+    Attributes        []attribute     `json:"attributes,omitempty"`
+    StartTimeUnixNano string          `json:"startTimeUnixNano"`
+    TimeUnixNano      string          `json:"timeUnixNano"`
+    Count             string          `json:"count"`
+    Sum               float64         `json:"sum"`
+    QuantileValues    []quantileValue `json:"quantileValues"`
+}
+
+type quantileValue struct {
+    Quantile float64 `json:"quantile"`
+    Value    float64 `json:"value"`
+}
+
+// Push exports the current metrics, so the exporter can run after every cycle like the other pushers
+func (e *Exporter) Push(ctx context.Context) error {
+    if e.MetricsEndpoint == "" {
+        return nil
+    }
+    families, err := e.gatherer.Gather()
+    if err != nil {
+        return fmt.Errorf("gathering metrics: %w", err)
+    }
+
+    var metrics []metric
+    for _, family := range families {
+        metrics = append(metrics, e.convert(family))
+    }
+    req := metricsRequest{ResourceMetrics: []resourceMetrics{{
+        Resource:     e.resource(),
+        ScopeMetrics: []scopeMetrics{{Scope: scope{Name: scopeName}, Metrics: metrics}},
+    }}}
+    if err := e.post(ctx, e.MetricsEndpoint, req); err != nil {
+        return fmt.Errorf("OTLP metrics: %w", err)
+    }
+    return nil
+}
+
+// convert maps a Prometheus metric family to its OTLP equivalent: gauges and untyped metrics
+// to gauges, counters to cumulative monotonic sums, histograms and summaries as they are.
+// NaN and infinite values are left out since JSON cannot encode them.
+func (e *Exporter) convert(family *dto.MetricFamily) metric {
+    now := nanos(e.clock.Now())
+    start := nanos(e.startedAt)
+    out := metric{Name: family.GetName(), Description: family.GetHelp()}
+
+    for _, m := range family.GetMetric() {
+        attributes := make([]attribute, 0, len(m.GetLabel()))
+        for _, pair := range m.GetLabel() {
+            attributes = append(attributes, stringAttribute(pair.GetName(), pair.GetValue()))
+        }
+
+        switch family.GetType() {
+        case dto.MetricType_COUNTER:
+            if out.Sum == nil {
+                out.Sum = &sum{AggregationTemporality: aggregationCumulative, IsMonotonic: true}
+            }
+            if !finite(m.GetCounter().GetValue()) {
+                continue
+            }
+            out.Sum.DataPoints = append(out.Sum.DataPoints, numberPoint{
+                Attributes: attributes, StartTimeUnixNano: start, TimeUnixNano: now, AsDouble: m.GetCounter().GetValue(),
+            })
+        case dto.MetricType_GAUGE, dto.MetricType_UNTYPED:
+            if out.Gauge == nil {
+                out.Gauge = &gauge{}
+            }
+            value := m.GetGauge().GetValue()
+            if family.GetType() == dto.MetricType_UNTYPED {
+                value = m.GetUntyped().GetValue()
+            }
+            if !finite(value) {
+                continue
+            }
+            out.Gauge.DataPoints = append(out.Gauge.DataPoints, numberPoint{Attributes: attributes, TimeUnixNano: now, AsDouble: value})
+        case dto.MetricType_HISTOGRAM:
+            if out.Histogram == nil {
+                out.Histogram = &histogram{AggregationTemporality: aggregationCumulative}
+            }
+            h := m.GetHistogram()
+            point := histogramPoint{
+                Attributes:        attributes,
+                StartTimeUnixNano: start,
+                TimeUnixNano:      now,
+                Count:             strconv.FormatUint(h.GetSampleCount(), 10),
+                Sum:               h.GetSampleSum(),
+                ExplicitBounds:    []float64{},
+            }
+            // OTLP buckets are not cumulative and end with an implicit +Inf bucket
+            var previous uint64
+            for _, bucket := range h.GetBucket() {
+                if math.IsInf(bucket.GetUpperBound(), 1) {
+                    break
+                }
+                point.ExplicitBounds = append(point.ExplicitBounds, bucket.GetUpperBound())
+                point.BucketCounts = append(point.BucketCounts, strconv.FormatUint(bucket.GetCumulativeCount()-previous, 10))
+                previous = bucket.GetCumulativeCount()
+            }
+            point.BucketCounts = append(point.BucketCounts, strconv.FormatUint(h.GetSampleCount()-previous, 10))
+            out.Histogram.DataPoints = append(out.Histogram.DataPoints, point)
+        case dto.MetricType_SUMMARY:
+            if out.Summary == nil {
+                out.Summary = &summary{}
+            }
+            s := m.GetSummary()
+            if !finite(s.GetSampleSum()) {
+                continue
+            }
+            point := summaryPoint{
+                Attributes:        attributes,
+                StartTimeUnixNano: start,
+                TimeUnixNano:      now,
+                Count:             strconv.FormatUint(s.GetSampleCount(), 10),
+                Sum:               s.GetSampleSum(),
+            }
+            for _, q := range s.GetQuantile() {
+                if !finite(q.GetValue()) {
+                    continue
+                }
+                point.QuantileValues = append(point.QuantileValues, quantileValue{Quantile: q.GetQuantile(), Value: q.GetValue()})
+            }
+            out.Summary.DataPoints = append(out.Summary.DataPoints, point)
+        }
+    }
+    return out
+}
+
+// finite reports whether JSON can encode the value
+func finite(v float64) bool {
+    return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
@@ -0,0 +1,231 @@
+// Package otlp exports the metrics and, optionally, a trace of every check cycle to an
+// OpenTelemetry collector over OTLP/HTTP with JSON encoding. It is configured with the
+// standard OTEL_* environment variables.
+package otlp
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "io"
+    "log"
+    "net/http"
+    "net/url"
+    "os"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/prometheus/client_golang/prometheus"
+
+    "gateway-monitor/internal/clock"
+)
+
+// DefaultServiceName is the service.name resource attribute unless OTEL_SERVICE_NAME is set
+const DefaultServiceName = "loracheck"
+
+// scopeName is the instrumentation scope of everything exported
+const scopeName = "gateway-monitor"
+
+// Exporter sends OTLP requests to a collector
+type Exporter struct {
+    // MetricsEndpoint and TracesEndpoint are the full URLs of the OTLP/HTTP signals;
+    // an empty endpoint disables the signal
+    MetricsEndpoint string
+    TracesEndpoint  string
+    // Headers are sent with every request, e.g. for authentication
+    Headers map[string]string
+    // Resource are the attributes identifying this instance, including service.name
+    Resource map[string]string
+    Timeout  time.Duration
+
+    gatherer  prometheus.Gatherer
+    client    *http.Client
+    clock     clock.Clock
+    logger    *log.Logger
+    startedAt time.Time
+}
+
+// NewFromEnv configures the exporter from the OTEL_* environment variables. It returns nil
+// unless OTEL_EXPORTER_OTLP_ENDPOINT or a signal specific endpoint is set. Metrics are exported
+// unless OTEL_METRICS_EXPORTER=none; traces only with OTEL_TRACES_EXPORTER=otlp.
+func NewFromEnv(gatherer prometheus.Gatherer, clk clock.Clock, logger *log.Logger) (*Exporter, error) {
+    if strings.EqualFold(os.Getenv("OTEL_SDK_DISABLED"), "true") {
+        return nil, nil
+    }
+    base := strings.TrimSuffix(os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"), "/")
+    metricsEndpoint := signalEndpoint(base, "OTEL_EXPORTER_OTLP_METRICS_ENDPOINT", "/v1/metrics")
+    tracesEndpoint := signalEndpoint(base, "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "/v1/traces")
+
+    switch exporter := envOrDefault("OTEL_METRICS_EXPORTER", "otlp"); exporter {
+    case "otlp":
+    case "none":
+        metricsEndpoint = ""
+    default:
+        return nil, fmt.Errorf("unsupported OTEL_METRICS_EXPORTER %q, expected otlp or none", exporter)
+    }
+    switch exporter := envOrDefault("OTEL_TRACES_EXPORTER", "none"); exporter {
+    case "otlp":
+    case "none":
+        tracesEndpoint = ""
+    default:
+        return nil, fmt.Errorf("unsupported OTEL_TRACES_EXPORTER %q, expected otlp or none", exporter)
+    }
+    if metricsEndpoint == "" && tracesEndpoint == "" {
+        return nil, nil
+    }
+    for _, key := range []string{"OTEL_EXPORTER_OTLP_PROTOCOL", "OTEL_EXPORTER_OTLP_METRICS_PROTOCOL", "OTEL_EXPORTER_OTLP_TRACES_PROTOCOL"} {
+        if protocol := os.Getenv(key); protocol != "" && protocol != "http/json" {
+            return nil, fmt.Errorf("unsupported %s %q, only http/json is supported", key, protocol)
+        }
+    }
+
+    e := &Exporter{
+        MetricsEndpoint: metricsEndpoint,
+        TracesEndpoint:  tracesEndpoint,
+        Timeout:         10 * time.Second,
+        gatherer:        gatherer,
+        client:          &http.Client{},
+        clock:           clk,
+        logger:          logger,
+    }
+    if clk != nil {
+        e.startedAt = clk.Now()
+    }
+
+    var err error
+    if e.Headers, err = parsePairs(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS")); err != nil {
+        return nil, fmt.Errorf("invalid OTEL_EXPORTER_OTLP_HEADERS: %v", err)
+    }
+    if e.Resource, err = parsePairs(os.Getenv("OTEL_RESOURCE_ATTRIBUTES")); err != nil {
+        return nil, fmt.Errorf("invalid OTEL_RESOURCE_ATTRIBUTES: %v", err)
+    }
+    if name := os.Getenv("OTEL_SERVICE_NAME"); name != "" {
+        e.Resource["service.name"] = name
+    } else if e.Resource["service.name"] == "" {
+        e.Resource["service.name"] = DefaultServiceName
+    }
+    if v := os.Getenv("OTEL_EXPORTER_OTLP_TIMEOUT"); v != "" {
+        ms, err := strconv.Atoi(v)
+        if err != nil || ms <= 0 {
+            return nil, fmt.Errorf("invalid OTEL_EXPORTER_OTLP_TIMEOUT %q, expected milliseconds", v)
+        }
+        e.Timeout = time.Duration(ms) * time.Millisecond
+    }
+    return e, nil
+}
+
+// signalEndpoint returns the signal specific endpoint, which is used as is, or the path
+// appended to the base endpoint
+func signalEndpoint(base, key, path string) string {
+    if endpoint := os.Getenv(key); endpoint != "" {
+        return endpoint
+    }
+    if base == "" {
+        return ""
+    }
+    return base + path
+}
+
+// parsePairs parses the W3C baggage style key=value lists of the OTEL_* variables,
+// whose values may be percent encoded
+func parsePairs(s string) (map[string]string, error) {
+    pairs := map[string]string{}
+    for _, pair := range strings.Split(s, ",") {
+        pair = strings.TrimSpace(pair)
+        if pair == "" {
+            continue
+        }
+        key, value, ok := strings.Cut(pair, "=")
+        key = strings.TrimSpace(key)
+        if !ok || key == "" {
+            return nil, fmt.Errorf("invalid entry %q, expected key=value", pair)
+        }
+        decoded, err := url.PathUnescape(strings.TrimSpace(value))
+        if err != nil {
+            return nil, fmt.Errorf("invalid value of %s: %v", key, err)
+        }
+        pairs[key] = decoded
+    }
+    return pairs, nil
+}
+
+// resource returns the resource of every request
+func (e *Exporter) resource() resource {
+    keys := make([]string, 0, len(e.Resource))
+    for key := range e.Resource {
+        keys = append(keys, key)
+    }
+    sort.Strings(keys)
+    var r resource
+    for _, key := range keys {
+        r.Attributes = append(r.Attributes, stringAttribute(key, e.Resource[key]))
+    }
+    return r
+}
+
+// post sends an OTLP/HTTP JSON request
+func (e *Exporter) post(ctx context.Context, endpoint string, body interface{}) error {
+    data, err := json.Marshal(body)
+    if err != nil {
+        return err
+    }
+
+    ctx, cancel := context.WithTimeout(ctx, e.Timeout)
+    defer cancel()
+    req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(data))
+    if err != nil {
+        return err
+    }
+    for key, value := range e.Headers {
+        req.Header.Set(key, value)
+    }
+    req.Header.Set("Content-Type", "application/json")
+
+    resp, err := e.client.Do(req)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+        msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+        return fmt.Errorf("unexpected HTTP status %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+    }
+    return nil
+}
+
+// nanos formats a time the way OTLP JSON encodes fixed64 timestamps
+func nanos(t time.Time) string {
+    return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+type resource struct {
+    Attributes []attribute `json:"attributes"`
+}
+
+type scope struct {
+    Name string `json:"name"`
+}
+
+type attribute struct {
+    Key   string         `json:"key"`
+    Value attributeValue `json:"value"`
+}
+
+type attributeValue struct {
+    StringValue string `json:"stringValue"`
+}
+
+func stringAttribute(key, value string) attribute {
+    return attribute{Key: key, Value: attributeValue{StringValue: value}}
+}
+
+// envOrDefault returns the environment variable or a fallback when it is unset
+func envOrDefault(key, fallback string) string {
+    if v := os.Getenv(key); v != "" {
+        return v
+    }
+    return fallback
+}
@@ -0,0 +1,110 @@
+package otlp
+
+import (
+    "context"
+    "crypto/rand"
+    "encoding/hex"
+    "fmt"
+
+    "gateway-monitor/internal/monitor"
+)
+
+// Span kinds and status codes of the OTLP trace protocol
+const (
+    spanKindInternal = 1
+    spanKindClient   = 3
+    statusOK         = 1
+    statusError      = 2
+)
+
+type tracesRequest struct {
+    ResourceSpans []resourceSpans `json:"resourceSpans"`
+}
+
+type resourceSpans struct {
+    Resource   resource     `json:"resource"`
+    ScopeSpans []scopeSpans `json:"scopeSpans"`
+}
+
+type scopeSpans struct {
+    Scope scope  `json:"scope"`
+    Spans []span `json:"spans"`
+}
+
+type span struct {
+    TraceID           string      `json:"traceId"`
+    SpanID            string      `json:"spanId"`
+    ParentSpanID      string      `json:"parentSpanId,omitempty"`
+    Name              string      `json:"name"`
+    Kind              int         `json:"kind"`
+    StartTimeUnixNano string      `json:"startTimeUnixNano"`
+    EndTimeUnixNano   string      `json:"endTimeUnixNano"`
+    Attributes        []attribute `json:"attributes,omitempty"`
+    Status            spanStatus  `json:"status"`
+}
+
+type spanStatus struct {
+    Code    int    `json:"code"`
+    Message string `json:"message,omitempty"`
+}
+
+// TraceCycle exports the cycle as a trace with a root span and a child span per check
+func (e *Exporter) TraceCycle(ctx context.Context, cycle monitor.Cycle) error {
+    if e.TracesEndpoint == "" {
+        return nil
+    }
+
+    traceID := randomID(16)
+    rootID := randomID(8)
+    spans := []span{{
+        TraceID:           traceID,
+        SpanID:            rootID,
+        Name:              "check cycle",
+        Kind:              spanKindInternal,
+        StartTimeUnixNano: nanos(cycle.Start),
+        EndTimeUnixNano:   nanos(cycle.End),
+        Attributes: []attribute{
+            stringAttribute("loracheck.gateways", fmt.Sprint(cycle.Gateways)),
+            stringAttribute("loracheck.checks", fmt.Sprint(len(cycle.Checks))),
+        },
+        Status: spanStatus{Code: statusOK},
+    }}
+    for _, check := range cycle.Checks {
+        status := spanStatus{Code: statusOK}
+        if check.Result.Error != "" {
+            status = spanStatus{Code: statusError, Message: check.Result.Error}
+        }
+        spans = append(spans, span{
+            TraceID:           traceID,
+            SpanID:            randomID(8),
+            ParentSpanID:      rootID,
+            Name:              check.Check.Type + " check",
+            Kind:              spanKindClient,
+            StartTimeUnixNano: nanos(check.Result.CheckedAt.Add(-check.Result.Duration)),
+            EndTimeUnixNano:   nanos(check.Result.CheckedAt),
+            Attributes: []attribute{
+                stringAttribute("loracheck.gateway", check.Gateway),
+                stringAttribute("loracheck.check.type", check.Check.Type),
+                stringAttribute("loracheck.check.target", check.Check.Target()),
+                stringAttribute("loracheck.check.status", check.Result.Status),
+            },
+            Status: status,
+        })
+    }
+
+    req := tracesRequest{ResourceSpans: []resourceSpans{{
+        Resource:   e.resource(),
+        ScopeSpans: []scopeSpans{{Scope: scope{Name: scopeName}, Spans: spans}},
+    }}}
+    if err := e.post(ctx, e.TracesEndpoint, req); err != nil {
+        return fmt.Errorf("OTLP traces: %w", err)
+    }
+    return nil
+}
+
+// randomID returns a random trace or span ID of n bytes, hex encoded as OTLP JSON expects
+func randomID(n int) string {
+    id := make([]byte, n)
+    rand.Read(id)
+    return hex.EncodeToString(id)
+}
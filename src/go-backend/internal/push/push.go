@@ -0,0 +1,124 @@
+// Package push sends the metrics to Prometheus via remote_write or to a Pushgateway after
+// every check cycle, for sites where Prometheus cannot scrape into the gateway network.
+package push
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "net/http"
+    "sort"
+    "strings"
+
+    "github.com/prometheus/client_golang/prometheus"
+    "github.com/prometheus/client_golang/prometheus/push"
+
+    "gateway-monitor/internal/clock"
+)
+
+// DefaultJob is the job label of the metrics pushed to a Pushgateway
+const DefaultJob = "loracheck"
+
+// Target is where and how the metrics are pushed
+type Target struct {
+    // RemoteWriteURL is a Prometheus remote_write endpoint, e.g. https://prometheus/api/v1/write
+    RemoteWriteURL string
+    // PushgatewayURL is the base URL of a Pushgateway
+    PushgatewayURL string
+    // Job is the job the metrics are grouped under in the Pushgateway
+    Job string
+    // Username and Password are sent as basic auth, BearerToken as an Authorization header
+    Username    string
+    Password    string
+    BearerToken string
+    // ExternalLabels are added to every pushed series that doesn't have the label already
+    ExternalLabels map[string]string
+}
+
+// Enabled reports whether any push endpoint is configured
+func (t Target) Enabled() bool {
+    return t.RemoteWriteURL != "" || t.PushgatewayURL != ""
+}
+
+// ParseLabels parses external labels written as name=value pairs separated by commas
+func ParseLabels(s string) (map[string]string, error) {
+    labels := map[string]string{}
+    for _, pair := range strings.Split(s, ",") {
+        pair = strings.TrimSpace(pair)
+        if pair == "" {
+            continue
+        }
+        name, value, ok := strings.Cut(pair, "=")
+        name = strings.TrimSpace(name)
+        if !ok || name == "" {
+            return nil, fmt.Errorf("invalid label %q, expected name=value", pair)
+        }
+        labels[name] = strings.TrimSpace(value)
+    }
+    return labels, nil
+}
+
+// Pusher pushes the gathered metrics to the configured endpoints
+type Pusher struct {
+    target   Target
+    gatherer prometheus.Gatherer
+    client   *http.Client
+    clock    clock.Clock
+}
+
+// New creates a Pusher sending the metrics of gatherer to target
+func New(target Target, gatherer prometheus.Gatherer, client *http.Client, clk clock.Clock) *Pusher {
+    if target.Job == "" {
+        target.Job = DefaultJob
+    }
+    return &Pusher{target: target, gatherer: gatherer, client: client, clock: clk}
+}
+
+// Push sends the current metrics to every configured endpoint. A failing endpoint does
+// not keep the metrics from the others.
+func (p *Pusher) Push(ctx context.Context) error {
+    var errs []error
+    if p.target.RemoteWriteURL != "" {
+        if err := p.remoteWrite(ctx); err != nil {
+            errs = append(errs, fmt.Errorf("remote_write: %w", err))
+        }
+    }
+    if p.target.PushgatewayURL != "" {
+        if err := p.pushgateway(ctx); err != nil {
+            errs = append(errs, fmt.Errorf("Pushgateway: %w", err))
+        }
+    }
+    return errors.Join(errs...)
+}
+
+// pushgateway replaces the metrics of the job's group, grouped by the external labels
+func (p *Pusher) pushgateway(ctx context.Context) error {
+    pusher := push.New(p.target.PushgatewayURL, p.target.Job).
+        Gatherer(p.gatherer).
+        Client(p.client)
+    names := make([]string, 0, len(p.target.ExternalLabels))
+    for name := range p.target.ExternalLabels {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    for _, name := range names {
+        pusher = pusher.Grouping(name, p.target.ExternalLabels[name])
+    }
+    if p.target.Username != "" {
+        pusher = pusher.BasicAuth(p.target.Username, p.target.Password)
+    }
+    if p.target.BearerToken != "" {
+        pusher = pusher.Header(http.Header{"Authorization": {"Bearer " + p.target.BearerToken}})
+    }
+    return pusher.PushContext(ctx)
+}
+
+// authorize adds the configured credentials to a request
+func (p *Pusher) authorize(req *http.Request) {
+    if p.target.Username != "" {
+        req.SetBasicAuth(p.target.Username, p.target.Password)
+    }
+    if p.target.BearerToken != "" {
+        req.Header.Set("Authorization", "Bearer "+p.target.BearerToken)
+    }
+}
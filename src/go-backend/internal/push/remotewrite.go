@@ -0,0 +1,172 @@
+package push
+
+import (
+    "bytes"
+    "context"
+    "fmt"
+    "io"
+    "math"
+    "net/http"
+    "sort"
+    "strconv"
+
+    "github.com/klauspost/compress/snappy"
+    dto "github.com/prometheus/client_model/go"
+    "google.golang.org/protobuf/encoding/protowire"
+)
+
+// series is one sample of a remote_write request
+type series struct {
+    labels    []label
+    value     float64
+    timestamp int64
+}
+
+type label struct {
+    name, value string
+}
+
+// remoteWrite sends the current metrics as a snappy compressed protobuf WriteRequest
+func (p *Pusher) remoteWrite(ctx context.Context) error {
+    families, err := p.gatherer.Gather()
+    if err != nil {
+        return fmt.Errorf("gathering metrics: %w", err)
+    }
+    body := snappy.Encode(nil, encodeWriteRequest(p.series(families)))
+
+    req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.target.RemoteWriteURL, bytes.NewReader(body))
+    if err != nil {
+        return err
+    }
+    req.Header.Set("Content-Encoding", "snappy")
+    req.Header.Set("Content-Type", "application/x-protobuf")
+    req.Header.Set("User-Agent", "LoRaCheck")
+    req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+    p.authorize(req)
+
+    resp, err := p.client.Do(req)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+        msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+        return fmt.Errorf("unexpected HTTP status %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+    }
+    return nil
+}
+
+// series flattens the metric families into samples the way Prometheus would scrape them:
+// histograms and summaries become their _bucket, quantile, _sum and _count series
+func (p *Pusher) series(families []*dto.MetricFamily) []series {
+    now := p.clock.Now().UnixMilli()
+    var out []series
+    for _, family := range families {
+        name := family.GetName()
+        for _, metric := range family.GetMetric() {
+            timestamp := now
+            if metric.TimestampMs != nil {
+                timestamp = metric.GetTimestampMs()
+            }
+            add := func(name string, value float64, extra ...label) {
+                out = append(out, series{labels: p.labels(name, metric.GetLabel(), extra), value: value, timestamp: timestamp})
+            }
+
+            switch family.GetType() {
+            case dto.MetricType_COUNTER:
+                add(name, metric.GetCounter().GetValue())
+            case dto.MetricType_GAUGE:
+                add(name, metric.GetGauge().GetValue())
+            case dto.MetricType_UNTYPED:
+                add(name, metric.GetUntyped().GetValue())
+            case dto.MetricType_SUMMARY:
+                summary := metric.GetSummary()
+                for _, q := range summary.GetQuantile() {
+                    add(name, q.GetValue(), label{"quantile", formatFloat(q.GetQuantile())})
+                }
+                add(name+"_sum", summary.GetSampleSum())
+                add(name+"_count", float64(summary.GetSampleCount()))
+            case dto.MetricType_HISTOGRAM:
+                histogram := metric.GetHistogram()
+                infSeen := false
+                for _, bucket := range histogram.GetBucket() {
+                    if math.IsInf(bucket.GetUpperBound(), 1) {
+                        infSeen = true
+                    }
+                    add(name+"_bucket", float64(bucket.GetCumulativeCount()), label{"le", formatFloat(bucket.GetUpperBound())})
+                }
+                if !infSeen {
+                    add(name+"_bucket", float64(histogram.GetSampleCount()), label{"le", "+Inf"})
+                }
+                add(name+"_sum", histogram.GetSampleSum())
+                add(name+"_count", float64(histogram.GetSampleCount()))
+            }
+        }
+    }
+    return out
+}
+
+// labels returns the sorted labels of a series, with the external labels it doesn't set itself
+func (p *Pusher) labels(name string, pairs []*dto.LabelPair, extra []label) []label {
+    labels := []label{{"__name__", name}}
+    set := map[string]bool{}
+    for _, pair := range pairs {
+        labels = append(labels, label{pair.GetName(), pair.GetValue()})
+        set[pair.GetName()] = true
+    }
+    for _, l := range extra {
+        labels = append(labels, l)
+        set[l.name] = true
+    }
+    for name, value := range p.target.ExternalLabels {
+        if !set[name] {
+            labels = append(labels, label{name, value})
+        }
+    }
+    sort.Slice(labels, func(i, j int) bool { return labels[i].name < labels[j].name })
+    return labels
+}
+
+// formatFloat formats the le and quantile labels like the text exposition format
+func formatFloat(f float64) string {
+    switch {
+    case math.IsInf(f, 1):
+        return "+Inf"
+    case math.IsInf(f, -1):
+        return "-Inf"
+    }
+    return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// encodeWriteRequest encodes the prometheus.WriteRequest message:
+//
+//	WriteRequest { repeated TimeSeries timeseries = 1; }
+//	TimeSeries   { repeated Label labels = 1; repeated Sample samples = 2; }
+//	Label        { string name = 1; string value = 2; }
+//	Sample       { double value = 1; int64 timestamp = 2; }
+func encodeWriteRequest(all []series) []byte {
+    var req []byte
+    for _, s := range all {
+        var ts []byte
+        for _, l := range s.labels {
+            var lb []byte
+            lb = protowire.AppendTag(lb, 1, protowire.BytesType)
+            lb = protowire.AppendString(lb, l.name)
+            lb = protowire.AppendTag(lb, 2, protowire.BytesType)
+            lb = protowire.AppendString(lb, l.value)
+            ts = protowire.AppendTag(ts, 1, protowire.BytesType)
+            ts = protowire.AppendBytes(ts, lb)
+        }
+        var sample []byte
+        sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+        sample = protowire.AppendFixed64(sample, math.Float64bits(s.value))
+        sample = protowire.AppendTag(sample, 2, protowire.VarintType)
+        sample = protowire.AppendVarint(sample, uint64(s.timestamp))
+        ts = protowire.AppendTag(ts, 2, protowire.BytesType)
+        ts = protowire.AppendBytes(ts, sample)
+
+        req = protowire.AppendTag(req, 1, protowire.BytesType)
+        req = protowire.AppendBytes(req, ts)
+    }
+    return req
+}
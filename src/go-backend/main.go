@@ -1,322 +1,366 @@
 package main
 
 import (
-    "encoding/json"
-    "fmt"
-    "io/ioutil"
+    "bytes"
+    "context"
+    "errors"
+    "flag"
+    "io"
     "log"
+    "net"
     "net/http"
     "os"
-    "text/template"
+    "os/signal"
+    "syscall"
     "time"
 
     "github.com/prometheus/client_golang/prometheus"
     "github.com/prometheus/client_golang/prometheus/promhttp"
-)
-
-// Gateway represents the structure of each gateway in the gateways.json file
-type Gateway struct {
-    Name     string `json:"name"`
-    Location struct {
-        Latitude  float64 `json:"latitude"`
-        Longitude float64 `json:"longitude"`
-    } `json:"location"`
-    Checks []struct {
-        Type string `json:"type"`
-        URL  string `json:"url"`
-    } `json:"checks"`
-}
-
-// GatewaysFile represents the JSON structure for gateways.json
-type GatewaysFile struct {
-    Gateways []Gateway `json:"gateways"`
-}
+    "google.golang.org/grpc"
+    "google.golang.org/grpc/credentials"
 
-// Prometheus metrics
-var (
-    gatewayOnlineStatus = prometheus.NewGaugeVec(
-        prometheus.GaugeOpts{
-            Name: "gateway_online_status",
-            Help: "Shows whether the gateway is online: 1 for online, 0 for offline",
-        },
-        []string{"name", "latitude", "longitude"},
-    )
+    "gateway-monitor/internal/api"
+    "gateway-monitor/internal/checker"
+    "gateway-monitor/internal/clock"
+    "gateway-monitor/internal/config"
+    "gateway-monitor/internal/dashboard"
+    "gateway-monitor/internal/discovery"
+    "gateway-monitor/internal/gitsync"
+    "gateway-monitor/internal/grpcapi"
+    "gateway-monitor/internal/history"
+    "gateway-monitor/internal/leader"
+    "gateway-monitor/internal/metrics"
+    "gateway-monitor/internal/monitor"
+    "gateway-monitor/internal/notify"
+    "gateway-monitor/internal/otlp"
+    "gateway-monitor/internal/push"
 )
 
-// Initialize Prometheus metrics
-func init() {
-    prometheus.MustRegister(gatewayOnlineStatus)
+func main() {
+    // Without a subcommand the binary runs the monitor, as it always has
+    if len(os.Args) > 1 {
+        if run, ok := subcommands[os.Args[1]]; ok {
+            os.Exit(run(os.Args[2:]))
+        }
+    }
+    os.Exit(runServe(os.Args[1:]))
 }
 
-// LoadGatewaysConfig loads the gateway configuration from the JSON file
-func LoadGatewaysConfig(filePath string) (*GatewaysFile, error) {
-    data, err := ioutil.ReadFile(filePath)
-    if err != nil {
-        return nil, err
+// runServe implements "serve", the default: it runs the monitor until SIGTERM, or a single
+// cycle with -once
+func runServe(args []string) int {
+    var s settings
+    fs := flag.NewFlagSet("serve", flag.ExitOnError)
+    s.addFlags(fs)
+    s.addServeFlags(fs)
+    once := fs.Bool("once", false, "run a single check cycle, print a summary and exit non-zero if any gateway is offline")
+    jsonOutput := fs.Bool("json", false, "with -once, print the summary as JSON")
+    verbose := fs.Bool("v", false, "with -once, log what the checks do")
+    fs.Parse(args)
+    if err := s.loadEnv(); err != nil {
+        log.Fatal(err)
+    }
+    if *once {
+        return runOnce(s, *jsonOutput, *verbose)
     }
+    serve(s)
+    return 0
+}
+
+// serve runs the monitor and the HTTP server until SIGTERM or SIGINT, then shuts down
+// gracefully: the cycle in progress is finished, pending notifications are delivered and
+// the HTTP server stops once its requests are done
+func serve(s settings) {
+    build := buildInfo()
+    log.Printf("Go-backend %s starting...", buildVersion())
+    startedAt := time.Now()
+    ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+    defer stop()
 
-    var gateways GatewaysFile
-    if err := json.Unmarshal(data, &gateways); err != nil {
-        return nil, err
+    logger := log.New(levelWriter{out: os.Stderr, warnOnly: s.LogLevel == "warn"}, "", log.LstdFlags)
+    clk := clock.Real{}
+    // In scrape mode the metrics are registered with the scraper, which runs a cycle before
+    // collecting them
+    var reg prometheus.Registerer = prometheus.DefaultRegisterer
+    var scraper *monitor.Scraper
+    if s.ScrapeMode {
+        scraper = monitor.NewScraper(clk, s.ScrapeMaxAge, s.ScrapeTimeout)
+        reg = scraper
     }
+    m := metrics.New(reg)
+    m.BuildInfo.WithLabelValues(build.Version, build.Commit, build.BuildDate, build.GoVersion).Set(1)
 
-    return &gateways, nil
-}
+    forwarders := checker.NewForwarders(m, clk, logger)
+    subscriptions := checker.NewSubscriptions(m, clk, logger)
+    c := checker.New(s.checkClient(), forwarders, subscriptions, m, clk, logger)
+    c.MaxStatusAge = s.MaxStatusAge
+    c.RateLimiter = s.rateLimiter(m)
+    c.StatusPolicy = s.StatusPolicy
+    c.CircuitOpenAfter, c.CircuitProbeInterval = s.CircuitOpenAfter, s.CircuitProbeInterval
+    c.UpdateGapFactor = s.UpdateGapFactor
 
-// FetchAndParseGatewayStatus fetches the JSON data from the URL and parses the 'online' status
-func FetchAndParseGatewayStatus(gateway Gateway) bool {
-    for _, check := range gateway.Checks {
-        log.Printf("Fetching data for %s from URL: %s", gateway.Name, check.URL)
-        
-        resp, err := http.Get(check.URL)
-        if err != nil {
-            log.Printf("Failed to fetch data from URL: %s, error: %v", check.URL, err)
-            return false
+    // Optionally keep every check result in a SQLite database
+    var db *history.Store
+    var store monitor.History
+    if s.HistoryDB != "" {
+        var err error
+        if db, err = history.Open(s.HistoryDB, s.HistoryRetention, clk, logger); err != nil {
+            log.Fatalf("Failed to open the history database: %v", err)
         }
-        defer resp.Body.Close()
-
-        log.Printf("Successfully fetched data from URL: %s", check.URL)
+        go db.Run(ctx)
+        store = db
+    }
 
-        body, err := ioutil.ReadAll(resp.Body)
+    // Optionally run the checks and send the notifications on the elected one of several
+    // replicas only; every replica serves the API and /metrics
+    var elector *leader.Elector
+    var lead monitor.Leader
+    m.Leader.Set(1)
+    switch s.LeaderElection {
+    case "sqlite":
+        elector = leader.New(db, s.LeaderLease, s.LeaderID, s.LeaderTTL, m, clk, logger)
+    case "kubernetes":
+        lock, err := leader.NewKubernetes(clk)
         if err != nil {
-            log.Printf("Failed to read response body from URL: %s, error: %v", check.URL, err)
-            return false
+            log.Fatalf("Failed to set up leader election: %v", err)
         }
+        elector = leader.New(lock, s.LeaderLease, s.LeaderID, s.LeaderTTL, m, clk, logger)
+    }
+    if elector != nil {
+        elector.Renew(ctx)
+        go elector.Run(ctx)
+        lead = elector
+    }
 
-        log.Printf("Parsing JSON data for %s...", gateway.Name)
-        var result map[string]interface{}
-        if err := json.Unmarshal(body, &result); err != nil {
-            log.Printf("Failed to parse JSON from URL: %s, error: %v", check.URL, err)
-            return false
-        }
+    // Optionally push the metrics after every cycle
+    var pushers []monitor.Pusher
+    if s.Push.Enabled() {
+        pushers = append(pushers, push.New(s.Push, prometheus.DefaultGatherer, &http.Client{Timeout: 30 * time.Second}, clk))
+    }
 
-        if val, ok := result["online"]; ok {
-            if online, ok := val.(bool); ok {
-                log.Printf("Gateway %s online status: %v", gateway.Name, online)
-                return online
-            }
-        } else if val, ok := result[gateway.Name]; ok {
-            if onlineStatus, ok := val.(map[string]interface{})["online"]; ok {
-                if online, ok := onlineStatus.(bool); ok {
-                    log.Printf("Gateway %s online status: %v", gateway.Name, online)
-                    return online
-                }
+    // Optionally export the metrics and cycle traces to an OpenTelemetry collector
+    var tracer monitor.Tracer
+    exporter, err := otlp.NewFromEnv(prometheus.DefaultGatherer, clk, logger)
+    if err != nil {
+        log.Fatalf("Invalid OpenTelemetry settings: %v", err)
+    }
+    if exporter != nil {
+        if exporter.MetricsEndpoint != "" {
+            if s.ScrapeMode {
+                log.Fatalf("OTLP metrics are exported after each cycle and need CHECK_MODE=loop; set OTEL_METRICS_EXPORTER=none")
             }
+            pushers = append(pushers, exporter)
+        }
+        if exporter.TracesEndpoint != "" {
+            tracer = exporter
         }
-
-        log.Printf("No 'online' status found for %s in the fetched data", gateway.Name)
     }
 
-    return false
-}
+    var dashboards monitor.Dashboards
+    if s.DashboardsDir != "" {
+        dashboards = dashboard.New(s.DashboardsDir, logger)
+    }
 
-// UpdateGatewayStatus updates the Prometheus metrics with the gateway's online status
-func UpdateGatewayStatus(gateway Gateway) {
-    online := FetchAndParseGatewayStatus(gateway)
+    mon := monitor.New(monitor.Options{
+        Checker:       c,
+        Forwarders:    forwarders,
+        Subscriptions: subscriptions,
+        Metrics:       m,
+        Dashboards:    dashboards,
+        History:       store,
+        Pushers:       pushers,
+        Tracer:        tracer,
+        Leader:        lead,
+        Clock:         clk,
+        Logger:        logger,
+        Interval:      s.Interval,
+        Concurrency:   s.Concurrency,
+        Spread:        s.CheckSpread,
+        OfflineAfter:  s.OfflineAfter,
+        OnlineAfter:   s.OnlineAfter,
 
-    gatewayOnlineStatus.With(prometheus.Labels{
-        "name":      gateway.Name,
-        "latitude":  fmt.Sprintf("%f", gateway.Location.Latitude),
-        "longitude": fmt.Sprintf("%f", gateway.Location.Longitude),
-    }).Set(boolToFloat64(online))
+        LocationLabels: s.LocationLabels,
 
-    log.Printf("Updated Prometheus metrics for gateway %s, online status: %v", gateway.Name, online)
-}
+        HeartbeatURL:     s.HeartbeatURL,
+        HeartbeatFailURL: s.HeartbeatFailURL,
+        HeartbeatClient:  &http.Client{},
+        MaxMissedCycles:  s.MaxMissedCycles,
+        DriftWarnAfter:   s.DriftWarnAfter,
+    })
 
-// Convert bool to float64 for Prometheus Gauge
-func boolToFloat64(value bool) float64 {
-    if value {
-        return 1.0
+    loader := config.NewLoader(s.ConfigLocation, &http.Client{Timeout: 30 * time.Second})
+    loader.Token = s.ConfigToken
+    gatewaysFile, err := loader.Load(context.Background())
+    if err != nil {
+        log.Fatalf("Failed to load gateway configuration from %s: %v", s.ConfigLocation, err)
     }
-    return 0.0
-}
 
-// MonitorGateways runs periodically to update the gateway statuses
-func MonitorGateways(gatewaysFile *GatewaysFile) {
-    for {
-        for _, gateway := range gatewaysFile.Gateways {
-            UpdateGatewayStatus(gateway)
-        }
-        time.Sleep(1 * time.Minute)
+    // Generate dashboards and start listeners for the configured gateways
+    if err := mon.Apply(gatewaysFile, loader.Source()); err != nil {
+        log.Fatalf("Failed to apply gateway configuration: %v", err)
     }
-}
 
-// CreateDashboardFile creates the dashboard JSON for each gateway
-func CreateDashboardFile(gateway Gateway) error {
-    const dashboardTemplate = `
-{
-  "id": null,
-  "title": "Gateway Monitor Dashboard",
-  "tags": [],
-  "timezone": "browser",
-  "schemaVersion": 16,
-  "version": 1,
-  "panels": [
-    {
-      "type": "stat",
-      "title": "Gateway Uptime (Last 7 Days)",
-      "targets": [
-        {
-          "expr": "rate(up{job='gateway'}[1w])",
-          "legendFormat": "{{.Name}} uptime",
-          "refId": "A"
+    // Optionally keep the configuration in sync with a Git repository. SIGHUP then syncs
+    // right away, and the configuration loaded above is no longer reloaded since it would
+    // replace the one applied from Git.
+    gitSync, err := gitsync.NewFromEnv(mon, m, clk, logger)
+    if err != nil {
+        log.Fatalf("Invalid Git sync settings: %v", err)
+    }
+    if gitSync != nil {
+        if _, err := gitSync.Sync(); err != nil {
+            log.Printf("Initial Git sync failed, keeping local gateways.json: %v", err)
         }
-      ],
-      "options": {
-        "reduceOptions": {
-          "calcs": ["mean"],
-          "fields": "",
-          "values": false
-        },
-        "orientation": "auto",
-        "textMode": "value"
-      },
-      "datasource": "Prometheus",
-      "fieldConfig": {
-        "defaults": {
-          "thresholds": {
-            "mode": "percentage",
-            "steps": [
-              {"color": "red", "value": 0},
-              {"color": "green", "value": 95}
-            ]
-          }
+        go gitSync.Run(ctx)
+        go gitSync.SyncOnSignal(ctx)
+    } else {
+        // Remote configurations are re-fetched periodically, local ones reloaded when they
+        // change. SIGHUP reloads either immediately.
+        if loader.IsRemote() {
+            go mon.Refresh(ctx, loader, s.ConfigRefresh)
+        } else if s.ConfigWatch > 0 {
+            go mon.Watch(ctx, loader, s.ConfigWatch)
         }
-      },
-      "gridPos": {
-        "h": 6,
-        "w": 8,
-        "x": 0,
-        "y": 0
-      }
-    },
-    {
-      "type": "status-history",
-      "title": "Gateway Status History",
-      "targets": [
-        {
-          "expr": "up{job='gateway', instance='{{.Name}}'}",
-          "refId": "A"
+        go mon.ReloadOnSignal(ctx, loader)
+    }
+
+    // Merge gateways discovered from The Things Stack when the configuration enables it
+    go mon.Discover(ctx, discovery.New(&http.Client{Timeout: 30 * time.Second}))
+
+    // Send the notifications configured in the gateway configuration. The notifier outlives
+    // ctx so it still hears about the transitions of the last cycle.
+    notifier := notify.New(mon, &http.Client{Timeout: 30 * time.Second}, clk, logger)
+    notifier.SMTP = s.SMTP
+    notifier.History = db
+    notifyCtx, stopNotifier := context.WithCancel(context.Background())
+    notifierDone := make(chan struct{})
+    go func() {
+        notifier.Run(notifyCtx)
+        close(notifierDone)
+    }()
+
+    // Start monitoring the gateways in the background, or on each scrape in scrape mode
+    monitorDone := make(chan struct{})
+    if scraper != nil {
+        scraper.Monitor = mon
+        prometheus.MustRegister(scraper)
+        go scraper.Refresh()
+        close(monitorDone)
+    } else {
+        go func() {
+            mon.Run(ctx)
+            close(monitorDone)
+        }()
+    }
+
+    // Expose Prometheus metrics and the JSON API
+    mux := http.NewServeMux()
+    mux.Handle("/metrics", promhttp.Handler())
+    server := api.New(mon, gitSync, logger, startedAt)
+    server.AdminToken = s.AdminToken
+    server.MaxSilence = s.SilenceMaxDuration
+    server.History = db
+    server.Register(mux)
+    server.Auth = s.HTTPAuth
+    server.Build = build
+    httpServer := &http.Server{Addr: s.ListenAddr, Handler: server.Protect(mux), TLSConfig: s.serverTLS()}
+    if s.TLSCertFile != "" {
+        if _, err := httpServer.TLSConfig.GetCertificate(nil); err != nil {
+            log.Fatalf("Invalid TLS certificate: %v", err)
         }
-      ],
-      "options": {
-        "reduceOptions": {
-          "calcs": ["lastNotNull"],
-          "fields": "",
-          "values": false
-        },
-        "orientation": "auto",
-        "showValue": "auto"
-      },
-      "fieldConfig": {
-        "defaults": {
-          "thresholds": {
-            "steps": [
-              {"color": "red", "value": 0},
-              {"color": "orange", "value": 1},
-              {"color": "green", "value": 2}
-            ]
-          }
+    }
+    go func() {
+        var err error
+        if httpServer.TLSConfig != nil {
+            log.Printf("Serving HTTPS on %s", s.ListenAddr)
+            err = httpServer.ListenAndServeTLS("", "")
+        } else {
+            err = httpServer.ListenAndServe()
         }
-      },
-      "datasource": "Prometheus",
-      "gridPos": {
-        "h": 6,
-        "w": 8,
-        "x": 8,
-        "y": 0
-      }
-    },
-    {
-      "type": "geomap",
-      "title": "Gateway Geomap",
-      "targets": [
-        {
-          "expr": "up{job='gateway', instance='{{.Name}}'}",
-          "refId": "A"
+        if err != nil && !errors.Is(err, http.ErrServerClosed) {
+            log.Fatal(err)
         }
-      ],
-      "fieldConfig": {
-        "defaults": {
-          "thresholds": {
-            "steps": [
-              {"color": "red", "value": 0},
-              {"color": "orange", "value": 1},
-              {"color": "green", "value": 2}
-            ]
-          }
+    }()
+
+    // Optionally serve the gRPC API, with the TLS configuration of the HTTP server
+    var grpcServer *grpc.Server
+    if s.GRPCListenAddr != "" {
+        grpcAPI := grpcapi.New(mon, logger)
+        grpcAPI.AdminToken = s.AdminToken
+        grpcAPI.Auth = s.HTTPAuth
+        grpcAPI.MaxSilence = s.SilenceMaxDuration
+        options := grpcAPI.Options()
+        if httpServer.TLSConfig != nil {
+            options = append(options, grpc.Creds(credentials.NewTLS(httpServer.TLSConfig)))
         }
-      },
-      "options": {
-        "layers": [
-          {
-            "type": "point",
-            "url": "",
-            "label": "Gateways",
-            "source": {
-              "type": "table"
-            },
-            "colorField": "value"
-          }
-        ],
-        "mapView": {
-          "lat": {{.Location.Latitude}},
-          "lon": {{.Location.Longitude}},
-          "zoom": 6
+        grpcServer = grpc.NewServer(options...)
+        grpcAPI.Register(grpcServer)
+        listener, err := net.Listen("tcp", s.GRPCListenAddr)
+        if err != nil {
+            log.Fatalf("Failed to listen for gRPC on %s: %v", s.GRPCListenAddr, err)
         }
-      },
-      "gridPos": {
-        "h": 10,
-        "w": 16,
-        "x": 0,
-        "y": 6
-      }
+        log.Printf("Serving gRPC on %s", s.GRPCListenAddr)
+        go func() {
+            if err := grpcServer.Serve(listener); err != nil {
+                log.Fatal(err)
+            }
+        }()
     }
-  ]
-}
-`
-    log.Printf("Creating dashboard for %s", gateway.Name)
 
-    filePath := fmt.Sprintf("/var/lib/grafana/dashboards/%s-dashboard.json", gateway.Name)
-    file, err := os.Create(filePath)
-    if err != nil {
-        return fmt.Errorf("failed to create dashboard file: %v", err)
-    }
-    defer file.Close()
+    <-ctx.Done()
+    stop()
+    log.Printf("Shutting down, waiting up to %s", s.ShutdownTimeout)
+    server.MarkShuttingDown()
+    shutdownCtx, cancel := context.WithTimeout(context.Background(), s.ShutdownTimeout)
+    defer cancel()
 
-    tmpl, err := template.New("dashboard").Parse(dashboardTemplate)
-    if err != nil {
-        return fmt.Errorf("failed to parse template: %v", err)
+    select {
+    case <-monitorDone:
+    case <-shutdownCtx.Done():
+        log.Printf("WARNING: the check cycle in progress did not finish in time")
     }
-
-    if err := tmpl.Execute(file, gateway); err != nil {
-        return fmt.Errorf("failed to execute template: %v", err)
+    stopNotifier()
+    <-notifierDone
+    if err := notifier.Flush(shutdownCtx); err != nil {
+        log.Printf("WARNING: %v", err)
     }
-
-    log.Printf("Dashboard created for %s", gateway.Name)
-    return nil
-}
-
-func main() {
-    log.Println("Go-backend starting...")
-
-    gatewaysFile, err := LoadGatewaysConfig("config/gateways.json")
-    if err != nil {
-        log.Fatalf("Failed to load gateways.json: %v", err)
+    if elector != nil {
+        elector.Release(shutdownCtx)
     }
-
-    // Generate dashboards for each gateway
-    for _, gateway := range gatewaysFile.Gateways {
-        if err := CreateDashboardFile(gateway); err != nil {
-            log.Fatalf("Error creating dashboard for %s: %v", gateway.Name, err)
+    if err := httpServer.Shutdown(shutdownCtx); err != nil {
+        log.Printf("WARNING: HTTP server did not shut down cleanly: %v", err)
+    }
+    if grpcServer != nil {
+        // GracefulStop waits for open WatchTransitions streams, which only end with the client
+        stopped := make(chan struct{})
+        go func() {
+            grpcServer.GracefulStop()
+            close(stopped)
+        }()
+        select {
+        case <-stopped:
+        case <-shutdownCtx.Done():
+            grpcServer.Stop()
+        }
+    }
+    if db != nil {
+        if err := db.Close(); err != nil {
+            log.Printf("WARNING: failed to close the history database: %v", err)
         }
     }
+    log.Println("Shut down")
+}
 
-    // Start monitoring the gateways in the background
-    go MonitorGateways(gatewaysFile)
+// levelWriter drops the log lines below the configured level. Warnings are the lines
+// logged with the "WARNING: " prefix; everything else is informational.
+type levelWriter struct {
+    out      io.Writer
+    warnOnly bool
+}
 
-    // Expose Prometheus metrics
-    http.Handle("/metrics", promhttp.Handler())
-    log.Fatal(http.ListenAndServe(":9100", nil)) // Serve metrics on port 9100
-		
+func (w levelWriter) Write(p []byte) (int, error) {
+    if w.warnOnly && !bytes.Contains(p, []byte("WARNING: ")) {
+        return len(p), nil
+    }
+    return w.out.Write(p)
 }
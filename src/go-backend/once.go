@@ -0,0 +1,114 @@
+package main
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "io"
+    "log"
+    "net/http"
+    "os"
+    "strings"
+    "text/tabwriter"
+    "time"
+
+    "github.com/prometheus/client_golang/prometheus"
+
+    "gateway-monitor/internal/checker"
+    "gateway-monitor/internal/clock"
+    "gateway-monitor/internal/config"
+    "gateway-monitor/internal/metrics"
+    "gateway-monitor/internal/monitor"
+)
+
+// runOnce runs a single check cycle over every gateway, prints a summary as a table or JSON
+// and returns 0 when every gateway is online or in maintenance, 1 when any is offline and
+// 2 on errors. Nothing is served, notified or recorded. udp-forwarder and mqtt checks only
+// see the traffic that arrives during the cycle, so they usually report no status.
+func runOnce(s settings, jsonOutput, verbose bool) int {
+    logger := log.New(io.Discard, "", 0)
+    if verbose {
+        logger = log.New(os.Stderr, "", log.LstdFlags)
+    }
+    clk := clock.Real{}
+    m := metrics.New(prometheus.NewRegistry())
+    forwarders := checker.NewForwarders(m, clk, logger)
+    subscriptions := checker.NewSubscriptions(m, clk, logger)
+    c := checker.New(s.checkClient(), forwarders, subscriptions, m, clk, logger)
+    c.MaxStatusAge = s.MaxStatusAge
+    c.RateLimiter = s.rateLimiter(m)
+    c.StatusPolicy = s.StatusPolicy
+
+    mon := monitor.New(monitor.Options{
+        Checker:       c,
+        Forwarders:    forwarders,
+        Subscriptions: subscriptions,
+        Metrics:       m,
+        Clock:         clk,
+        Logger:        logger,
+        Interval:      s.Interval,
+        Concurrency:   s.Concurrency,
+        OfflineAfter:  1,
+        OnlineAfter:   1,
+    })
+
+    loader := config.NewLoader(s.ConfigLocation, &http.Client{Timeout: 30 * time.Second})
+    loader.Token = s.ConfigToken
+    gatewaysFile, err := loader.Load(context.Background())
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Failed to load gateway configuration from %s: %v\n", s.ConfigLocation, err)
+        return exitError
+    }
+    if err := mon.Apply(gatewaysFile, loader.Source()); err != nil {
+        fmt.Fprintf(os.Stderr, "Failed to apply gateway configuration: %v\n", err)
+        return exitError
+    }
+    if !mon.RunCycle(context.Background()) {
+        fmt.Fprintf(os.Stderr, "The check cycle took longer than the %s interval, some gateways were skipped\n", s.Interval)
+    }
+
+    statuses := mon.Status()
+    result := exitOnline
+    for _, status := range statuses {
+        if status.State != "online" && status.State != "maintenance" {
+            result = exitOffline
+        }
+    }
+
+    if jsonOutput {
+        encoder := json.NewEncoder(os.Stdout)
+        encoder.SetIndent("", "  ")
+        if err := encoder.Encode(statuses); err != nil {
+            fmt.Fprintln(os.Stderr, err)
+            return exitError
+        }
+        return result
+    }
+
+    table := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+    fmt.Fprintln(table, "GATEWAY\tPROJECT\tSTATE\tCHECKS\tERRORS")
+    offline := 0
+    for _, status := range statuses {
+        online := 0
+        var errors []string
+        for _, check := range status.Checks {
+            if check.Status == "online" {
+                online++
+            }
+            if check.LastError != "" {
+                errors = append(errors, check.Type+": "+check.LastError)
+            }
+        }
+        if status.State != "online" && status.State != "maintenance" {
+            offline++
+        }
+        project := status.Project
+        if project == "" {
+            project = "-"
+        }
+        fmt.Fprintf(table, "%s\t%s\t%s\t%d/%d online\t%s\n", status.Name, project, status.State, online, len(status.Checks), strings.Join(errors, "; "))
+    }
+    table.Flush()
+    fmt.Printf("%d of %d gateways offline\n", offline, len(statuses))
+    return result
+}
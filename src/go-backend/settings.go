@@ -0,0 +1,336 @@
+package main
+
+import (
+    "flag"
+    "fmt"
+    "net"
+    "net/http"
+    "net/url"
+    "os"
+    "strconv"
+    "strings"
+    "time"
+
+    "gateway-monitor/internal/api"
+    "gateway-monitor/internal/checker"
+    "gateway-monitor/internal/config"
+    "gateway-monitor/internal/dashboard"
+    "gateway-monitor/internal/metrics"
+    "gateway-monitor/internal/notify"
+    "gateway-monitor/internal/push"
+)
+
+// defaultConfigPath is the gateway configuration baked into the image
+const defaultConfigPath = "config/gateways.json"
+
+// settings are the process-wide options taken from flags and environment variables
+type settings struct {
+    ConfigLocation string
+    ConfigToken    string
+    ConfigRefresh  time.Duration
+    // ConfigWatch is how often a local configuration is checked for changes; zero disables it
+    ConfigWatch  time.Duration
+    Interval     time.Duration
+    CheckTimeout time.Duration
+    // CheckProxy is the proxy used by HTTP based checks unless they set their own,
+    // taking precedence over HTTPS_PROXY and HTTP_PROXY
+    CheckProxy  *url.URL
+    Concurrency int
+    // RateLimit and RateLimitPerHost cap the requests per second of HTTP based checks in total
+    // and to each host, with RateLimitHosts overriding the per-host limit; zero is unlimited
+    RateLimit        float64
+    RateLimitPerHost float64
+    RateLimitHosts   map[string]float64
+    // StatusPolicy combines the results of the checks of gateways without a status_policy
+    StatusPolicy string
+    // CircuitOpenAfter is how long a check may fail before it is only probed every
+    // CircuitProbeInterval; zero disables the circuit breaker
+    CircuitOpenAfter     time.Duration
+    CircuitProbeInterval time.Duration
+    // UpdateGapFactor is how many times its usual interval a check's status source may go
+    // without an update before the gap counts as an anomaly; zero disables the detection
+    UpdateGapFactor float64
+    // CheckSpread staggers the checks over this much of each cycle; zero starts them all at once
+    CheckSpread time.Duration
+    // OfflineAfter and OnlineAfter are how many consecutive check results change a gateway's status
+    OfflineAfter    int
+    OnlineAfter     int
+    MaxMissedCycles int
+    // ShutdownTimeout bounds the graceful shutdown on SIGTERM
+    ShutdownTimeout time.Duration
+    // ScrapeMode runs the checks when /metrics is scraped instead of every Interval,
+    // reusing the results for ScrapeMaxAge and waiting at most ScrapeTimeout for a cycle
+    ScrapeMode       bool
+    ScrapeMaxAge     time.Duration
+    ScrapeTimeout    time.Duration
+    HeartbeatURL     string
+    HeartbeatFailURL string
+    AdminToken       string
+    // SilenceMaxDuration is the longest silence the API creates
+    SilenceMaxDuration time.Duration
+    LocationLabels     bool
+    DriftWarnAfter     time.Duration
+    // MaxStatusAge applies to http checks without a max_age of their own; zero disables it
+    MaxStatusAge time.Duration
+    // HistoryDB is the SQLite database check results are stored in; empty disables the history
+    HistoryDB        string
+    HistoryRetention time.Duration
+    // LeaderElection is "sqlite" or "kubernetes" to run the checks on one of several
+    // replicas only, the holder of the LeaderLease; empty disables it
+    LeaderElection string
+    LeaderLease    string
+    LeaderID       string
+    LeaderTTL      time.Duration
+    // Push is where the metrics are pushed after every cycle, if anywhere
+    Push push.Target
+    // ListenAddr is the address of the HTTP server; Port replaces its port when set
+    ListenAddr string
+    Port       string
+    // GRPCListenAddr is the address of the gRPC server; empty disables it
+    GRPCListenAddr string
+    // LogLevel is "info" to log everything or "warn" to log only warnings
+    LogLevel string
+    // DashboardsDir is where the Grafana dashboards are generated; empty disables them
+    DashboardsDir string
+    // TLSCertFile and TLSKeyFile, or ACMEDomains for Let's Encrypt, serve HTTPS instead of HTTP
+    TLSCertFile      string
+    TLSKeyFile       string
+    ACMEDomains      []string
+    ACMEEmail        string
+    ACMECacheDir     string
+    ACMEDirectoryURL string
+    // HTTPAuth protects every endpoint except the probes when set
+    HTTPAuth api.Auth
+    // SMTP is the mail server for email notifications, nil unless SMTP_HOST is set
+    SMTP *notify.SMTP
+
+    // interval is the -interval flag, parsed into Interval by loadEnv
+    interval string
+}
+
+// addFlags registers the flags shared by the server and the subcommands
+func (s *settings) addFlags(fs *flag.FlagSet) {
+    fs.StringVar(&s.ConfigLocation, "config", envOrDefault("GATEWAYS_CONFIG", defaultConfigPath),
+        "gateway configuration: a JSON or YAML file, a directory of fragments, an http(s) URL, s3://bucket/key, gs://bucket/object or configmap://[namespace/]name[/key] (env GATEWAYS_CONFIG)")
+    fs.BoolVar(&s.LocationLabels, "location-labels", true,
+        "keep the latitude and longitude labels on gateway_online_status in addition to the gateway_latitude and gateway_longitude gauges")
+    fs.StringVar(&s.interval, "interval", envOrDefault("CHECK_INTERVAL", "1m"), "how often every gateway is checked (env CHECK_INTERVAL)")
+}
+
+// addServeFlags registers the flags that only apply to the server
+func (s *settings) addServeFlags(fs *flag.FlagSet) {
+    fs.StringVar(&s.ListenAddr, "listen", envOrDefault("LISTEN_ADDR", ":9100"), "address of the HTTP server serving /metrics and the API (env LISTEN_ADDR)")
+    fs.StringVar(&s.Port, "port", os.Getenv("PORT"), "port of the HTTP server, replacing the port of -listen (env PORT)")
+    fs.StringVar(&s.GRPCListenAddr, "grpc-listen", os.Getenv("GRPC_LISTEN_ADDR"), "address of the gRPC API server, empty to not serve it (env GRPC_LISTEN_ADDR)")
+    fs.StringVar(&s.LogLevel, "log-level", envOrDefault("LOG_LEVEL", "info"), "info to log everything, warn to log only warnings (env LOG_LEVEL)")
+    fs.StringVar(&s.DashboardsDir, "dashboards-dir", envOrDefault("DASHBOARDS_DIR", dashboard.DefaultDir),
+        "directory the Grafana dashboards are generated in, empty to not generate them (env DASHBOARDS_DIR)")
+}
+
+// loadEnv reads the settings that only come from the environment
+func (s *settings) loadEnv() error {
+    s.ConfigToken = os.Getenv("GATEWAYS_CONFIG_TOKEN")
+    s.HeartbeatURL = os.Getenv("HEARTBEAT_URL")
+    s.HeartbeatFailURL = os.Getenv("HEARTBEAT_FAIL_URL")
+    s.AdminToken = os.Getenv("API_ADMIN_TOKEN")
+    s.DriftWarnAfter = 15 * time.Minute
+
+    var err error
+    if s.Interval, err = time.ParseDuration(s.interval); err != nil || s.Interval <= 0 {
+        return fmt.Errorf("invalid interval: %q", s.interval)
+    }
+    if s.ConfigRefresh, err = time.ParseDuration(envOrDefault("GATEWAYS_CONFIG_REFRESH", "5m")); err != nil {
+        return fmt.Errorf("invalid GATEWAYS_CONFIG_REFRESH: %v", err)
+    }
+    if s.ConfigWatch, err = time.ParseDuration(envOrDefault("GATEWAYS_CONFIG_WATCH", "10s")); err != nil {
+        return fmt.Errorf("invalid GATEWAYS_CONFIG_WATCH: %v", err)
+    }
+    if s.CheckTimeout, err = time.ParseDuration(envOrDefault("CHECK_TIMEOUT", "30s")); err != nil {
+        return fmt.Errorf("invalid CHECK_TIMEOUT: %v", err)
+    }
+    if proxy := os.Getenv("CHECK_PROXY"); proxy != "" {
+        if s.CheckProxy, err = url.Parse(proxy); err != nil || s.CheckProxy.Host == "" {
+            return fmt.Errorf("invalid CHECK_PROXY: %q", proxy)
+        }
+    }
+    if s.MaxStatusAge, err = time.ParseDuration(envOrDefault("MAX_STATUS_AGE", "0s")); err != nil {
+        return fmt.Errorf("invalid MAX_STATUS_AGE: %v", err)
+    }
+    if s.Concurrency, err = strconv.Atoi(envOrDefault("CHECK_CONCURRENCY", "10")); err != nil || s.Concurrency < 1 {
+        return fmt.Errorf("invalid CHECK_CONCURRENCY: %q", os.Getenv("CHECK_CONCURRENCY"))
+    }
+    if s.RateLimit, err = strconv.ParseFloat(envOrDefault("RATE_LIMIT", "0"), 64); err != nil || s.RateLimit < 0 {
+        return fmt.Errorf("invalid RATE_LIMIT: %q", os.Getenv("RATE_LIMIT"))
+    }
+    if s.RateLimitPerHost, err = strconv.ParseFloat(envOrDefault("RATE_LIMIT_PER_HOST", "0"), 64); err != nil || s.RateLimitPerHost < 0 {
+        return fmt.Errorf("invalid RATE_LIMIT_PER_HOST: %q", os.Getenv("RATE_LIMIT_PER_HOST"))
+    }
+    if s.RateLimitHosts, err = checker.ParseHostLimits(os.Getenv("RATE_LIMIT_HOSTS")); err != nil {
+        return fmt.Errorf("invalid RATE_LIMIT_HOSTS: %v", err)
+    }
+    if s.StatusPolicy = envOrDefault("STATUS_POLICY", config.PolicyFirst); !config.ValidPolicy(s.StatusPolicy) {
+        return fmt.Errorf("invalid STATUS_POLICY: %q, expected first, any or all", s.StatusPolicy)
+    }
+    if s.CircuitOpenAfter, err = time.ParseDuration(envOrDefault("CIRCUIT_OPEN_AFTER", "0s")); err != nil || s.CircuitOpenAfter < 0 {
+        return fmt.Errorf("invalid CIRCUIT_OPEN_AFTER: %q", os.Getenv("CIRCUIT_OPEN_AFTER"))
+    }
+    if s.CircuitProbeInterval, err = time.ParseDuration(envOrDefault("CIRCUIT_PROBE_INTERVAL", "15m")); err != nil || s.CircuitProbeInterval <= 0 {
+        return fmt.Errorf("invalid CIRCUIT_PROBE_INTERVAL: %q", os.Getenv("CIRCUIT_PROBE_INTERVAL"))
+    }
+    if s.UpdateGapFactor, err = strconv.ParseFloat(envOrDefault("UPDATE_GAP_ANOMALY_FACTOR", "3"), 64); err != nil || (s.UpdateGapFactor != 0 && s.UpdateGapFactor <= 1) {
+        return fmt.Errorf("invalid UPDATE_GAP_ANOMALY_FACTOR: %q, expected 0 or a factor above 1", os.Getenv("UPDATE_GAP_ANOMALY_FACTOR"))
+    }
+    if s.CheckSpread, err = time.ParseDuration(envOrDefault("CHECK_SPREAD", "0s")); err != nil || s.CheckSpread < 0 || s.CheckSpread >= s.Interval {
+        return fmt.Errorf("invalid CHECK_SPREAD: %q, expected a duration shorter than the %s interval", os.Getenv("CHECK_SPREAD"), s.Interval)
+    }
+    if host := os.Getenv("SMTP_HOST"); host != "" {
+        s.SMTP = &notify.SMTP{
+            Host:     host,
+            Username: os.Getenv("SMTP_USERNAME"),
+            Password: os.Getenv("SMTP_PASSWORD"),
+            From:     envOrDefault("SMTP_FROM", os.Getenv("SMTP_USERNAME")),
+        }
+        if s.SMTP.Port, err = strconv.Atoi(envOrDefault("SMTP_PORT", "587")); err != nil || s.SMTP.Port < 1 {
+            return fmt.Errorf("invalid SMTP_PORT: %q", os.Getenv("SMTP_PORT"))
+        }
+        if s.SMTP.From == "" {
+            return fmt.Errorf("SMTP_FROM is required when SMTP_HOST is set")
+        }
+    }
+    if s.OfflineAfter, err = strconv.Atoi(envOrDefault("OFFLINE_AFTER", "1")); err != nil || s.OfflineAfter < 1 {
+        return fmt.Errorf("invalid OFFLINE_AFTER: %q", os.Getenv("OFFLINE_AFTER"))
+    }
+    if s.OnlineAfter, err = strconv.Atoi(envOrDefault("ONLINE_AFTER", "1")); err != nil || s.OnlineAfter < 1 {
+        return fmt.Errorf("invalid ONLINE_AFTER: %q", os.Getenv("ONLINE_AFTER"))
+    }
+    s.HistoryDB = os.Getenv("HISTORY_DB")
+    if s.HistoryRetention, err = time.ParseDuration(envOrDefault("HISTORY_RETENTION", "2160h")); err != nil {
+        return fmt.Errorf("invalid HISTORY_RETENTION: %v", err)
+    }
+    switch s.LeaderElection = os.Getenv("LEADER_ELECTION"); s.LeaderElection {
+    case "", "kubernetes":
+    case "sqlite":
+        if s.HistoryDB == "" {
+            return fmt.Errorf("LEADER_ELECTION=sqlite keeps the lease in the history database and needs HISTORY_DB")
+        }
+    default:
+        return fmt.Errorf("invalid LEADER_ELECTION: %q, expected sqlite or kubernetes", s.LeaderElection)
+    }
+    s.LeaderLease = envOrDefault("LEADER_ELECTION_LEASE", "loracheck")
+    if s.LeaderID = os.Getenv("LEADER_ELECTION_ID"); s.LeaderID == "" {
+        if s.LeaderID, err = os.Hostname(); err != nil {
+            return fmt.Errorf("LEADER_ELECTION_ID is not set and the hostname is unknown: %v", err)
+        }
+    }
+    if s.LeaderTTL, err = time.ParseDuration(envOrDefault("LEADER_ELECTION_TTL", "15s")); err != nil || s.LeaderTTL < 3*time.Second {
+        return fmt.Errorf("invalid LEADER_ELECTION_TTL: %q, expected at least 3s", os.Getenv("LEADER_ELECTION_TTL"))
+    }
+    switch mode := envOrDefault("CHECK_MODE", "loop"); mode {
+    case "loop":
+    case "scrape":
+        s.ScrapeMode = true
+    default:
+        return fmt.Errorf("invalid CHECK_MODE: %q, expected loop or scrape", mode)
+    }
+    if s.CheckSpread > 0 && s.ScrapeMode {
+        return fmt.Errorf("CHECK_SPREAD would delay every scrape and needs CHECK_MODE=loop")
+    }
+    if s.ScrapeMaxAge, err = time.ParseDuration(envOrDefault("SCRAPE_MAX_AGE", "30s")); err != nil {
+        return fmt.Errorf("invalid SCRAPE_MAX_AGE: %v", err)
+    }
+    if s.ScrapeTimeout, err = time.ParseDuration(envOrDefault("SCRAPE_TIMEOUT", "10s")); err != nil || s.ScrapeTimeout <= 0 {
+        return fmt.Errorf("invalid SCRAPE_TIMEOUT: %q", os.Getenv("SCRAPE_TIMEOUT"))
+    }
+    s.Push = push.Target{
+        RemoteWriteURL: os.Getenv("REMOTE_WRITE_URL"),
+        PushgatewayURL: os.Getenv("PUSHGATEWAY_URL"),
+        Job:            envOrDefault("PUSHGATEWAY_JOB", push.DefaultJob),
+        Username:       os.Getenv("PUSH_USERNAME"),
+        Password:       os.Getenv("PUSH_PASSWORD"),
+        BearerToken:    os.Getenv("PUSH_BEARER_TOKEN"),
+    }
+    if s.Push.ExternalLabels, err = push.ParseLabels(os.Getenv("PUSH_EXTERNAL_LABELS")); err != nil {
+        return fmt.Errorf("invalid PUSH_EXTERNAL_LABELS: %v", err)
+    }
+    if s.Push.Enabled() && s.ScrapeMode {
+        return fmt.Errorf("REMOTE_WRITE_URL and PUSHGATEWAY_URL push after each cycle and need CHECK_MODE=loop")
+    }
+    if s.Port != "" {
+        host, _, err := net.SplitHostPort(s.ListenAddr)
+        if err != nil {
+            return fmt.Errorf("invalid listen address %q: %v", s.ListenAddr, err)
+        }
+        s.ListenAddr = net.JoinHostPort(host, s.Port)
+    }
+    if s.LogLevel != "" && s.LogLevel != "info" && s.LogLevel != "warn" {
+        return fmt.Errorf("invalid log level %q, expected info or warn", s.LogLevel)
+    }
+    s.TLSCertFile = os.Getenv("TLS_CERT_FILE")
+    s.TLSKeyFile = os.Getenv("TLS_KEY_FILE")
+    if (s.TLSCertFile == "") != (s.TLSKeyFile == "") {
+        return fmt.Errorf("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+    }
+    s.ACMEDomains = splitList(os.Getenv("ACME_DOMAINS"))
+    s.ACMEEmail = os.Getenv("ACME_EMAIL")
+    s.ACMECacheDir = envOrDefault("ACME_CACHE_DIR", "acme-cache")
+    s.ACMEDirectoryURL = os.Getenv("ACME_DIRECTORY_URL")
+    if len(s.ACMEDomains) > 0 && s.TLSCertFile != "" {
+        return fmt.Errorf("ACME_DOMAINS and TLS_CERT_FILE are mutually exclusive")
+    }
+    s.HTTPAuth = api.Auth{
+        Username:     os.Getenv("HTTP_AUTH_USERNAME"),
+        Password:     os.Getenv("HTTP_AUTH_PASSWORD"),
+        BearerTokens: splitList(os.Getenv("HTTP_AUTH_BEARER_TOKENS")),
+    }
+    if (s.HTTPAuth.Username == "") != (s.HTTPAuth.Password == "") {
+        return fmt.Errorf("HTTP_AUTH_USERNAME and HTTP_AUTH_PASSWORD must be set together")
+    }
+    if s.ShutdownTimeout, err = time.ParseDuration(envOrDefault("SHUTDOWN_TIMEOUT", "25s")); err != nil || s.ShutdownTimeout <= 0 {
+        return fmt.Errorf("invalid SHUTDOWN_TIMEOUT: %q", os.Getenv("SHUTDOWN_TIMEOUT"))
+    }
+    if s.SilenceMaxDuration, err = time.ParseDuration(envOrDefault("SILENCE_MAX_DURATION", "168h")); err != nil || s.SilenceMaxDuration <= 0 {
+        return fmt.Errorf("invalid SILENCE_MAX_DURATION: %q", os.Getenv("SILENCE_MAX_DURATION"))
+    }
+    if s.MaxMissedCycles, err = strconv.Atoi(envOrDefault("READYZ_MAX_MISSED_CYCLES", "3")); err != nil || s.MaxMissedCycles < 1 {
+        return fmt.Errorf("invalid READYZ_MAX_MISSED_CYCLES: %q", os.Getenv("READYZ_MAX_MISSED_CYCLES"))
+    }
+    return nil
+}
+
+// checkClient returns the HTTP client shared by the checks
+func (s settings) checkClient() *http.Client {
+    client := &http.Client{Timeout: s.CheckTimeout}
+    if s.CheckProxy != nil {
+        transport := http.DefaultTransport.(*http.Transport).Clone()
+        transport.Proxy = http.ProxyURL(s.CheckProxy)
+        client.Transport = transport
+    }
+    return client
+}
+
+// rateLimiter returns the rate limiter of the checks, nil when no limit is configured
+func (s settings) rateLimiter(m *metrics.Metrics) *checker.RateLimiter {
+    if s.RateLimit == 0 && s.RateLimitPerHost == 0 && len(s.RateLimitHosts) == 0 {
+        return nil
+    }
+    return checker.NewRateLimiter(s.RateLimit, s.RateLimitPerHost, s.RateLimitHosts, m)
+}
+
+// splitList splits a comma separated list, dropping empty entries
+func splitList(s string) []string {
+    var items []string
+    for _, item := range strings.Split(s, ",") {
+        if item = strings.TrimSpace(item); item != "" {
+            items = append(items, item)
+        }
+    }
+    return items
+}
+
+// envOrDefault returns the environment variable or a fallback when it is unset
+func envOrDefault(key, fallback string) string {
+    if v := os.Getenv(key); v != "" {
+        return v
+    }
+    return fallback
+}
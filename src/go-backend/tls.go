@@ -0,0 +1,88 @@
+package main
+
+import (
+    "crypto/tls"
+    "fmt"
+    "os"
+    "sync"
+    "time"
+
+    "golang.org/x/crypto/acme"
+    "golang.org/x/crypto/acme/autocert"
+)
+
+// serverTLS returns the TLS configuration of the HTTP server, or nil to serve plain HTTP.
+// Certificates come from TLS_CERT_FILE and TLS_KEY_FILE, reloaded when they change, or from
+// Let's Encrypt for ACME_DOMAINS using the TLS-ALPN-01 challenge, which needs the server to
+// be reachable on port 443.
+func (s settings) serverTLS() *tls.Config {
+    switch {
+    case len(s.ACMEDomains) > 0:
+        manager := &autocert.Manager{
+            Prompt:     autocert.AcceptTOS,
+            HostPolicy: autocert.HostWhitelist(s.ACMEDomains...),
+            Cache:      autocert.DirCache(s.ACMECacheDir),
+            Email:      s.ACMEEmail,
+        }
+        if s.ACMEDirectoryURL != "" {
+            manager.Client = &acme.Client{DirectoryURL: s.ACMEDirectoryURL}
+        }
+        return manager.TLSConfig()
+    case s.TLSCertFile != "":
+        certificates := &certReloader{certFile: s.TLSCertFile, keyFile: s.TLSKeyFile}
+        return &tls.Config{MinVersion: tls.VersionTLS12, GetCertificate: certificates.GetCertificate}
+    }
+    return nil
+}
+
+// certReloader serves a certificate from files, loading it again whenever either file
+// changes so renewed certificates are picked up without a restart
+type certReloader struct {
+    certFile string
+    keyFile  string
+
+    mu       sync.Mutex
+    cert     *tls.Certificate
+    modified time.Time
+}
+
+// GetCertificate implements tls.Config.GetCertificate
+func (c *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    modified, err := latestModTime(c.certFile, c.keyFile)
+    if err != nil {
+        if c.cert != nil {
+            return c.cert, nil
+        }
+        return nil, err
+    }
+    if c.cert == nil || modified.After(c.modified) {
+        cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+        if err != nil {
+            // A renewal writing the files one at a time can leave a mismatched pair for a moment
+            if c.cert != nil {
+                return c.cert, nil
+            }
+            return nil, fmt.Errorf("loading TLS certificate: %w", err)
+        }
+        c.cert, c.modified = &cert, modified
+    }
+    return c.cert, nil
+}
+
+// latestModTime returns the most recent modification time of the files
+func latestModTime(paths ...string) (time.Time, error) {
+    var latest time.Time
+    for _, path := range paths {
+        info, err := os.Stat(path)
+        if err != nil {
+            return time.Time{}, err
+        }
+        if info.ModTime().After(latest) {
+            latest = info.ModTime()
+        }
+    }
+    return latest, nil
+}